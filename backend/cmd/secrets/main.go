@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/redteamsec/backend/config"
+	"github.com/redteamsec/backend/internal/database"
+	"github.com/redteamsec/backend/internal/secrets"
+	"gorm.io/gorm"
+)
+
+// 凭证密钥的离线维护命令，应在服务停止时执行
+//
+//	secrets generate-key <密钥ID>  生成新的主密钥行
+//	secrets status                 统计各主密钥加密的凭证数
+//	secrets reencrypt              加密明文凭证，并将旧主密钥加密的凭证改为使用当前主密钥
+//
+// 轮换主密钥：生成新密钥并放在密钥列表第一行，保留旧密钥，执行 reencrypt 后即可移除旧密钥
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "generate-key":
+		id := "default"
+		if len(os.Args) > 2 {
+			id = os.Args[2]
+		}
+		line, err := secrets.GenerateKey(id)
+		if err != nil {
+			log.Fatalf("Failed to generate master key: %v", err)
+		}
+		fmt.Println(line)
+	case "status":
+		keyring, db := open()
+		usage, err := secrets.KeyUsage(db)
+		if err != nil {
+			log.Fatalf("Failed to read encrypted secrets: %v", err)
+		}
+		fmt.Printf("Primary master key: %s\n", keyring.PrimaryKeyID())
+		ids := make([]string, 0, len(usage))
+		for id := range usage {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			fmt.Printf("%s: %d\n", id, usage[id])
+		}
+	case "reencrypt":
+		keyring, db := open()
+		encrypted, rewrapped, err := secrets.Reencrypt(db, keyring)
+		if err != nil {
+			log.Fatalf("Failed to re-encrypt secrets: %v", err)
+		}
+		fmt.Printf("Encrypted %d plaintext secrets, re-wrapped %d secrets with master key %s\n", encrypted, rewrapped, keyring.PrimaryKeyID())
+	default:
+		usage()
+	}
+}
+
+// usage 打印用法并退出
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: secrets generate-key <id> | status | reencrypt")
+	os.Exit(2)
+}
+
+// open 按服务相同的配置加载主密钥并打开数据库
+func open() (*secrets.Keyring, *gorm.DB) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	keyring, err := secrets.LoadKeyring(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load master key: %v", err)
+	}
+	db, err := database.InitDB(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	return keyring, db
+}
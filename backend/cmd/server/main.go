@@ -1,13 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/redteamsec/backend/config"
 	"github.com/redteamsec/backend/internal/api"
+	"github.com/redteamsec/backend/internal/auth"
 	"github.com/redteamsec/backend/internal/database"
+	"github.com/redteamsec/backend/internal/playbook"
+	"github.com/redteamsec/backend/internal/secrets"
+	"github.com/redteamsec/backend/internal/session"
 	"github.com/redteamsec/backend/internal/task"
 )
 
@@ -18,12 +29,29 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// 加载访问令牌签名密钥，非开发环境使用默认或过短的 JWT_SECRET 时拒绝启动
+	issuer, err := auth.NewTokenIssuer(cfg)
+	if err != nil {
+		log.Fatalf("Invalid JWT configuration: %v", err)
+	}
+
 	// 初始化数据库
 	db, err := database.InitDB(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	// 加载主密钥，并加密旧版本保存的明文凭证密钥和 TOTP 密钥
+	keyring, err := secrets.LoadKeyring(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load master key: %v", err)
+	}
+	if count, err := secrets.EncryptPlaintext(db, keyring); err != nil {
+		log.Fatalf("Failed to encrypt secrets: %v", err)
+	} else if count > 0 {
+		log.Printf("Encrypted %d plaintext secrets", count)
+	}
+
 	// 初始化 Redis
 	var redisClient *redis.Client
 	redisClient, err = database.InitRedis(cfg)
@@ -31,19 +59,72 @@ func main() {
 		log.Printf("Warning: Failed to initialize Redis: %v, running without Redis", err)
 	}
 
+	// 收到 SIGINT/SIGTERM 时开始优雅退出
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 创建任务队列，Redis 不可用时使用进程内队列
+	queue := task.NewQueue(redisClient, time.Duration(cfg.TaskVisibilityTimeout)*time.Second)
+	if redisClient == nil {
+		// 进程内队列不会持久化，从数据库恢复上次未处理的任务
+		count, err := task.EnqueuePending(ctx, db, queue)
+		if err != nil {
+			log.Printf("Warning: Failed to restore pending tasks: %v", err)
+		} else if count > 0 {
+			log.Printf("Restored %d pending tasks into in-memory queue", count)
+		}
+	}
+
+	// 创建任务进度事件代理
+	broker := task.NewBroker(redisClient)
+
+	// 创建任务限流器，并发名额最长占用单个任务的超时时间加可见性超时时间
+	limiter := task.NewLimiter(redisClient, time.Duration(cfg.TaskTimeout+cfg.TaskVisibilityTimeout)*time.Second)
+
 	// 创建任务处理 worker
-	worker := task.NewWorker(db, redisClient, cfg)
+	worker := task.NewWorker(db, queue, broker, limiter, keyring, cfg)
+
+	// 启动任务处理 worker 池（后台运行）
+	worker.Start(ctx)
+
+	// 启动定时任务调度器
+	task.NewScheduler(db, queue).Start(ctx)
 
-	// 启动任务处理 worker（后台运行）
-	go worker.Start()
+	// 启动剧本执行引擎
+	engine := playbook.NewEngine(db, queue, broker)
+	engine.Start(ctx)
 
 	// 设置路由
-	router := api.SetupRouter(db, redisClient, cfg)
+	sessions := session.NewManager(db, issuer, cfg)
+	router := api.SetupRouter(db, queue, broker, engine, keyring, sessions, cfg)
 
 	// 启动服务器
 	serverAddr := fmt.Sprintf("%s:%d", cfg.ServerHost, cfg.ServerPort)
-	log.Printf("Server starting on %s", serverAddr)
-	if err := router.Run(serverAddr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{
+		Addr:    serverAddr,
+		Handler: router,
+		// 收到退出信号时取消请求上下文，结束 SSE 等长连接
+		BaseContext: func(net.Listener) context.Context { return ctx },
 	}
+	go func() {
+		log.Printf("Server starting on %s", serverAddr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Printf("Shutting down...")
+
+	// 先停止接收新请求，再等待进行中的任务
+	shutdownTimeout := time.Duration(cfg.ShutdownTimeout) * time.Second
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Warning: Failed to shut down server gracefully: %v", err)
+	}
+
+	worker.Stop(shutdownTimeout)
+	log.Printf("Server stopped")
 }
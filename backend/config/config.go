@@ -25,14 +25,40 @@ type Config struct {
 	RedisAddr string
 
 	// JWT 配置
-	JWTSecret string
-	JWTExpiry int
+	JWTSecret         string // HS256 签名密钥，非开发环境至少 32 字节且不能为默认值
+	JWTAlgorithm      string // HS256、RS256 或 EdDSA
+	JWTPrivateKeyFile string // RS256/EdDSA 的 PEM 格式私钥文件，公钥通过 JWKS 公开
+	JWTKeyID          string // 令牌头中的 kid，为空时使用公钥摘要
+	JWTAccessTTL      int    // 访问令牌有效期（分钟）
+	JWTRefreshTTL     int    // 刷新令牌有效期（小时）
 
 	// 下载配置
 	DownloadPath string
 
+	// 主密钥配置，用于加密保存的云凭证密钥，按顺序取第一个已配置的来源
+	MasterKey        string // 主密钥内容，每行一个 <密钥ID>:<base64密钥>，第一行为当前主密钥
+	MasterKeyFile    string // 保存主密钥的文件路径，格式同 MasterKey
+	MasterKeyKeyring string // Linux 内核密钥环中保存主密钥的 user 类型密钥描述
+
 	// 环境配置
 	Environment string
+
+	// 任务处理配置
+	WorkerCount     int // 并发 worker 数量
+	TaskTimeout     int // 单个任务超时时间（秒）
+	TaskMaxRetries  int // 临时性错误的最大重试次数
+	ShutdownTimeout int // 优雅退出时等待进行中任务的时间（秒）
+	TaskWaitTimeout int // wait=true 的云操作请求最多等待任务结束的时间（秒）
+
+	// 可靠队列配置
+	TaskVisibilityTimeout int // 任务取出后未续期超过该时间（秒）即视为 worker 已崩溃
+	TaskMaxDeliveries     int // 任务最多被取出执行的次数，超过后转入死信队列
+
+	// 任务限流配置，0 表示不限制
+	TaskCredentialConcurrency int // 同一凭证同时执行的任务数上限
+	TaskCredentialRate        int // 同一凭证每分钟开始执行的任务数上限
+	TaskProviderConcurrency   int // 同一云平台同时执行的任务数上限
+	TaskProviderRate          int // 同一云平台每分钟开始执行的任务数上限
 }
 
 // LoadConfig 加载配置
@@ -55,11 +81,24 @@ func LoadConfig() (*Config, error) {
 		dbPort = 5432
 	}
 
-	// 解析 JWT 过期时间
-	jwtExpiry, err := strconv.Atoi(getEnv("JWT_EXPIRY", "24"))
-	if err != nil {
-		jwtExpiry = 24
-	}
+	// 解析 JWT 有效期
+	jwtAccessTTL := getEnvInt("JWT_ACCESS_TTL", 15)
+	jwtRefreshTTL := getEnvInt("JWT_REFRESH_TTL", 168)
+
+	// 解析任务处理配置
+	workerCount := getEnvInt("WORKER_COUNT", 4)
+	taskTimeout := getEnvInt("TASK_TIMEOUT", 600)
+	taskMaxRetries := getEnvInt("TASK_MAX_RETRIES", 3)
+	shutdownTimeout := getEnvInt("SHUTDOWN_TIMEOUT", 30)
+	taskWaitTimeout := getEnvInt("TASK_WAIT_TIMEOUT", 60)
+	taskVisibilityTimeout := getEnvInt("TASK_VISIBILITY_TIMEOUT", 120)
+	taskMaxDeliveries := getEnvInt("TASK_MAX_DELIVERIES", 3)
+
+	// 解析任务限流配置
+	taskCredentialConcurrency := getEnvInt("TASK_CREDENTIAL_CONCURRENCY", 2)
+	taskCredentialRate := getEnvInt("TASK_CREDENTIAL_RATE", 30)
+	taskProviderConcurrency := getEnvInt("TASK_PROVIDER_CONCURRENCY", 0)
+	taskProviderRate := getEnvInt("TASK_PROVIDER_RATE", 0)
 
 	// 获取用户主目录
 	homeDir, err := os.UserHomeDir()
@@ -86,14 +125,40 @@ func LoadConfig() (*Config, error) {
 		RedisAddr: getEnv("REDIS_ADDR", "localhost:6379"),
 
 		// JWT 配置
-		JWTSecret: getEnv("JWT_SECRET", "your-secret-key"),
-		JWTExpiry: jwtExpiry,
+		JWTSecret:         getEnv("JWT_SECRET", "your-secret-key"),
+		JWTAlgorithm:      getEnv("JWT_ALGORITHM", "HS256"),
+		JWTPrivateKeyFile: os.Getenv("JWT_PRIVATE_KEY_FILE"),
+		JWTKeyID:          os.Getenv("JWT_KEY_ID"),
+		JWTAccessTTL:      jwtAccessTTL,
+		JWTRefreshTTL:     jwtRefreshTTL,
 
 		// 下载配置
 		DownloadPath: getEnv("DOWNLOAD_PATH", defaultDownloadPath),
 
+		// 主密钥配置
+		MasterKey:        os.Getenv("MASTER_KEY"),
+		MasterKeyFile:    os.Getenv("MASTER_KEY_FILE"),
+		MasterKeyKeyring: os.Getenv("MASTER_KEY_KEYRING"),
+
 		// 环境配置
 		Environment: getEnv("ENVIRONMENT", "development"),
+
+		// 任务处理配置
+		WorkerCount:     workerCount,
+		TaskTimeout:     taskTimeout,
+		TaskMaxRetries:  taskMaxRetries,
+		ShutdownTimeout: shutdownTimeout,
+		TaskWaitTimeout: taskWaitTimeout,
+
+		// 可靠队列配置
+		TaskVisibilityTimeout: taskVisibilityTimeout,
+		TaskMaxDeliveries:     taskMaxDeliveries,
+
+		// 任务限流配置
+		TaskCredentialConcurrency: taskCredentialConcurrency,
+		TaskCredentialRate:        taskCredentialRate,
+		TaskProviderConcurrency:   taskProviderConcurrency,
+		TaskProviderRate:          taskProviderRate,
 	}, nil
 }
 
@@ -105,3 +170,12 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getEnvInt 获取整数类型的环境变量，不存在或格式错误时返回默认值
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnv(key, strconv.Itoa(defaultValue)))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -1,26 +1,37 @@
 package api
 
 import (
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
-	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
 	"github.com/redteamsec/backend/config"
+	"github.com/redteamsec/backend/internal/audit"
 	"github.com/redteamsec/backend/internal/auth"
-	"github.com/redteamsec/backend/internal/cloud"
+	"github.com/redteamsec/backend/internal/cloud/capability"
+	"github.com/redteamsec/backend/internal/cloud/plan"
+	"github.com/redteamsec/backend/internal/cloud/progress"
+	"github.com/redteamsec/backend/internal/cloud/scope"
 	"github.com/redteamsec/backend/internal/database"
+	"github.com/redteamsec/backend/internal/mfa"
+	"github.com/redteamsec/backend/internal/playbook"
+	"github.com/redteamsec/backend/internal/project"
+	"github.com/redteamsec/backend/internal/secrets"
+	"github.com/redteamsec/backend/internal/session"
+	"github.com/redteamsec/backend/internal/task"
 	"gorm.io/gorm"
 )
 
 // SetupRouter 设置路由
-func SetupRouter(db *gorm.DB, redisClient *redis.Client, cfg *config.Config) *gin.Engine {
+func SetupRouter(db *gorm.DB, queue task.Queue, broker task.Broker, engine *playbook.Engine, keyring *secrets.Keyring, sessions *session.Manager, cfg *config.Config) *gin.Engine {
 	// 创建路由
 	router := gin.Default()
 
@@ -39,91 +50,295 @@ func SetupRouter(db *gorm.DB, redisClient *redis.Client, cfg *config.Config) *gi
 		c.Next()
 	})
 
+	// 验证访问令牌的公钥（RS256/EdDSA）
+	router.GET("/.well-known/jwks.json", jwksHandler(sessions))
+
 	// 创建API组
 	api := router.Group("/api")
 
 	// 公开路由
-	api.POST("/auth/register", registerHandler(db, cfg))
-	api.POST("/auth/login", loginHandler(db, cfg))
+	api.POST("/auth/register", registerHandler(db, sessions))
+	api.POST("/auth/login", loginHandler(db, sessions, keyring))
+	api.POST("/auth/refresh", refreshTokenHandler(db, sessions))
 
 	// 需要认证的路由
 	authGroup := api.Group("/")
-	authGroup.Use(authMiddleware(cfg))
+	authGroup.Use(authMiddleware(sessions))
 	{
+		authGroup.POST("/auth/logout", logoutHandler(sessions))
 		// 用户相关
 		authGroup.GET("/user/profile", getUserProfileHandler(db))
-		authGroup.PUT("/user/profile", updateUserProfileHandler(db))
+		authGroup.PUT("/user/profile", updateUserProfileHandler(db, sessions))
+		authGroup.GET("/user/mfa", getMFAStatusHandler(db))
+		authGroup.POST("/user/mfa/enroll", enrollMFAHandler(db, keyring))
+		authGroup.POST("/user/mfa/verify", confirmMFAHandler(db, keyring, sessions))
+		authGroup.POST("/user/mfa/disable", disableMFAHandler(db, keyring))
+		authGroup.POST("/user/mfa/recovery-codes", regenerateRecoveryCodesHandler(db, keyring))
+
+		// 用户管理
+		// 审计日志
+		authGroup.GET("/audit", requirePermission(auth.PermAuditRead), listAuditLogsHandler(db))
+		authGroup.GET("/audit/verify", requirePermission(auth.PermAuditRead), verifyAuditLogHandler(db))
+
+		authGroup.GET("/users", requirePermission(auth.PermUsersManage), listUsersHandler(db))
+		authGroup.PUT("/users/:id/role", requirePermission(auth.PermUsersManage), updateUserRoleHandler(db))
+		authGroup.DELETE("/users/:id/mfa", requirePermission(auth.PermUsersManage), resetUserMFAHandler(db, sessions))
+
+		// 安全设置
+		authGroup.GET("/settings/security", requirePermission(auth.PermUsersManage), getSecuritySettingsHandler(db))
+		authGroup.PUT("/settings/security", requirePermission(auth.PermUsersManage), updateSecuritySettingsHandler(db))
+
+		// 项目管理
+		authGroup.GET("/projects", requirePermission(auth.PermProjectsRead), listProjectsHandler(db))
+		authGroup.POST("/projects", requirePermission(auth.PermProjectsWrite), createProjectHandler(db))
+		authGroup.GET("/projects/:id", requirePermission(auth.PermProjectsRead), getProjectHandler(db))
+		authGroup.PUT("/projects/:id", requirePermission(auth.PermProjectsWrite), updateProjectHandler(db))
+		authGroup.DELETE("/projects/:id", requirePermission(auth.PermProjectsWrite), deleteProjectHandler(db))
+		authGroup.GET("/projects/:id/scope", requirePermission(auth.PermProjectsRead), getProjectScopeHandler(db))
+		authGroup.PUT("/projects/:id/scope", requirePermission(auth.PermProjectsWrite), updateProjectScopeHandler(db))
+		authGroup.GET("/projects/:id/members", requirePermission(auth.PermProjectsRead), listProjectMembersHandler(db))
+		authGroup.POST("/projects/:id/members", requirePermission(auth.PermProjectsWrite), addProjectMemberHandler(db))
+		authGroup.PUT("/projects/:id/members/:userId", requirePermission(auth.PermProjectsWrite), updateProjectMemberHandler(db))
+		authGroup.DELETE("/projects/:id/members/:userId", requirePermission(auth.PermProjectsWrite), removeProjectMemberHandler(db))
+
+		// 项目（评估）中平台创建的资源及清理
+		authGroup.GET("/engagements/:id/artifacts", requirePermission(auth.PermCloudRead), listArtifactsHandler(db))
+		authGroup.POST("/engagements/:id/cleanup", requirePermission(auth.PermCloudOperate), cleanupEngagementHandler(db, queue))
 
 		// 云平台凭证管理
-		authGroup.GET("/credentials", listCredentialsHandler(db))
-		authGroup.POST("/credentials", createCredentialHandler(db))
-		authGroup.GET("/credentials/:id", getCredentialHandler(db))
-		authGroup.PUT("/credentials/:id", updateCredentialHandler(db))
-		authGroup.DELETE("/credentials/:id", deleteCredentialHandler(db))
+		authGroup.GET("/credentials", requirePermission(auth.PermCredentialsRead), listCredentialsHandler(db))
+		authGroup.POST("/credentials", requirePermission(auth.PermCredentialsWrite), createCredentialHandler(db, keyring))
+		authGroup.GET("/credentials/:id", requirePermission(auth.PermCredentialsRead), getCredentialHandler(db))
+		authGroup.PUT("/credentials/:id", requirePermission(auth.PermCredentialsWrite), updateCredentialHandler(db, keyring))
+		authGroup.DELETE("/credentials/:id", requirePermission(auth.PermCredentialsWrite), deleteCredentialHandler(db))
 
 		// 任务管理
-		authGroup.GET("/tasks", listTasksHandler(db))
-		authGroup.POST("/tasks", createTaskHandler(db, redisClient))
-		authGroup.GET("/tasks/:id", getTaskHandler(db))
-		authGroup.GET("/tasks/:id/results", getTaskResultsHandler(db))
-		authGroup.DELETE("/tasks/:id", deleteTaskHandler(db))
-		authGroup.DELETE("/tasks", deleteAllTasksHandler(db))
+		authGroup.GET("/tasks", requirePermission(auth.PermTasksRead), listTasksHandler(db))
+		authGroup.POST("/tasks", requirePermission(auth.PermTasksWrite), createTaskHandler(db, queue))
+		authGroup.GET("/tasks/:id", requirePermission(auth.PermTasksRead), getTaskHandler(db))
+		authGroup.GET("/tasks/:id/results", requirePermission(auth.PermTasksRead), getTaskResultsHandler(db, keyring))
+		authGroup.GET("/tasks/:id/events", requirePermission(auth.PermTasksRead), getTaskEventsHandler(db, broker))
+		authGroup.POST("/tasks/:id/cancel", requirePermission(auth.PermTasksWrite), cancelTaskHandler(db, broker))
+		authGroup.POST("/tasks/:id/approve-plan", requirePermission(auth.PermCloudOperate), approvePlanHandler(db))
+		authGroup.DELETE("/tasks/:id", requirePermission(auth.PermTasksDelete), deleteTaskHandler(db))
+		authGroup.DELETE("/tasks", requirePermission(auth.PermTasksDelete), deleteAllTasksHandler(db))
+
+		// 定时任务
+		authGroup.GET("/schedules", requirePermission(auth.PermSchedulesRead), listSchedulesHandler(db))
+		authGroup.POST("/schedules", requirePermission(auth.PermSchedulesWrite), createScheduleHandler(db))
+		authGroup.POST("/schedules/:id/pause", requirePermission(auth.PermSchedulesWrite), setScheduleStatusHandler(db, "paused"))
+		authGroup.POST("/schedules/:id/resume", requirePermission(auth.PermSchedulesWrite), setScheduleStatusHandler(db, "active"))
+		authGroup.DELETE("/schedules/:id", requirePermission(auth.PermSchedulesWrite), deleteScheduleHandler(db))
+
+		// 剧本
+		authGroup.GET("/playbook-templates", requirePermission(auth.PermPlaybooksRead), listPlaybookTemplatesHandler())
+		authGroup.GET("/playbooks", requirePermission(auth.PermPlaybooksRead), listPlaybooksHandler(db))
+		authGroup.POST("/playbooks", requirePermission(auth.PermPlaybooksWrite), createPlaybookHandler(db))
+		authGroup.GET("/playbooks/:id", requirePermission(auth.PermPlaybooksRead), getPlaybookHandler(db))
+		authGroup.DELETE("/playbooks/:id", requirePermission(auth.PermPlaybooksWrite), deletePlaybookHandler(db))
+		authGroup.POST("/playbooks/:id/runs", requirePermission(auth.PermPlaybooksWrite), runPlaybookHandler(db, engine))
+		authGroup.GET("/playbook-runs", requirePermission(auth.PermPlaybooksRead), listPlaybookRunsHandler(db))
+		authGroup.GET("/playbook-runs/:id", requirePermission(auth.PermPlaybooksRead), getPlaybookRunHandler(db, engine))
+		authGroup.POST("/playbook-runs/:id/steps/:stepId/approve", requirePermission(auth.PermPlaybooksApprove), reviewPlaybookStepHandler(db, engine, true))
+		authGroup.POST("/playbook-runs/:id/steps/:stepId/reject", requirePermission(auth.PermPlaybooksApprove), reviewPlaybookStepHandler(db, engine, false))
+		authGroup.POST("/playbook-runs/:id/cancel", requirePermission(auth.PermPlaybooksWrite), cancelPlaybookRunHandler(db, engine))
 
 		// 云平台操作
-		authGroup.POST("/cloud/enumerate", enumerateResourcesHandler(db))
-		authGroup.POST("/cloud/escalate", escalatePrivilegesHandler(db))
-		authGroup.POST("/cloud/operate", operateResourceHandler(db))
-		authGroup.POST("/cloud/takeover", takeoverCloudHandler(db))
-		authGroup.POST("/cloud/userinfo", getUserInfoHandler(db))
-		authGroup.POST("/cloud/resources", getResourcesFromDatabaseHandler(db))
-		authGroup.POST("/cloud/permissions", getPermissionsFromDatabaseHandler(db))
-		authGroup.POST("/cloud/download", downloadFileHandler(db))
+		authGroup.POST("/cloud/enumerate", requirePermission(auth.PermCloudScan), enumerateResourcesHandler(db, queue, broker, cfg))
+		authGroup.POST("/cloud/escalate", requirePermission(auth.PermCloudScan), escalatePrivilegesHandler(db, queue, broker, cfg))
+		authGroup.POST("/cloud/operate", requirePermission(auth.PermCloudOperate), operateResourceHandler(db, queue, broker, keyring, cfg))
+		authGroup.POST("/cloud/takeover", requirePermission(auth.PermCloudOperate), takeoverCloudHandler(db, queue, broker, cfg))
+		authGroup.POST("/cloud/userinfo", requirePermission(auth.PermCloudScan), getUserInfoHandler(db, queue, broker, cfg))
+		authGroup.POST("/cloud/resources", requirePermission(auth.PermCloudRead), getResourcesFromDatabaseHandler(db))
+		authGroup.POST("/cloud/permissions", requirePermission(auth.PermCloudRead), getPermissionsFromDatabaseHandler(db))
+		authGroup.POST("/cloud/download", requirePermission(auth.PermCloudOperate), downloadFileHandler(db, queue, broker, cfg))
+
+		// 联邦令牌管理
+		authGroup.GET("/federation-tokens", requirePermission(auth.PermFederationRead), listFederationTokensHandler(db))
+		authGroup.POST("/federation-tokens/:id/revoke", requirePermission(auth.PermFederationRevoke), revokeFederationTokenHandler(db, queue, broker, cfg))
 
 		// 结果分析
-		authGroup.GET("/analysis/task-stats", getTaskStatsHandler(db))
-		authGroup.GET("/analysis/vulnerability-stats", getVulnerabilityStatsHandler(db))
-		authGroup.GET("/analysis/resource-stats", getResourceStatsHandler(db))
-		authGroup.GET("/analysis/recent-findings", getRecentFindingsHandler(db))
+		authGroup.GET("/analysis/task-stats", requirePermission(auth.PermAnalysisRead), getTaskStatsHandler(db))
+		authGroup.GET("/analysis/vulnerability-stats", requirePermission(auth.PermAnalysisRead), getVulnerabilityStatsHandler(db))
+		authGroup.GET("/analysis/resource-stats", requirePermission(auth.PermAnalysisRead), getResourceStatsHandler(db))
+		authGroup.GET("/analysis/recent-findings", requirePermission(auth.PermAnalysisRead), getRecentFindingsHandler(db))
+		authGroup.GET("/analysis/capabilities", requirePermission(auth.PermAnalysisRead), getCapabilityComparisonHandler(db))
 	}
 
 	return router
 }
 
+// mfaSetupPaths 尚未绑定 MFA 的受限令牌可以访问的接口
+var mfaSetupPaths = map[string]bool{
+	"/api/user/profile":    true,
+	"/api/user/mfa":        true,
+	"/api/user/mfa/enroll": true,
+	"/api/user/mfa/verify": true,
+	"/api/auth/logout":     true,
+}
+
 // 认证中间件
-func authMiddleware(cfg *config.Config) gin.HandlerFunc {
+func authMiddleware(sessions *session.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 从请求头获取token
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
+		// 从请求头获取 Bearer 令牌
+		header := c.GetHeader("Authorization")
+		if header == "" {
 			c.JSON(401, gin.H{"error": "Authorization header required"})
 			c.Abort()
 			return
 		}
+		if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+			c.JSON(401, gin.H{"error": "Authorization header must use the Bearer scheme"})
+			c.Abort()
+			return
+		}
 
-		// 验证token
-		claims, err := auth.ValidateToken(tokenString, cfg)
+		// 验证令牌，已注销或退出所有会话前签发的令牌均被拒绝
+		claims, user, err := sessions.Authenticate(strings.TrimSpace(header[7:]))
 		if err != nil {
+			if !errors.Is(err, session.ErrInvalidToken) {
+				fmt.Printf("Error authenticating request: %v\n", err)
+			}
 			c.JSON(401, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
+		// 需要修改密码的用户只能查看和修改个人资料
+		if claims.PasswordChangeRequired && c.FullPath() != "/api/user/profile" && c.FullPath() != "/api/auth/logout" {
+			c.JSON(403, gin.H{"error": "Password change required", "code": "password_change_required"})
+			c.Abort()
+			return
+		}
+
+		// 角色要求启用 MFA 但尚未绑定的用户只能绑定验证器
+		if claims.MFASetupRequired && !mfaSetupPaths[c.FullPath()] {
+			c.JSON(403, gin.H{"error": "MFA enrollment required", "code": "mfa_setup_required"})
+			c.Abort()
+			return
+		}
+
 		// 将用户信息存储到上下文
 		c.Set("userID", claims.UserID)
 		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
+		c.Set("role", user.Role)
+		c.Set("claims", claims)
+
+		c.Next()
+	}
+}
 
+// requirePermission 权限检查中间件，当前用户的角色没有指定权限时返回 403
+func requirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.HasPermission(c.GetString("role"), permission) {
+			c.JSON(403, gin.H{"error": "Permission denied", "permission": permission})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
+// authorizeProject 检查当前用户在项目中是否拥有指定权限，失败时写入响应并返回 false
+// 不是项目成员时返回 404，避免泄露其他项目中的资源是否存在
+func authorizeProject(c *gin.Context, db *gorm.DB, userID, projectID uint, permission, notFound string) bool {
+	role, err := project.Access(db, userID, c.GetString("role"), projectID)
+	if errors.Is(err, project.ErrNoAccess) {
+		c.JSON(404, gin.H{"error": notFound})
+		return false
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to check project access"})
+		return false
+	}
+	if !project.Allows(role, permission) {
+		c.JSON(403, gin.H{"error": "Permission denied", "permission": permission})
+		return false
+	}
+	return true
+}
+
+// visibleProjects 返回当前用户可以访问的项目ID，请求指定 projectId 时只返回该项目
+func visibleProjects(c *gin.Context, db *gorm.DB, userID uint) ([]uint, bool) {
+	ids, err := project.IDs(db, userID, c.GetString("role"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to fetch projects"})
+		return nil, false
+	}
+	if filter := c.Query("projectId"); filter != "" {
+		for _, id := range ids {
+			if fmt.Sprint(id) == filter {
+				return []uint{id}, true
+			}
+		}
+		c.JSON(404, gin.H{"error": "Project not found"})
+		return nil, false
+	}
+	return ids, true
+}
+
+// targetProject 返回新建资源所属的项目：请求指定了 projectId 时检查权限，否则使用用户的个人项目
+func targetProject(c *gin.Context, db *gorm.DB, userID, projectID uint, permission string) (uint, bool) {
+	if projectID != 0 {
+		return projectID, authorizeProject(c, db, userID, projectID, permission, "Project not found")
+	}
+	var user database.User
+	if result := db.First(&user, userID); result.Error != nil {
+		c.JSON(404, gin.H{"error": "User not found"})
+		return 0, false
+	}
+	id, err := project.Default(db, &user)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to load personal project"})
+		return 0, false
+	}
+	return id, true
+}
+
+// loadCredential 加载凭证并检查当前用户在凭证所属项目中是否拥有指定权限
+func loadCredential(c *gin.Context, db *gorm.DB, userID uint, id interface{}, permission string) (*database.CloudCredential, bool) {
+	var credential database.CloudCredential
+	if result := db.Where("id = ?", id).First(&credential); result.Error != nil {
+		c.JSON(404, gin.H{"error": "Credential not found"})
+		return nil, false
+	}
+	if !authorizeProject(c, db, userID, credential.ProjectID, permission, "Credential not found") {
+		return nil, false
+	}
+	return &credential, true
+}
+
+// loadTask 加载任务并检查当前用户在任务所属项目中是否拥有指定权限
+func loadTask(c *gin.Context, db *gorm.DB, userID uint, id interface{}, permission string) (*database.Task, bool) {
+	var t database.Task
+	if result := db.Where("id = ?", id).First(&t); result.Error != nil {
+		c.JSON(404, gin.H{"error": "Task not found"})
+		return nil, false
+	}
+	if !authorizeProject(c, db, userID, t.ProjectID, permission, "Task not found") {
+		return nil, false
+	}
+	return &t, true
+}
+
+// checkTaskPermission 检查当前用户能否创建指定类型的任务，没有权限时写入 403 响应并返回 false
+func checkTaskPermission(c *gin.Context, taskType string) bool {
+	permission := auth.TaskPermission(taskType)
+	if !auth.HasPermission(c.GetString("role"), permission) {
+		c.JSON(403, gin.H{"error": "Permission denied", "permission": permission})
+		return false
+	}
+	return true
+}
+
 // 路由处理函数
-func registerHandler(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
+func registerHandler(db *gorm.DB, sessions *session.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input struct {
 			Username string `json:"username" binding:"required"`
 			Email    string `json:"email" binding:"required,email"`
-			Password string `json:"password" binding:"required,min=6"`
+			Password string `json:"password" binding:"required"`
 		}
 
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -131,6 +346,11 @@ func registerHandler(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		if err := auth.ValidatePassword(input.Password, input.Username); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
 		// 检查用户名是否已存在
 		var existingUser database.User
 		if result := db.Where("username = ?", input.Username).First(&existingUser); result.Error == nil {
@@ -144,21 +364,35 @@ func registerHandler(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		hash, err := auth.HashPassword(input.Password)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to hash password"})
+			return
+		}
+
 		// 创建新用户
 		user := database.User{
 			Username: input.Username,
 			Email:    input.Email,
-			Password: input.Password, // 实际应用中应该加密存储
-			Role:     "user",
+			Password: hash,
+			Role:     auth.RoleViewer,
 		}
 
-		if result := db.Create(&user); result.Error != nil {
+		// 新用户同时获得一个个人项目
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			_, err := database.CreatePersonalProject(tx, &user)
+			return err
+		})
+		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to create user"})
 			return
 		}
 
-		// 生成JWT令牌
-		token, err := auth.GenerateToken(user.ID, user.Username, user.Role, cfg)
+		// 签发访问令牌和刷新令牌
+		tokens, err := sessions.Issue(&user)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to generate token"})
 			return
@@ -167,21 +401,26 @@ func registerHandler(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 		c.JSON(201, gin.H{
 			"message": "User created successfully",
 			"user": gin.H{
-				"id":       user.ID,
-				"username": user.Username,
-				"email":    user.Email,
-				"role":     user.Role,
+				"id":               user.ID,
+				"username":         user.Username,
+				"email":            user.Email,
+				"role":             user.Role,
+				"mfaSetupRequired": mfa.SetupRequired(db, &user),
+				"permissions":      auth.Permissions(user.Role),
 			},
-			"token": token,
+			"token":         tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
+			"expires_in":    tokens.ExpiresIn,
 		})
 	}
 }
 
-func loginHandler(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
+func loginHandler(db *gorm.DB, sessions *session.Manager, keyring *secrets.Keyring) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input struct {
 			Username string `json:"username" binding:"required"`
 			Password string `json:"password" binding:"required"`
+			OTP      string `json:"otp"` // TOTP 验证码或恢复码
 		}
 
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -196,14 +435,35 @@ func loginHandler(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		// 验证密码（实际应用中应该使用加密验证）
-		if user.Password != input.Password {
+		// 验证密码
+		if !auth.CheckPassword(user.Password, input.Password) {
 			c.JSON(401, gin.H{"error": "Invalid username or password"})
 			return
 		}
 
-		// 生成JWT令牌
-		token, err := auth.GenerateToken(user.ID, user.Username, user.Role, cfg)
+		// 已启用 MFA 的用户需要同时提交验证码或恢复码
+		if user.MFAEnabled {
+			if input.OTP == "" {
+				c.JSON(401, gin.H{"error": "MFA code required", "code": "mfa_required"})
+				return
+			}
+			if err := mfa.Verify(db, keyring, &user, input.OTP); err != nil {
+				if errors.Is(err, mfa.ErrInvalidCode) {
+					c.JSON(401, gin.H{"error": "Invalid MFA code", "code": "mfa_invalid"})
+					return
+				}
+				if errors.Is(err, mfa.ErrLocked) {
+					c.JSON(429, gin.H{"error": "Too many failed MFA attempts, try again later", "code": "mfa_locked"})
+					return
+				}
+				fmt.Printf("Error verifying MFA code: %v\n", err)
+				c.JSON(500, gin.H{"error": "Failed to verify MFA code"})
+				return
+			}
+		}
+
+		// 签发访问令牌和刷新令牌
+		tokens, err := sessions.Issue(&user)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to generate token"})
 			return
@@ -212,16 +472,93 @@ func loginHandler(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 		c.JSON(200, gin.H{
 			"message": "Login successful",
 			"user": gin.H{
-				"id":       user.ID,
-				"username": user.Username,
-				"email":    user.Email,
-				"role":     user.Role,
+				"id":                 user.ID,
+				"username":           user.Username,
+				"email":              user.Email,
+				"role":               user.Role,
+				"mustChangePassword": user.MustChangePassword,
+				"mfaEnabled":         user.MFAEnabled,
+				"mfaSetupRequired":   mfa.SetupRequired(db, &user),
+				"permissions":        auth.Permissions(user.Role),
 			},
-			"token": token,
+			"token":         tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
+			"expires_in":    tokens.ExpiresIn,
+		})
+	}
+}
+
+// refreshTokenHandler 用刷新令牌换取新的访问令牌，刷新令牌随之轮换
+func refreshTokenHandler(db *gorm.DB, sessions *session.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			RefreshToken string `json:"refresh_token" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		tokens, user, err := sessions.Refresh(input.RefreshToken)
+		if err != nil {
+			if errors.Is(err, session.ErrInvalidToken) || errors.Is(err, session.ErrRefreshReused) {
+				c.JSON(401, gin.H{"error": err.Error()})
+				return
+			}
+			fmt.Printf("Error refreshing token: %v\n", err)
+			c.JSON(500, gin.H{"error": "Failed to refresh token"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"message":            "Token refreshed",
+			"mustChangePassword": user.MustChangePassword,
+			"mfaSetupRequired":   mfa.SetupRequired(db, user),
+			"token":              tokens.AccessToken,
+			"refresh_token":      tokens.RefreshToken,
+			"expires_in":         tokens.ExpiresIn,
 		})
 	}
 }
 
+// logoutHandler 注销当前会话，all 为 true 时退出该用户的所有会话
+func logoutHandler(sessions *session.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			RefreshToken string `json:"refresh_token"`
+			All          bool   `json:"all"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		claims := c.MustGet("claims").(*auth.Claims)
+		var err error
+		if input.All {
+			err = sessions.RevokeAll(claims.UserID)
+		} else {
+			err = sessions.Logout(claims, input.RefreshToken)
+		}
+		if err != nil {
+			fmt.Printf("Error logging out: %v\n", err)
+			c.JSON(500, gin.H{"error": "Failed to log out"})
+			return
+		}
+
+		c.JSON(200, gin.H{"message": "Logged out"})
+	}
+}
+
+// jwksHandler 公开验证访问令牌的公钥，使用 HS256 时密钥集合为空
+func jwksHandler(sessions *session.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, sessions.Issuer().JWKS())
+	}
+}
+
 func getUserProfileHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
@@ -237,15 +574,19 @@ func getUserProfileHandler(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		c.JSON(200, gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
-			"role":     user.Role,
+			"id":                 user.ID,
+			"username":           user.Username,
+			"email":              user.Email,
+			"role":               user.Role,
+			"mustChangePassword": user.MustChangePassword,
+			"mfaEnabled":         user.MFAEnabled,
+			"mfaSetupRequired":   mfa.SetupRequired(db, &user),
+			"permissions":        auth.Permissions(user.Role),
 		})
 	}
 }
 
-func updateUserProfileHandler(db *gorm.DB) gin.HandlerFunc {
+func updateUserProfileHandler(db *gorm.DB, sessions *session.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -254,9 +595,10 @@ func updateUserProfileHandler(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		var input struct {
-			Username string `json:"username"`
-			Email    string `json:"email"`
-			Password string `json:"password"`
+			Username        string `json:"username"`
+			Email           string `json:"email"`
+			Password        string `json:"password"`
+			CurrentPassword string `json:"currentPassword"`
 		}
 
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -277,8 +619,32 @@ func updateUserProfileHandler(db *gorm.DB) gin.HandlerFunc {
 		if input.Email != "" {
 			user.Email = input.Email
 		}
+		passwordChanged := false
 		if input.Password != "" {
-			user.Password = input.Password // 实际应用中应该加密存储
+			// 修改密码需要验证当前密码
+			if !auth.CheckPassword(user.Password, input.CurrentPassword) {
+				c.JSON(400, gin.H{"error": "Current password is incorrect"})
+				return
+			}
+			if input.Password == input.CurrentPassword {
+				c.JSON(400, gin.H{"error": "New password must differ from the current password"})
+				return
+			}
+			if err := auth.ValidatePassword(input.Password, user.Username); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			hash, err := auth.HashPassword(input.Password)
+			if err != nil {
+				c.JSON(500, gin.H{"error": "Failed to hash password"})
+				return
+			}
+			user.Password = hash
+			user.MustChangePassword = false
+			passwordChanged = true
+		} else if user.MustChangePassword {
+			c.JSON(403, gin.H{"error": "Password change required", "code": "password_change_required"})
+			return
 		}
 
 		if result := db.Save(&user); result.Error != nil {
@@ -286,150 +652,252 @@ func updateUserProfileHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(200, gin.H{
+		response := gin.H{
 			"message": "User updated successfully",
 			"user": gin.H{
-				"id":       user.ID,
-				"username": user.Username,
-				"email":    user.Email,
-				"role":     user.Role,
+				"id":                 user.ID,
+				"username":           user.Username,
+				"email":              user.Email,
+				"role":               user.Role,
+				"mustChangePassword": user.MustChangePassword,
+				"mfaSetupRequired":   mfa.SetupRequired(db, &user),
 			},
-		})
+		}
+
+		// 修改密码后吊销此前签发的全部令牌，并签发新令牌替换当前会话（包括登录时签发的受限令牌）
+		if passwordChanged {
+			if err := sessions.RevokeAll(user.ID); err != nil {
+				c.JSON(500, gin.H{"error": "Failed to revoke existing sessions"})
+				return
+			}
+			tokens, err := sessions.Issue(&user)
+			if err != nil {
+				c.JSON(500, gin.H{"error": "Failed to generate token"})
+				return
+			}
+			response["token"] = tokens.AccessToken
+			response["refresh_token"] = tokens.RefreshToken
+			response["expires_in"] = tokens.ExpiresIn
+		}
+
+		c.JSON(200, response)
 	}
 }
 
-func listCredentialsHandler(db *gorm.DB) gin.HandlerFunc {
+// getMFAStatusHandler 查看当前用户的 MFA 状态
+func getMFAStatusHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, exists := c.Get("userID")
-		if !exists {
-			c.JSON(401, gin.H{"error": "User not authenticated"})
+		var user database.User
+		if result := db.First(&user, c.GetUint("userID")); result.Error != nil {
+			c.JSON(404, gin.H{"error": "User not found"})
 			return
 		}
 
-		var credentials []database.CloudCredential
-		if result := db.Where("user_id = ?", userID).Find(&credentials); result.Error != nil {
-			c.JSON(500, gin.H{"error": "Failed to fetch credentials"})
+		remaining, err := mfa.RemainingRecoveryCodes(db, user.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to count recovery codes"})
 			return
 		}
 
-		c.JSON(200, credentials)
+		c.JSON(200, gin.H{
+			"enabled":                user.MFAEnabled,
+			"required":               mfa.Required(db, &user),
+			"recoveryCodesRemaining": remaining,
+		})
 	}
 }
 
-func createCredentialHandler(db *gorm.DB) gin.HandlerFunc {
+// enrollMFAHandler 开始绑定验证器，返回 TOTP 密钥和 otpauth URI，验证码确认后才会启用
+func enrollMFAHandler(db *gorm.DB, keyring *secrets.Keyring) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, exists := c.Get("userID")
-		if !exists {
-			c.JSON(401, gin.H{"error": "User not authenticated"})
+		var user database.User
+		if result := db.First(&user, c.GetUint("userID")); result.Error != nil {
+			c.JSON(404, gin.H{"error": "User not found"})
 			return
 		}
 
-		var input struct {
-			CloudProvider string `json:"cloud_provider" binding:"required"`
-			AccessKey     string `json:"access_key" binding:"required"`
-			SecretKey     string `json:"secret_key" binding:"required"`
-			Name          string `json:"name" binding:"required"`
-			Description   string `json:"description"`
+		secret, uri, err := mfa.Begin(db, keyring, &user)
+		if err != nil {
+			if errors.Is(err, mfa.ErrAlreadyEnabled) {
+				c.JSON(409, gin.H{"error": err.Error()})
+				return
+			}
+			fmt.Printf("Error starting MFA enrollment: %v\n", err)
+			c.JSON(500, gin.H{"error": "Failed to start MFA enrollment"})
+			return
 		}
 
+		c.JSON(200, gin.H{
+			"secret":     secret,
+			"otpauthUrl": uri,
+		})
+	}
+}
+
+// confirmMFAHandler 用验证码确认绑定并启用 MFA，返回恢复码
+// 启用后吊销此前签发的全部令牌，并签发新令牌替换当前会话（包括未绑定 MFA 时签发的受限令牌）
+func confirmMFAHandler(db *gorm.DB, keyring *secrets.Keyring, sessions *session.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			OTP string `json:"otp" binding:"required"`
+		}
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
 
-		credential := database.CloudCredential{
-			UserID:        userID.(uint),
-			CloudProvider: input.CloudProvider,
-			AccessKey:     input.AccessKey,
-			SecretKey:     input.SecretKey, // 实际应用中应该加密存储
-			Region:        "",              // 不再收集区域信息，设为空字符串
-			Name:          input.Name,
-			Description:   input.Description,
+		var user database.User
+		if result := db.First(&user, c.GetUint("userID")); result.Error != nil {
+			c.JSON(404, gin.H{"error": "User not found"})
+			return
 		}
 
-		if result := db.Create(&credential); result.Error != nil {
-			c.JSON(500, gin.H{"error": "Failed to create credential"})
+		codes, err := mfa.Confirm(db, keyring, &user, input.OTP)
+		if err != nil {
+			switch {
+			case errors.Is(err, mfa.ErrInvalidCode):
+				c.JSON(400, gin.H{"error": err.Error(), "code": "mfa_invalid"})
+			case errors.Is(err, mfa.ErrLocked):
+				c.JSON(429, gin.H{"error": err.Error(), "code": "mfa_locked"})
+			case errors.Is(err, mfa.ErrAlreadyEnabled), errors.Is(err, mfa.ErrNotEnrolled):
+				c.JSON(409, gin.H{"error": err.Error()})
+			default:
+				fmt.Printf("Error confirming MFA enrollment: %v\n", err)
+				c.JSON(500, gin.H{"error": "Failed to enable MFA"})
+			}
 			return
 		}
 
-		c.JSON(201, credential)
-	}
-}
-
-func getCredentialHandler(db *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		userID, exists := c.Get("userID")
-		if !exists {
-			c.JSON(401, gin.H{"error": "User not authenticated"})
+		if err := sessions.RevokeAll(user.ID); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to revoke existing sessions"})
 			return
 		}
-
-		id := c.Param("id")
-		var credential database.CloudCredential
-		if result := db.Where("id = ? AND user_id = ?", id, userID).First(&credential); result.Error != nil {
-			c.JSON(404, gin.H{"error": "Credential not found"})
+		tokens, err := sessions.Issue(&user)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to generate token"})
 			return
 		}
 
-		c.JSON(200, credential)
+		c.JSON(200, gin.H{
+			"message":       "MFA enabled",
+			"recoveryCodes": codes,
+			"user": gin.H{
+				"id":                 user.ID,
+				"username":           user.Username,
+				"email":              user.Email,
+				"role":               user.Role,
+				"mustChangePassword": user.MustChangePassword,
+				"mfaEnabled":         user.MFAEnabled,
+				"mfaSetupRequired":   false,
+				"permissions":        auth.Permissions(user.Role),
+			},
+			"token":         tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
+			"expires_in":    tokens.ExpiresIn,
+		})
 	}
 }
 
-func updateCredentialHandler(db *gorm.DB) gin.HandlerFunc {
+// disableMFAHandler 停用 MFA，需要验证密码和验证码，角色要求启用 MFA 时不允许停用
+func disableMFAHandler(db *gorm.DB, keyring *secrets.Keyring) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, exists := c.Get("userID")
-		if !exists {
-			c.JSON(401, gin.H{"error": "User not authenticated"})
+		var input struct {
+			Password string `json:"password" binding:"required"`
+			OTP      string `json:"otp" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
 
-		id := c.Param("id")
-		var credential database.CloudCredential
-		if result := db.Where("id = ? AND user_id = ?", id, userID).First(&credential); result.Error != nil {
-			c.JSON(404, gin.H{"error": "Credential not found"})
+		user, ok := verifyMFAUser(c, db, keyring, input.Password, input.OTP)
+		if !ok {
+			return
+		}
+		if mfa.Required(db, user) {
+			c.JSON(403, gin.H{"error": "MFA is required for your role"})
 			return
 		}
 
-		var input struct {
-			CloudProvider string `json:"cloud_provider"`
-			AccessKey     string `json:"access_key"`
-			SecretKey     string `json:"secret_key"`
-			Name          string `json:"name"`
-			Description   string `json:"description"`
+		if err := mfa.Disable(db, user.ID); err != nil {
+			fmt.Printf("Error disabling MFA: %v\n", err)
+			c.JSON(500, gin.H{"error": "Failed to disable MFA"})
+			return
 		}
+		c.JSON(200, gin.H{"message": "MFA disabled"})
+	}
+}
 
+// regenerateRecoveryCodesHandler 重新生成恢复码，需要验证码，旧恢复码全部失效
+func regenerateRecoveryCodesHandler(db *gorm.DB, keyring *secrets.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			OTP string `json:"otp" binding:"required"`
+		}
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
 
-		// 更新凭证信息
-		if input.CloudProvider != "" {
-			credential.CloudProvider = input.CloudProvider
-		}
-		if input.AccessKey != "" {
-			credential.AccessKey = input.AccessKey
-		}
-		if input.SecretKey != "" {
-			credential.SecretKey = input.SecretKey // 实际应用中应该加密存储
-		}
-		if input.Name != "" {
-			credential.Name = input.Name
+		user, ok := verifyMFAUser(c, db, keyring, "", input.OTP)
+		if !ok {
+			return
 		}
-		if input.Description != "" {
-			credential.Description = input.Description
+
+		codes, err := mfa.RegenerateRecoveryCodes(db, user.ID)
+		if err != nil {
+			fmt.Printf("Error regenerating recovery codes: %v\n", err)
+			c.JSON(500, gin.H{"error": "Failed to regenerate recovery codes"})
+			return
 		}
+		c.JSON(200, gin.H{"recoveryCodes": codes})
+	}
+}
 
-		if result := db.Save(&credential); result.Error != nil {
-			c.JSON(500, gin.H{"error": "Failed to update credential"})
+// verifyMFAUser 加载当前用户并校验验证码，password 不为空时同时校验密码，失败时写入响应并返回 false
+func verifyMFAUser(c *gin.Context, db *gorm.DB, keyring *secrets.Keyring, password, code string) (*database.User, bool) {
+	var user database.User
+	if result := db.First(&user, c.GetUint("userID")); result.Error != nil {
+		c.JSON(404, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	if password != "" && !auth.CheckPassword(user.Password, password) {
+		c.JSON(400, gin.H{"error": "Current password is incorrect"})
+		return nil, false
+	}
+
+	if err := mfa.Verify(db, keyring, &user, code); err != nil {
+		switch {
+		case errors.Is(err, mfa.ErrInvalidCode):
+			c.JSON(400, gin.H{"error": err.Error(), "code": "mfa_invalid"})
+		case errors.Is(err, mfa.ErrLocked):
+			c.JSON(429, gin.H{"error": err.Error(), "code": "mfa_locked"})
+		case errors.Is(err, mfa.ErrNotEnrolled):
+			c.JSON(409, gin.H{"error": err.Error()})
+		default:
+			fmt.Printf("Error verifying MFA code: %v\n", err)
+			c.JSON(500, gin.H{"error": "Failed to verify MFA code"})
+		}
+		return nil, false
+	}
+	return &user, true
+}
+
+// listUsersHandler 列出所有用户及其角色
+func listUsersHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var users []database.User
+		if result := db.Order("id").Find(&users); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch users"})
 			return
 		}
 
-		c.JSON(200, credential)
+		c.JSON(200, users)
 	}
 }
 
-func deleteCredentialHandler(db *gorm.DB) gin.HandlerFunc {
+// updateUserRoleHandler 修改用户角色，不能修改自己的角色，避免管理员误操作后无人可以管理用户
+func updateUserRoleHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -437,15 +905,982 @@ func deleteCredentialHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		id := c.Param("id")
-		var credential database.CloudCredential
-		if result := db.Where("id = ? AND user_id = ?", id, userID).First(&credential); result.Error != nil {
-			c.JSON(404, gin.H{"error": "Credential not found"})
+		var input struct {
+			Role string `json:"role" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
 
-		if result := db.Delete(&credential); result.Error != nil {
-			c.JSON(500, gin.H{"error": "Failed to delete credential"})
+		if !auth.ValidRole(input.Role) {
+			c.JSON(400, gin.H{"error": "Invalid role"})
+			return
+		}
+
+		var user database.User
+		if result := db.First(&user, c.Param("id")); result.Error != nil {
+			c.JSON(404, gin.H{"error": "User not found"})
+			return
+		}
+
+		if user.ID == userID.(uint) {
+			c.JSON(400, gin.H{"error": "Cannot change your own role"})
+			return
+		}
+
+		if result := db.Model(&user).Update("role", input.Role); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to update user role"})
+			return
+		}
+
+		c.JSON(200, user)
+	}
+}
+
+// resetUserMFAHandler 管理员为丢失验证器的用户停用 MFA，并使其全部会话失效，不能重置自己的 MFA
+// 角色要求启用 MFA 时，用户下次登录后需要重新绑定
+func resetUserMFAHandler(db *gorm.DB, sessions *session.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user database.User
+		if result := db.First(&user, c.Param("id")); result.Error != nil {
+			c.JSON(404, gin.H{"error": "User not found"})
+			return
+		}
+
+		// 重置自己的 MFA 需要通过停用接口验证密码和验证码
+		if user.ID == c.GetUint("userID") {
+			c.JSON(400, gin.H{"error": "Cannot reset your own MFA"})
+			return
+		}
+
+		if err := mfa.Disable(db, user.ID); err != nil {
+			fmt.Printf("Error resetting MFA: %v\n", err)
+			c.JSON(500, gin.H{"error": "Failed to reset MFA"})
+			return
+		}
+		if err := sessions.RevokeAll(user.ID); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to revoke existing sessions"})
+			return
+		}
+
+		c.JSON(200, gin.H{"message": "MFA reset"})
+	}
+}
+
+// getSecuritySettingsHandler 查看安全设置
+func getSecuritySettingsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{"requireMfa": mfa.Enforced(db)})
+	}
+}
+
+// updateSecuritySettingsHandler 修改安全设置
+// 开启强制 MFA 后，可以执行云操作但尚未绑定验证器的用户刷新令牌或重新登录时只能绑定验证器
+func updateSecuritySettingsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			RequireMFA *bool `json:"requireMfa" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := mfa.SetEnforced(db, *input.RequireMFA, c.GetUint("userID")); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to save settings"})
+			return
+		}
+		c.JSON(200, gin.H{"requireMfa": *input.RequireMFA})
+	}
+}
+
+// recordAudit 为在请求中直接调用的云平台操作追加审计记录，操作者为当前用户
+func recordAudit(c *gin.Context, db *gorm.DB, entry database.AuditLog, opErr error) {
+	entry.ActorID = c.GetUint("userID")
+	entry.Actor = c.GetString("username")
+	entry.Result = audit.ResultSuccess
+	if opErr != nil {
+		entry.Result = audit.ResultFailed
+		entry.Error = opErr.Error()
+	}
+	if _, err := audit.Record(db, entry); err != nil {
+		fmt.Printf("Error recording audit log: %v\n", err)
+	}
+}
+
+// auditQuery 按请求参数筛选当前用户可见项目中的审计记录
+func auditQuery(c *gin.Context, db *gorm.DB, projectIDs []uint) *gorm.DB {
+	query := db.Model(&database.AuditLog{}).Where("project_id IN ?", projectIDs)
+	for param, column := range map[string]string{
+		"actorId":      "actor_id",
+		"credentialId": "credential_id",
+		"taskId":       "task_id",
+		"provider":     "provider",
+		"result":       "result",
+	} {
+		if value := c.Query(param); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action LIKE ?", action+"%")
+	}
+	if target := c.Query("target"); target != "" {
+		query = query.Where("target LIKE ?", "%"+target+"%")
+	}
+	if from := c.Query("from"); from != "" {
+		query = query.Where("timestamp >= ?", from)
+	}
+	if to := c.Query("to"); to != "" {
+		query = query.Where("timestamp <= ?", to)
+	}
+	return query
+}
+
+// auditExportLimit 单次导出的最大记录数
+const auditExportLimit = 100000
+
+// listAuditLogsHandler 查询审计日志，支持筛选、分页，export=csv 或 export=json 时导出全部匹配记录
+func listAuditLogsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		projectIDs, ok := visibleProjects(c, db, userID.(uint))
+		if !ok {
+			return
+		}
+
+		export := c.Query("export")
+		if export != "" {
+			var entries []database.AuditLog
+			if result := auditQuery(c, db, projectIDs).Order("id").Limit(auditExportLimit).Find(&entries); result.Error != nil {
+				c.JSON(500, gin.H{"error": "Failed to export audit log"})
+				return
+			}
+			filename := "audit-" + time.Now().Format("20060102-150405")
+			switch export {
+			case "csv":
+				c.Header("Content-Disposition", "attachment; filename="+filename+".csv")
+				c.Header("Content-Type", "text/csv; charset=utf-8")
+				w := csv.NewWriter(c.Writer)
+				w.Write([]string{"id", "timestamp", "actor_id", "actor", "project_id", "credential_id", "provider", "task_id", "action", "target", "parameters", "result", "error", "prev_hash", "hash"})
+				for _, e := range entries {
+					w.Write([]string{
+						fmt.Sprint(e.ID), e.Timestamp, fmt.Sprint(e.ActorID), e.Actor, fmt.Sprint(e.ProjectID),
+						fmt.Sprint(e.CredentialID), e.Provider, fmt.Sprint(e.TaskID), e.Action, e.Target,
+						e.Parameters, e.Result, e.Error, e.PrevHash, e.Hash,
+					})
+				}
+				w.Flush()
+			case "json":
+				c.Header("Content-Disposition", "attachment; filename="+filename+".json")
+				c.JSON(200, entries)
+			default:
+				c.JSON(400, gin.H{"error": "Unsupported export format"})
+			}
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if limit <= 0 || limit > 1000 {
+			limit = 100
+		}
+		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if offset < 0 {
+			offset = 0
+		}
+
+		var total int64
+		if result := auditQuery(c, db, projectIDs).Count(&total); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch audit log"})
+			return
+		}
+		var entries []database.AuditLog
+		if result := auditQuery(c, db, projectIDs).Order("id DESC").Limit(limit).Offset(offset).Find(&entries); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch audit log"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"entries": entries,
+			"total":   total,
+			"limit":   limit,
+			"offset":  offset,
+		})
+	}
+}
+
+// verifyAuditLogHandler 校验审计日志的哈希链是否完整
+func verifyAuditLogHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		verification, err := audit.Verify(db)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, verification)
+	}
+}
+
+// projectView 项目及当前用户在项目中的角色
+type projectView struct {
+	database.Project
+	Role string `json:"role"`
+}
+
+// listProjectsHandler 列出当前用户可以访问的项目
+func listProjectsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		projectIDs, ok := visibleProjects(c, db, userID.(uint))
+		if !ok {
+			return
+		}
+
+		var projects []database.Project
+		if result := db.Where("id IN ?", projectIDs).Order("id").Find(&projects); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch projects"})
+			return
+		}
+
+		views := make([]projectView, 0, len(projects))
+		for _, p := range projects {
+			role, err := project.Access(db, userID.(uint), c.GetString("role"), p.ID)
+			if err != nil {
+				continue
+			}
+			views = append(views, projectView{Project: p, Role: role})
+		}
+
+		c.JSON(200, views)
+	}
+}
+
+// createProjectHandler 创建项目，创建者成为项目所有者
+func createProjectHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var input struct {
+			Name        string `json:"name" binding:"required"`
+			Description string `json:"description"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		p := database.Project{
+			Name:        input.Name,
+			Description: input.Description,
+			CreatedBy:   userID.(uint),
+			CreatedAt:   time.Now().Format(time.RFC3339),
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&p).Error; err != nil {
+				return err
+			}
+			return tx.Create(&database.ProjectMember{
+				ProjectID: p.ID,
+				UserID:    userID.(uint),
+				Role:      project.RoleOwner,
+				CreatedAt: p.CreatedAt,
+			}).Error
+		})
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to create project"})
+			return
+		}
+
+		c.JSON(201, projectView{Project: p, Role: project.RoleOwner})
+	}
+}
+
+// getProjectHandler 获取项目详情
+func getProjectHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var p database.Project
+		if result := db.Where("id = ?", c.Param("id")).First(&p); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Project not found"})
+			return
+		}
+
+		role, err := project.Access(db, userID.(uint), c.GetString("role"), p.ID)
+		if errors.Is(err, project.ErrNoAccess) {
+			c.JSON(404, gin.H{"error": "Project not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to check project access"})
+			return
+		}
+
+		c.JSON(200, projectView{Project: p, Role: role})
+	}
+}
+
+// updateProjectHandler 修改项目名称和描述，需要项目所有者
+func updateProjectHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var input struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		var p database.Project
+		if result := db.Where("id = ?", c.Param("id")).First(&p); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Project not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), p.ID, auth.PermProjectsWrite, "Project not found") {
+			return
+		}
+
+		if input.Name != "" {
+			p.Name = input.Name
+		}
+		p.Description = input.Description
+
+		if result := db.Save(&p); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to update project"})
+			return
+		}
+
+		c.JSON(200, p)
+	}
+}
+
+// deleteProjectHandler 删除项目，项目中仍有凭证、任务、定时任务或剧本时拒绝删除
+func deleteProjectHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var p database.Project
+		if result := db.Where("id = ?", c.Param("id")).First(&p); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Project not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), p.ID, auth.PermProjectsWrite, "Project not found") {
+			return
+		}
+
+		if p.Personal {
+			c.JSON(400, gin.H{"error": "Personal projects cannot be deleted"})
+			return
+		}
+
+		for _, model := range []interface{}{&database.CloudCredential{}, &database.Task{}, &database.Schedule{}, &database.Playbook{}} {
+			var count int64
+			if err := db.Model(model).Where("project_id = ?", p.ID).Count(&count).Error; err != nil {
+				c.JSON(500, gin.H{"error": "Failed to delete project"})
+				return
+			}
+			if count > 0 {
+				c.JSON(409, gin.H{"error": "Project is not empty"})
+				return
+			}
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("project_id = ?", p.ID).Delete(&database.ProjectMember{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&p).Error
+		})
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to delete project"})
+			return
+		}
+
+		c.JSON(200, gin.H{"message": "Project deleted successfully"})
+	}
+}
+
+// getProjectScopeHandler 获取项目的授权范围，未定义时返回 null
+func getProjectScopeHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var p database.Project
+		if result := db.Where("id = ?", c.Param("id")).First(&p); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Project not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), p.ID, auth.PermProjectsRead, "Project not found") {
+			return
+		}
+
+		s, err := scope.Parse(p.Scope)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"scope": s})
+	}
+}
+
+// updateProjectScopeHandler 设置项目的授权范围，请求体为 null 时清除范围限制
+func updateProjectScopeHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var input json.RawMessage
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		var p database.Project
+		if result := db.Where("id = ?", c.Param("id")).First(&p); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Project not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), p.ID, auth.PermProjectsWrite, "Project not found") {
+			return
+		}
+
+		data := ""
+		if string(input) != "null" {
+			data = string(input)
+		}
+		s, err := scope.Parse(data)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		// 保存规范化后的范围定义，丢弃未知字段
+		if s != nil {
+			normalized, _ := json.Marshal(s)
+			data = string(normalized)
+		}
+
+		if result := db.Model(&p).Update("scope", data); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to update project scope"})
+			return
+		}
+
+		c.JSON(200, gin.H{"scope": s})
+	}
+}
+
+// listArtifactsHandler 列出平台在项目中创建的资源，可按 status 筛选
+func listArtifactsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var p database.Project
+		if result := db.Where("id = ?", c.Param("id")).First(&p); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Project not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), p.ID, auth.PermCloudRead, "Project not found") {
+			return
+		}
+
+		query := db.Where("project_id = ?", p.ID)
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		var artifacts []database.Artifact
+		if result := query.Order("id desc").Find(&artifacts); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch artifacts"})
+			return
+		}
+
+		c.JSON(200, gin.H{"artifacts": artifacts})
+	}
+}
+
+// cleanupEngagementHandler 为项目中创建过未清理资源的每个凭证提交清理任务，返回任务ID
+// 任务结果中包含已删除和无法删除的资源
+func cleanupEngagementHandler(db *gorm.DB, queue task.Queue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var p database.Project
+		if result := db.Where("id = ?", c.Param("id")).First(&p); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Project not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), p.ID, auth.PermCloudOperate, "Project not found") {
+			return
+		}
+
+		var credentialIDs []uint
+		if err := db.Model(&database.Artifact{}).
+			Where("project_id = ? AND status IN ?", p.ID, []string{database.ArtifactActive, database.ArtifactFailed}).
+			Distinct().Pluck("credential_id", &credentialIDs).Error; err != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch artifacts"})
+			return
+		}
+		if len(credentialIDs) == 0 {
+			c.JSON(200, gin.H{"message": "No artifacts to clean up", "clean": true, "task_ids": []uint{}})
+			return
+		}
+
+		// 每个凭证一个清理任务，经过任务队列的限流和重试控制
+		taskIDs := []uint{}
+		for _, credentialID := range credentialIDs {
+			t := database.Task{
+				UserID:       userID.(uint),
+				ProjectID:    p.ID,
+				CredentialID: credentialID,
+				Name:         "Engagement cleanup",
+				TaskType:     "cleanup",
+				Parameters:   "{}",
+			}
+			if err := task.Submit(c.Request.Context(), db, queue, &t); err != nil {
+				fmt.Printf("Error submitting task: %v\n", err)
+				c.JSON(500, gin.H{"error": "Failed to create task", "task_ids": taskIDs})
+				return
+			}
+			taskIDs = append(taskIDs, t.ID)
+		}
+
+		c.JSON(202, gin.H{"message": "Cleanup tasks queued", "task_ids": taskIDs})
+	}
+}
+
+// projectMemberView 项目成员及其用户名
+type projectMemberView struct {
+	database.ProjectMember
+	Username string `json:"username"`
+}
+
+// listProjectMembersHandler 列出项目成员
+func listProjectMembersHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var p database.Project
+		if result := db.Where("id = ?", c.Param("id")).First(&p); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Project not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), p.ID, auth.PermProjectsRead, "Project not found") {
+			return
+		}
+
+		var members []projectMemberView
+		result := db.Model(&database.ProjectMember{}).
+			Select("project_members.*, users.username").
+			Joins("LEFT JOIN users ON users.id = project_members.user_id").
+			Where("project_members.project_id = ?", p.ID).
+			Order("project_members.id").
+			Scan(&members)
+		if result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch project members"})
+			return
+		}
+
+		c.JSON(200, members)
+	}
+}
+
+// addProjectMemberHandler 按用户名添加项目成员，需要项目所有者
+func addProjectMemberHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var input struct {
+			Username string `json:"username" binding:"required"`
+			Role     string `json:"role" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !project.ValidRole(input.Role) {
+			c.JSON(400, gin.H{"error": "Invalid role"})
+			return
+		}
+
+		var p database.Project
+		if result := db.Where("id = ?", c.Param("id")).First(&p); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Project not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), p.ID, auth.PermProjectsWrite, "Project not found") {
+			return
+		}
+
+		var user database.User
+		if result := db.Where("username = ?", input.Username).First(&user); result.Error != nil {
+			c.JSON(404, gin.H{"error": "User not found"})
+			return
+		}
+
+		var count int64
+		db.Model(&database.ProjectMember{}).Where("project_id = ? AND user_id = ?", p.ID, user.ID).Count(&count)
+		if count > 0 {
+			c.JSON(409, gin.H{"error": "User is already a member of the project"})
+			return
+		}
+
+		member := database.ProjectMember{
+			ProjectID: p.ID,
+			UserID:    user.ID,
+			Role:      input.Role,
+			CreatedAt: time.Now().Format(time.RFC3339),
+		}
+		if result := db.Create(&member); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to add project member"})
+			return
+		}
+
+		c.JSON(201, projectMemberView{ProjectMember: member, Username: user.Username})
+	}
+}
+
+// loadProjectMember 加载项目成员并检查当前用户是否为项目所有者
+func loadProjectMember(c *gin.Context, db *gorm.DB, userID uint) (*database.ProjectMember, bool) {
+	var p database.Project
+	if result := db.Where("id = ?", c.Param("id")).First(&p); result.Error != nil {
+		c.JSON(404, gin.H{"error": "Project not found"})
+		return nil, false
+	}
+	if !authorizeProject(c, db, userID, p.ID, auth.PermProjectsWrite, "Project not found") {
+		return nil, false
+	}
+
+	var member database.ProjectMember
+	if result := db.Where("project_id = ? AND user_id = ?", p.ID, c.Param("userId")).First(&member); result.Error != nil {
+		c.JSON(404, gin.H{"error": "Project member not found"})
+		return nil, false
+	}
+	return &member, true
+}
+
+// isLastOwner 判断成员是否为项目中唯一的所有者，项目必须至少保留一个所有者
+func isLastOwner(db *gorm.DB, member *database.ProjectMember) bool {
+	if member.Role != project.RoleOwner {
+		return false
+	}
+	var owners int64
+	db.Model(&database.ProjectMember{}).Where("project_id = ? AND role = ?", member.ProjectID, project.RoleOwner).Count(&owners)
+	return owners <= 1
+}
+
+// updateProjectMemberHandler 修改项目成员角色
+func updateProjectMemberHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var input struct {
+			Role string `json:"role" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !project.ValidRole(input.Role) {
+			c.JSON(400, gin.H{"error": "Invalid role"})
+			return
+		}
+
+		member, ok := loadProjectMember(c, db, userID.(uint))
+		if !ok {
+			return
+		}
+
+		if input.Role != project.RoleOwner && isLastOwner(db, member) {
+			c.JSON(400, gin.H{"error": "Project must keep at least one owner"})
+			return
+		}
+
+		if result := db.Model(member).Update("role", input.Role); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to update project member"})
+			return
+		}
+
+		c.JSON(200, member)
+	}
+}
+
+// removeProjectMemberHandler 移除项目成员
+func removeProjectMemberHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		member, ok := loadProjectMember(c, db, userID.(uint))
+		if !ok {
+			return
+		}
+
+		if isLastOwner(db, member) {
+			c.JSON(400, gin.H{"error": "Project must keep at least one owner"})
+			return
+		}
+
+		if result := db.Delete(member); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to remove project member"})
+			return
+		}
+
+		c.JSON(200, gin.H{"message": "Project member removed successfully"})
+	}
+}
+
+func listCredentialsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		projectIDs, ok := visibleProjects(c, db, userID.(uint))
+		if !ok {
+			return
+		}
+
+		var credentials []database.CloudCredential
+		if result := db.Where("project_id IN ?", projectIDs).Find(&credentials); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch credentials"})
+			return
+		}
+
+		c.JSON(200, credentials)
+	}
+}
+
+func createCredentialHandler(db *gorm.DB, keyring *secrets.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var input struct {
+			CloudProvider string `json:"cloud_provider" binding:"required"`
+			AccessKey     string `json:"access_key" binding:"required"`
+			SecretKey     string `json:"secret_key" binding:"required"`
+			Name          string `json:"name" binding:"required"`
+			Description   string `json:"description"`
+			Endpoint      string `json:"endpoint"`
+			ProjectID     uint   `json:"project_id"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		projectID, ok := targetProject(c, db, userID.(uint), input.ProjectID, auth.PermCredentialsWrite)
+		if !ok {
+			return
+		}
+
+		secretKey, err := keyring.Encrypt(input.SecretKey)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to encrypt secret key"})
+			return
+		}
+
+		credential := database.CloudCredential{
+			UserID:        userID.(uint),
+			ProjectID:     projectID,
+			CloudProvider: input.CloudProvider,
+			AccessKey:     input.AccessKey,
+			SecretKey:     secretKey,
+			Region:        "", // 不再收集区域信息，设为空字符串
+			Endpoint:      input.Endpoint,
+			Name:          input.Name,
+			Description:   input.Description,
+		}
+
+		if result := db.Create(&credential); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to create credential"})
+			return
+		}
+
+		c.JSON(201, credential)
+	}
+}
+
+func getCredentialHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		id := c.Param("id")
+		credential, ok := loadCredential(c, db, userID.(uint), id, auth.PermCredentialsRead)
+		if !ok {
+			return
+		}
+
+		c.JSON(200, credential)
+	}
+}
+
+func updateCredentialHandler(db *gorm.DB, keyring *secrets.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		id := c.Param("id")
+		credential, ok := loadCredential(c, db, userID.(uint), id, auth.PermCredentialsWrite)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			CloudProvider string `json:"cloud_provider"`
+			AccessKey     string `json:"access_key"`
+			SecretKey     string `json:"secret_key"`
+			Name          string `json:"name"`
+			Description   string `json:"description"`
+			Endpoint      string `json:"endpoint"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 更新凭证信息
+		if input.CloudProvider != "" {
+			credential.CloudProvider = input.CloudProvider
+		}
+		if input.AccessKey != "" {
+			credential.AccessKey = input.AccessKey
+		}
+		if input.SecretKey != "" {
+			secretKey, err := keyring.Encrypt(input.SecretKey)
+			if err != nil {
+				c.JSON(500, gin.H{"error": "Failed to encrypt secret key"})
+				return
+			}
+			credential.SecretKey = secretKey
+		}
+		if input.Name != "" {
+			credential.Name = input.Name
+		}
+		if input.Description != "" {
+			credential.Description = input.Description
+		}
+		if input.Endpoint != "" {
+			credential.Endpoint = input.Endpoint
+		}
+
+		if result := db.Save(credential); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to update credential"})
+			return
+		}
+
+		c.JSON(200, credential)
+	}
+}
+
+func deleteCredentialHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		id := c.Param("id")
+		credential, ok := loadCredential(c, db, userID.(uint), id, auth.PermCredentialsWrite)
+		if !ok {
+			return
+		}
+
+		// 凭证创建的资源尚未清理时删除凭证将无法再自动清理
+		var artifacts int64
+		if err := db.Model(&database.Artifact{}).Where("credential_id = ? AND status <> ?", credential.ID, database.ArtifactRemoved).Count(&artifacts).Error; err != nil {
+			c.JSON(500, gin.H{"error": "Failed to delete credential"})
+			return
+		}
+		if artifacts > 0 {
+			c.JSON(409, gin.H{"error": "Credential has created artifacts that have not been cleaned up, run the engagement cleanup first"})
+			return
+		}
+
+		if result := db.Delete(credential); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to delete credential"})
 			return
 		}
 
@@ -453,7 +1888,430 @@ func deleteCredentialHandler(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-func listTasksHandler(db *gorm.DB) gin.HandlerFunc {
+func listTasksHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		projectIDs, ok := visibleProjects(c, db, userID.(uint))
+		if !ok {
+			return
+		}
+
+		var tasks []database.Task
+		if result := db.Where("project_id IN ?", projectIDs).Find(&tasks); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch tasks"})
+			return
+		}
+
+		c.JSON(200, tasks)
+	}
+}
+
+func createTaskHandler(db *gorm.DB, queue task.Queue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var input struct {
+			CredentialID uint   `json:"credentialId" binding:"required"`
+			TaskType     string `json:"taskType" binding:"required"`
+			Parameters   string `json:"parameters" binding:"required"`
+			Name         string `json:"name" binding:"required"`
+			Priority     string `json:"priority"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if input.Priority == "" {
+			input.Priority = task.PriorityNormal
+		}
+		if !task.ValidPriority(input.Priority) {
+			c.JSON(400, gin.H{"error": "Invalid priority"})
+			return
+		}
+
+		if !task.ValidType(input.TaskType) {
+			c.JSON(400, gin.H{"error": "Unsupported task type"})
+			return
+		}
+		if !checkTaskPermission(c, input.TaskType) {
+			return
+		}
+
+		// worker 按 JSON 对象解析参数，提交时即拒绝无效参数
+		var params map[string]interface{}
+		if err := json.Unmarshal([]byte(input.Parameters), &params); err != nil || params == nil {
+			c.JSON(400, gin.H{"error": "Parameters must be a JSON object"})
+			return
+		}
+
+		// 验证用户在凭证所属项目中是否可以执行该类型的任务
+		credential, ok := loadCredential(c, db, userID.(uint), input.CredentialID, auth.TaskPermission(input.TaskType))
+		if !ok {
+			return
+		}
+
+		t := database.Task{
+			UserID:       userID.(uint),
+			ProjectID:    credential.ProjectID,
+			CredentialID: input.CredentialID,
+			Name:         input.Name,
+			TaskType:     input.TaskType,
+			Priority:     input.Priority,
+			Parameters:   input.Parameters,
+		}
+
+		// 创建任务并放入队列
+		if err := task.Submit(c.Request.Context(), db, queue, &t); err != nil {
+			fmt.Printf("Error submitting task: %v\n", err)
+			c.JSON(500, gin.H{"error": "Failed to create task"})
+			return
+		}
+
+		c.JSON(201, t)
+	}
+}
+
+func getTaskHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		id := c.Param("id")
+		task, ok := loadTask(c, db, userID.(uint), id, auth.PermTasksRead)
+		if !ok {
+			return
+		}
+
+		// 失败的任务附带失败原因说明
+		c.JSON(200, taskDetail{Task: *task, FailureReason: taskFailureReason(*task)})
+	}
+}
+
+// taskDetail 任务详情
+type taskDetail struct {
+	database.Task
+	FailureReason string `json:"failureReason,omitempty"`
+}
+
+// taskFailureReason 根据错误分类和错误信息生成失败原因说明
+func taskFailureReason(t database.Task) string {
+	if t.Status != "failed" && t.Status != "cancelled" {
+		return ""
+	}
+
+	explanation := task.ExplainFailure(t.ErrorClass)
+	switch {
+	case t.Error == "":
+		return explanation
+	case explanation == "":
+		return t.Error
+	}
+	return fmt.Sprintf("%s（%s）", explanation, t.Error)
+}
+
+func getTaskResultsHandler(db *gorm.DB, keyring *secrets.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		id := c.Param("id")
+		t, ok := loadTask(c, db, userID.(uint), id, auth.PermTasksRead)
+		if !ok {
+			return
+		}
+
+		var results []database.TaskResult
+		if result := db.Where("task_id = ?", id).Find(&results); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch task results"})
+			return
+		}
+
+		// 联邦登录签发的临时凭证加密保存，只向任务提交者解密，其他项目成员看不到
+		for i := range results {
+			var result map[string]interface{}
+			if err := json.Unmarshal([]byte(results[i].Result), &result); err != nil {
+				continue
+			}
+			if _, sealed := result[task.SealedKey]; !sealed {
+				continue
+			}
+			if err := task.RevealSecrets(keyring, result, t.UserID == userID.(uint)); err != nil {
+				fmt.Printf("Error revealing task result: %v\n", err)
+			}
+			data, _ := json.Marshal(result)
+			results[i].Result = string(data)
+		}
+
+		c.JSON(200, results)
+	}
+}
+
+// getTaskEventsHandler 以 SSE 推送任务进度事件，任务结束后关闭连接
+func getTaskEventsHandler(db *gorm.DB, broker task.Broker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		id := c.Param("id")
+		t, ok := loadTask(c, db, userID.(uint), id, auth.PermTasksRead)
+		if !ok {
+			return
+		}
+
+		// 先订阅再读取任务状态，避免错过两者之间发布的事件
+		events, unsubscribe, err := broker.Subscribe(c.Request.Context(), t.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to subscribe to task events"})
+			return
+		}
+		defer unsubscribe()
+
+		if result := db.First(t, t.ID); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Task not found"})
+			return
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+
+		// 首个事件为任务当前状态，已结束的任务直接关闭连接
+		c.SSEvent("progress", progress.Event{
+			Type:      progress.TypeStatus,
+			Status:    t.Status,
+			Error:     t.Error,
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		c.Writer.Flush()
+		if task.IsTerminal(t.Status) {
+			return
+		}
+
+		keepalive := time.NewTicker(15 * time.Second)
+		defer keepalive.Stop()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return false
+				}
+				c.SSEvent("progress", event)
+				return !(event.Type == progress.TypeStatus && task.IsTerminal(event.Status))
+			case <-keepalive.C:
+				// 注释行用于保持连接，避免被代理断开
+				fmt.Fprint(w, ": keepalive\n\n")
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
+		})
+	}
+}
+
+// approvePlanHandler 批准 dry_run 生成的执行计划，批准人需要在项目中有执行资源操作的权限且不能是生成计划的用户
+// 批准在到期前只能被一次资源操作使用
+func approvePlanHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		t, ok := loadTask(c, db, userID.(uint), c.Param("id"), auth.PermCloudOperate)
+		if !ok {
+			return
+		}
+
+		approval, err := task.ApprovePlan(db, t, userID.(uint))
+		switch {
+		case errors.Is(err, task.ErrNotPlan):
+			c.JSON(400, gin.H{"error": "Task is not a completed dry run"})
+			return
+		case errors.Is(err, task.ErrPlanSelfApproval):
+			c.JSON(403, gin.H{"error": "Approval must come from someone other than the user who generated the plan"})
+			return
+		case errors.Is(err, task.ErrPlanAlreadyApproved):
+			c.JSON(409, gin.H{"error": "Plan has already been approved"})
+			return
+		case err != nil:
+			fmt.Printf("Error approving plan %d: %v\n", t.ID, err)
+			c.JSON(500, gin.H{"error": "Failed to approve plan"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"message":  "Plan approved, pass its task_id as params.approved_plan to execute it",
+			"approval": approval,
+		})
+	}
+}
+
+// 取消任务：排队中的任务直接标记为已取消，执行中的任务通知 worker 停止执行
+func cancelTaskHandler(db *gorm.DB, broker task.Broker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		t, ok := loadTask(c, db, userID.(uint), c.Param("id"), auth.PermTasksWrite)
+		if !ok {
+			return
+		}
+
+		status, err := task.Cancel(c, db, broker, t.ID)
+		if errors.Is(err, task.ErrTaskFinished) {
+			c.JSON(409, gin.H{"error": "Task has already finished"})
+			return
+		}
+		if err != nil {
+			fmt.Printf("Error cancelling task %d: %v\n", t.ID, err)
+			c.JSON(500, gin.H{"error": "Failed to cancel task"})
+			return
+		}
+		if status == "cancelled" {
+			c.JSON(200, gin.H{"message": "Task cancelled", "task_id": t.ID, "status": status})
+			return
+		}
+		c.JSON(202, gin.H{"message": "Cancellation requested", "task_id": t.ID, "status": "cancelling"})
+	}
+}
+
+func deleteTaskHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		id := c.Param("id")
+		t, ok := loadTask(c, db, userID.(uint), id, auth.PermTasksDelete)
+		if !ok {
+			return
+		}
+		// 未结束的任务仍可能被 worker 执行，需要先取消
+		if !task.IsTerminal(t.Status) {
+			c.JSON(409, gin.H{"error": "Task has not finished, cancel it before deleting", "status": t.Status})
+			return
+		}
+
+		// 开始事务
+		tx := db.Begin()
+
+		// 删除任务，状态在检查后被修改时不删除
+		result := tx.Where("status IN ?", task.TerminalStatuses).Delete(t)
+		if result.Error != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": "Failed to delete task"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			c.JSON(409, gin.H{"error": "Task has not finished, cancel it before deleting"})
+			return
+		}
+
+		// 删除任务结果
+		if err := tx.Where("task_id = ?", id).Delete(&database.TaskResult{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": "Failed to delete task results"})
+			return
+		}
+
+		// 提交事务
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(500, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		c.JSON(200, gin.H{"message": "Task deleted successfully"})
+	}
+}
+
+func deleteAllTasksHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		// 只删除当前用户在可访问项目中创建的任务，不影响项目其他成员的任务
+		projectIDs, ok := visibleProjects(c, db, userID.(uint))
+		if !ok {
+			return
+		}
+
+		// 开始事务
+		tx := db.Begin()
+
+		// 查找用户已结束的任务，排队和执行中的任务需要先取消
+		var tasks []database.Task
+		if result := tx.Where("user_id = ? AND project_id IN ? AND status IN ?", userID, projectIDs, task.TerminalStatuses).Find(&tasks); result.Error != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": "Failed to fetch tasks"})
+			return
+		}
+
+		// 收集所有任务ID
+		var taskIDs []uint
+		for _, t := range tasks {
+			taskIDs = append(taskIDs, t.ID)
+		}
+
+		// 删除所有任务结果
+		if len(taskIDs) > 0 {
+			if err := tx.Where("task_id IN ?", taskIDs).Delete(&database.TaskResult{}).Error; err != nil {
+				tx.Rollback()
+				c.JSON(500, gin.H{"error": "Failed to delete task results"})
+				return
+			}
+
+			// 删除所有任务
+			if err := tx.Where("id IN ? AND status IN ?", taskIDs, task.TerminalStatuses).Delete(&database.Task{}).Error; err != nil {
+				tx.Rollback()
+				c.JSON(500, gin.H{"error": "Failed to delete tasks"})
+				return
+			}
+		}
+
+		// 提交事务
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(500, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		c.JSON(200, gin.H{"message": "All tasks deleted successfully"})
+	}
+}
+
+// listSchedulesHandler 列出当前用户的定时任务
+func listSchedulesHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -461,17 +2319,23 @@ func listTasksHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var tasks []database.Task
-		if result := db.Where("user_id = ?", userID).Find(&tasks); result.Error != nil {
-			c.JSON(500, gin.H{"error": "Failed to fetch tasks"})
+		projectIDs, ok := visibleProjects(c, db, userID.(uint))
+		if !ok {
+			return
+		}
+
+		var schedules []database.Schedule
+		if result := db.Where("project_id IN ?", projectIDs).Order("id").Find(&schedules); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch schedules"})
 			return
 		}
 
-		c.JSON(200, tasks)
+		c.JSON(200, schedules)
 	}
 }
 
-func createTaskHandler(db *gorm.DB, redisClient *redis.Client) gin.HandlerFunc {
+// createScheduleHandler 创建定时任务
+func createScheduleHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -481,9 +2345,10 @@ func createTaskHandler(db *gorm.DB, redisClient *redis.Client) gin.HandlerFunc {
 
 		var input struct {
 			CredentialID uint   `json:"credentialId" binding:"required"`
-			TaskType     string `json:"taskType" binding:"required"`
-			Parameters   string `json:"parameters" binding:"required"`
 			Name         string `json:"name" binding:"required"`
+			TaskType     string `json:"taskType" binding:"required"`
+			Parameters   string `json:"parameters"`
+			CronExpr     string `json:"cronExpr" binding:"required"`
 		}
 
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -491,45 +2356,95 @@ func createTaskHandler(db *gorm.DB, redisClient *redis.Client) gin.HandlerFunc {
 			return
 		}
 
-		// 验证凭证是否属于该用户
-		var credential database.CloudCredential
-		if result := db.Where("id = ? AND user_id = ?", input.CredentialID, userID).First(&credential); result.Error != nil {
-			c.JSON(404, gin.H{"error": "Credential not found"})
+		if !task.ValidType(input.TaskType) {
+			c.JSON(400, gin.H{"error": "Unsupported task type"})
+			return
+		}
+		if !checkTaskPermission(c, input.TaskType) {
+			return
+		}
+
+		if input.Parameters == "" {
+			input.Parameters = "{}"
+		}
+		if !json.Valid([]byte(input.Parameters)) {
+			c.JSON(400, gin.H{"error": "Parameters must be valid JSON"})
+			return
+		}
+
+		nextRunAt, err := task.NextRun(input.CronExpr, time.Now())
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 验证用户在凭证所属项目中是否可以创建定时任务
+		credential, ok := loadCredential(c, db, userID.(uint), input.CredentialID, auth.PermSchedulesWrite)
+		if !ok {
 			return
 		}
 
-		task := database.Task{
+		schedule := database.Schedule{
 			UserID:       userID.(uint),
+			ProjectID:    credential.ProjectID,
 			CredentialID: input.CredentialID,
+			Name:         input.Name,
 			TaskType:     input.TaskType,
-			Status:       "pending",
 			Parameters:   input.Parameters,
-			StartTime:    "",
-			EndTime:      "",
+			CronExpr:     input.CronExpr,
+			Status:       "active",
+			NextRunAt:    nextRunAt,
+			CreatedAt:    time.Now().Format(time.RFC3339),
 		}
 
-		if result := db.Create(&task); result.Error != nil {
-			c.JSON(500, gin.H{"error": "Failed to create task"})
+		if result := db.Create(&schedule); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to create schedule"})
+			return
+		}
+
+		c.JSON(201, schedule)
+	}
+}
+
+// setScheduleStatusHandler 暂停或恢复定时任务，恢复时从当前时间重新计算下一次运行时间
+func setScheduleStatusHandler(db *gorm.DB, status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var schedule database.Schedule
+		if result := db.Where("id = ?", c.Param("id")).First(&schedule); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Schedule not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), schedule.ProjectID, auth.PermSchedulesWrite, "Schedule not found") {
 			return
 		}
 
-		// 将任务ID添加到Redis队列
-		if redisClient != nil {
-			if err := redisClient.LPush(c, "task_queue", task.ID).Err(); err != nil {
-				c.JSON(500, gin.H{"error": "Failed to add task to queue"})
+		updates := map[string]interface{}{"status": status}
+		if status == "active" {
+			nextRunAt, err := task.NextRun(schedule.CronExpr, time.Now())
+			if err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
 				return
 			}
-		} else {
-			// 如果Redis不可用，直接处理任务
-			fmt.Printf("Redis not available, processing task %d directly\n", task.ID)
-			// 这里可以添加直接处理任务的代码
+			updates["next_run_at"] = nextRunAt
 		}
 
-		c.JSON(201, task)
+		if result := db.Model(&schedule).Updates(updates); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to update schedule"})
+			return
+		}
+
+		c.JSON(200, schedule)
 	}
 }
 
-func getTaskHandler(db *gorm.DB) gin.HandlerFunc {
+// deleteScheduleHandler 删除定时任务，已创建的任务保留
+func deleteScheduleHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -537,18 +2452,33 @@ func getTaskHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		id := c.Param("id")
-		var task database.Task
-		if result := db.Where("id = ? AND user_id = ?", id, userID).First(&task); result.Error != nil {
-			c.JSON(404, gin.H{"error": "Task not found"})
+		var schedule database.Schedule
+		if result := db.Where("id = ?", c.Param("id")).First(&schedule); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Schedule not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), schedule.ProjectID, auth.PermSchedulesWrite, "Schedule not found") {
+			return
+		}
+
+		if result := db.Delete(&schedule); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to delete schedule"})
 			return
 		}
 
-		c.JSON(200, task)
+		c.JSON(200, gin.H{"message": "Schedule deleted successfully"})
+	}
+}
+
+// listPlaybookTemplatesHandler 列出内置剧本模板
+func listPlaybookTemplatesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, playbook.Templates)
 	}
 }
 
-func getTaskResultsHandler(db *gorm.DB) gin.HandlerFunc {
+// listPlaybooksHandler 列出当前用户的剧本
+func listPlaybooksHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -556,24 +2486,23 @@ func getTaskResultsHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		id := c.Param("id")
-		var task database.Task
-		if result := db.Where("id = ? AND user_id = ?", id, userID).First(&task); result.Error != nil {
-			c.JSON(404, gin.H{"error": "Task not found"})
+		projectIDs, ok := visibleProjects(c, db, userID.(uint))
+		if !ok {
 			return
 		}
 
-		var results []database.TaskResult
-		if result := db.Where("task_id = ?", id).Find(&results); result.Error != nil {
-			c.JSON(500, gin.H{"error": "Failed to fetch task results"})
+		var playbooks []database.Playbook
+		if result := db.Where("project_id IN ?", projectIDs).Order("id").Find(&playbooks); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch playbooks"})
 			return
 		}
 
-		c.JSON(200, results)
+		c.JSON(200, playbooks)
 	}
 }
 
-func deleteTaskHandler(db *gorm.DB) gin.HandlerFunc {
+// createPlaybookHandler 创建剧本，definition 为剧本定义对象
+func createPlaybookHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -581,41 +2510,70 @@ func deleteTaskHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		id := c.Param("id")
-		var task database.Task
-		if result := db.Where("id = ? AND user_id = ?", id, userID).First(&task); result.Error != nil {
-			c.JSON(404, gin.H{"error": "Task not found"})
+		var input struct {
+			Name        string          `json:"name" binding:"required"`
+			Description string          `json:"description"`
+			Definition  json.RawMessage `json:"definition" binding:"required"`
+			ProjectID   uint            `json:"projectId"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
 
-		// 开始事务
-		tx := db.Begin()
+		if _, err := playbook.Parse(string(input.Definition)); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
 
-		// 删除任务结果
-		if err := tx.Where("task_id = ?", id).Delete(&database.TaskResult{}).Error; err != nil {
-			tx.Rollback()
-			c.JSON(500, gin.H{"error": "Failed to delete task results"})
+		projectID, ok := targetProject(c, db, userID.(uint), input.ProjectID, auth.PermPlaybooksWrite)
+		if !ok {
 			return
 		}
 
-		// 删除任务
-		if err := tx.Delete(&task).Error; err != nil {
-			tx.Rollback()
-			c.JSON(500, gin.H{"error": "Failed to delete task"})
+		pb := database.Playbook{
+			UserID:      userID.(uint),
+			ProjectID:   projectID,
+			Name:        input.Name,
+			Description: input.Description,
+			Definition:  string(input.Definition),
+			CreatedAt:   time.Now().Format(time.RFC3339),
+		}
+
+		if result := db.Create(&pb); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to create playbook"})
 			return
 		}
 
-		// 提交事务
-		if err := tx.Commit().Error; err != nil {
-			c.JSON(500, gin.H{"error": "Failed to commit transaction"})
+		c.JSON(201, pb)
+	}
+}
+
+// getPlaybookHandler 获取剧本详情
+func getPlaybookHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "Task deleted successfully"})
+		var pb database.Playbook
+		if result := db.Where("id = ?", c.Param("id")).First(&pb); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Playbook not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), pb.ProjectID, auth.PermPlaybooksRead, "Playbook not found") {
+			return
+		}
+
+		c.JSON(200, pb)
 	}
 }
 
-func deleteAllTasksHandler(db *gorm.DB) gin.HandlerFunc {
+// deletePlaybookHandler 删除剧本，已有的执行记录保留
+func deletePlaybookHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -623,50 +2581,286 @@ func deleteAllTasksHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// 开始事务
-		tx := db.Begin()
+		var pb database.Playbook
+		if result := db.Where("id = ?", c.Param("id")).First(&pb); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Playbook not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), pb.ProjectID, auth.PermPlaybooksWrite, "Playbook not found") {
+			return
+		}
 
-		// 查找用户的所有任务
-		var tasks []database.Task
-		if result := tx.Where("user_id = ?", userID).Find(&tasks); result.Error != nil {
-			tx.Rollback()
-			c.JSON(500, gin.H{"error": "Failed to fetch tasks"})
+		if result := db.Delete(&pb); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to delete playbook"})
 			return
 		}
 
-		// 收集所有任务ID
-		var taskIDs []uint
-		for _, task := range tasks {
-			taskIDs = append(taskIDs, task.ID)
+		c.JSON(200, gin.H{"message": "Playbook deleted successfully"})
+	}
+}
+
+// runPlaybookHandler 使用指定凭证执行剧本
+func runPlaybookHandler(db *gorm.DB, engine *playbook.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
 		}
 
-		// 删除所有任务结果
-		if len(taskIDs) > 0 {
-			if err := tx.Where("task_id IN ?", taskIDs).Delete(&database.TaskResult{}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(500, gin.H{"error": "Failed to delete task results"})
+		var input struct {
+			CredentialID uint `json:"credentialId" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		var pb database.Playbook
+		if result := db.Where("id = ?", c.Param("id")).First(&pb); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Playbook not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), pb.ProjectID, auth.PermPlaybooksWrite, "Playbook not found") {
+			return
+		}
+
+		// 剧本中的每个步骤都需要有对应任务类型的权限
+		def, err := playbook.Parse(pb.Definition)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		for _, step := range def.Steps {
+			if !checkTaskPermission(c, step.TaskType) {
 				return
 			}
+		}
 
-			// 删除所有任务
-			if err := tx.Where("user_id = ?", userID).Delete(&database.Task{}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(500, gin.H{"error": "Failed to delete tasks"})
+		// 剧本只能使用同一项目中的凭证
+		credential, ok := loadCredential(c, db, userID.(uint), input.CredentialID, auth.PermPlaybooksWrite)
+		if !ok {
+			return
+		}
+		if credential.ProjectID != pb.ProjectID {
+			c.JSON(400, gin.H{"error": "Credential belongs to a different project"})
+			return
+		}
+
+		run, err := engine.Run(c.Request.Context(), &pb, userID.(uint), input.CredentialID)
+		if errors.Is(err, playbook.ErrNoApprover) {
+			c.JSON(409, gin.H{"error": "Playbook has steps that need approval, but no admin or other project owner can approve them"})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to run playbook: " + err.Error()})
+			return
+		}
+
+		c.JSON(201, run)
+	}
+}
+
+// listPlaybookRunsHandler 列出当前用户的剧本执行记录，可通过 playbookId 过滤
+func listPlaybookRunsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		projectIDs, ok := visibleProjects(c, db, userID.(uint))
+		if !ok {
+			return
+		}
+
+		query := db.Where("project_id IN ?", projectIDs)
+		if playbookID := c.Query("playbookId"); playbookID != "" {
+			query = query.Where("playbook_id = ?", playbookID)
+		}
+
+		var runs []database.PlaybookRun
+		if result := query.Order("id desc").Find(&runs); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch playbook runs"})
+			return
+		}
+
+		c.JSON(200, runs)
+	}
+}
+
+// getPlaybookRunHandler 获取剧本执行详情及各步骤状态
+func getPlaybookRunHandler(db *gorm.DB, engine *playbook.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var run database.PlaybookRun
+		if result := db.Where("id = ?", c.Param("id")).First(&run); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Playbook run not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), run.ProjectID, auth.PermPlaybooksRead, "Playbook run not found") {
+			return
+		}
+
+		// 查询时顺带推进一次，步骤状态不必等待下一次定时检查
+		if err := engine.Advance(c.Request.Context(), run.ID); err != nil {
+			fmt.Printf("Error advancing playbook run %d: %v\n", run.ID, err)
+		}
+		db.First(&run, run.ID)
+
+		var steps []database.PlaybookStep
+		if result := db.Where("run_id = ?", run.ID).Order("id").Find(&steps); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch playbook steps"})
+			return
+		}
+
+		c.JSON(200, gin.H{"run": run, "steps": steps})
+	}
+}
+
+// reviewPlaybookStepHandler 批准或拒绝等待审批的步骤
+func reviewPlaybookStepHandler(db *gorm.DB, engine *playbook.Engine, approve bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var run database.PlaybookRun
+		if result := db.Where("id = ?", c.Param("id")).First(&run); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Playbook run not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), run.ProjectID, auth.PermPlaybooksApprove, "Playbook run not found") {
+			return
+		}
+		// 审批需要由发起者以外的全局管理员或项目所有者完成，发起者可以拒绝自己的步骤
+		if approve && run.UserID == userID.(uint) {
+			c.JSON(403, gin.H{"error": "Approval must come from someone other than the user who started the run"})
+			return
+		}
+		if approve {
+			ok, err := project.CanApprove(db, userID.(uint), c.GetString("role"), run.ProjectID)
+			if err != nil {
+				c.JSON(500, gin.H{"error": "Failed to check project access"})
 				return
 			}
+			if !ok {
+				c.JSON(403, gin.H{"error": "Only an admin or a project owner can approve playbook steps"})
+				return
+			}
+		}
+
+		review := engine.Reject
+		if approve {
+			review = engine.Approve
+		}
+		if err := review(c.Request.Context(), run.ID, c.Param("stepId"), userID.(uint)); err != nil {
+			if errors.Is(err, playbook.ErrStepNotWaiting) {
+				c.JSON(409, gin.H{"error": "Step is not waiting for approval"})
+				return
+			}
+			c.JSON(500, gin.H{"error": "Failed to review step: " + err.Error()})
+			return
 		}
 
-		// 提交事务
-		if err := tx.Commit().Error; err != nil {
-			c.JSON(500, gin.H{"error": "Failed to commit transaction"})
-			return
-		}
+		var step database.PlaybookStep
+		db.Where("run_id = ? AND step_id = ?", run.ID, c.Param("stepId")).First(&step)
+
+		c.JSON(200, step)
+	}
+}
+
+// cancelPlaybookRunHandler 取消剧本执行
+func cancelPlaybookRunHandler(db *gorm.DB, engine *playbook.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var run database.PlaybookRun
+		if result := db.Where("id = ?", c.Param("id")).First(&run); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Playbook run not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), run.ProjectID, auth.PermPlaybooksWrite, "Playbook run not found") {
+			return
+		}
+
+		if task.IsTerminal(run.Status) {
+			c.JSON(409, gin.H{"error": "Playbook run has already finished"})
+			return
+		}
+
+		if err := engine.Cancel(c.Request.Context(), run.ID); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to cancel playbook run: " + err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"message": "Playbook run cancelled"})
+	}
+}
+
+// runCloudTask 将云操作作为任务提交给 worker 执行
+// 默认立即返回 202 及任务ID；wait=true 时最多等待 cfg.TaskWaitTimeout 秒，任务结束后返回任务及其结果，
+// 超过等待时间仍返回 202，调用方可通过任务接口查询进度和结果。返回 false 表示响应已写入
+// 优先级可通过 priority 参数指定，wait=true 的请求默认使用高优先级
+func runCloudTask(c *gin.Context, db *gorm.DB, queue task.Queue, broker task.Broker, cfg *config.Config, t *database.Task) (*database.Task, map[string]interface{}, bool) {
+	wait := c.Query("wait") == "true"
+	t.Priority = c.Query("priority")
+	if t.Priority == "" {
+		t.Priority = task.PriorityNormal
+		if wait {
+			t.Priority = task.PriorityHigh
+		}
+	}
+	if !task.ValidPriority(t.Priority) {
+		c.JSON(400, gin.H{"error": "Invalid priority"})
+		return nil, nil, false
+	}
+
+	if err := task.Submit(c.Request.Context(), db, queue, t); err != nil {
+		fmt.Printf("Error submitting task: %v\n", err)
+		c.JSON(500, gin.H{"error": "Failed to create task"})
+		return nil, nil, false
+	}
+
+	if !wait {
+		c.JSON(202, gin.H{"message": "Task queued", "task_id": t.ID, "status": t.Status})
+		return nil, nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(cfg.TaskWaitTimeout)*time.Second)
+	defer cancel()
+	finished, err := task.Wait(ctx, db, broker, t.ID)
+	if err != nil {
+		db.First(t, t.ID)
+		c.JSON(202, gin.H{"message": "Task is still running", "task_id": t.ID, "status": t.Status})
+		return nil, nil, false
+	}
 
-		c.JSON(200, gin.H{"message": "All tasks deleted successfully"})
+	// 读取任务结果，失败的任务可能带有部分结果
+	result := map[string]interface{}{}
+	var taskResult database.TaskResult
+	if err := db.Where("task_id = ?", t.ID).Order("id desc").First(&taskResult).Error; err == nil {
+		if err := json.Unmarshal([]byte(taskResult.Result), &result); err != nil {
+			fmt.Printf("Error unmarshaling task result: %v\n", err)
+		}
 	}
+	return finished, result, true
 }
 
-func enumerateResourcesHandler(db *gorm.DB) gin.HandlerFunc {
+func enumerateResourcesHandler(db *gorm.DB, queue task.Queue, broker task.Broker, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -685,10 +2879,9 @@ func enumerateResourcesHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// 验证凭证是否属于该用户
-		var credential database.CloudCredential
-		if result := db.Where("id = ? AND user_id = ?", input.CredentialID, userID).First(&credential); result.Error != nil {
-			c.JSON(404, gin.H{"error": "Credential not found"})
+		// 验证用户在凭证所属项目中的权限
+		credential, ok := loadCredential(c, db, userID.(uint), input.CredentialID, auth.TaskPermission("enumerate"))
+		if !ok {
 			return
 		}
 
@@ -698,54 +2891,27 @@ func enumerateResourcesHandler(db *gorm.DB) gin.HandlerFunc {
 			region = input.Region
 		}
 
-		// 创建云平台实例
-		provider, err := cloud.NewCloudProvider(credential.CloudProvider, credential.AccessKey, credential.SecretKey, region)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create cloud provider: " + err.Error()})
-			return
-		}
-
-		// 枚举资源
-		result, err := provider.EnumerateResources(input.ResourceType)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to enumerate resources: " + err.Error()})
-			return
-		}
-
-		// 将结果保存到数据库
-		// 创建任务记录
 		parameters, _ := json.Marshal(map[string]interface{}{
 			"resource_type": input.ResourceType,
 			"region":        region,
 		})
 
-		task := database.Task{
+		t := database.Task{
 			UserID:       userID.(uint),
+			ProjectID:    credential.ProjectID,
 			CredentialID: input.CredentialID,
 			TaskType:     "enumerate",
-			Status:       "completed",
 			Parameters:   string(parameters),
-			StartTime:    time.Now().Format(time.RFC3339),
-			EndTime:      time.Now().Format(time.RFC3339),
-		}
-
-		if err := db.Create(&task).Error; err != nil {
-			// 记录错误但不影响返回结果
-			fmt.Printf("Failed to create task: %v\n", err)
 		}
 
-		// 创建任务结果记录
-		resultJSON, _ := json.Marshal(result)
-		taskResult := database.TaskResult{
-			TaskID:    task.ID,
-			Result:    string(resultJSON),
-			Error:     "",
-			Timestamp: time.Now().Format(time.RFC3339),
+		// 枚举资源
+		finished, result, ok := runCloudTask(c, db, queue, broker, cfg, &t)
+		if !ok {
+			return
 		}
-
-		if err := db.Create(&taskResult).Error; err != nil {
-			// 记录错误但不影响返回结果
-			fmt.Printf("Failed to create task result: %v\n", err)
+		if finished.Status != "completed" {
+			c.JSON(500, gin.H{"error": "Failed to enumerate resources: " + finished.Error, "task_id": finished.ID})
+			return
 		}
 
 		c.JSON(200, gin.H{
@@ -753,12 +2919,12 @@ func enumerateResourcesHandler(db *gorm.DB) gin.HandlerFunc {
 			"credential":    credential.Name,
 			"resource_type": input.ResourceType,
 			"result":        result,
-			"task_id":       task.ID,
+			"task_id":       finished.ID,
 		})
 	}
 }
 
-func escalatePrivilegesHandler(db *gorm.DB) gin.HandlerFunc {
+func escalatePrivilegesHandler(db *gorm.DB, queue task.Queue, broker task.Broker, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -775,72 +2941,44 @@ func escalatePrivilegesHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// 验证凭证是否属于该用户
-		var credential database.CloudCredential
-		if result := db.Where("id = ? AND user_id = ?", input.CredentialID, userID).First(&credential); result.Error != nil {
-			c.JSON(404, gin.H{"error": "Credential not found"})
-			return
-		}
-
-		// 创建云平台实例
-		provider, err := cloud.NewCloudProvider(credential.CloudProvider, credential.AccessKey, credential.SecretKey, credential.Region)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create cloud provider: " + err.Error()})
-			return
-		}
-
-		// 权限提升
-		result, err := provider.EscalatePrivileges()
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to escalate privileges: " + err.Error()})
+		// 验证用户在凭证所属项目中的权限
+		credential, ok := loadCredential(c, db, userID.(uint), input.CredentialID, auth.TaskPermission("escalate"))
+		if !ok {
 			return
 		}
 
-		// 将结果保存到数据库
-		// 创建任务记录
 		parameters, _ := json.Marshal(map[string]interface{}{
 			"credential_id": input.CredentialID,
 		})
 
-		task := database.Task{
+		t := database.Task{
 			UserID:       userID.(uint),
+			ProjectID:    credential.ProjectID,
 			CredentialID: input.CredentialID,
 			TaskType:     "escalate",
-			Status:       "completed",
 			Parameters:   string(parameters),
-			StartTime:    time.Now().Format(time.RFC3339),
-			EndTime:      time.Now().Format(time.RFC3339),
-		}
-
-		if err := db.Create(&task).Error; err != nil {
-			// 记录错误但不影响返回结果
-			fmt.Printf("Failed to create task: %v\n", err)
 		}
 
-		// 创建任务结果记录
-		resultJSON, _ := json.Marshal(result)
-		taskResult := database.TaskResult{
-			TaskID:    task.ID,
-			Result:    string(resultJSON),
-			Error:     "",
-			Timestamp: time.Now().Format(time.RFC3339),
+		// 权限提升
+		finished, result, ok := runCloudTask(c, db, queue, broker, cfg, &t)
+		if !ok {
+			return
 		}
-
-		if err := db.Create(&taskResult).Error; err != nil {
-			// 记录错误但不影响返回结果
-			fmt.Printf("Failed to create task result: %v\n", err)
+		if finished.Status != "completed" {
+			c.JSON(500, gin.H{"error": "Failed to escalate privileges: " + finished.Error, "task_id": finished.ID})
+			return
 		}
 
 		c.JSON(200, gin.H{
 			"message":    "Privilege escalation completed",
 			"credential": credential.Name,
 			"result":     result,
-			"task_id":    task.ID,
+			"task_id":    finished.ID,
 		})
 	}
 }
 
-func operateResourceHandler(db *gorm.DB) gin.HandlerFunc {
+func operateResourceHandler(db *gorm.DB, queue task.Queue, broker task.Broker, keyring *secrets.Keyring, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -861,130 +2999,77 @@ func operateResourceHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// 验证凭证是否属于该用户
-		var credential database.CloudCredential
-		if result := db.Where("id = ? AND user_id = ?", input.CredentialID, userID).First(&credential); result.Error != nil {
-			c.JSON(404, gin.H{"error": "Credential not found"})
+		// 验证用户在凭证所属项目中的权限
+		credential, ok := loadCredential(c, db, userID.(uint), input.CredentialID, auth.TaskPermission("operate"))
+		if !ok {
 			return
 		}
 
-		// 创建云平台实例
-		provider, err := cloud.NewCloudProvider(credential.CloudProvider, credential.AccessKey, credential.SecretKey, credential.Region)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create cloud provider: " + err.Error()})
-			return
+		// 操作参数与任务参数合并保存，worker 执行时原样传给 OperateResource
+		params := map[string]interface{}{}
+		for k, v := range input.Params {
+			params[k] = v
 		}
+		params["resource_type"] = input.ResourceType
+		params["action"] = input.Action
+		params["resource_id"] = input.ResourceID
+		parameters, _ := json.Marshal(params)
 
-		// 为EC2命令执行准备任务记录
-	var taskID uint
-	if input.ResourceType == "ec2" && input.Action == "execute_command" {
-		// 将参数保存到数据库
-		// 创建任务记录
-		parameters, _ := json.Marshal(map[string]interface{}{
-			"resource_type": input.ResourceType,
-			"action":        input.Action,
-			"resource_id":   input.ResourceID,
-			"params":        input.Params,
-		})
-
-		task := database.Task{
+		t := database.Task{
 			UserID:       userID.(uint),
+			ProjectID:    credential.ProjectID,
 			CredentialID: input.CredentialID,
 			TaskType:     "operate",
-			Status:       "running",
 			Parameters:   string(parameters),
-			StartTime:    time.Now().Format(time.RFC3339),
 		}
 
-		if err := db.Create(&task).Error; err != nil {
-			// 记录错误但不影响返回结果
-			fmt.Printf("Failed to create task: %v\n", err)
-		} else {
-			taskID = task.ID
+		// 资源操作
+		finished, result, ok := runCloudTask(c, db, queue, broker, cfg, &t)
+		if !ok {
+			return
 		}
-	}
-
-	// 资源操作
-	result, err := provider.OperateResource(input.ResourceType, input.Action, input.ResourceID, input.Params)
-
-	// 为EC2命令执行更新任务记录
-	if input.ResourceType == "ec2" && input.Action == "execute_command" && taskID > 0 {
-		// 更新任务状态
-		status := "completed"
-		errorMessage := ""
-		if err != nil {
-			status = "failed"
-			errorMessage = err.Error()
+		// 联邦登录的临时凭证加密保存，返回给提交者时解密
+		if err := task.RevealSecrets(keyring, result, true); err != nil {
+			fmt.Printf("Error revealing task result: %v\n", err)
 		}
 
-		task := database.Task{}
-		if err := db.First(&task, taskID).Error; err == nil {
-			task.Status = status
-			task.EndTime = time.Now().Format(time.RFC3339)
-			if err := db.Save(&task).Error; err != nil {
-				fmt.Printf("Failed to update task: %v\n", err)
-			}
+		// 超出项目授权范围的操作被拒绝，返回拒绝原因
+		if finished.ErrorClass == task.ErrorClassOutOfScope {
+			c.JSON(403, gin.H{"error": "Action is out of engagement scope: " + finished.Error, "task_id": finished.ID})
+			return
 		}
 
-		// 创建任务结果记录
-		resultJSON, _ := json.Marshal(result)
-		taskResult := database.TaskResult{
-			TaskID:    taskID,
-			Result:    string(resultJSON),
-			Error:     errorMessage,
-			Timestamp: time.Now().Format(time.RFC3339),
+		// 目标环境在批准后发生变化，返回已批准的计划和当前计划供重新审批
+		if finished.ErrorClass == task.ErrorClassPlanChanged {
+			c.JSON(409, gin.H{"error": finished.Error, "task_id": finished.ID, "result": result})
+			return
 		}
 
-		if err := db.Create(&taskResult).Error; err != nil {
-			// 记录错误但不影响返回结果
-			fmt.Printf("Failed to create task result: %v\n", err)
+		// EC2命令执行失败时仍返回已执行的步骤（executionSteps）
+		if finished.Status != "completed" && !(input.ResourceType == "ec2" && input.Action == "execute_command" && len(result) > 0) {
+			c.JSON(500, gin.H{"error": "Failed to operate resource: " + finished.Error, "task_id": finished.ID})
+			return
 		}
-	}
 
-	if err != nil {
-		// 检查是否是EC2命令执行错误，如果是，返回result（包含executionSteps）
-		if input.ResourceType == "ec2" && input.Action == "execute_command" && result != nil {
-			c.JSON(200, gin.H{
-				"message":       "Resource operation completed",
-				"credential":    credential.Name,
-				"resource_type": input.ResourceType,
-				"action":        input.Action,
-				"resource_id":   input.ResourceID,
-				"result":        result,
-				"task_id":       taskID,
-			})
-			return
+		// dry_run 只生成执行计划，批准后以 approved_plan 参数引用该任务ID执行
+		message := "Resource operation completed"
+		if plan.IsDryRun(params) {
+			message = "Plan generated, another user must approve it before its task_id can be passed as params.approved_plan"
 		}
-		c.JSON(500, gin.H{"error": "Failed to operate resource: " + err.Error(), "task_id": taskID})
-		return
-	}
 
-	// 添加任务ID到响应
-	if input.ResourceType == "ec2" && input.Action == "execute_command" {
 		c.JSON(200, gin.H{
-			"message":       "Resource operation completed",
+			"message":       message,
 			"credential":    credential.Name,
 			"resource_type": input.ResourceType,
 			"action":        input.Action,
 			"resource_id":   input.ResourceID,
 			"result":        result,
-			"task_id":       taskID,
-		})
-		return
-	}
-
-	c.JSON(200, gin.H{
-		"message":       "Resource operation completed",
-		"credential":    credential.Name,
-		"resource_type": input.ResourceType,
-		"action":        input.Action,
-		"resource_id":   input.ResourceID,
-		"result":        result,
+			"task_id":       finished.ID,
 		})
 	}
 }
 
-func takeoverCloudHandler(db *gorm.DB) gin.HandlerFunc {
+func takeoverCloudHandler(db *gorm.DB, queue task.Queue, broker task.Broker, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -1001,24 +3086,27 @@ func takeoverCloudHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// 验证凭证是否属于该用户
-		var credential database.CloudCredential
-		if result := db.Where("id = ? AND user_id = ?", input.CredentialID, userID).First(&credential); result.Error != nil {
-			c.JSON(404, gin.H{"error": "Credential not found"})
+		// 验证用户在凭证所属项目中的权限
+		credential, ok := loadCredential(c, db, userID.(uint), input.CredentialID, auth.TaskPermission("takeover"))
+		if !ok {
 			return
 		}
 
-		// 创建云平台实例
-		provider, err := cloud.NewCloudProvider(credential.CloudProvider, credential.AccessKey, credential.SecretKey, credential.Region)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create cloud provider: " + err.Error()})
-			return
+		t := database.Task{
+			UserID:       userID.(uint),
+			ProjectID:    credential.ProjectID,
+			CredentialID: input.CredentialID,
+			TaskType:     "takeover",
+			Parameters:   "{}",
 		}
 
 		// 平台接管
-		result, err := provider.Takeover()
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to takeover cloud platform: " + err.Error()})
+		finished, result, ok := runCloudTask(c, db, queue, broker, cfg, &t)
+		if !ok {
+			return
+		}
+		if finished.Status != "completed" {
+			c.JSON(500, gin.H{"error": "Failed to takeover cloud platform: " + finished.Error, "task_id": finished.ID})
 			return
 		}
 
@@ -1026,6 +3114,7 @@ func takeoverCloudHandler(db *gorm.DB) gin.HandlerFunc {
 			"message":    "Cloud platform takeover completed",
 			"credential": credential.Name,
 			"result":     result,
+			"task_id":    finished.ID,
 		})
 	}
 }
@@ -1048,34 +3137,33 @@ func getResourcesFromDatabaseHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// 验证凭证是否属于该用户
-		var credential database.CloudCredential
-		if result := db.Where("id = ? AND user_id = ?", input.CredentialID, userID).First(&credential); result.Error != nil {
-			c.JSON(404, gin.H{"error": "Credential not found"})
+		// 验证用户在凭证所属项目中的权限
+		credential, ok := loadCredential(c, db, userID.(uint), input.CredentialID, auth.PermCloudRead)
+		if !ok {
 			return
 		}
 
 		// 查找最新的枚举任务
 		var task database.Task
-		if result := db.Where("user_id = ? AND credential_id = ? AND task_type = ? AND status = ?", userID, input.CredentialID, "enumerate", "completed").Order("end_time DESC").First(&task); result.Error != nil {
+		if result := db.Where("credential_id = ? AND task_type = ? AND status = ?", input.CredentialID, "enumerate", "completed").Order("end_time DESC").First(&task); result.Error != nil {
 			// 找不到枚举任务，返回默认的空资源结构
 			c.JSON(200, gin.H{
 				"message":    "No enumeration task found",
 				"credential": credential.Name,
 				"result": map[string]interface{}{
-					"instances":     []interface{}{},
-					"buckets":       []interface{}{},
-					"roles":         []interface{}{},
-					"users":         []interface{}{},
-					"vpcs":          []interface{}{},
-					"routeTables":   []interface{}{},
-					"elbs":          []interface{}{},
-					"eksClusters":   []interface{}{},
-					"kmsKeys":       []interface{}{},
-					"rdsInstances":  []interface{}{},
+					"instances":    []interface{}{},
+					"buckets":      []interface{}{},
+					"roles":        []interface{}{},
+					"users":        []interface{}{},
+					"vpcs":         []interface{}{},
+					"routeTables":  []interface{}{},
+					"elbs":         []interface{}{},
+					"eksClusters":  []interface{}{},
+					"kmsKeys":      []interface{}{},
+					"rdsInstances": []interface{}{},
 				},
-				"task_id":    0,
-				"timestamp":  "",
+				"task_id":   0,
+				"timestamp": "",
 			})
 			return
 		}
@@ -1088,19 +3176,19 @@ func getResourcesFromDatabaseHandler(db *gorm.DB) gin.HandlerFunc {
 				"message":    "Task result not found",
 				"credential": credential.Name,
 				"result": map[string]interface{}{
-					"instances":     []interface{}{},
-					"buckets":       []interface{}{},
-					"roles":         []interface{}{},
-					"users":         []interface{}{},
-					"vpcs":          []interface{}{},
-					"routeTables":   []interface{}{},
-					"elbs":          []interface{}{},
-					"eksClusters":   []interface{}{},
-					"kmsKeys":       []interface{}{},
-					"rdsInstances":  []interface{}{},
+					"instances":    []interface{}{},
+					"buckets":      []interface{}{},
+					"roles":        []interface{}{},
+					"users":        []interface{}{},
+					"vpcs":         []interface{}{},
+					"routeTables":  []interface{}{},
+					"elbs":         []interface{}{},
+					"eksClusters":  []interface{}{},
+					"kmsKeys":      []interface{}{},
+					"rdsInstances": []interface{}{},
 				},
-				"task_id":    task.ID,
-				"timestamp":  task.EndTime,
+				"task_id":   task.ID,
+				"timestamp": task.EndTime,
 			})
 			return
 		}
@@ -1113,19 +3201,19 @@ func getResourcesFromDatabaseHandler(db *gorm.DB) gin.HandlerFunc {
 				"message":    "Failed to parse task result",
 				"credential": credential.Name,
 				"result": map[string]interface{}{
-					"instances":     []interface{}{},
-					"buckets":       []interface{}{},
-					"roles":         []interface{}{},
-					"users":         []interface{}{},
-					"vpcs":          []interface{}{},
-					"routeTables":   []interface{}{},
-					"elbs":          []interface{}{},
-					"eksClusters":   []interface{}{},
-					"kmsKeys":       []interface{}{},
-					"rdsInstances":  []interface{}{},
+					"instances":    []interface{}{},
+					"buckets":      []interface{}{},
+					"roles":        []interface{}{},
+					"users":        []interface{}{},
+					"vpcs":         []interface{}{},
+					"routeTables":  []interface{}{},
+					"elbs":         []interface{}{},
+					"eksClusters":  []interface{}{},
+					"kmsKeys":      []interface{}{},
+					"rdsInstances": []interface{}{},
 				},
-				"task_id":    task.ID,
-				"timestamp":  task.EndTime,
+				"task_id":   task.ID,
+				"timestamp": task.EndTime,
 			})
 			return
 		}
@@ -1140,6 +3228,161 @@ func getResourcesFromDatabaseHandler(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// federationTokenStatus 返回令牌当前状态，已过期的有效令牌视为 expired
+func federationTokenStatus(token database.FederationToken) string {
+	if token.Status != "active" || token.Expiration == "" {
+		return token.Status
+	}
+	expiration, err := time.Parse(time.RFC3339, token.Expiration)
+	if err == nil && time.Now().After(expiration) {
+		return "expired"
+	}
+	return token.Status
+}
+
+// 获取联邦令牌签发记录
+func listFederationTokensHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		projectIDs, ok := visibleProjects(c, db, userID.(uint))
+		if !ok {
+			return
+		}
+
+		query := db.Where("project_id IN ?", projectIDs)
+		if credentialID := c.Query("credential_id"); credentialID != "" {
+			query = query.Where("credential_id = ?", credentialID)
+		}
+
+		var tokens []database.FederationToken
+		if result := query.Order("id DESC").Find(&tokens); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch federation tokens"})
+			return
+		}
+
+		// 按状态筛选并统计
+		status := c.Query("status")
+		summary := gin.H{"total": 0, "active": 0, "expired": 0, "revoked": 0}
+		filtered := []database.FederationToken{}
+		for _, token := range tokens {
+			token.Status = federationTokenStatus(token)
+			if status != "" && token.Status != status {
+				continue
+			}
+			summary["total"] = summary["total"].(int) + 1
+			if count, ok := summary[token.Status].(int); ok {
+				summary[token.Status] = count + 1
+			}
+			filtered = append(filtered, token)
+		}
+
+		c.JSON(200, gin.H{
+			"tokens":  filtered,
+			"summary": summary,
+		})
+	}
+}
+
+// 撤销联邦令牌
+func revokeFederationTokenHandler(db *gorm.DB, queue task.Queue, broker task.Broker, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		// params 可以指定 dry_run 或 approved_plan，与资源操作相同
+		var input struct {
+			Params map[string]interface{} `json:"params"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		id := c.Param("id")
+		var token database.FederationToken
+		if result := db.Where("id = ?", id).First(&token); result.Error != nil {
+			c.JSON(404, gin.H{"error": "Federation token not found"})
+			return
+		}
+		if !authorizeProject(c, db, userID.(uint), token.ProjectID, auth.PermFederationRevoke, "Federation token not found") {
+			return
+		}
+
+		if token.Status == "revoked" {
+			c.JSON(400, gin.H{"error": "Federation token already revoked"})
+			return
+		}
+
+		// 目前只有AWS支持按会话名称撤销已签发的令牌
+		if token.CloudProvider != "AWS" {
+			c.JSON(400, gin.H{"error": "Revocation is only supported for AWS federation tokens"})
+			return
+		}
+
+		credential, ok := loadCredential(c, db, userID.(uint), token.CredentialID, auth.PermFederationRevoke)
+		if !ok {
+			return
+		}
+
+		// 撤销作为资源操作任务执行，worker 成功撤销后标记同一会话名称的令牌
+		params := map[string]interface{}{}
+		for k, v := range input.Params {
+			params[k] = v
+		}
+		params["resource_type"] = "sts"
+		params["action"] = "revoke_federation_token"
+		params["resource_id"] = token.AccessKey
+		params["session_name"] = token.SessionName
+		params["federation_token_id"] = token.ID
+		parameters, _ := json.Marshal(params)
+
+		t := database.Task{
+			UserID:       userID.(uint),
+			ProjectID:    credential.ProjectID,
+			CredentialID: credential.ID,
+			TaskType:     "operate",
+			Parameters:   string(parameters),
+		}
+
+		finished, result, ok := runCloudTask(c, db, queue, broker, cfg, &t)
+		if !ok {
+			return
+		}
+		if finished.ErrorClass == task.ErrorClassOutOfScope {
+			c.JSON(403, gin.H{"error": "Action is out of engagement scope: " + finished.Error, "task_id": finished.ID})
+			return
+		}
+		if finished.ErrorClass == task.ErrorClassPlanChanged {
+			c.JSON(409, gin.H{"error": finished.Error, "task_id": finished.ID, "result": result})
+			return
+		}
+		if finished.Status != "completed" {
+			c.JSON(500, gin.H{"error": "Failed to revoke federation token: " + finished.Error, "task_id": finished.ID})
+			return
+		}
+
+		message := "Federation token revoked"
+		if plan.IsDryRun(params) {
+			message = "Plan generated, another user must approve it before its task_id can be passed as params.approved_plan"
+		}
+		c.JSON(200, gin.H{
+			"message": message,
+			"result":  result,
+			"task_id": finished.ID,
+		})
+	}
+}
+
 // 从数据库获取权限信息
 func getPermissionsFromDatabaseHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -1158,16 +3401,15 @@ func getPermissionsFromDatabaseHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// 验证凭证是否属于该用户
-		var credential database.CloudCredential
-		if result := db.Where("id = ? AND user_id = ?", input.CredentialID, userID).First(&credential); result.Error != nil {
-			c.JSON(404, gin.H{"error": "Credential not found"})
+		// 验证用户在凭证所属项目中的权限
+		credential, ok := loadCredential(c, db, userID.(uint), input.CredentialID, auth.PermCloudRead)
+		if !ok {
 			return
 		}
 
 		// 查找最新的权限提升任务
 		var task database.Task
-		if result := db.Where("user_id = ? AND credential_id = ? AND task_type = ? AND status = ?", userID, input.CredentialID, "escalate", "completed").Order("end_time DESC").First(&task); result.Error != nil {
+		if result := db.Where("credential_id = ? AND task_type = ? AND status = ?", input.CredentialID, "escalate", "completed").Order("end_time DESC").First(&task); result.Error != nil {
 			// 找不到权限提升任务，返回空权限结构
 			c.JSON(200, gin.H{
 				"message":    "No escalation task found",
@@ -1226,12 +3468,17 @@ func getTaskStatsHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		projectIDs, ok := visibleProjects(c, db, userID.(uint))
+		if !ok {
+			return
+		}
+
 		// 统计任务数量
 		var total, success, failed, running int64
-		db.Model(&database.Task{}).Where("user_id = ?", userID).Count(&total)
-		db.Model(&database.Task{}).Where("user_id = ? AND status = ?", userID, "completed").Count(&success)
-		db.Model(&database.Task{}).Where("user_id = ? AND status = ?", userID, "failed").Count(&failed)
-		db.Model(&database.Task{}).Where("user_id = ? AND status = ?", userID, "running").Count(&running)
+		db.Model(&database.Task{}).Where("project_id IN ?", projectIDs).Count(&total)
+		db.Model(&database.Task{}).Where("project_id IN ? AND status = ?", projectIDs, "completed").Count(&success)
+		db.Model(&database.Task{}).Where("project_id IN ? AND status = ?", projectIDs, "failed").Count(&failed)
+		db.Model(&database.Task{}).Where("project_id IN ? AND status = ?", projectIDs, "running").Count(&running)
 
 		successRate := float64(0)
 		if total > 0 {
@@ -1339,6 +3586,75 @@ func getResourceStatsHandler(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// 按标准化能力对比各凭证的权限，数据来自每个凭证最新的权限提升任务
+func getCapabilityComparisonHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		projectIDs, ok := visibleProjects(c, db, userID.(uint))
+		if !ok {
+			return
+		}
+
+		var credentials []database.CloudCredential
+		if result := db.Where("project_id IN ?", projectIDs).Find(&credentials); result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch credentials"})
+			return
+		}
+
+		comparison := []gin.H{}
+		for _, credential := range credentials {
+			item := gin.H{
+				"credential_id":  credential.ID,
+				"credential":     credential.Name,
+				"cloud_provider": credential.CloudProvider,
+				"capabilities":   []string{},
+				"riskScore":      0,
+				"riskLevel":      "Unknown",
+				"timestamp":      "",
+			}
+
+			var task database.Task
+			if result := db.Where("credential_id = ? AND task_type = ? AND status = ?", credential.ID, "escalate", "completed").Order("end_time DESC").First(&task); result.Error != nil {
+				comparison = append(comparison, item)
+				continue
+			}
+
+			var taskResult database.TaskResult
+			if result := db.Where("task_id = ?", task.ID).First(&taskResult); result.Error != nil {
+				comparison = append(comparison, item)
+				continue
+			}
+
+			var result struct {
+				Capabilities []string `json:"capabilities"`
+			}
+			if err := json.Unmarshal([]byte(taskResult.Result), &result); err != nil {
+				comparison = append(comparison, item)
+				continue
+			}
+
+			// 旧的任务结果没有能力字段，按未知处理
+			if result.Capabilities != nil {
+				item["capabilities"] = result.Capabilities
+				item["riskScore"] = capability.Score(result.Capabilities)
+				item["riskLevel"] = capability.RiskLevel(result.Capabilities)
+			}
+			item["timestamp"] = task.EndTime
+			comparison = append(comparison, item)
+		}
+
+		c.JSON(200, gin.H{
+			"definitions": capability.Definitions,
+			"credentials": comparison,
+		})
+	}
+}
+
 func getRecentFindingsHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		_, exists := c.Get("userID")
@@ -1396,7 +3712,7 @@ func getRecentFindingsHandler(db *gorm.DB) gin.HandlerFunc {
 }
 
 // 获取用户信息
-func getUserInfoHandler(db *gorm.DB) gin.HandlerFunc {
+func getUserInfoHandler(db *gorm.DB, queue task.Queue, broker task.Broker, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -1413,24 +3729,27 @@ func getUserInfoHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// 验证凭证是否属于该用户
-		var credential database.CloudCredential
-		if result := db.Where("id = ? AND user_id = ?", input.CredentialID, userID).First(&credential); result.Error != nil {
-			c.JSON(404, gin.H{"error": "Credential not found"})
+		// 验证用户在凭证所属项目中的权限
+		credential, ok := loadCredential(c, db, userID.(uint), input.CredentialID, auth.TaskPermission("userinfo"))
+		if !ok {
 			return
 		}
 
-		// 创建云平台实例
-		provider, err := cloud.NewCloudProvider(credential.CloudProvider, credential.AccessKey, credential.SecretKey, credential.Region)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create cloud provider: " + err.Error()})
-			return
+		t := database.Task{
+			UserID:       userID.(uint),
+			ProjectID:    credential.ProjectID,
+			CredentialID: input.CredentialID,
+			TaskType:     "userinfo",
+			Parameters:   "{}",
 		}
 
 		// 获取用户信息
-		result, err := provider.GetPermissions()
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to get user info: " + err.Error()})
+		finished, result, ok := runCloudTask(c, db, queue, broker, cfg, &t)
+		if !ok {
+			return
+		}
+		if finished.Status != "completed" {
+			c.JSON(500, gin.H{"error": "Failed to get user info: " + finished.Error, "task_id": finished.ID})
 			return
 		}
 
@@ -1438,12 +3757,13 @@ func getUserInfoHandler(db *gorm.DB) gin.HandlerFunc {
 			"message":    "User info retrieved",
 			"credential": credential.Name,
 			"result":     result,
+			"task_id":    finished.ID,
 		})
 	}
 }
 
 // 下载文件到指定目录
-func downloadFileHandler(db *gorm.DB) gin.HandlerFunc {
+func downloadFileHandler(db *gorm.DB, queue task.Queue, broker task.Broker, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -1451,13 +3771,6 @@ func downloadFileHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// 获取配置
-		cfg, exists := c.MustGet("config").(*config.Config)
-		if !exists {
-			c.JSON(500, gin.H{"error": "Config not found"})
-			return
-		}
-
 		var input struct {
 			CredentialID uint   `json:"credential_id" binding:"required"`
 			Bucket       string `json:"bucket" binding:"required"`
@@ -1469,76 +3782,32 @@ func downloadFileHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// 验证凭证是否属于该用户
-		var credential database.CloudCredential
-		if result := db.Where("id = ? AND user_id = ?", input.CredentialID, userID).First(&credential); result.Error != nil {
-			c.JSON(404, gin.H{"error": "Credential not found"})
-			return
-		}
-
-		// 创建云平台实例
-		provider, err := cloud.NewCloudProvider(credential.CloudProvider, credential.AccessKey, credential.SecretKey, credential.Region)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create cloud provider: " + err.Error()})
-			return
-		}
-
-		// 调用下载操作
-		result, err := provider.OperateResource("s3", "download", input.Bucket, map[string]interface{}{"key": input.Key})
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to download file: " + err.Error()})
-			return
-		}
-
-		// 获取下载URL
-		downloadURL, ok := result["download_url"].(string)
+		// 验证用户在凭证所属项目中的权限
+		credential, ok := loadCredential(c, db, userID.(uint), input.CredentialID, auth.TaskPermission("download"))
 		if !ok {
-			c.JSON(500, gin.H{"error": "Failed to get download URL"})
 			return
 		}
 
-		// 创建下载目录结构
-		downloadDir := cfg.DownloadPath + "/" + input.Bucket
-		if err := os.MkdirAll(downloadDir, 0755); err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create download directory: " + err.Error()})
-			return
-		}
+		parameters, _ := json.Marshal(map[string]interface{}{
+			"bucket": input.Bucket,
+			"key":    input.Key,
+		})
 
-		// 构建文件路径
-		filePath := downloadDir + "/" + input.Key
-		// 确保目录存在
-		fileDir := filepath.Dir(filePath)
-		if err := os.MkdirAll(fileDir, 0755); err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create file directory: " + err.Error()})
-			return
+		t := database.Task{
+			UserID:       userID.(uint),
+			ProjectID:    credential.ProjectID,
+			CredentialID: input.CredentialID,
+			TaskType:     "download",
+			Parameters:   string(parameters),
 		}
 
 		// 下载文件
-		resp, err := http.Get(downloadURL)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to download file: " + err.Error()})
-			return
-		}
-		defer resp.Body.Close()
-
-		// 检查响应状态
-		if resp.StatusCode != http.StatusOK {
-			c.JSON(500, gin.H{"error": "Failed to download file: HTTP " + resp.Status})
-			return
-		}
-
-		// 创建文件
-		out, err := os.Create(filePath)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create file: " + err.Error()})
+		finished, result, ok := runCloudTask(c, db, queue, broker, cfg, &t)
+		if !ok {
 			return
 		}
-		defer out.Close()
-
-		// 写入文件
-		_, err = io.Copy(out, resp.Body)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to write file: " + err.Error()})
+		if finished.Status != "completed" {
+			c.JSON(500, gin.H{"error": "Failed to download file: " + finished.Error, "task_id": finished.ID})
 			return
 		}
 
@@ -1547,8 +3816,9 @@ func downloadFileHandler(db *gorm.DB) gin.HandlerFunc {
 			"message":  "File downloaded successfully",
 			"bucket":   input.Bucket,
 			"key":      input.Key,
-			"path":     filePath,
+			"path":     result["path"],
 			"basePath": cfg.DownloadPath,
+			"task_id":  finished.ID,
 		})
 	}
 }
@@ -0,0 +1,167 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redteamsec/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// 审计记录的执行结果
+const (
+	ResultSuccess   = "success"
+	ResultFailed    = "failed"
+	ResultRefused   = "refused" // 权限不足或超出授权范围，未调用云平台
+	ResultCancelled = "cancelled"
+)
+
+// redacted 替换敏感参数值的占位符
+const redacted = "[REDACTED]"
+
+// sensitiveKeys 参数名（小写）包含这些片段时视为敏感信息
+var sensitiveKeys = []string{"secret", "password", "passwd", "token", "private_key", "privatekey", "session_key"}
+
+// mu 串行化本进程内的写入，多进程写入时由 PrevHash 的唯一索引保证哈希链不分叉
+var mu sync.Mutex
+
+// maxAppendAttempts 其他进程抢先追加时的重试次数
+const maxAppendAttempts = 5
+
+// Record 追加一条审计记录，参数中的敏感信息在写入前脱敏
+func Record(db *gorm.DB, entry database.AuditLog) (*database.AuditLog, error) {
+	if entry.Timestamp == "" {
+		entry.Timestamp = time.Now().Format(time.RFC3339)
+	}
+	entry.Parameters = RedactJSON(entry.Parameters)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < maxAppendAttempts; attempt++ {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var last database.AuditLog
+			result := tx.Select("hash").Order("id DESC").Limit(1).Find(&last)
+			if result.Error != nil {
+				return result.Error
+			}
+			entry.ID = 0
+			entry.PrevHash = last.Hash
+			entry.Hash = Hash(&entry)
+			return tx.Create(&entry).Error
+		})
+		if err == nil {
+			return &entry, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to append audit log: %w", err)
+}
+
+// Hash 计算记录的哈希，包含 PrevHash 和除 ID、Hash 以外的全部字段
+func Hash(entry *database.AuditLog) string {
+	content, _ := json.Marshal([]interface{}{
+		entry.PrevHash,
+		entry.Timestamp,
+		entry.ActorID,
+		entry.Actor,
+		entry.ProjectID,
+		entry.CredentialID,
+		entry.Provider,
+		entry.TaskID,
+		entry.Action,
+		entry.Target,
+		entry.Parameters,
+		entry.Result,
+		entry.Error,
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verification 哈希链校验结果
+type Verification struct {
+	Entries  int    `json:"entries"`
+	Valid    bool   `json:"valid"`
+	BrokenAt uint   `json:"brokenAt,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Verify 按顺序重新计算全部记录的哈希，返回第一条被篡改或断链的记录
+func Verify(db *gorm.DB) (*Verification, error) {
+	v := &Verification{Valid: true}
+	prevHash := ""
+	var entries []database.AuditLog
+	result := db.Order("id").FindInBatches(&entries, 500, func(tx *gorm.DB, batch int) error {
+		for i := range entries {
+			entry := &entries[i]
+			v.Entries++
+			switch {
+			case entry.PrevHash != prevHash:
+				v.Valid, v.BrokenAt, v.Reason = false, entry.ID, "previous hash does not match, an entry was removed or reordered"
+			case Hash(entry) != entry.Hash:
+				v.Valid, v.BrokenAt, v.Reason = false, entry.ID, "entry content does not match its hash"
+			}
+			if !v.Valid {
+				return errStop
+			}
+			prevHash = entry.Hash
+		}
+		return nil
+	})
+	if result.Error != nil && !errors.Is(result.Error, errStop) {
+		return nil, fmt.Errorf("failed to verify audit log: %w", result.Error)
+	}
+	return v, nil
+}
+
+// errStop 发现断链后停止遍历
+var errStop = errors.New("stop")
+
+// RedactJSON 对 JSON 参数中的敏感字段脱敏，无法解析时整体替换
+func RedactJSON(data string) string {
+	if strings.TrimSpace(data) == "" {
+		return data
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		return redacted
+	}
+	out, _ := json.Marshal(redact(value))
+	return string(out)
+}
+
+// redact 递归替换敏感字段的值
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if isSensitive(key) {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redact(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redact(item)
+		}
+	}
+	return value
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, fragment := range sensitiveKeys {
+		if strings.Contains(key, fragment) {
+			return true
+		}
+	}
+	return false
+}
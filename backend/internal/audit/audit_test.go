@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/redteamsec/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// newTestChain 创建包含三条审计记录的临时数据库
+// 测试数据库不创建触发器，以便直接改写记录模拟篡改
+func newTestChain(t *testing.T) (*gorm.DB, []*database.AuditLog) {
+	t.Helper()
+	db := database.NewTestDB(t, &database.AuditLog{})
+
+	var entries []*database.AuditLog
+	for i := 1; i <= 3; i++ {
+		entry, err := Record(db, database.AuditLog{
+			ActorID:    1,
+			Actor:      "alice",
+			Provider:   "aws",
+			Action:     "stop_instance",
+			Target:     fmt.Sprintf("i-%d", i),
+			Parameters: `{"instance_id":"i-1"}`,
+			Result:     ResultSuccess,
+		})
+		if err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	return db, entries
+}
+
+func TestRecordChainsHashes(t *testing.T) {
+	db, entries := newTestChain(t)
+
+	if entries[0].PrevHash != "" {
+		t.Fatalf("first entry PrevHash = %q, want empty", entries[0].PrevHash)
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].PrevHash != entries[i-1].Hash {
+			t.Fatalf("entry %d PrevHash = %q, want %q", i, entries[i].PrevHash, entries[i-1].Hash)
+		}
+	}
+
+	v, err := Verify(db)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !v.Valid || v.Entries != len(entries) {
+		t.Fatalf("Verify() = %+v, want %d valid entries", v, len(entries))
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   func(db *gorm.DB, entries []*database.AuditLog) error
+		brokenAt int // entries 中被判定断链的记录下标
+	}{
+		{
+			name: "content changed",
+			tamper: func(db *gorm.DB, entries []*database.AuditLog) error {
+				return db.Exec("UPDATE audit_logs SET result = ? WHERE id = ?", ResultFailed, entries[1].ID).Error
+			},
+			brokenAt: 1,
+		},
+		{
+			name: "content and hash rewritten",
+			tamper: func(db *gorm.DB, entries []*database.AuditLog) error {
+				forged := *entries[1]
+				forged.Target = "i-999"
+				return db.Exec("UPDATE audit_logs SET target = ?, hash = ? WHERE id = ?", forged.Target, Hash(&forged), forged.ID).Error
+			},
+			brokenAt: 2,
+		},
+		{
+			name: "entry removed",
+			tamper: func(db *gorm.DB, entries []*database.AuditLog) error {
+				return db.Exec("DELETE FROM audit_logs WHERE id = ?", entries[1].ID).Error
+			},
+			brokenAt: 2,
+		},
+		{
+			name: "first entry removed",
+			tamper: func(db *gorm.DB, entries []*database.AuditLog) error {
+				return db.Exec("DELETE FROM audit_logs WHERE id = ?", entries[0].ID).Error
+			},
+			brokenAt: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, entries := newTestChain(t)
+			if err := tt.tamper(db, entries); err != nil {
+				t.Fatalf("tamper: %v", err)
+			}
+			v, err := Verify(db)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if v.Valid || v.BrokenAt != entries[tt.brokenAt].ID || v.Reason == "" {
+				t.Fatalf("Verify() = %+v, want broken at entry %d", v, entries[tt.brokenAt].ID)
+			}
+		})
+	}
+}
+
+func TestRecordRejectsORMChanges(t *testing.T) {
+	db, entries := newTestChain(t)
+
+	if err := db.Model(entries[0]).Update("result", ResultFailed).Error; !errors.Is(err, database.ErrAuditLogImmutable) {
+		t.Fatalf("Update: got %v, want ErrAuditLogImmutable", err)
+	}
+	if err := db.Delete(entries[0]).Error; !errors.Is(err, database.ErrAuditLogImmutable) {
+		t.Fatalf("Delete: got %v, want ErrAuditLogImmutable", err)
+	}
+	if v, err := Verify(db); err != nil || !v.Valid {
+		t.Fatalf("Verify() = %+v, %v, want valid", v, err)
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "", want: ""},
+		{input: `{"region":"us-east-1"}`, want: `{"region":"us-east-1"}`},
+		{input: `{"password":"p","SecretKey":"s","session_token":"t"}`, want: `{"SecretKey":"[REDACTED]","password":"[REDACTED]","session_token":"[REDACTED]"}`},
+		{input: `{"users":[{"name":"a","private_key":"k"}]}`, want: `{"users":[{"name":"a","private_key":"[REDACTED]"}]}`},
+		{input: `not json`, want: redacted},
+	}
+	for _, tt := range tests {
+		if got := RedactJSON(tt.input); got != tt.want {
+			t.Errorf("RedactJSON(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
@@ -1,69 +1,201 @@
 package auth
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"math/big"
+	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/redteamsec/backend/config"
 )
 
+// DefaultJWTSecret 配置中 JWT_SECRET 的默认值，非开发环境拒绝使用
+const DefaultJWTSecret = "your-secret-key"
+
+// minSecretLength HS256 密钥的最小长度（字节）
+const minSecretLength = 32
+
 // Claims JWT声明
 type Claims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	// PasswordChangeRequired 用户必须先修改密码，认证中间件据此限制可访问的接口
+	PasswordChangeRequired bool `json:"pwd_change,omitempty"`
+	// MFASetupRequired 用户的角色要求启用 MFA 但尚未绑定验证器，认证中间件只允许访问 MFA 绑定接口
+	MFASetupRequired bool `json:"mfa_setup,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成JWT令牌
-func GenerateToken(userID uint, username, role string, cfg *config.Config) (string, error) {
-	// 设置过期时间
-	expirationTime := time.Now().Add(time.Duration(cfg.JWTExpiry) * time.Hour)
+// TokenIssuer 签发和验证访问令牌，支持 HS256、RS256 和 EdDSA
+type TokenIssuer struct {
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+	keyID     string
+	ttl       time.Duration
+}
+
+// NewTokenIssuer 按配置加载签名密钥
+// 非开发环境使用 HS256 时拒绝默认密钥和过短的密钥，RS256 和 EdDSA 从 JWT_PRIVATE_KEY_FILE 读取 PEM 格式私钥
+func NewTokenIssuer(cfg *config.Config) (*TokenIssuer, error) {
+	issuer := &TokenIssuer{ttl: time.Duration(cfg.JWTAccessTTL) * time.Minute}
+	if issuer.ttl <= 0 {
+		return nil, errors.New("JWT_ACCESS_TTL must be positive")
+	}
+
+	switch cfg.JWTAlgorithm {
+	case "", "HS256":
+		if cfg.JWTSecret == DefaultJWTSecret || len(cfg.JWTSecret) < minSecretLength {
+			if cfg.Environment != "development" {
+				return nil, fmt.Errorf("JWT_SECRET must be a random secret of at least %d bytes and not the default value", minSecretLength)
+			}
+			fmt.Println("Warning: JWT_SECRET is the default value or too short, only acceptable for development")
+		}
+		issuer.method = jwt.SigningMethodHS256
+		issuer.signKey = []byte(cfg.JWTSecret)
+		issuer.verifyKey = []byte(cfg.JWTSecret)
+	case "RS256", "EdDSA":
+		if cfg.JWTPrivateKeyFile == "" {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s", cfg.JWTAlgorithm)
+		}
+		data, err := os.ReadFile(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT private key: %w", err)
+		}
+		if cfg.JWTAlgorithm == "RS256" {
+			key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RSA private key: %w", err)
+			}
+			if key.N.BitLen() < 2048 {
+				return nil, errors.New("RSA private key must be at least 2048 bits")
+			}
+			issuer.method = jwt.SigningMethodRS256
+			issuer.signKey = key
+			issuer.verifyKey = &key.PublicKey
+		} else {
+			parsed, err := jwt.ParseEdPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Ed25519 private key: %w", err)
+			}
+			key, ok := parsed.(ed25519.PrivateKey)
+			if !ok {
+				return nil, errors.New("invalid Ed25519 private key")
+			}
+			issuer.method = jwt.SigningMethodEdDSA
+			issuer.signKey = key
+			issuer.verifyKey = key.Public()
+		}
+		issuer.keyID = cfg.JWTKeyID
+		if issuer.keyID == "" {
+			issuer.keyID, err = keyThumbprint(issuer.verifyKey)
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q (supported: HS256, RS256, EdDSA)", cfg.JWTAlgorithm)
+	}
+	return issuer, nil
+}
+
+// TTL 访问令牌有效期
+func (i *TokenIssuer) TTL() time.Duration {
+	return i.ttl
+}
 
-	// 创建声明
+// GenerateToken 生成访问令牌，令牌ID（jti）用于注销时吊销
+func (i *TokenIssuer) GenerateToken(userID uint, username, role string, passwordChangeRequired, mfaSetupRequired bool) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
+		UserID:                 userID,
+		Username:               username,
+		Role:                   role,
+		PasswordChangeRequired: passwordChangeRequired,
+		MFASetupRequired:       mfaSetupRequired,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        RandomToken(16),
+			ExpiresAt: jwt.NewNumericDate(now.Add(i.ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
-	// 创建令牌
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// 签名令牌
-	tokenString, err := token.SignedString([]byte(cfg.JWTSecret))
-	if err != nil {
-		return "", err
+	token := jwt.NewWithClaims(i.method, claims)
+	if i.keyID != "" {
+		token.Header["kid"] = i.keyID
 	}
-
-	return tokenString, nil
+	return token.SignedString(i.signKey)
 }
 
-// ValidateToken 验证JWT令牌
-func ValidateToken(tokenString string, cfg *config.Config) (*Claims, error) {
-	// 解析令牌
+// ValidateToken 验证访问令牌的签名和有效期，只接受配置的签名算法
+func (i *TokenIssuer) ValidateToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		// 验证签名方法
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
+		if i.keyID != "" && token.Header["kid"] != i.keyID {
+			return nil, errors.New("unknown signing key")
 		}
-		return []byte(cfg.JWTSecret), nil
-	})
-
+		return i.verifyKey, nil
+	}, jwt.WithValidMethods([]string{i.method.Alg()}), jwt.WithExpirationRequired())
 	if err != nil {
 		return nil, err
 	}
-
-	if !token.Valid {
+	if !token.Valid || claims.ID == "" {
 		return nil, errors.New("invalid token")
 	}
-
 	return claims, nil
 }
+
+// JWKS 返回验证访问令牌的公钥集合，HS256 密钥不能公开，返回空集合
+func (i *TokenIssuer) JWKS() map[string]interface{} {
+	keys := []map[string]interface{}{}
+	switch key := i.verifyKey.(type) {
+	case *rsa.PublicKey:
+		keys = append(keys, map[string]interface{}{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": i.method.Alg(),
+			"kid": i.keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	case ed25519.PublicKey:
+		keys = append(keys, map[string]interface{}{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"use": "sig",
+			"alg": i.method.Alg(),
+			"kid": i.keyID,
+			"x":   base64.RawURLEncoding.EncodeToString(key),
+		})
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+// keyThumbprint 未配置 JWT_KEY_ID 时用公钥摘要作为密钥ID
+func keyThumbprint(key crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWT public key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// RandomToken 生成 n 字节随机数的 URL 安全编码，用于令牌ID和刷新令牌
+func RandomToken(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf) // 自 Go 1.24 起 crypto/rand.Read 不会返回错误
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 密码策略
+const (
+	minPasswordLength = 8
+	// bcrypt 只使用前 72 字节，更长的密码超出部分不参与校验
+	maxPasswordLength = 72
+	// minCharClasses 小写字母、大写字母、数字、符号中至少包含的种类
+	minCharClasses = 3
+)
+
+// commonPasswords 常见弱密码，即使满足长度和字符种类要求也不允许使用
+var commonPasswords = map[string]bool{
+	"password": true, "password1": true, "password123": true, "passw0rd": true, "p@ssw0rd": true,
+	"admin123": true, "admin@123": true, "administrator": true, "qwerty123": true, "qwe123!@#": true,
+	"12345678": true, "123456789": true, "1234567890": true, "abc12345": true, "1qaz2wsx": true,
+	"1qaz@wsx": true, "iloveyou": true, "welcome1": true, "changeme": true, "letmein1": true,
+}
+
+// HashPassword 使用 bcrypt 计算密码哈希
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword 校验密码与哈希是否匹配
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// IsPasswordHash 判断存储的密码是否已是 bcrypt 哈希，用于迁移旧的明文密码
+func IsPasswordHash(value string) bool {
+	_, err := bcrypt.Cost([]byte(value))
+	return err == nil
+}
+
+// ValidatePassword 检查密码是否符合密码策略
+func ValidatePassword(password, username string) error {
+	if len(password) < minPasswordLength {
+		return errors.New("password must be at least 8 characters")
+	}
+	if len(password) > maxPasswordLength {
+		return errors.New("password must be at most 72 bytes")
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, ok := range []bool{lower, upper, digit, symbol} {
+		if ok {
+			classes++
+		}
+	}
+	if classes < minCharClasses {
+		return errors.New("password must contain at least three of: lowercase letters, uppercase letters, digits, symbols")
+	}
+
+	lowered := strings.ToLower(password)
+	if username != "" && strings.Contains(lowered, strings.ToLower(username)) {
+		return errors.New("password must not contain the username")
+	}
+	if commonPasswords[lowered] {
+		return errors.New("password is too common")
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package auth
+
+// 角色
+const (
+	RoleAdmin    = "admin"    // 全部权限，包括用户管理
+	RoleOperator = "operator" // 可以执行云操作和管理任务，不能管理用户
+	RoleViewer   = "viewer"   // 只能查看凭证、任务结果和分析数据，不能调用云平台接口
+)
+
+// roleLegacyUser 早期版本注册用户的角色，按 viewer 处理
+const roleLegacyUser = "user"
+
+// 权限
+const (
+	PermCredentialsRead  = "credentials:read"
+	PermCredentialsWrite = "credentials:write"
+	PermTasksRead        = "tasks:read"
+	PermTasksWrite       = "tasks:write" // 创建和取消任务
+	PermTasksDelete      = "tasks:delete"
+	PermSchedulesRead    = "schedules:read"
+	PermSchedulesWrite   = "schedules:write"
+	PermPlaybooksRead    = "playbooks:read"
+	PermPlaybooksWrite   = "playbooks:write" // 创建、删除、执行和取消剧本
+	PermPlaybooksApprove = "playbooks:approve"
+	PermCloudRead        = "cloud:read"    // 查看已保存的资源和权限结果
+	PermCloudScan        = "cloud:scan"    // 只读的云平台调用：资源枚举、权限分析、用户信息、敏感数据扫描
+	PermCloudOperate     = "cloud:operate" // 资源操作（包括执行命令、联邦登录）、接管和下载
+	PermFederationRead   = "federation:read"
+	PermFederationRevoke = "federation:revoke"
+	PermAnalysisRead     = "analysis:read"
+	PermUsersManage      = "users:manage"
+	PermProjectsRead     = "projects:read"
+	PermProjectsWrite    = "projects:write" // 创建项目，管理自己拥有的项目及成员
+	PermAuditRead        = "audit:read"     // 查看和导出可访问项目的审计日志
+)
+
+// viewerPermissions 只读权限
+var viewerPermissions = []string{
+	PermCredentialsRead,
+	PermTasksRead,
+	PermSchedulesRead,
+	PermPlaybooksRead,
+	PermCloudRead,
+	PermFederationRead,
+	PermAnalysisRead,
+	PermProjectsRead,
+	PermAuditRead,
+}
+
+// operatorPermissions 在只读权限基础上可以执行操作
+var operatorPermissions = append([]string{
+	PermCredentialsWrite,
+	PermTasksWrite,
+	PermTasksDelete,
+	PermSchedulesWrite,
+	PermPlaybooksWrite,
+	PermPlaybooksApprove,
+	PermCloudScan,
+	PermCloudOperate,
+	PermFederationRevoke,
+	PermProjectsWrite,
+}, viewerPermissions...)
+
+// rolePermissions 各角色拥有的权限
+var rolePermissions = map[string][]string{
+	RoleAdmin:    append([]string{PermUsersManage}, operatorPermissions...),
+	RoleOperator: operatorPermissions,
+	RoleViewer:   viewerPermissions,
+}
+
+// taskPermissions 创建各类型任务所需的权限
+var taskPermissions = map[string]string{
+	"enumerate":      PermCloudScan,
+	"escalate":       PermCloudScan,
+	"userinfo":       PermCloudScan,
+	"sensitive_scan": PermCloudScan,
+	"operate":        PermCloudOperate,
+	"takeover":       PermCloudOperate,
+	"download":       PermCloudOperate,
+	"cleanup":        PermCloudOperate,
+}
+
+// ValidRole 判断角色是否有效
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Permissions 返回角色拥有的权限，未知角色没有任何权限
+func Permissions(role string) []string {
+	if role == roleLegacyUser {
+		role = RoleViewer
+	}
+	return rolePermissions[role]
+}
+
+// HasPermission 判断角色是否拥有指定权限
+func HasPermission(role, permission string) bool {
+	for _, p := range Permissions(role) {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// TaskPermission 返回创建指定类型任务所需的权限，未知类型需要最高的 cloud:operate 权限
+func TaskPermission(taskType string) string {
+	if permission, ok := taskPermissions[taskType]; ok {
+		return permission
+	}
+	return PermCloudOperate
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret RFC 6238 附录 B 中 SHA1 测试向量使用的密钥 "12345678901234567890"
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTP(t *testing.T) {
+	tests := []struct {
+		name        string
+		secret      string
+		code        string
+		lastCounter int64
+		now         int64
+		wantCounter int64
+		wantOK      bool
+	}{
+		// RFC 6238 测试向量取 8 位结果的后 6 位
+		{name: "rfc vector 59", secret: rfc6238Secret, code: "287082", now: 59, wantCounter: 1, wantOK: true},
+		{name: "rfc vector 1111111109", secret: rfc6238Secret, code: "081804", now: 1111111109, wantCounter: 37037036, wantOK: true},
+		{name: "rfc vector 1234567890", secret: rfc6238Secret, code: "005924", now: 1234567890, wantCounter: 41152263, wantOK: true},
+		{name: "lowercase secret", secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code: "287082", now: 59, wantCounter: 1, wantOK: true},
+		{name: "surrounding spaces", secret: rfc6238Secret, code: " 287082 ", now: 59, wantCounter: 1, wantOK: true},
+
+		// 允许前后各一个时间步的时钟偏差
+		{name: "previous step", secret: rfc6238Secret, code: "287082", now: 89, wantCounter: 1, wantOK: true},
+		{name: "next step", secret: rfc6238Secret, code: "081804", now: 1111111109 - 30, wantCounter: 37037036, wantOK: true},
+		{name: "two steps late", secret: rfc6238Secret, code: "287082", now: 119},
+		{name: "two steps early", secret: rfc6238Secret, code: "081804", now: 1111111109 - 60},
+
+		// 已使用的时间步及之前的时间步不再接受
+		{name: "replay same step", secret: rfc6238Secret, code: "287082", lastCounter: 1, now: 59},
+		{name: "replay older step", secret: rfc6238Secret, code: "287082", lastCounter: 2, now: 59},
+		{name: "after previous step", secret: rfc6238Secret, code: "287082", lastCounter: 0, now: 59, wantCounter: 1, wantOK: true},
+
+		{name: "wrong code", secret: rfc6238Secret, code: "123456", now: 59},
+		{name: "too short", secret: rfc6238Secret, code: "28708", now: 59},
+		{name: "not digits", secret: rfc6238Secret, code: "28708a", now: 59},
+		{name: "invalid secret", secret: "not base32!", code: "287082", now: 59},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, ok := ValidateTOTP(tt.secret, tt.code, tt.lastCounter, time.Unix(tt.now, 0))
+			if ok != tt.wantOK || counter != tt.wantCounter {
+				t.Fatalf("ValidateTOTP() = (%d, %v), want (%d, %v)", counter, ok, tt.wantCounter, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPGeneratedSecret(t *testing.T) {
+	secret := GenerateTOTPSecret()
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("generated secret is not valid base32: %v", err)
+	}
+
+	now := time.Now()
+	counter := now.Unix() / totpPeriod
+	code := hotp(key, counter)
+	got, ok := ValidateTOTP(secret, code, 0, now)
+	if !ok || got != counter {
+		t.Fatalf("ValidateTOTP() = (%d, %v), want (%d, true)", got, ok, counter)
+	}
+	// 调用方保存返回的时间步后，同一验证码不能再次使用
+	if _, ok := ValidateTOTP(secret, code, got, now); ok {
+		t.Fatal("code accepted again after its step was recorded")
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	codes := GenerateRecoveryCodes()
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), RecoveryCodeCount)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if IsTOTPCode(code) {
+			t.Fatalf("recovery code %q looks like a TOTP code", code)
+		}
+		hash := HashRecoveryCode(code)
+		if seen[hash] {
+			t.Fatalf("duplicate recovery code %q", code)
+		}
+		seen[hash] = true
+	}
+
+	// 输入时的大小写、空格和连字符不影响匹配
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{input: "abcde-fghij", want: true},
+		{input: "ABCDE-FGHIJ", want: true},
+		{input: "abcdefghij", want: true},
+		{input: " abcde fghij ", want: true},
+		{input: "abcde-fghik", want: false},
+	}
+	want := HashRecoveryCode("abcde-fghij")
+	for _, tt := range tests {
+		if got := HashRecoveryCode(tt.input) == want; got != tt.want {
+			t.Errorf("HashRecoveryCode(%q) matches = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
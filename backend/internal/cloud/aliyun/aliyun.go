@@ -2,9 +2,15 @@ package aliyun
 
 import (
 	"fmt"
+	"net/url"
+	"time"
 
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ram"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/sts"
+	"github.com/redteamsec/backend/internal/cloud/capability"
+	"github.com/redteamsec/backend/internal/cloud/federation"
 )
 
 // AliyunProvider 阿里云平台实现
@@ -177,27 +183,63 @@ func (p *AliyunProvider) enumerateRAMRoles() ([]interface{}, error) {
 	}, nil
 }
 
+// capabilityRules 阿里云原生权限（RAM系统策略名称及操作）到标准化能力的映射
+var capabilityRules = []capability.Rule{
+	{Pattern: "*:*", Capabilities: capability.All},
+	{Pattern: "AdministratorAccess", Capabilities: capability.All},
+	{Pattern: "AliyunRAMFullAccess", Capabilities: []string{capability.IdentityAdmin, capability.IdentityRead}},
+	{Pattern: "AliyunRAMReadOnlyAccess", Capabilities: []string{capability.IdentityRead}},
+	{Pattern: "AliyunSTSAssumeRoleAccess", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "ram:*", Capabilities: []string{capability.IdentityAdmin, capability.IdentityRead}},
+	{Pattern: "ram:Attach*", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "ram:CreateAccessKey", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "ram:List*", Capabilities: []string{capability.IdentityRead}},
+	{Pattern: "ram:Get*", Capabilities: []string{capability.IdentityRead}},
+	{Pattern: "AliyunECSFullAccess", Capabilities: []string{capability.ComputeAdmin, capability.ComputeExec}},
+	{Pattern: "ecs:*", Capabilities: []string{capability.ComputeAdmin, capability.ComputeExec}},
+	{Pattern: "AliyunECSAssistantFullAccess", Capabilities: []string{capability.ComputeExec}},
+	{Pattern: "ecs:RunCommand", Capabilities: []string{capability.ComputeExec}},
+	{Pattern: "ecs:InvokeCommand", Capabilities: []string{capability.ComputeExec}},
+	{Pattern: "AliyunOSSFullAccess", Capabilities: []string{capability.StorageReadAll, capability.StorageWrite}},
+	{Pattern: "AliyunOSSReadOnlyAccess", Capabilities: []string{capability.StorageReadAll}},
+	{Pattern: "oss:*", Capabilities: []string{capability.StorageReadAll, capability.StorageWrite}},
+	{Pattern: "oss:GetObject", Capabilities: []string{capability.StorageReadAll}},
+	{Pattern: "oss:PutObject", Capabilities: []string{capability.StorageWrite}},
+	{Pattern: "AliyunKMSFullAccess", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "kms:GetSecretValue", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "AliyunActionTrailFullAccess", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "actiontrail:StopLogging", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "actiontrail:DeleteTrail", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "AliyunVPCFullAccess", Capabilities: []string{capability.NetworkAdmin}},
+	{Pattern: "ecs:AuthorizeSecurityGroup", Capabilities: []string{capability.NetworkAdmin}},
+}
+
 // EscalatePrivileges 权限提升
 func (p *AliyunProvider) EscalatePrivileges() (map[string]interface{}, error) {
 	// 这里应该实现阿里云权限提升逻辑
+	permissions := []string{
+		"ecs:DescribeInstances",
+		"oss:ListBuckets",
+		"ram:ListUsers",
+		"ram:ListRoles",
+		"oss:GetBucketLocation",
+		"oss:ListObjects",
+	}
+	capabilities := capability.Map(permissions, capabilityRules)
+
 	// 返回前端期望的数据结构
 	return map[string]interface{}{
-		"user": "Aliyun RAM User",
-		"role": "None",
-		"permissions": []string{
-			"ecs:DescribeInstances",
-			"oss:ListBuckets",
-			"ram:ListUsers",
-			"ram:ListRoles",
-			"oss:GetBucketLocation",
-			"oss:ListObjects",
-		},
+		"user":         "Aliyun RAM User",
+		"role":         "None",
+		"permissions":  permissions,
+		"capabilities": capabilities,
+		"riskScore":    capability.Score(capabilities),
 		"potentialEscalation": []string{
 			"Create RAM user with admin privileges",
 			"Modify existing RAM policies",
 			"Access OSS buckets with sensitive data",
 		},
-		"riskLevel": "Medium",
+		"riskLevel": capability.RiskLevel(capabilities),
 		"message":   "Privilege escalation attempted",
 		"actions": []string{
 			"Checked RAM policies",
@@ -209,6 +251,11 @@ func (p *AliyunProvider) EscalatePrivileges() (map[string]interface{}, error) {
 
 // OperateResource 资源操作
 func (p *AliyunProvider) OperateResource(resourceType, action, resourceID string, params map[string]interface{}) (map[string]interface{}, error) {
+	// 处理联邦登录操作
+	if action == "federated_login" {
+		return p.federatedLogin(params)
+	}
+
 	// 这里应该实现阿里云资源操作逻辑
 	// 暂时返回模拟数据
 	return map[string]interface{}{
@@ -220,6 +267,90 @@ func (p *AliyunProvider) OperateResource(resourceType, action, resourceID string
 	}, nil
 }
 
+// federatedLogin 通过STS扮演RAM角色并生成控制台登录URL
+// 1. 使用AssumeRole获取角色的临时凭证
+// 2. 使用临时凭证调用GetSigninToken获取登录令牌
+// 3. 拼接控制台登录URL
+func (p *AliyunProvider) federatedLogin(params map[string]interface{}) (map[string]interface{}, error) {
+	region := p.region
+	if region == "" {
+		region = "cn-hangzhou"
+	}
+
+	stsClient, err := sts.NewClientWithAccessKey(region, p.accessKey, p.secretKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create STS client: %w", err)
+	}
+
+	// 阿里云控制台只能以RAM角色身份登录，需要指定要扮演的角色
+	roleArn, _ := params["role_arn"].(string)
+	if roleArn == "" {
+		roleName, _ := params["role_name"].(string)
+		if roleName == "" {
+			return nil, fmt.Errorf("role_arn or role_name is required for Aliyun console login")
+		}
+
+		identity, err := stsClient.GetCallerIdentity(sts.CreateGetCallerIdentityRequest())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get caller identity: %w", err)
+		}
+		roleArn = fmt.Sprintf("acs:ram::%s:role/%s", identity.AccountId, roleName)
+	}
+
+	request := sts.CreateAssumeRoleRequest()
+	request.Scheme = "https"
+	request.RoleArn = roleArn
+	request.RoleSessionName = "federated-user"
+	request.DurationSeconds = requests.NewInteger(int(federation.DefaultDuration.Seconds()))
+	response, err := stsClient.AssumeRole(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assume role: %w", err)
+	}
+	creds := response.Credentials
+
+	// 获取SigninToken
+	tokenURL := "https://signin.aliyun.com/federation?" + url.Values{
+		"Action":          {"GetSigninToken"},
+		"AccessKeyId":     {creds.AccessKeyId},
+		"AccessKeySecret": {creds.AccessKeySecret},
+		"SecurityToken":   {creds.SecurityToken},
+		"TicketType":      {"mini"},
+	}.Encode()
+	var token struct {
+		SigninToken string `json:"SigninToken"`
+	}
+	if err := federation.GetJSON(tokenURL, &token); err != nil {
+		return nil, fmt.Errorf("failed to get signin token: %w", err)
+	}
+	if token.SigninToken == "" {
+		return nil, fmt.Errorf("empty signin token")
+	}
+
+	// 生成控制台登录URL
+	loginURL := "https://signin.aliyun.com/federation?" + url.Values{
+		"Action":      {"Login"},
+		"LoginUrl":    {"https://signin.aliyun.com/login.htm"},
+		"Destination": {"https://home.console.aliyun.com"},
+		"SigninToken": {token.SigninToken},
+	}.Encode()
+
+	expiry, _ := time.Parse(time.RFC3339, creds.Expiration)
+
+	login := &federation.Login{
+		Provider:     "阿里云",
+		URL:          loginURL,
+		Expiry:       expiry,
+		AccessKey:    creds.AccessKeyId,
+		SecretKey:    creds.AccessKeySecret,
+		SessionToken: creds.SecurityToken,
+		Region:       region,
+		Extra: map[string]interface{}{
+			"role_arn": roleArn,
+		},
+	}
+	return login.Result(), nil
+}
+
 // Takeover 平台接管
 func (p *AliyunProvider) Takeover() (map[string]interface{}, error) {
 	// 这里应该实现阿里云平台接管逻辑
@@ -238,13 +369,15 @@ func (p *AliyunProvider) Takeover() (map[string]interface{}, error) {
 func (p *AliyunProvider) GetPermissions() (map[string]interface{}, error) {
 	// 这里应该实现获取阿里云权限信息逻辑
 	// 暂时返回模拟数据
+	permissions := []string{
+		"ecs:DescribeInstances",
+		"oss:ListBuckets",
+		"ram:ListUsers",
+	}
 	return map[string]interface{}{
-		"message": "Permissions retrieved",
-		"permissions": []string{
-			"ecs:DescribeInstances",
-			"oss:ListBuckets",
-			"ram:ListUsers",
-		},
+		"message":      "Permissions retrieved",
+		"permissions":  permissions,
+		"capabilities": capability.Map(permissions, capabilityRules),
 	}, nil
 }
 
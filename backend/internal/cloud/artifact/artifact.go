@@ -0,0 +1,67 @@
+package artifact
+
+import (
+	"errors"
+	"sync"
+)
+
+// 平台在目标账号中创建的资源类型
+const (
+	TypeIAMRole             = "iam_role"                     // Resource 为角色名称
+	TypeRolePolicy          = "iam_role_policy_attachment"   // Resource 为策略ARN，Parent 为角色名称
+	TypeInstanceProfile     = "iam_instance_profile"         // Resource 为实例配置文件名称
+	TypeInstanceProfileRole = "instance_profile_role"        // Resource 为角色名称，Parent 为实例配置文件名称
+	TypeProfileAssociation  = "instance_profile_association" // Resource 为关联ID，Parent 为实例ID
+	TypeUserPolicy          = "iam_user_inline_policy"       // Resource 为内联策略名称，Parent 为IAM用户名称
+)
+
+// ErrNotYetRemovable 资源暂时不能删除，例如撤销联邦令牌的策略在令牌过期前必须保留
+var ErrNotYetRemovable = errors.New("artifact cannot be removed yet")
+
+// Artifact 云平台操作创建的一项资源
+type Artifact struct {
+	Type     string `json:"type"`
+	Resource string `json:"resource"`
+	Parent   string `json:"parent,omitempty"`
+	Region   string `json:"region,omitempty"`
+	// RemoveAfter 早于该时间（RFC3339）不能删除，为空表示随时可以删除
+	RemoveAfter string `json:"removeAfter,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Recorder 接收新创建资源的回调
+type Recorder func(Artifact)
+
+// Tracked 创建资源时上报资源的云平台实现
+type Tracked interface {
+	SetRecorder(recorder Recorder)
+}
+
+// Cleaner 能删除自己创建的资源的云平台实现
+type Cleaner interface {
+	// RemoveArtifact 删除资源，资源已不存在时视为删除成功
+	RemoveArtifact(a Artifact) error
+}
+
+// Ledger 嵌入云平台实现中用于上报新创建的资源，未设置 Recorder 时为空操作
+type Ledger struct {
+	mu       sync.RWMutex
+	recorder Recorder
+}
+
+// SetRecorder 设置资源上报回调
+func (l *Ledger) SetRecorder(recorder Recorder) {
+	l.mu.Lock()
+	l.recorder = recorder
+	l.mu.Unlock()
+}
+
+// RecordArtifact 上报新创建的资源
+func (l *Ledger) RecordArtifact(a Artifact) {
+	l.mu.RLock()
+	recorder := l.recorder
+	l.mu.RUnlock()
+	if recorder != nil {
+		recorder(a)
+	}
+}
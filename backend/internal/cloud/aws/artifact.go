@@ -0,0 +1,79 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/redteamsec/backend/internal/cloud/artifact"
+)
+
+// RemoveArtifact 删除平台创建的资源，需要按创建的相反顺序调用，例如先解除关联再删除实例配置文件
+func (p *AWSProvider) RemoveArtifact(a artifact.Artifact) error {
+	if a.RemoveAfter != "" {
+		removeAfter, err := time.Parse(time.RFC3339, a.RemoveAfter)
+		if err == nil && time.Now().Before(removeAfter) {
+			return fmt.Errorf("%w until %s", artifact.ErrNotYetRemovable, a.RemoveAfter)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(p.Context(), 30*time.Second)
+	defer cancel()
+
+	var err error
+	switch a.Type {
+	case artifact.TypeProfileAssociation:
+		ec2Client := p.ec2Client
+		if a.Region != "" && a.Region != ec2Client.Options().Region {
+			regionProvider, err := p.newRegionProvider(a.Region)
+			if err != nil {
+				return fmt.Errorf("failed to create AWS provider for region %s: %w", a.Region, err)
+			}
+			ec2Client = regionProvider.ec2Client
+		}
+		_, err = ec2Client.DisassociateIamInstanceProfile(ctx, &ec2.DisassociateIamInstanceProfileInput{
+			AssociationId: aws.String(a.Resource),
+		})
+	case artifact.TypeInstanceProfileRole:
+		_, err = p.iamClient.RemoveRoleFromInstanceProfile(ctx, &iam.RemoveRoleFromInstanceProfileInput{
+			InstanceProfileName: aws.String(a.Parent),
+			RoleName:            aws.String(a.Resource),
+		})
+	case artifact.TypeInstanceProfile:
+		_, err = p.iamClient.DeleteInstanceProfile(ctx, &iam.DeleteInstanceProfileInput{
+			InstanceProfileName: aws.String(a.Resource),
+		})
+	case artifact.TypeRolePolicy:
+		_, err = p.iamClient.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{
+			RoleName:  aws.String(a.Parent),
+			PolicyArn: aws.String(a.Resource),
+		})
+	case artifact.TypeIAMRole:
+		_, err = p.iamClient.DeleteRole(ctx, &iam.DeleteRoleInput{
+			RoleName: aws.String(a.Resource),
+		})
+	case artifact.TypeUserPolicy:
+		_, err = p.iamClient.DeleteUserPolicy(ctx, &iam.DeleteUserPolicyInput{
+			UserName:   aws.String(a.Parent),
+			PolicyName: aws.String(a.Resource),
+		})
+	default:
+		return fmt.Errorf("unsupported artifact type: %s", a.Type)
+	}
+
+	// 资源已被手动删除时视为清理成功
+	if err != nil && !isNotFoundError(err) {
+		return fmt.Errorf("failed to remove %s %s: %w", a.Type, a.Resource, err)
+	}
+	return nil
+}
+
+// isNotFoundError 判断错误是否表示资源不存在
+func isNotFoundError(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "NoSuchEntity") || strings.Contains(message, "InvalidAssociationID.NotFound")
+}
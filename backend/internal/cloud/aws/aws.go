@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
 	"time"
 
@@ -31,10 +32,20 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	sts "github.com/aws/aws-sdk-go-v2/service/sts"
 	stsTypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/redteamsec/backend/internal/cloud/artifact"
+	"github.com/redteamsec/backend/internal/cloud/capability"
+	"github.com/redteamsec/backend/internal/cloud/federation"
+	"github.com/redteamsec/backend/internal/cloud/plan"
+	"github.com/redteamsec/backend/internal/cloud/progress"
+	"github.com/redteamsec/backend/internal/cloud/scope"
 )
 
 // AWSProvider AWS云平台实现
 type AWSProvider struct {
+	progress.Emitter
+	scope.Checker
+	artifact.Ledger
+
 	accessKey              string
 	secretKey              string
 	region                 string
@@ -83,6 +94,16 @@ func NewAWSProvider(accessKey, secretKey, region string) (*AWSProvider, error) {
 	return provider, nil
 }
 
+// newRegionProvider 创建指定区域的AWS云平台实例，沿用当前操作的上下文
+func (p *AWSProvider) newRegionProvider(region string) (*AWSProvider, error) {
+	regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+	if err != nil {
+		return nil, err
+	}
+	regionProvider.SetContext(p.Context())
+	return regionProvider, nil
+}
+
 // Init 初始化AWS客户端
 func (p *AWSProvider) Init(accessKey, secretKey, region string) error {
 	// 加载配置
@@ -172,6 +193,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 
 		// 处理每个资源类型
 		for _, rt := range resourceTypes {
+			// 任务已取消或超时时不再枚举剩余的资源类型
+			if p.Err() != nil {
+				break
+			}
 			rt = strings.TrimSpace(rt)
 			if rt == "" {
 				continue
@@ -208,12 +233,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 枚举EC2实例
 		var allInstances []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("EC2", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("EC2 (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create EC2 client for region %s: %v\n", region, err)
+				p.RegionFailed("EC2", region, err)
 				continue
 			}
 
@@ -223,8 +253,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("EC2 (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate EC2 instances in region %s: %v\n", region, err)
+				p.RegionFailed("EC2", region, err)
 				continue
 			}
+			p.RegionFinished("EC2", region, len(instances))
 
 			// 将该区域的实例添加到总列表
 			for _, instance := range instances {
@@ -286,12 +318,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 枚举VPC资源
 		var allVPCs []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("VPC", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("VPC (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create VPC client for region %s: %v\n", region, err)
+				p.RegionFailed("VPC", region, err)
 				continue
 			}
 
@@ -301,8 +338,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("VPC (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate VPCs in region %s: %v\n", region, err)
+				p.RegionFailed("VPC", region, err)
 				continue
 			}
+			p.RegionFinished("VPC", region, len(vpcs))
 
 			// 将该区域的VPC添加到总列表
 			for _, vpc := range vpcs {
@@ -324,12 +363,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 枚举路由表资源
 		var allRouteTables []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("Route Tables", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("Route Tables (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create Route Tables client for region %s: %v\n", region, err)
+				p.RegionFailed("Route Tables", region, err)
 				continue
 			}
 
@@ -339,8 +383,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("Route Tables (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate Route Tables in region %s: %v\n", region, err)
+				p.RegionFailed("Route Tables", region, err)
 				continue
 			}
+			p.RegionFinished("Route Tables", region, len(routeTables))
 
 			// 将该区域的路由表添加到总列表
 			for _, rt := range routeTables {
@@ -362,12 +408,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 枚举ELB资源
 		var allELBs []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("ELB", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("ELB (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create ELB client for region %s: %v\n", region, err)
+				p.RegionFailed("ELB", region, err)
 				continue
 			}
 
@@ -377,8 +428,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("ELB (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate ELBs in region %s: %v\n", region, err)
+				p.RegionFailed("ELB", region, err)
 				continue
 			}
+			p.RegionFinished("ELB", region, len(elbs))
 
 			// 将该区域的ELB添加到总列表
 			for _, elb := range elbs {
@@ -400,12 +453,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 枚举EKS集群
 		var allClusters []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("EKS", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("EKS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create EKS client for region %s: %v\n", region, err)
+				p.RegionFailed("EKS", region, err)
 				continue
 			}
 
@@ -415,8 +473,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("EKS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate EKS clusters in region %s: %v\n", region, err)
+				p.RegionFailed("EKS", region, err)
 				continue
 			}
+			p.RegionFinished("EKS", region, len(clusters))
 
 			// 将该区域的EKS集群添加到总列表
 			for _, cluster := range clusters {
@@ -438,12 +498,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 枚举KMS密钥
 		var allKeys []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("KMS", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("KMS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create KMS client for region %s: %v\n", region, err)
+				p.RegionFailed("KMS", region, err)
 				continue
 			}
 
@@ -453,8 +518,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("KMS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate KMS keys in region %s: %v\n", region, err)
+				p.RegionFailed("KMS", region, err)
 				continue
 			}
+			p.RegionFinished("KMS", region, len(keys))
 
 			// 将该区域的KMS密钥添加到总列表
 			for _, key := range keys {
@@ -476,12 +543,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 枚举RDS数据库实例
 		var allInstances []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("RDS", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("RDS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create RDS client for region %s: %v\n", region, err)
+				p.RegionFailed("RDS", region, err)
 				continue
 			}
 
@@ -491,8 +563,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("RDS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate RDS instances in region %s: %v\n", region, err)
+				p.RegionFailed("RDS", region, err)
 				continue
 			}
+			p.RegionFinished("RDS", region, len(instances))
 
 			// 将该区域的RDS实例添加到总列表
 			for _, instance := range instances {
@@ -514,12 +588,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 枚举Lambda函数
 		var allFunctions []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("Lambda", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("Lambda (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create Lambda client for region %s: %v\n", region, err)
+				p.RegionFailed("Lambda", region, err)
 				continue
 			}
 
@@ -529,8 +608,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("Lambda (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate Lambda functions in region %s: %v\n", region, err)
+				p.RegionFailed("Lambda", region, err)
 				continue
 			}
+			p.RegionFinished("Lambda", region, len(functions))
 
 			// 将该区域的Lambda函数添加到总列表
 			for _, function := range functions {
@@ -552,12 +633,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 枚举API Gateway
 		var allAPIs []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("API Gateway", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("API Gateway (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create API Gateway client for region %s: %v\n", region, err)
+				p.RegionFailed("API Gateway", region, err)
 				continue
 			}
 
@@ -567,8 +653,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("API Gateway (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate API Gateways in region %s: %v\n", region, err)
+				p.RegionFailed("API Gateway", region, err)
 				continue
 			}
+			p.RegionFinished("API Gateway", region, len(apis))
 
 			// 将该区域的API Gateway添加到总列表
 			for _, api := range apis {
@@ -590,12 +678,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 枚举CloudTrail
 		var allTrails []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("CloudTrail", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("CloudTrail (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create CloudTrail client for region %s: %v\n", region, err)
+				p.RegionFailed("CloudTrail", region, err)
 				continue
 			}
 
@@ -605,8 +698,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("CloudTrail (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate CloudTrails in region %s: %v\n", region, err)
+				p.RegionFailed("CloudTrail", region, err)
 				continue
 			}
+			p.RegionFinished("CloudTrail", region, len(trails))
 
 			// 将该区域的CloudTrail添加到总列表
 			for _, trail := range trails {
@@ -628,12 +723,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 枚举CloudWatch Logs
 		var allLogGroups []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("CloudWatch Logs", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("CloudWatch Logs (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create CloudWatch Logs client for region %s: %v\n", region, err)
+				p.RegionFailed("CloudWatch Logs", region, err)
 				continue
 			}
 
@@ -643,8 +743,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("CloudWatch Logs (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate CloudWatch Log Groups in region %s: %v\n", region, err)
+				p.RegionFailed("CloudWatch Logs", region, err)
 				continue
 			}
+			p.RegionFinished("CloudWatch Logs", region, len(logGroups))
 
 			// 将该区域的CloudWatch Logs添加到总列表
 			for _, logGroup := range logGroups {
@@ -666,12 +768,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 枚举DynamoDB表
 		var allTables []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("DynamoDB", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("DynamoDB (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create DynamoDB client for region %s: %v\n", region, err)
+				p.RegionFailed("DynamoDB", region, err)
 				continue
 			}
 
@@ -681,8 +788,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("DynamoDB (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate DynamoDB tables in region %s: %v\n", region, err)
+				p.RegionFailed("DynamoDB", region, err)
 				continue
 			}
+			p.RegionFinished("DynamoDB", region, len(tables))
 
 			// 将该区域的DynamoDB表添加到总列表
 			for _, table := range tables {
@@ -704,12 +813,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 枚举Secrets Manager
 		var allSecrets []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("Secrets Manager", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("Secrets Manager (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create Secrets Manager client for region %s: %v\n", region, err)
+				p.RegionFailed("Secrets Manager", region, err)
 				continue
 			}
 
@@ -719,8 +833,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("Secrets Manager (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate Secrets in region %s: %v\n", region, err)
+				p.RegionFailed("Secrets Manager", region, err)
 				continue
 			}
+			p.RegionFinished("Secrets Manager", region, len(secrets))
 
 			// 将该区域的Secrets Manager添加到总列表
 			for _, secret := range secrets {
@@ -742,12 +858,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 枚举SNS主题
 		var allTopics []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("SNS", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("SNS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create SNS client for region %s: %v\n", region, err)
+				p.RegionFailed("SNS", region, err)
 				continue
 			}
 
@@ -757,8 +878,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("SNS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate SNS topics in region %s: %v\n", region, err)
+				p.RegionFailed("SNS", region, err)
 				continue
 			}
+			p.RegionFinished("SNS", region, len(topics))
 
 			// 将该区域的SNS主题添加到总列表
 			for _, topic := range topics {
@@ -780,12 +903,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 枚举SQS队列
 		var allQueues []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("SQS", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("SQS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create SQS client for region %s: %v\n", region, err)
+				p.RegionFailed("SQS", region, err)
 				continue
 			}
 
@@ -795,8 +923,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("SQS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate SQS queues in region %s: %v\n", region, err)
+				p.RegionFailed("SQS", region, err)
 				continue
 			}
+			p.RegionFinished("SQS", region, len(queues))
 
 			// 将该区域的SQS队列添加到总列表
 			for _, queue := range queues {
@@ -820,12 +950,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 尝试枚举EC2实例
 		var allInstances []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("EC2", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("EC2 (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create EC2 client for region %s: %v\n", region, err)
+				p.RegionFailed("EC2", region, err)
 				continue
 			}
 
@@ -835,8 +970,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("EC2 (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate EC2 instances in region %s: %v\n", region, err)
+				p.RegionFailed("EC2", region, err)
 				continue
 			}
+			p.RegionFinished("EC2", region, len(instances))
 
 			// 将该区域的实例添加到总列表
 			for _, instance := range instances {
@@ -890,12 +1027,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 尝试枚举VPC资源
 		var allVPCs []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("VPC", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("VPC (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create VPC client for region %s: %v\n", region, err)
+				p.RegionFailed("VPC", region, err)
 				continue
 			}
 
@@ -905,8 +1047,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("VPC (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate VPCs in region %s: %v\n", region, err)
+				p.RegionFailed("VPC", region, err)
 				continue
 			}
+			p.RegionFinished("VPC", region, len(vpcs))
 
 			// 将该区域的VPC添加到总列表
 			for _, vpc := range vpcs {
@@ -927,12 +1071,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 尝试枚举路由表资源
 		var allRouteTables []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("Route Tables", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("Route Tables (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create Route Tables client for region %s: %v\n", region, err)
+				p.RegionFailed("Route Tables", region, err)
 				continue
 			}
 
@@ -942,8 +1091,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("Route Tables (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate Route Tables in region %s: %v\n", region, err)
+				p.RegionFailed("Route Tables", region, err)
 				continue
 			}
+			p.RegionFinished("Route Tables", region, len(routeTables))
 
 			// 将该区域的路由表添加到总列表
 			for _, rt := range routeTables {
@@ -964,12 +1115,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 尝试枚举ELB资源
 		var allELBs []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("ELB", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("ELB (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create ELB client for region %s: %v\n", region, err)
+				p.RegionFailed("ELB", region, err)
 				continue
 			}
 
@@ -979,8 +1135,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("ELB (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate ELBs in region %s: %v\n", region, err)
+				p.RegionFailed("ELB", region, err)
 				continue
 			}
+			p.RegionFinished("ELB", region, len(elbs))
 
 			// 将该区域的ELB添加到总列表
 			for _, elb := range elbs {
@@ -1001,12 +1159,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 尝试枚举EKS集群
 		var allClusters []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("EKS", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("EKS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create EKS client for region %s: %v\n", region, err)
+				p.RegionFailed("EKS", region, err)
 				continue
 			}
 
@@ -1016,8 +1179,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("EKS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate EKS clusters in region %s: %v\n", region, err)
+				p.RegionFailed("EKS", region, err)
 				continue
 			}
+			p.RegionFinished("EKS", region, len(clusters))
 
 			// 将该区域的EKS集群添加到总列表
 			for _, cluster := range clusters {
@@ -1038,12 +1203,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 尝试枚举KMS密钥
 		var allKeys []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("KMS", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("KMS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create KMS client for region %s: %v\n", region, err)
+				p.RegionFailed("KMS", region, err)
 				continue
 			}
 
@@ -1053,8 +1223,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("KMS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate KMS keys in region %s: %v\n", region, err)
+				p.RegionFailed("KMS", region, err)
 				continue
 			}
+			p.RegionFinished("KMS", region, len(keys))
 
 			// 将该区域的KMS密钥添加到总列表
 			for _, key := range keys {
@@ -1075,12 +1247,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 尝试枚举RDS数据库实例
 		var allRDSInstances []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("RDS", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("RDS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create RDS client for region %s: %v\n", region, err)
+				p.RegionFailed("RDS", region, err)
 				continue
 			}
 
@@ -1090,8 +1267,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("RDS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate RDS instances in region %s: %v\n", region, err)
+				p.RegionFailed("RDS", region, err)
 				continue
 			}
+			p.RegionFinished("RDS", region, len(instances))
 
 			// 将该区域的RDS实例添加到总列表
 			for _, instance := range instances {
@@ -1112,12 +1291,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 尝试枚举Lambda函数
 		var allLambdaFunctions []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("Lambda", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("Lambda (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create Lambda client for region %s: %v\n", region, err)
+				p.RegionFailed("Lambda", region, err)
 				continue
 			}
 
@@ -1127,8 +1311,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("Lambda (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate Lambda functions in region %s: %v\n", region, err)
+				p.RegionFailed("Lambda", region, err)
 				continue
 			}
+			p.RegionFinished("Lambda", region, len(functions))
 
 			// 将该区域的Lambda函数添加到总列表
 			for _, function := range functions {
@@ -1149,12 +1335,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 尝试枚举API Gateway
 		var allAPIGateways []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("API Gateway", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("API Gateway (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create API Gateway client for region %s: %v\n", region, err)
+				p.RegionFailed("API Gateway", region, err)
 				continue
 			}
 
@@ -1164,8 +1355,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("API Gateway (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate API Gateways in region %s: %v\n", region, err)
+				p.RegionFailed("API Gateway", region, err)
 				continue
 			}
+			p.RegionFinished("API Gateway", region, len(apis))
 
 			// 将该区域的API Gateway添加到总列表
 			for _, api := range apis {
@@ -1186,12 +1379,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 尝试枚举CloudTrail
 		var allCloudTrails []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("CloudTrail", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("CloudTrail (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create CloudTrail client for region %s: %v\n", region, err)
+				p.RegionFailed("CloudTrail", region, err)
 				continue
 			}
 
@@ -1201,8 +1399,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("CloudTrail (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate CloudTrails in region %s: %v\n", region, err)
+				p.RegionFailed("CloudTrail", region, err)
 				continue
 			}
+			p.RegionFinished("CloudTrail", region, len(trails))
 
 			// 将该区域的CloudTrail添加到总列表
 			for _, trail := range trails {
@@ -1223,12 +1423,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 尝试枚举CloudWatch Logs
 		var allCloudWatchLogGroups []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("CloudWatch Logs", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("CloudWatch Logs (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create CloudWatch Logs client for region %s: %v\n", region, err)
+				p.RegionFailed("CloudWatch Logs", region, err)
 				continue
 			}
 
@@ -1238,8 +1443,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("CloudWatch Logs (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate CloudWatch Log Groups in region %s: %v\n", region, err)
+				p.RegionFailed("CloudWatch Logs", region, err)
 				continue
 			}
+			p.RegionFinished("CloudWatch Logs", region, len(logGroups))
 
 			// 将该区域的CloudWatch Logs添加到总列表
 			for _, logGroup := range logGroups {
@@ -1260,12 +1467,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 尝试枚举DynamoDB表
 		var allDynamoDBTables []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("DynamoDB", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("DynamoDB (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create DynamoDB client for region %s: %v\n", region, err)
+				p.RegionFailed("DynamoDB", region, err)
 				continue
 			}
 
@@ -1275,8 +1487,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("DynamoDB (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate DynamoDB tables in region %s: %v\n", region, err)
+				p.RegionFailed("DynamoDB", region, err)
 				continue
 			}
+			p.RegionFinished("DynamoDB", region, len(tables))
 
 			// 将该区域的DynamoDB表添加到总列表
 			for _, table := range tables {
@@ -1297,12 +1511,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 尝试枚举Secrets Manager
 		var allSecrets []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("Secrets Manager", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("Secrets Manager (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create Secrets Manager client for region %s: %v\n", region, err)
+				p.RegionFailed("Secrets Manager", region, err)
 				continue
 			}
 
@@ -1312,8 +1531,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("Secrets Manager (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate Secrets in region %s: %v\n", region, err)
+				p.RegionFailed("Secrets Manager", region, err)
 				continue
 			}
+			p.RegionFinished("Secrets Manager", region, len(secrets))
 
 			// 将该区域的Secrets Manager添加到总列表
 			for _, secret := range secrets {
@@ -1334,12 +1555,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 尝试枚举SNS主题
 		var allSNSTopics []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("SNS", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("SNS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create SNS client for region %s: %v\n", region, err)
+				p.RegionFailed("SNS", region, err)
 				continue
 			}
 
@@ -1349,8 +1575,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("SNS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate SNS topics in region %s: %v\n", region, err)
+				p.RegionFailed("SNS", region, err)
 				continue
 			}
+			p.RegionFinished("SNS", region, len(topics))
 
 			// 将该区域的SNS主题添加到总列表
 			for _, topic := range topics {
@@ -1371,12 +1599,17 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 		// 尝试枚举SQS队列
 		var allSQSQueues []interface{}
 		for _, region := range regions {
+			if p.Err() != nil {
+				break
+			}
+			p.RegionStarted("SQS", region)
 			// 创建该区域的客户端
-			regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, region)
+			regionProvider, err := p.newRegionProvider(region)
 			if err != nil {
 				errorMsg := fmt.Sprintf("SQS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to create SQS client for region %s: %v\n", region, err)
+				p.RegionFailed("SQS", region, err)
 				continue
 			}
 
@@ -1386,8 +1619,10 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 				errorMsg := fmt.Sprintf("SQS (%s): %v", region, err)
 				errors = append(errors, errorMsg)
 				fmt.Printf("Warning: Failed to enumerate SQS queues in region %s: %v\n", region, err)
+				p.RegionFailed("SQS", region, err)
 				continue
 			}
+			p.RegionFinished("SQS", region, len(queues))
 
 			// 将该区域的SQS队列添加到总列表
 			for _, queue := range queues {
@@ -1425,7 +1660,7 @@ func (p *AWSProvider) EnumerateResources(resourceType string) (map[string]interf
 // enumerateEC2Instances 枚举EC2实例
 func (p *AWSProvider) enumerateEC2Instances() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取EC2实例列表
@@ -1480,7 +1715,7 @@ func (p *AWSProvider) enumerateEC2Instances() ([]interface{}, error) {
 // enumerateS3Buckets 枚举S3存储桶
 func (p *AWSProvider) enumerateS3Buckets() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	ctx, cancel := context.WithTimeout(p.Context(), 1*time.Minute)
 	defer cancel()
 
 	// 调用AWS SDK获取S3存储桶列表
@@ -1530,7 +1765,7 @@ func (p *AWSProvider) enumerateS3Buckets() ([]interface{}, error) {
 		}
 
 		// 创建新的S3客户端，使用存储桶的实际区域
-		cfg, err := config.LoadDefaultConfig(context.Background(),
+		cfg, err := config.LoadDefaultConfig(p.Context(),
 			config.WithRegion(bucketRegion),
 			config.WithCredentialsProvider(&StaticCredentialsProvider{
 				Value:  p.accessKey,
@@ -1610,7 +1845,7 @@ func (p *AWSProvider) enumerateS3Buckets() ([]interface{}, error) {
 // enumerateIAMUsers 枚举IAM用户
 func (p *AWSProvider) enumerateIAMUsers() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取IAM用户列表
@@ -1635,7 +1870,7 @@ func (p *AWSProvider) enumerateIAMUsers() ([]interface{}, error) {
 // enumerateIAMRoles 枚举IAM角色
 func (p *AWSProvider) enumerateIAMRoles() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取IAM角色列表
@@ -1660,7 +1895,7 @@ func (p *AWSProvider) enumerateIAMRoles() ([]interface{}, error) {
 // enumerateVPCs 枚举VPC资源
 func (p *AWSProvider) enumerateVPCs() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取VPC列表
@@ -1694,7 +1929,7 @@ func (p *AWSProvider) enumerateVPCs() ([]interface{}, error) {
 // enumerateRouteTables 枚举路由表资源
 func (p *AWSProvider) enumerateRouteTables() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取路由表列表
@@ -1737,7 +1972,7 @@ func (p *AWSProvider) enumerateRouteTables() ([]interface{}, error) {
 // enumerateELBs 枚举ELB资源
 func (p *AWSProvider) enumerateELBs() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取ELB列表
@@ -1766,7 +2001,7 @@ func (p *AWSProvider) enumerateELBs() ([]interface{}, error) {
 // enumerateEKSClusters 枚举EKS集群
 func (p *AWSProvider) enumerateEKSClusters() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取EKS集群列表
@@ -1807,7 +2042,7 @@ func (p *AWSProvider) enumerateEKSClusters() ([]interface{}, error) {
 // enumerateKMSKeys 枚举KMS密钥
 func (p *AWSProvider) enumerateKMSKeys() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取KMS密钥列表
@@ -1845,7 +2080,7 @@ func (p *AWSProvider) enumerateKMSKeys() ([]interface{}, error) {
 // enumerateRDSInstances 枚举RDS数据库实例
 func (p *AWSProvider) enumerateRDSInstances() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取RDS实例列表
@@ -1878,7 +2113,7 @@ func (p *AWSProvider) enumerateRDSInstances() ([]interface{}, error) {
 // enumerateLambdaFunctions 枚举Lambda函数
 func (p *AWSProvider) enumerateLambdaFunctions() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取Lambda函数列表
@@ -1908,7 +2143,7 @@ func (p *AWSProvider) enumerateLambdaFunctions() ([]interface{}, error) {
 // enumerateAPIGateways 枚举API Gateway
 func (p *AWSProvider) enumerateAPIGateways() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取API Gateway列表
@@ -1937,7 +2172,7 @@ func (p *AWSProvider) enumerateAPIGateways() ([]interface{}, error) {
 // enumerateCloudTrails 枚举CloudTrail
 func (p *AWSProvider) enumerateCloudTrails() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取CloudTrail列表
@@ -1967,7 +2202,7 @@ func (p *AWSProvider) enumerateCloudTrails() ([]interface{}, error) {
 // enumerateCloudWatchLogGroups 枚举CloudWatch Logs
 func (p *AWSProvider) enumerateCloudWatchLogGroups() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取CloudWatch Log Groups列表
@@ -1994,7 +2229,7 @@ func (p *AWSProvider) enumerateCloudWatchLogGroups() ([]interface{}, error) {
 // enumerateDynamoDBTables 枚举DynamoDB表
 func (p *AWSProvider) enumerateDynamoDBTables() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取DynamoDB表列表
@@ -2034,7 +2269,7 @@ func (p *AWSProvider) enumerateDynamoDBTables() ([]interface{}, error) {
 // enumerateSecretsManager 枚举Secrets Manager
 func (p *AWSProvider) enumerateSecretsManager() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取Secrets列表
@@ -2062,7 +2297,7 @@ func (p *AWSProvider) enumerateSecretsManager() ([]interface{}, error) {
 // enumerateSNSTopics 枚举SNS主题
 func (p *AWSProvider) enumerateSNSTopics() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取SNS主题列表
@@ -2085,7 +2320,7 @@ func (p *AWSProvider) enumerateSNSTopics() ([]interface{}, error) {
 // enumerateSQSQueues 枚举SQS队列
 func (p *AWSProvider) enumerateSQSQueues() ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用AWS SDK获取SQS队列列表
@@ -2111,7 +2346,7 @@ func (p *AWSProvider) enumerateSQSQueues() ([]interface{}, error) {
 // EscalatePrivileges 权限提升
 func (p *AWSProvider) EscalatePrivileges() (map[string]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用IAM GetUser API获取用户信息
@@ -2212,7 +2447,7 @@ func (p *AWSProvider) EscalatePrivileges() (map[string]interface{}, error) {
 					potentialEscalation = analyzePotentialEscalation(permissions)
 
 					// 计算风险等级
-					riskLevel = calculateRiskLevel(permissions, potentialEscalation)
+					riskLevel = capability.RiskLevel(capability.Map(permissions, capabilityRules))
 				} else if userType == "IAM Role" {
 					// 尝试获取角色的权限
 					rolePolicies, err := p.getRolePolicies(ctx, userName)
@@ -2233,18 +2468,23 @@ func (p *AWSProvider) EscalatePrivileges() (map[string]interface{}, error) {
 					// 分析潜在的权限提升路径
 					potentialEscalation = analyzePotentialEscalation(permissions)
 					// 计算风险等级
-					riskLevel = calculateRiskLevel(permissions, potentialEscalation)
+					riskLevel = capability.RiskLevel(capability.Map(permissions, capabilityRules))
 				}
 			}
 		}
 	}
 
+	// 将原生权限映射为标准化能力
+	capabilities := capability.Map(permissions, capabilityRules)
+
 	// 返回前端期望的数据结构
 	return map[string]interface{}{
 		"user":                userName,
 		"userType":            userType,
 		"role":                "None",
 		"permissions":         permissions,
+		"capabilities":        capabilities,
+		"riskScore":           capability.Score(capabilities),
 		"potentialEscalation": potentialEscalation,
 		"riskLevel":           riskLevel,
 		"message":             "Privilege escalation attempted",
@@ -2459,39 +2699,55 @@ func analyzePotentialEscalation(permissions []string) []string {
 	return potentialEscalation
 }
 
-// calculateRiskLevel 计算风险等级
-func calculateRiskLevel(permissions []string, potentialEscalation []string) string {
-	// 检查是否是Root用户
-	for _, perm := range permissions {
-		if perm == "*:*" {
-			return "High"
-		}
-	}
-
-	// 检查是否有管理员权限
-	for _, perm := range permissions {
-		if strings.Contains(perm, "AdministratorAccess") {
-			return "High"
-		}
-	}
-
-	// 检查潜在的提升路径数量
-	if len(potentialEscalation) > 2 {
-		return "Medium"
-	}
-
-	// 检查是否有IAM相关的提升路径
-	for _, path := range potentialEscalation {
-		if strings.Contains(path, "IAM") {
-			return "Medium"
-		}
-	}
-
-	return "Low"
+// capabilityRules AWS 原生权限（托管策略名称及操作）到标准化能力的映射
+var capabilityRules = []capability.Rule{
+	{Pattern: "*:*", Capabilities: capability.All},
+	{Pattern: "All Permissions", Capabilities: capability.All},
+	{Pattern: "AdministratorAccess", Capabilities: capability.All},
+	{Pattern: "PowerUserAccess", Capabilities: capability.AllExceptIdentity},
+	{Pattern: "IAMFullAccess", Capabilities: []string{capability.IdentityAdmin, capability.IdentityRead}},
+	{Pattern: "IAMReadOnlyAccess", Capabilities: []string{capability.IdentityRead}},
+	{Pattern: "ReadOnlyAccess", Capabilities: []string{capability.IdentityRead, capability.StorageReadAll}},
+	{Pattern: "iam:*", Capabilities: []string{capability.IdentityAdmin, capability.IdentityRead}},
+	{Pattern: "iam:Attach*Policy", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "iam:Put*Policy", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "iam:CreatePolicyVersion", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "iam:CreateAccessKey", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "iam:UpdateAssumeRolePolicy", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "iam:List*", Capabilities: []string{capability.IdentityRead}},
+	{Pattern: "iam:Get*", Capabilities: []string{capability.IdentityRead}},
+	{Pattern: "AmazonEC2FullAccess", Capabilities: []string{capability.ComputeAdmin, capability.NetworkAdmin}},
+	{Pattern: "ec2:*", Capabilities: []string{capability.ComputeAdmin, capability.NetworkAdmin}},
+	{Pattern: "ec2:RunInstances", Capabilities: []string{capability.ComputeAdmin}},
+	{Pattern: "AmazonSSMFullAccess", Capabilities: []string{capability.ComputeExec, capability.SecretsRead}},
+	{Pattern: "ssm:*", Capabilities: []string{capability.ComputeExec, capability.SecretsRead}},
+	{Pattern: "ssm:SendCommand", Capabilities: []string{capability.ComputeExec}},
+	{Pattern: "ssm:StartSession", Capabilities: []string{capability.ComputeExec}},
+	{Pattern: "ssm:GetParameter*", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "AmazonS3FullAccess", Capabilities: []string{capability.StorageReadAll, capability.StorageWrite}},
+	{Pattern: "AmazonS3ReadOnlyAccess", Capabilities: []string{capability.StorageReadAll}},
+	{Pattern: "s3:*", Capabilities: []string{capability.StorageReadAll, capability.StorageWrite}},
+	{Pattern: "s3:GetObject", Capabilities: []string{capability.StorageReadAll}},
+	{Pattern: "s3:PutObject", Capabilities: []string{capability.StorageWrite}},
+	{Pattern: "s3:DeleteObject", Capabilities: []string{capability.StorageWrite}},
+	{Pattern: "SecretsManagerReadWrite", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "secretsmanager:*", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "secretsmanager:GetSecretValue", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "kms:Decrypt", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "AWSCloudTrail_FullAccess", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "cloudtrail:*", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "cloudtrail:StopLogging", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "cloudtrail:DeleteTrail", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "cloudtrail:UpdateTrail", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "AmazonVPCFullAccess", Capabilities: []string{capability.NetworkAdmin}},
+	{Pattern: "ec2:AuthorizeSecurityGroupIngress", Capabilities: []string{capability.NetworkAdmin}},
 }
 
 // OperateResource 资源操作
+// params 中 dry_run 为 true 时只返回将要执行的修改列表（plan.Plan），不修改任何云上资源
 func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, params map[string]interface{}) (map[string]interface{}, error) {
+	dryRun := plan.IsDryRun(params)
+
 	// 处理联邦登录操作
 	if action == "federated_login" {
 		// 实现简化的AWS联邦登录流程，参考用户提供的代码
@@ -2506,7 +2762,7 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 		}
 
 		// 创建配置
-		cfg, err := config.LoadDefaultConfig(context.Background(),
+		cfg, err := config.LoadDefaultConfig(p.Context(),
 			config.WithRegion(reqRegion),
 			config.WithCredentialsProvider(&StaticCredentialsProvider{
 				Value:  p.accessKey,
@@ -2520,24 +2776,54 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 		// 创建STS客户端
 		stsClient := sts.NewFromConfig(cfg)
 
-		// 管理员权限策略文档
-		adminPolicy := `{
-			"Version": "2012-10-17",
-			"Statement": [
-				{
-					"Effect": "Allow",
-					"Action": "*",
-					"Resource": "*"
-				}
-			]
-		}`
+		// 签发联邦凭证前确认目标账号在授权范围内
+		if p.Enabled() {
+			var accountID string
+			if identity, err := stsClient.GetCallerIdentity(p.Context(), &sts.GetCallerIdentityInput{}); err == nil {
+				accountID = aws.ToString(identity.Account)
+			}
+			if err := p.CheckScope(scope.Action{
+				Class:     scope.ClassFederation,
+				Operation: "federated_login",
+				AccountID: accountID,
+				Region:    reqRegion,
+			}); err != nil {
+				return nil, err
+			}
+		}
+
+		// 根据参数确定会话策略、有效期和会话名称
+		session, err := parseFederationSession(params)
+		if err != nil {
+			return nil, err
+		}
+
+		if dryRun {
+			ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
+			defer cancel()
+			recorder := newPlanRecorder(resourceType, action, resourceID, p.iamClient)
+			recorder.simulate(ctx, plan.Mutation{
+				Service:     "sts",
+				API:         "GetFederationToken",
+				Resource:    session.Name,
+				Description: fmt.Sprintf("签发会话名称为 %s、策略预设为 %s、有效期 %d 秒的联邦令牌", session.Name, session.Preset, session.DurationSeconds),
+			}, "*", "sts:GetFederationToken")
+			return recorder.plan.Result(), nil
+		}
 
 		// 步骤2: 调用GetFederationToken获取联邦令牌
-		resp, err := stsClient.GetFederationToken(context.Background(), &sts.GetFederationTokenInput{
-			Name:            aws.String("federated-user"),
-			DurationSeconds: aws.Int32(3600), // 1小时有效期
-			Policy:          aws.String(adminPolicy),
-		})
+		// 联邦用户的最终权限为当前IAM用户权限与会话策略的交集
+		tokenInput := &sts.GetFederationTokenInput{
+			Name:            aws.String(session.Name),
+			DurationSeconds: aws.Int32(session.DurationSeconds),
+		}
+		if session.PolicyDocument != "" {
+			tokenInput.Policy = aws.String(session.PolicyDocument)
+		}
+		for _, arn := range session.PolicyArns {
+			tokenInput.PolicyArns = append(tokenInput.PolicyArns, stsTypes.PolicyDescriptorType{Arn: aws.String(arn)})
+		}
+		resp, err := stsClient.GetFederationToken(p.Context(), tokenInput)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get federation token: %w", err)
 		}
@@ -2551,17 +2837,30 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 		// 步骤4: 检查用户是否是根用户
 		isRoot, _ := p.isRootUser()
 
-		return map[string]interface{}{
-			"message":             "Federated login successful",
-			"federated_login_url": federatedLoginURL,
-			"region":              reqRegion,
-			"access_key":          resp.Credentials.AccessKeyId,
-			"secret_key":          resp.Credentials.SecretAccessKey,
-			"session_token":       resp.Credentials.SessionToken,
-			"expiration":          resp.Credentials.Expiration,
-			"federated":           true,
-			"is_root":             isRoot,
-		}, nil
+		login := &federation.Login{
+			Provider:     "AWS",
+			URL:          federatedLoginURL,
+			Expiry:       aws.ToTime(resp.Credentials.Expiration),
+			AccessKey:    aws.ToString(resp.Credentials.AccessKeyId),
+			SecretKey:    aws.ToString(resp.Credentials.SecretAccessKey),
+			SessionToken: aws.ToString(resp.Credentials.SessionToken),
+			Region:       reqRegion,
+			Extra: map[string]interface{}{
+				"is_root":            isRoot,
+				"session_name":       session.Name,
+				"policy_preset":      session.Preset,
+				"policy_document":    session.PolicyDocument,
+				"policy_arns":        session.PolicyArns,
+				"duration_seconds":   session.DurationSeconds,
+				"federated_user_arn": aws.ToString(resp.FederatedUser.Arn),
+			},
+		}
+		return login.Result(), nil
+	}
+
+	// 撤销指定会话名称的联邦令牌
+	if action == "revoke_federation_token" {
+		return p.revokeFederationToken(resourceType, action, resourceID, params)
 	}
 
 	// 处理EC2实例操作
@@ -2593,10 +2892,10 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 
 			if instanceRegion != p.region {
 				// 创建新的客户端，使用实例的区域
-				executionSteps = append(executionSteps, fmt.Sprintf("创建实例区域 (%s) 的AWS客户端...", instanceRegion))
-				regionProvider, err := NewAWSProvider(p.accessKey, p.secretKey, instanceRegion)
+				executionSteps = p.AddStep(executionSteps, fmt.Sprintf("创建实例区域 (%s) 的AWS客户端...", instanceRegion))
+				regionProvider, err := p.newRegionProvider(instanceRegion)
 				if err != nil {
-					executionSteps = append(executionSteps, fmt.Sprintf("创建AWS客户端失败: %v", err))
+					executionSteps = p.AddStep(executionSteps, fmt.Sprintf("创建AWS客户端失败: %v", err))
 					return map[string]interface{}{
 						"message":        "Failed to create AWS provider for instance region",
 						"instanceId":     resourceID,
@@ -2605,30 +2904,30 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 						"executionSteps": executionSteps,
 					}, nil
 				}
-				executionSteps = append(executionSteps, "AWS客户端创建成功")
+				executionSteps = p.AddStep(executionSteps, "AWS客户端创建成功")
 				ec2Client = regionProvider.ec2Client
 				iamClient = regionProvider.iamClient
 				ssmClient = regionProvider.ssmClient
 			} else {
 				// 使用当前客户端
-				executionSteps = append(executionSteps, "使用当前区域的AWS客户端")
+				executionSteps = p.AddStep(executionSteps, "使用当前区域的AWS客户端")
 				ec2Client = p.ec2Client
 				iamClient = p.iamClient
 				ssmClient = p.ssmClient
 			}
 
 			// 检查实例状态
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 			defer cancel()
 
 			// 检查实例状态
-			executionSteps = append(executionSteps, "检查实例状态...")
+			executionSteps = p.AddStep(executionSteps, "检查实例状态...")
 			ec2Input := &ec2.DescribeInstancesInput{
 				InstanceIds: []string{resourceID},
 			}
 			ec2Resp, err := ec2Client.DescribeInstances(ctx, ec2Input)
 			if err != nil {
-				executionSteps = append(executionSteps, fmt.Sprintf("检查实例状态失败: %v", err))
+				executionSteps = p.AddStep(executionSteps, fmt.Sprintf("检查实例状态失败: %v", err))
 				return map[string]interface{}{
 					"message":        "Failed to check instance status",
 					"instanceId":     resourceID,
@@ -2639,7 +2938,7 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 			}
 
 			if len(ec2Resp.Reservations) == 0 || len(ec2Resp.Reservations[0].Instances) == 0 {
-				executionSteps = append(executionSteps, "实例不存在")
+				executionSteps = p.AddStep(executionSteps, "实例不存在")
 				return map[string]interface{}{
 					"message":        "Instance not found",
 					"instanceId":     resourceID,
@@ -2651,7 +2950,7 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 
 			instance := ec2Resp.Reservations[0].Instances[0]
 			if instance.State == nil || string(instance.State.Name) != "running" {
-				executionSteps = append(executionSteps, fmt.Sprintf("实例状态不是running，当前状态: %s", string(instance.State.Name)))
+				executionSteps = p.AddStep(executionSteps, fmt.Sprintf("实例状态不是running，当前状态: %s", string(instance.State.Name)))
 				return map[string]interface{}{
 					"message":        "Instance is not in running state",
 					"instanceId":     resourceID,
@@ -2660,13 +2959,35 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 					"executionSteps": executionSteps,
 				}, nil
 			}
-			executionSteps = append(executionSteps, "实例状态检查通过，状态为running")
+			executionSteps = p.AddStep(executionSteps, "实例状态检查通过，状态为running")
+
+			// 执行命令前确认实例在授权范围内，越界时返回错误使任务失败
+			if err := p.CheckScope(instanceAction(scope.ClassExecute, "execute_command", instanceRegion, ec2Resp.Reservations[0], instance)); err != nil {
+				executionSteps = p.AddStep(executionSteps, fmt.Sprintf("超出授权范围: %v", err))
+				return nil, err
+			}
+
+			// dry_run 时只生成创建实例配置文件和执行命令的计划
+			if dryRun {
+				recorder := newPlanRecorder(resourceType, action, resourceID, iamClient)
+				if err := p.checkAndCreateInstanceProfileWithClient(ctx, resourceID, ec2Client, iamClient, recorder); err != nil {
+					return nil, fmt.Errorf("failed to plan instance profile: %w", err)
+				}
+				instanceARN := instanceAction(scope.ClassExecute, action, instanceRegion, ec2Resp.Reservations[0], instance).Resource
+				recorder.simulate(ctx, plan.Mutation{
+					Service:     "ssm",
+					API:         "SendCommand",
+					Resource:    instanceARN,
+					Description: "通过 AWS-RunShellScript 执行命令: " + command,
+				}, instanceARN, "ssm:SendCommand")
+				return recorder.plan.Result(), nil
+			}
 
 			// 检查并创建实例配置文件
-			executionSteps = append(executionSteps, "检查实例配置文件...")
-			err = p.checkAndCreateInstanceProfileWithClient(ctx, resourceID, ec2Client, iamClient)
+			executionSteps = p.AddStep(executionSteps, "检查实例配置文件...")
+			err = p.checkAndCreateInstanceProfileWithClient(ctx, resourceID, ec2Client, iamClient, nil)
 			if err != nil {
-				executionSteps = append(executionSteps, fmt.Sprintf("检查实例配置文件失败: %v", err))
+				executionSteps = p.AddStep(executionSteps, fmt.Sprintf("检查实例配置文件失败: %v", err))
 				return map[string]interface{}{
 					"message":        "Failed to check or create instance profile",
 					"instanceId":     resourceID,
@@ -2675,14 +2996,14 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 					"executionSteps": executionSteps,
 				}, nil
 			}
-			executionSteps = append(executionSteps, "实例配置文件检查完成")
+			executionSteps = p.AddStep(executionSteps, "实例配置文件检查完成")
 
 			// 创建带有超时的上下文
-			ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+			ctx, cancel = context.WithTimeout(p.Context(), 30*time.Second)
 			defer cancel()
 
 			// 调用SSM SendCommand API执行命令
-			executionSteps = append(executionSteps, "执行命令...")
+			executionSteps = p.AddStep(executionSteps, "执行命令...")
 			resp, err := ssmClient.SendCommand(ctx, &ssm.SendCommandInput{
 				InstanceIds:  []string{resourceID},
 				DocumentName: aws.String("AWS-RunShellScript"),
@@ -2691,19 +3012,19 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 				},
 			})
 			if err != nil {
-				executionSteps = append(executionSteps, fmt.Sprintf("发送命令失败: %v", err))
-				executionSteps = append(executionSteps, "可能的原因:")
-				executionSteps = append(executionSteps, "1. SSM Agent 未安装或未运行")
-				executionSteps = append(executionSteps, "2. 实例没有互联网连接")
-				executionSteps = append(executionSteps, "3. 实例配置文件尚未生效")
-				executionSteps = append(executionSteps, "4. 安全组没有允许SSM流量")
-				executionSteps = append(executionSteps, "5. 实例状态不是running")
-				executionSteps = append(executionSteps, "建议:")
-				executionSteps = append(executionSteps, "- 确保实例状态为running")
-				executionSteps = append(executionSteps, "- 确保SSM Agent已安装并运行")
-				executionSteps = append(executionSteps, "- 确保实例有互联网连接")
-				executionSteps = append(executionSteps, "- 确保安全组允许SSM流量")
-				executionSteps = append(executionSteps, "- 等待10分钟后再尝试，确保实例配置文件生效")
+				executionSteps = p.AddStep(executionSteps, fmt.Sprintf("发送命令失败: %v", err))
+				executionSteps = p.AddStep(executionSteps, "可能的原因:")
+				executionSteps = p.AddStep(executionSteps, "1. SSM Agent 未安装或未运行")
+				executionSteps = p.AddStep(executionSteps, "2. 实例没有互联网连接")
+				executionSteps = p.AddStep(executionSteps, "3. 实例配置文件尚未生效")
+				executionSteps = p.AddStep(executionSteps, "4. 安全组没有允许SSM流量")
+				executionSteps = p.AddStep(executionSteps, "5. 实例状态不是running")
+				executionSteps = p.AddStep(executionSteps, "建议:")
+				executionSteps = p.AddStep(executionSteps, "- 确保实例状态为running")
+				executionSteps = p.AddStep(executionSteps, "- 确保SSM Agent已安装并运行")
+				executionSteps = p.AddStep(executionSteps, "- 确保实例有互联网连接")
+				executionSteps = p.AddStep(executionSteps, "- 确保安全组允许SSM流量")
+				executionSteps = p.AddStep(executionSteps, "- 等待10分钟后再尝试，确保实例配置文件生效")
 				return map[string]interface{}{
 					"message":        "Failed to send command",
 					"instanceId":     resourceID,
@@ -2715,14 +3036,24 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 			}
 
 			commandID := *resp.Command.CommandId
-			executionSteps = append(executionSteps, fmt.Sprintf("命令已发送，CommandId: %s", commandID))
-			executionSteps = append(executionSteps, "正在等待命令执行结果...")
+			executionSteps = p.AddStep(executionSteps, fmt.Sprintf("命令已发送，CommandId: %s", commandID))
+			executionSteps = p.AddStep(executionSteps, "正在等待命令执行结果...")
 
-			// 等待命令执行完成
-			time.Sleep(3 * time.Second)
+			// 等待命令执行完成，期间任务被取消时返回已发送的命令
+			if err := p.Sleep(3 * time.Second); err != nil {
+				executionSteps = p.AddStep(executionSteps, "任务已取消，停止等待命令执行结果")
+				return map[string]interface{}{
+					"message":        "Command sent but task was cancelled before the result was fetched",
+					"instanceId":     resourceID,
+					"commandId":      commandID,
+					"command":        command,
+					"status":         "cancelled",
+					"executionSteps": executionSteps,
+				}, err
+			}
 
 			// 获取命令执行结果
-			executionSteps = append(executionSteps, "获取命令执行结果...")
+			executionSteps = p.AddStep(executionSteps, "获取命令执行结果...")
 			invocationInput := &ssm.GetCommandInvocationInput{
 				CommandId:  aws.String(commandID),
 				InstanceId: aws.String(resourceID),
@@ -2730,7 +3061,7 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 
 			invocationResp, err := ssmClient.GetCommandInvocation(ctx, invocationInput)
 			if err != nil {
-				executionSteps = append(executionSteps, fmt.Sprintf("获取命令执行结果失败: %v", err))
+				executionSteps = p.AddStep(executionSteps, fmt.Sprintf("获取命令执行结果失败: %v", err))
 				// 如果获取结果失败，返回命令ID和状态
 				return map[string]interface{}{
 					"message":        "Command sent but failed to get result",
@@ -2744,11 +3075,11 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 				}, nil
 			}
 
-			executionSteps = append(executionSteps, "命令执行结果:")
-			executionSteps = append(executionSteps, *invocationResp.StandardOutputContent)
+			executionSteps = p.AddStep(executionSteps, "命令执行结果:")
+			executionSteps = p.AddStep(executionSteps, *invocationResp.StandardOutputContent)
 			if invocationResp.StandardErrorContent != nil && *invocationResp.StandardErrorContent != "" {
-				executionSteps = append(executionSteps, "错误输出:")
-				executionSteps = append(executionSteps, *invocationResp.StandardErrorContent)
+				executionSteps = p.AddStep(executionSteps, "错误输出:")
+				executionSteps = p.AddStep(executionSteps, *invocationResp.StandardErrorContent)
 			}
 
 			// 构建结果
@@ -2779,6 +3110,13 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 		}
 	}
 
+	// 其余操作不修改云上资源，执行计划为空
+	if dryRun {
+		recorder := newPlanRecorder(resourceType, action, resourceID, p.iamClient)
+		recorder.note("操作不修改云上资源")
+		return recorder.plan.Result(), nil
+	}
+
 	// 处理S3存储桶操作
 	if resourceType == "s3" {
 		switch action {
@@ -2805,7 +3143,7 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 			}
 
 			// 获取存储桶的实际区域
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 			defer cancel()
 
 			location, err := p.s3Client.GetBucketLocation(ctx, &s3.GetBucketLocationInput{
@@ -2825,7 +3163,7 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 			}
 
 			// 创建使用存储桶实际区域的S3客户端
-			cfg, err := config.LoadDefaultConfig(context.Background(),
+			cfg, err := config.LoadDefaultConfig(p.Context(),
 				config.WithRegion(bucketRegion),
 				config.WithCredentialsProvider(&StaticCredentialsProvider{
 					Value:  p.accessKey,
@@ -2844,7 +3182,7 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 				Bucket: aws.String(resourceID),
 				Key:    aws.String(key),
 			}
-			presignedURL, err := presignClient.PresignGetObject(context.Background(), getObjectInput, s3.WithPresignExpires(15*time.Minute))
+			presignedURL, err := presignClient.PresignGetObject(p.Context(), getObjectInput, s3.WithPresignExpires(15*time.Minute))
 			if err != nil {
 				return nil, fmt.Errorf("failed to generate presigned URL: %w", err)
 			}
@@ -2872,7 +3210,7 @@ func (p *AWSProvider) OperateResource(resourceType, action, resourceID string, p
 // listS3Objects 列出S3存储桶中的对象
 func (p *AWSProvider) listS3Objects(bucketName, prefix string) ([]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 30*time.Second)
 	defer cancel()
 
 	// 获取存储桶的实际区域
@@ -2893,7 +3231,7 @@ func (p *AWSProvider) listS3Objects(bucketName, prefix string) ([]interface{}, e
 	}
 
 	// 创建使用存储桶实际区域的S3客户端
-	cfg, err := config.LoadDefaultConfig(context.Background(),
+	cfg, err := config.LoadDefaultConfig(p.Context(),
 		config.WithRegion(bucketRegion),
 		config.WithCredentialsProvider(&StaticCredentialsProvider{
 			Value:  p.accessKey,
@@ -2955,11 +3293,12 @@ func (p *AWSProvider) Takeover() (map[string]interface{}, error) {
 
 // checkAndCreateInstanceProfile 检查并创建实例配置文件
 func (p *AWSProvider) checkAndCreateInstanceProfile(ctx context.Context, instanceID string) error {
-	return p.checkAndCreateInstanceProfileWithClient(ctx, instanceID, p.ec2Client, p.iamClient)
+	return p.checkAndCreateInstanceProfileWithClient(ctx, instanceID, p.ec2Client, p.iamClient, nil)
 }
 
 // checkAndCreateInstanceProfileWithClient 检查并创建实例配置文件（使用指定的客户端）
-func (p *AWSProvider) checkAndCreateInstanceProfileWithClient(ctx context.Context, instanceID string, ec2Client *ec2.Client, iamClient *iam.Client) error {
+// recorder 不为 nil 时只把需要创建的角色、实例配置文件和关联记录到执行计划中，不做任何修改
+func (p *AWSProvider) checkAndCreateInstanceProfileWithClient(ctx context.Context, instanceID string, ec2Client *ec2.Client, iamClient *iam.Client, recorder *planRecorder) error {
 	// 检查实例是否已经有关联的实例配置文件
 	ec2Input := &ec2.DescribeInstancesInput{
 		InstanceIds: []string{instanceID},
@@ -2972,12 +3311,23 @@ func (p *AWSProvider) checkAndCreateInstanceProfileWithClient(ctx context.Contex
 
 	instance := ec2Resp.Reservations[0].Instances[0]
 	if instance.IamInstanceProfile != nil {
+		if recorder != nil {
+			recorder.note("实例已关联实例配置文件 %s，无需创建角色和实例配置文件", aws.ToString(instance.IamInstanceProfile.Arn))
+		}
 		return nil
 	}
 
+	// 创建角色和实例配置文件前确认实例在授权范围内
+	if err := p.CheckScope(instanceAction(scope.ClassIAMWrite, "create_instance_profile", ec2Client.Options().Region, ec2Resp.Reservations[0], instance)); err != nil {
+		return err
+	}
+
 	// 创建实例配置文件和角色
 	profileName := "aws-key-tools-profile"
 	roleName := "aws-key-tools-role"
+	accountID := aws.ToString(ec2Resp.Reservations[0].OwnerId)
+	roleARN := fmt.Sprintf("arn:aws:iam::%s:role/%s", accountID, roleName)
+	profileARN := fmt.Sprintf("arn:aws:iam::%s:instance-profile/%s", accountID, profileName)
 
 	// 检查角色是否存在
 	roleExists := false
@@ -2992,7 +3342,20 @@ func (p *AWSProvider) checkAndCreateInstanceProfileWithClient(ctx context.Contex
 		}
 	}
 
-	if !roleExists {
+	if !roleExists && recorder != nil {
+		recorder.simulate(ctx, plan.Mutation{
+			Service:     "iam",
+			API:         "CreateRole",
+			Resource:    roleARN,
+			Description: "创建允许EC2服务扮演的角色 " + roleName,
+		}, roleARN, "iam:CreateRole")
+		recorder.simulate(ctx, plan.Mutation{
+			Service:     "iam",
+			API:         "AttachRolePolicy",
+			Resource:    roleARN,
+			Description: "为角色附加 AmazonSSMManagedInstanceCore 策略",
+		}, roleARN, "iam:AttachRolePolicy")
+	} else if !roleExists {
 		// 创建角色
 		createRoleInput := &iam.CreateRoleInput{
 			RoleName: aws.String(roleName),
@@ -3014,6 +3377,7 @@ func (p *AWSProvider) checkAndCreateInstanceProfileWithClient(ctx context.Contex
 		if err != nil {
 			return fmt.Errorf("failed to create role: %w", err)
 		}
+		p.RecordArtifact(artifact.Artifact{Type: artifact.TypeIAMRole, Resource: roleName, Description: "SSM执行命令使用的EC2角色"})
 
 		// 附加SSM权限策略
 		attachPolicyInput := &iam.AttachRolePolicyInput{
@@ -3025,6 +3389,7 @@ func (p *AWSProvider) checkAndCreateInstanceProfileWithClient(ctx context.Contex
 		if err != nil {
 			return fmt.Errorf("failed to attach policy: %w", err)
 		}
+		p.RecordArtifact(artifact.Artifact{Type: artifact.TypeRolePolicy, Resource: aws.ToString(attachPolicyInput.PolicyArn), Parent: roleName})
 	}
 
 	// 检查实例配置文件是否存在
@@ -3040,7 +3405,20 @@ func (p *AWSProvider) checkAndCreateInstanceProfileWithClient(ctx context.Contex
 		}
 	}
 
-	if !profileExists {
+	if !profileExists && recorder != nil {
+		recorder.simulate(ctx, plan.Mutation{
+			Service:     "iam",
+			API:         "CreateInstanceProfile",
+			Resource:    profileARN,
+			Description: "创建实例配置文件 " + profileName,
+		}, profileARN, "iam:CreateInstanceProfile")
+		recorder.simulate(ctx, plan.Mutation{
+			Service:     "iam",
+			API:         "AddRoleToInstanceProfile",
+			Resource:    profileARN,
+			Description: fmt.Sprintf("将角色 %s 添加到实例配置文件", roleName),
+		}, profileARN, "iam:AddRoleToInstanceProfile")
+	} else if !profileExists {
 		// 创建实例配置文件
 		createProfileInput := &iam.CreateInstanceProfileInput{
 			InstanceProfileName: aws.String(profileName),
@@ -3050,6 +3428,7 @@ func (p *AWSProvider) checkAndCreateInstanceProfileWithClient(ctx context.Contex
 		if err != nil {
 			return fmt.Errorf("failed to create instance profile: %w", err)
 		}
+		p.RecordArtifact(artifact.Artifact{Type: artifact.TypeInstanceProfile, Resource: profileName})
 
 		// 将角色添加到实例配置文件
 		addRoleInput := &iam.AddRoleToInstanceProfileInput{
@@ -3061,10 +3440,24 @@ func (p *AWSProvider) checkAndCreateInstanceProfileWithClient(ctx context.Contex
 		if err != nil {
 			return fmt.Errorf("failed to add role to instance profile: %w", err)
 		}
+		p.RecordArtifact(artifact.Artifact{Type: artifact.TypeInstanceProfileRole, Resource: roleName, Parent: profileName})
+	}
+
+	if recorder != nil {
+		instanceARN := instanceAction(scope.ClassIAMWrite, "associate_instance_profile", ec2Client.Options().Region, ec2Resp.Reservations[0], instance).Resource
+		recorder.simulate(ctx, plan.Mutation{
+			Service:     "ec2",
+			API:         "AssociateIamInstanceProfile",
+			Resource:    instanceARN,
+			Description: fmt.Sprintf("将实例配置文件 %s 关联到实例 %s", profileName, instanceID),
+		}, instanceARN, "ec2:AssociateIamInstanceProfile")
+		return nil
 	}
 
 	// 等待实例配置文件创建完成
-	time.Sleep(5 * time.Second)
+	if err := p.Sleep(5 * time.Second); err != nil {
+		return err
+	}
 
 	// 验证实例配置文件是否存在
 	var getProfileErr error
@@ -3079,7 +3472,9 @@ func (p *AWSProvider) checkAndCreateInstanceProfileWithClient(ctx context.Contex
 		if getProfileErr == nil {
 			break
 		}
-		time.Sleep(2 * time.Second)
+		if err := p.Sleep(2 * time.Second); err != nil {
+			return err
+		}
 	}
 
 	if getProfileErr != nil {
@@ -3087,7 +3482,9 @@ func (p *AWSProvider) checkAndCreateInstanceProfileWithClient(ctx context.Contex
 	}
 
 	// 等待实例配置文件可用
-	time.Sleep(10 * time.Second)
+	if err := p.Sleep(10 * time.Second); err != nil {
+		return err
+	}
 
 	// 将实例配置文件附加到EC2实例
 	associateInput := &ec2.AssociateIamInstanceProfileInput{
@@ -3097,17 +3494,43 @@ func (p *AWSProvider) checkAndCreateInstanceProfileWithClient(ctx context.Contex
 		InstanceId: aws.String(instanceID),
 	}
 
-	_, err = ec2Client.AssociateIamInstanceProfile(ctx, associateInput)
+	association, err := ec2Client.AssociateIamInstanceProfile(ctx, associateInput)
 	if err != nil {
 		return fmt.Errorf("failed to associate instance profile: %w", err)
 	}
+	if association.IamInstanceProfileAssociation != nil {
+		p.RecordArtifact(artifact.Artifact{
+			Type:        artifact.TypeProfileAssociation,
+			Resource:    aws.ToString(association.IamInstanceProfileAssociation.AssociationId),
+			Parent:      instanceID,
+			Region:      ec2Client.Options().Region,
+			Description: fmt.Sprintf("实例配置文件 %s 与实例 %s 的关联", profileName, instanceID),
+		})
+	}
 
 	return nil
 }
 
+// instanceAction 构造针对EC2实例的范围检查操作，账号ID取自实例所属的预留
+func instanceAction(class, operation, region string, reservation types.Reservation, instance types.Instance) scope.Action {
+	accountID := aws.ToString(reservation.OwnerId)
+	tags := make(map[string]string, len(instance.Tags))
+	for _, tag := range instance.Tags {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return scope.Action{
+		Class:     class,
+		Operation: operation,
+		AccountID: accountID,
+		Region:    region,
+		Resource:  fmt.Sprintf("arn:aws:ec2:%s:%s:instance/%s", region, accountID, aws.ToString(instance.InstanceId)),
+		Tags:      tags,
+	}
+}
+
 // isRootUser 检查当前用户是否是根用户
 func (p *AWSProvider) isRootUser() (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 尝试调用IAM GetUser API
@@ -3130,7 +3553,7 @@ func (p *AWSProvider) isRootUser() (bool, error) {
 
 // assumeRole 使用AssumeRole API获取临时凭证
 func (p *AWSProvider) assumeRole(roleARN string) (*stsTypes.Credentials, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 创建STS客户端
@@ -3142,7 +3565,7 @@ func (p *AWSProvider) assumeRole(roleARN string) (*stsTypes.Credentials, error)
 		region = "us-east-1"
 	}
 
-	cfg, err := config.LoadDefaultConfig(context.Background(),
+	cfg, err := config.LoadDefaultConfig(p.Context(),
 		config.WithRegion(region),
 		config.WithCredentialsProvider(&StaticCredentialsProvider{
 			Value:  p.accessKey,
@@ -3170,6 +3593,163 @@ func (p *AWSProvider) assumeRole(roleARN string) (*stsTypes.Credentials, error)
 	return response.Credentials, nil
 }
 
+// federationSession 联邦登录会话参数
+type federationSession struct {
+	Name            string
+	DurationSeconds int32
+	Preset          string
+	PolicyDocument  string
+	PolicyArns      []string
+}
+
+// federationSessionNamePattern GetFederationToken 对会话名称的要求
+var federationSessionNamePattern = regexp.MustCompile(`^[\w+=,.@-]{2,32}$`)
+
+// federationServicePattern 服务策略预设中的服务前缀，例如 s3、ec2
+var federationServicePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// parseFederationSession 解析联邦登录参数
+// policy 支持 read-only（默认）、admin、service（配合 service 参数，如 s3）和 custom（配合 policy_document 参数）
+func parseFederationSession(params map[string]interface{}) (*federationSession, error) {
+	session := &federationSession{
+		Name:            "federated-user",
+		DurationSeconds: 3600,
+		Preset:          "read-only",
+	}
+
+	if name, ok := params["session_name"].(string); ok && name != "" {
+		if !federationSessionNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid session_name: must be 2-32 characters of letters, digits and +=,.@-_")
+		}
+		session.Name = name
+	}
+
+	// JSON 数字解码后为 float64
+	if duration, ok := params["duration_seconds"].(float64); ok && duration > 0 {
+		if duration < 900 || duration > 129600 {
+			return nil, fmt.Errorf("invalid duration_seconds: must be between 900 and 129600")
+		}
+		session.DurationSeconds = int32(duration)
+	}
+
+	if preset, ok := params["policy"].(string); ok && preset != "" {
+		session.Preset = preset
+	}
+
+	switch session.Preset {
+	case "read-only":
+		session.PolicyArns = []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"}
+	case "admin":
+		session.PolicyDocument = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"*","Resource":"*"}]}`
+	case "service":
+		service, _ := params["service"].(string)
+		if service == "" || !federationServicePattern.MatchString(service) {
+			return nil, fmt.Errorf("service is required for the service policy preset, e.g. s3")
+		}
+		session.PolicyDocument = fmt.Sprintf(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"%s:*","Resource":"*"}]}`, service)
+	case "custom":
+		document, _ := params["policy_document"].(string)
+		if document == "" || !json.Valid([]byte(document)) {
+			return nil, fmt.Errorf("policy_document must be a valid JSON policy for the custom policy preset")
+		}
+		session.PolicyDocument = document
+	default:
+		return nil, fmt.Errorf("unsupported policy preset: %s", session.Preset)
+	}
+
+	return session, nil
+}
+
+// revokeFederationToken 撤销联邦令牌
+// 联邦令牌无法直接作废，这里为当前IAM用户添加内联策略，拒绝指定会话名称在撤销时间之前签发的所有令牌
+func (p *AWSProvider) revokeFederationToken(resourceType, action, resourceID string, params map[string]interface{}) (map[string]interface{}, error) {
+	sessionName, _ := params["session_name"].(string)
+	if sessionName == "" {
+		return nil, fmt.Errorf("session_name is required")
+	}
+
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
+	defer cancel()
+
+	user, err := p.iamClient.GetUser(ctx, &iam.GetUserInput{})
+	if err != nil || user.User == nil {
+		return nil, fmt.Errorf("failed to get current IAM user (root federation tokens cannot be revoked): %w", err)
+	}
+
+	// 添加拒绝策略前确认目标账号在授权范围内
+	userARN := aws.ToString(user.User.Arn)
+	if p.Enabled() {
+		var accountID string
+		if parts := strings.Split(userARN, ":"); len(parts) > 4 {
+			accountID = parts[4]
+		}
+		if err := p.CheckScope(scope.Action{
+			Class:     scope.ClassFederation,
+			Operation: action,
+			AccountID: accountID,
+			Resource:  userARN,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	policyName := "aws-key-tools-revoke-" + sessionName
+	if plan.IsDryRun(params) {
+		recorder := newPlanRecorder(resourceType, action, resourceID, p.iamClient)
+		recorder.simulate(ctx, plan.Mutation{
+			Service:     "iam",
+			API:         "PutUserPolicy",
+			Resource:    userARN + "/" + policyName,
+			Description: fmt.Sprintf("为用户 %s 添加内联策略 %s，拒绝会话 %s 此前签发的令牌", aws.ToString(user.User.UserName), policyName, sessionName),
+		}, userARN, "iam:PutUserPolicy")
+		return recorder.plan.Result(), nil
+	}
+
+	revokedAt := time.Now().UTC().Format(time.RFC3339)
+	policyDocument, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Effect":   "Deny",
+				"Action":   "*",
+				"Resource": "*",
+				"Condition": map[string]interface{}{
+					"StringLike":   map[string]string{"aws:userid": "*:" + sessionName},
+					"DateLessThan": map[string]string{"aws:TokenIssueTime": revokedAt},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build revoke policy: %w", err)
+	}
+
+	_, err = p.iamClient.PutUserPolicy(ctx, &iam.PutUserPolicyInput{
+		UserName:       user.User.UserName,
+		PolicyName:     aws.String(policyName),
+		PolicyDocument: aws.String(string(policyDocument)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to put revoke policy: %w", err)
+	}
+	// 撤销前签发的令牌最长有效期为 129600 秒，在此之前删除策略会使令牌重新生效
+	p.RecordArtifact(artifact.Artifact{
+		Type:        artifact.TypeUserPolicy,
+		Resource:    policyName,
+		Parent:      aws.ToString(user.User.UserName),
+		RemoveAfter: time.Now().UTC().Add(129600 * time.Second).Format(time.RFC3339),
+		Description: "拒绝会话 " + sessionName + " 已签发联邦令牌的策略",
+	})
+
+	return map[string]interface{}{
+		"message":      "Federation tokens revoked",
+		"session_name": sessionName,
+		"policy_name":  policyName,
+		"user_name":    aws.ToString(user.User.UserName),
+		"revoked_at":   revokedAt,
+	}, nil
+}
+
 // buildFederationURL 构建联邦登录URL
 func (p *AWSProvider) buildFederationURL(creds *stsTypes.Credentials) (string, error) {
 	// 构建凭证JSON
@@ -3230,7 +3810,7 @@ func (p *AWSProvider) getSigninToken(credsEncoded string) (string, error) {
 // GetPermissions 获取权限信息
 func (p *AWSProvider) GetPermissions() (map[string]interface{}, error) {
 	// 创建带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(p.Context(), 10*time.Second)
 	defer cancel()
 
 	// 调用IAM GetUser API获取用户信息
@@ -3281,10 +3861,11 @@ func (p *AWSProvider) GetPermissions() (map[string]interface{}, error) {
 	}
 
 	return map[string]interface{}{
-		"message":     "Permissions retrieved",
-		"userType":    userType,
-		"userName":    userName,
-		"permissions": permissions,
+		"message":      "Permissions retrieved",
+		"userType":     userType,
+		"userName":     userName,
+		"permissions":  permissions,
+		"capabilities": capability.Map(permissions, capabilityRules),
 	}, nil
 }
 
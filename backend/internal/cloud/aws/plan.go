@@ -0,0 +1,104 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamTypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/redteamsec/backend/internal/cloud/plan"
+)
+
+// SupportsDryRun AWS的状态变更操作支持 dry_run 参数
+func (p *AWSProvider) SupportsDryRun() bool {
+	return true
+}
+
+// planRecorder 在 dry_run 模式下记录将要执行的修改并做预检，当前身份的ARN只查询一次
+type planRecorder struct {
+	plan      *plan.Plan
+	iamClient *iam.Client
+
+	principal    string
+	principalErr error
+	resolved     bool
+}
+
+// newPlanRecorder 创建执行计划记录器
+func newPlanRecorder(resourceType, action, resourceID string, iamClient *iam.Client) *planRecorder {
+	return &planRecorder{
+		plan: &plan.Plan{
+			ResourceType: resourceType,
+			Action:       action,
+			ResourceID:   resourceID,
+		},
+		iamClient: iamClient,
+	}
+}
+
+// note 为执行计划添加说明
+func (r *planRecorder) note(format string, args ...interface{}) {
+	r.plan.Notes = append(r.plan.Notes, fmt.Sprintf(format, args...))
+}
+
+// simulate 记录一项修改，通过IAM策略模拟判断当前身份是否有权对资源执行全部接口
+// 执行路径上的IAM、STS、SSM接口和 AssociateIamInstanceProfile 都不支持 DryRun 参数，只能做策略模拟
+func (r *planRecorder) simulate(ctx context.Context, m plan.Mutation, resource string, actions ...string) {
+	m.CheckMethod = "simulate_policy"
+	defer func() { r.plan.Add(m) }()
+
+	principal, err := r.principalARN(ctx)
+	if err != nil {
+		m.Check = plan.CheckNotSupported
+		m.CheckDetail = err.Error()
+		return
+	}
+
+	resp, err := r.iamClient.SimulatePrincipalPolicy(ctx, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principal),
+		ActionNames:     actions,
+		ResourceArns:    []string{resource},
+	})
+	if err != nil {
+		m.Check = plan.CheckError
+		m.CheckDetail = fmt.Sprintf("failed to simulate %s: %v", strings.Join(actions, ", "), err)
+		return
+	}
+	if len(resp.EvaluationResults) == 0 {
+		m.Check = plan.CheckError
+		m.CheckDetail = "policy simulation returned no result"
+		return
+	}
+
+	var denied []string
+	for _, result := range resp.EvaluationResults {
+		if result.EvalDecision != iamTypes.PolicyEvaluationDecisionTypeAllowed {
+			denied = append(denied, fmt.Sprintf("%s: %s", aws.ToString(result.EvalActionName), result.EvalDecision))
+		}
+	}
+	if len(denied) > 0 {
+		m.Check = plan.CheckDenied
+		m.CheckDetail = strings.Join(denied, "; ")
+		return
+	}
+	m.Check = plan.CheckPassed
+}
+
+// principalARN 返回当前IAM用户的ARN，根用户和临时凭证无法做策略模拟
+func (r *planRecorder) principalARN(ctx context.Context) (string, error) {
+	if !r.resolved {
+		r.resolved = true
+		user, err := r.iamClient.GetUser(ctx, &iam.GetUserInput{})
+		switch {
+		case err != nil:
+			r.principalErr = fmt.Errorf("policy simulation requires an IAM user: %w", err)
+		case user.User == nil || strings.HasSuffix(aws.ToString(user.User.Arn), ":root"):
+			r.principalErr = fmt.Errorf("policy simulation is not available for the root user")
+		default:
+			r.principal = aws.ToString(user.User.Arn)
+		}
+	}
+	return r.principal, r.principalErr
+}
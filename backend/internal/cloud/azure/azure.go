@@ -8,6 +8,7 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization"
+	"github.com/redteamsec/backend/internal/cloud/federation"
 )
 
 // AzureProvider Azure云平台实现
@@ -191,6 +192,11 @@ func (p *AzureProvider) EscalatePrivileges() (map[string]interface{}, error) {
 
 // OperateResource 资源操作
 func (p *AzureProvider) OperateResource(resourceType, action, resourceID string, params map[string]interface{}) (map[string]interface{}, error) {
+	// Azure门户只接受用户账号登录，服务主体无法换取门户会话
+	if action == "federated_login" {
+		return nil, federation.ErrUnsupported("Azure", "the Azure portal does not accept service principal credentials")
+	}
+
 	// 这里应该实现Azure资源操作逻辑
 	// 暂时返回模拟数据
 	return map[string]interface{}{
@@ -0,0 +1,128 @@
+package capability
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 与云平台无关的标准化能力，各云平台将自身的原生权限映射到这些能力上
+const (
+	IdentityAdmin  = "identity.admin"   // 可创建/修改身份与授权（用户、角色、策略、密钥）
+	IdentityRead   = "identity.read"    // 可读取身份与授权配置
+	ComputeExec    = "compute.exec"     // 可在计算实例上执行命令
+	ComputeAdmin   = "compute.admin"    // 可创建/修改/删除计算实例
+	StorageReadAll = "storage.read-all" // 可读取对象存储中的全部数据
+	StorageWrite   = "storage.write"    // 可写入/删除对象存储中的数据
+	SecretsRead    = "secrets.read"     // 可读取密钥、凭据等敏感配置
+	LoggingDisable = "logging.disable"  // 可关闭或删除审计日志
+	NetworkAdmin   = "network.admin"    // 可修改网络及安全组配置
+)
+
+// Definition 能力定义
+type Definition struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Weight      int    `json:"weight"`
+}
+
+// Definitions 所有能力定义，权重用于计算风险分数
+var Definitions = []Definition{
+	{Name: IdentityAdmin, Description: "身份与授权管理", Weight: 60},
+	{Name: ComputeExec, Description: "实例命令执行", Weight: 40},
+	{Name: SecretsRead, Description: "读取密钥凭据", Weight: 40},
+	{Name: LoggingDisable, Description: "关闭审计日志", Weight: 35},
+	{Name: StorageReadAll, Description: "读取全部存储数据", Weight: 30},
+	{Name: ComputeAdmin, Description: "计算实例管理", Weight: 25},
+	{Name: StorageWrite, Description: "写入存储数据", Weight: 20},
+	{Name: NetworkAdmin, Description: "网络配置管理", Weight: 20},
+	{Name: IdentityRead, Description: "读取身份配置", Weight: 5},
+}
+
+// All 全部能力，用于映射管理员等全权限策略
+var All = []string{
+	IdentityAdmin, IdentityRead, ComputeExec, ComputeAdmin,
+	StorageReadAll, StorageWrite, SecretsRead, LoggingDisable, NetworkAdmin,
+}
+
+// AllExceptIdentity 除身份管理外的全部能力，用于映射 PowerUser 类策略
+var AllExceptIdentity = []string{
+	IdentityRead, ComputeExec, ComputeAdmin,
+	StorageReadAll, StorageWrite, SecretsRead, LoggingDisable, NetworkAdmin,
+}
+
+// Rule 原生权限到标准化能力的映射规则
+// Pattern 不区分大小写，支持 * 通配符，匹配策略名称或操作名称
+type Rule struct {
+	Pattern      string
+	Capabilities []string
+}
+
+// Map 将原生权限列表映射为标准化能力列表（去重并排序）
+func Map(permissions []string, rules []Rule) []string {
+	matched := make(map[string]bool)
+	for _, permission := range permissions {
+		for _, rule := range rules {
+			if match(rule.Pattern, permission) {
+				for _, c := range rule.Capabilities {
+					matched[c] = true
+				}
+			}
+		}
+	}
+
+	capabilities := []string{}
+	for c := range matched {
+		capabilities = append(capabilities, c)
+	}
+	sort.Strings(capabilities)
+	return capabilities
+}
+
+// match 判断权限是否匹配通配符模式
+func match(pattern, permission string) bool {
+	if !strings.Contains(pattern, "*") {
+		return strings.EqualFold(pattern, permission)
+	}
+	expr := "(?i)^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(permission)
+}
+
+// Score 计算能力集合的风险分数（0-100）
+func Score(capabilities []string) int {
+	score := 0
+	for _, c := range capabilities {
+		for _, d := range Definitions {
+			if d.Name == c {
+				score += d.Weight
+			}
+		}
+	}
+	if score > 100 {
+		score = 100
+	}
+	return score
+}
+
+// RiskLevel 根据能力集合计算风险等级
+// 具备身份管理能力即视为高风险，因为可以直接为自身授予任意权限
+func RiskLevel(capabilities []string) string {
+	for _, c := range capabilities {
+		if c == IdentityAdmin {
+			return "High"
+		}
+	}
+
+	score := Score(capabilities)
+	if score >= 60 {
+		return "High"
+	}
+	if score >= 25 {
+		return "Medium"
+	}
+	return "Low"
+}
@@ -0,0 +1,78 @@
+package capability
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	rules := []Rule{
+		{Pattern: "AdministratorAccess", Capabilities: All},
+		{Pattern: "PowerUserAccess", Capabilities: AllExceptIdentity},
+		{Pattern: "iam:*", Capabilities: []string{IdentityAdmin}},
+		{Pattern: "iam:Get*", Capabilities: []string{IdentityRead}},
+		{Pattern: "ssm:SendCommand", Capabilities: []string{ComputeExec}},
+		{Pattern: "*ReadOnlyAccess", Capabilities: []string{IdentityRead, StorageReadAll}},
+		{Pattern: "ecs.Run*", Capabilities: []string{ComputeAdmin}},
+	}
+
+	tests := []struct {
+		name        string
+		permissions []string
+		want        []string
+	}{
+		{name: "no permissions", permissions: nil, want: []string{}},
+		{name: "unknown permission", permissions: []string{"ec2:DescribeInstances"}, want: []string{}},
+		{name: "exact match ignores case", permissions: []string{"ssm:sendcommand"}, want: []string{ComputeExec}},
+		{name: "wildcard suffix", permissions: []string{"iam:CreateUser"}, want: []string{IdentityAdmin}},
+		{name: "overlapping rules", permissions: []string{"iam:GetRole"}, want: []string{IdentityAdmin, IdentityRead}},
+		{name: "wildcard prefix", permissions: []string{"AmazonS3ReadOnlyAccess"}, want: []string{IdentityRead, StorageReadAll}},
+		{name: "deduplicated and sorted", permissions: []string{"PowerUserAccess", "ssm:SendCommand"}, want: []string{
+			ComputeAdmin, ComputeExec, IdentityRead, LoggingDisable, NetworkAdmin, SecretsRead, StorageReadAll, StorageWrite,
+		}},
+		{name: "administrator", permissions: []string{"AdministratorAccess"}, want: []string{
+			ComputeAdmin, ComputeExec, IdentityAdmin, IdentityRead, LoggingDisable, NetworkAdmin, SecretsRead, StorageReadAll, StorageWrite,
+		}},
+		// 通配符之外的正则元字符按字面匹配
+		{name: "regexp characters are literal", permissions: []string{"ecsXRunInstances"}, want: []string{}},
+		{name: "dot in pattern", permissions: []string{"ecs.RunInstances"}, want: []string{ComputeAdmin}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Map(tt.permissions, rules); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Map(%v) = %v, want %v", tt.permissions, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRiskLevel(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities []string
+		wantScore    int
+		wantLevel    string
+	}{
+		{name: "none", capabilities: nil, wantScore: 0, wantLevel: "Low"},
+		{name: "read identity", capabilities: []string{IdentityRead}, wantScore: 5, wantLevel: "Low"},
+		{name: "storage write", capabilities: []string{StorageWrite}, wantScore: 20, wantLevel: "Low"},
+		{name: "compute admin", capabilities: []string{ComputeAdmin}, wantScore: 25, wantLevel: "Medium"},
+		{name: "exec and secrets", capabilities: []string{ComputeExec, SecretsRead}, wantScore: 80, wantLevel: "High"},
+		// 身份管理能力可以为自身授权，单独出现也是高风险
+		{name: "identity admin", capabilities: []string{IdentityAdmin}, wantScore: 60, wantLevel: "High"},
+		{name: "capped at 100", capabilities: All, wantScore: 100, wantLevel: "High"},
+		{name: "unknown capability", capabilities: []string{"billing.read"}, wantScore: 0, wantLevel: "Low"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Score(tt.capabilities); got != tt.wantScore {
+				t.Fatalf("Score() = %d, want %d", got, tt.wantScore)
+			}
+			if got := RiskLevel(tt.capabilities); got != tt.wantLevel {
+				t.Fatalf("RiskLevel() = %q, want %q", got, tt.wantLevel)
+			}
+		})
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"github.com/redteamsec/backend/internal/cloud/aws"
 	"github.com/redteamsec/backend/internal/cloud/aliyun"
 	"github.com/redteamsec/backend/internal/cloud/gcp"
+	"github.com/redteamsec/backend/internal/cloud/huawei"
+	"github.com/redteamsec/backend/internal/cloud/s3compat"
+	"github.com/redteamsec/backend/internal/cloud/tencent"
 	// "github.com/redteamsec/backend/internal/cloud/azure"
 )
 
@@ -32,7 +35,8 @@ type CloudProvider interface {
 }
 
 // NewCloudProvider 创建云平台实例
-func NewCloudProvider(providerType, accessKey, secretKey, region string) (CloudProvider, error) {
+// endpoint 仅用于S3兼容存储等需要自定义访问地址的平台，其他平台忽略
+func NewCloudProvider(providerType, accessKey, secretKey, region, endpoint string) (CloudProvider, error) {
 	switch providerType {
 	case "AWS":
 		return aws.NewAWSProvider(accessKey, secretKey, region)
@@ -42,8 +46,12 @@ func NewCloudProvider(providerType, accessKey, secretKey, region string) (CloudP
 		return gcp.NewGCPProvider(accessKey, secretKey, region)
 	// case "Azure":
 	// 	return azure.NewAzureProvider(accessKey, secretKey, region)
-	// case "腾讯云":
-	// 	return NewTencentProvider(accessKey, secretKey, region)
+	case "腾讯云":
+		return tencent.NewTencentProvider(accessKey, secretKey, region)
+	case "华为云":
+		return huawei.NewHuaweiProvider(accessKey, secretKey, region)
+	case "S3兼容":
+		return s3compat.NewS3CompatProvider(accessKey, secretKey, region, endpoint)
 	default:
 		return nil, nil
 	}
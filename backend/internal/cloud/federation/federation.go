@@ -0,0 +1,91 @@
+package federation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultDuration 联邦登录临时凭证的默认有效期
+const DefaultDuration = 1 * time.Hour
+
+// Login 联邦登录结果，各云平台统一返回该结构，前端据此提供"打开控制台"
+type Login struct {
+	Provider     string
+	URL          string
+	Expiry       time.Time
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Region       string
+	// Extra 平台特有的附加信息，例如扮演的角色ARN
+	Extra map[string]interface{}
+}
+
+// Result 转换为OperateResource的返回结构
+// 统一字段为 url、expiry、credentials，同时保留 federated_login_url 等旧字段以兼容现有前端
+func (l *Login) Result() map[string]interface{} {
+	expiry := ""
+	if !l.Expiry.IsZero() {
+		expiry = l.Expiry.UTC().Format(time.RFC3339)
+	}
+
+	result := map[string]interface{}{
+		"message":  "Federated login successful",
+		"provider": l.Provider,
+		"url":      l.URL,
+		"expiry":   expiry,
+		"credentials": map[string]interface{}{
+			"access_key":    l.AccessKey,
+			"secret_key":    l.SecretKey,
+			"session_token": l.SessionToken,
+		},
+		"federated":           true,
+		"federated_login_url": l.URL,
+		"region":              l.Region,
+		"access_key":          l.AccessKey,
+		"secret_key":          l.SecretKey,
+		"session_token":       l.SessionToken,
+		"expiration":          expiry,
+	}
+	for k, v := range l.Extra {
+		result[k] = v
+	}
+	return result
+}
+
+// GetJSON 发起GET请求并解析JSON响应，用于获取各平台的登录令牌
+// endpoint 的查询参数中可能带有临时凭证，错误信息中不能包含请求地址
+func GetJSON(endpoint string, out interface{}) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to request signin token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// ErrUnsupported 平台不支持使用AK/SK换取控制台登录时返回的错误
+func ErrUnsupported(provider, reason string) error {
+	return fmt.Errorf("federated console login is not supported for %s: %s", provider, reason)
+}
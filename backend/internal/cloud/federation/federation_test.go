@@ -0,0 +1,53 @@
+package federation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGetJSONErrorOmitsCredentials(t *testing.T) {
+	const secret = "tmp-access-key-secret"
+	const token = "tmp-security-token"
+	query := "?" + url.Values{
+		"Action":          {"GetSigninToken"},
+		"AccessKeySecret": {secret},
+		"SecurityToken":   {token},
+	}.Encode()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "denied", http.StatusForbidden)
+	}))
+	defer failing.Close()
+	invalid := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}))
+	defer invalid.Close()
+
+	tests := []struct {
+		name     string
+		endpoint string
+	}{
+		{name: "connection refused", endpoint: closed.URL + "/federation" + query},
+		{name: "unexpected status", endpoint: failing.URL + "/federation" + query},
+		{name: "invalid response", endpoint: invalid.URL + "/federation" + query},
+		{name: "invalid url", endpoint: "http://signin.invalid\x7f/federation" + query},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out struct{ SigninToken string }
+			err := GetJSON(tt.endpoint, &out)
+			if err == nil {
+				t.Fatal("GetJSON succeeded, want error")
+			}
+			if msg := err.Error(); strings.Contains(msg, secret) || strings.Contains(msg, token) {
+				t.Fatalf("error leaks credentials: %s", msg)
+			}
+		})
+	}
+}
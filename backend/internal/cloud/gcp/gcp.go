@@ -5,6 +5,8 @@ import (
 	"fmt"
 
 	"cloud.google.com/go/storage"
+	"github.com/redteamsec/backend/internal/cloud/capability"
+	"github.com/redteamsec/backend/internal/cloud/federation"
 	"google.golang.org/api/iam/v1"
 )
 
@@ -177,26 +179,66 @@ func (p *GCPProvider) enumerateIAMRoles() ([]interface{}, error) {
 	}, nil
 }
 
+// capabilityRules GCP原生权限（预定义角色及IAM权限）到标准化能力的映射
+var capabilityRules = []capability.Rule{
+	{Pattern: "roles/owner", Capabilities: capability.All},
+	{Pattern: "roles/editor", Capabilities: capability.AllExceptIdentity},
+	{Pattern: "roles/viewer", Capabilities: []string{capability.IdentityRead}},
+	{Pattern: "roles/iam.securityAdmin", Capabilities: []string{capability.IdentityAdmin, capability.IdentityRead}},
+	{Pattern: "roles/resourcemanager.projectIamAdmin", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "roles/iam.serviceAccountKeyAdmin", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "roles/iam.serviceAccountTokenCreator", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "*.setIamPolicy", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "iam.serviceAccountKeys.create", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "iam.serviceAccounts.getAccessToken", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "iam.*.list", Capabilities: []string{capability.IdentityRead}},
+	{Pattern: "iam.*.get", Capabilities: []string{capability.IdentityRead}},
+	{Pattern: "roles/compute.admin", Capabilities: []string{capability.ComputeAdmin, capability.ComputeExec, capability.NetworkAdmin}},
+	{Pattern: "roles/compute.instanceAdmin*", Capabilities: []string{capability.ComputeAdmin, capability.ComputeExec}},
+	{Pattern: "compute.instances.setMetadata", Capabilities: []string{capability.ComputeExec}},
+	{Pattern: "compute.projects.setCommonInstanceMetadata", Capabilities: []string{capability.ComputeExec}},
+	{Pattern: "compute.instances.osAdminLogin", Capabilities: []string{capability.ComputeExec}},
+	{Pattern: "compute.instances.create", Capabilities: []string{capability.ComputeAdmin}},
+	{Pattern: "roles/storage.admin", Capabilities: []string{capability.StorageReadAll, capability.StorageWrite}},
+	{Pattern: "roles/storage.objectAdmin", Capabilities: []string{capability.StorageReadAll, capability.StorageWrite}},
+	{Pattern: "roles/storage.objectViewer", Capabilities: []string{capability.StorageReadAll}},
+	{Pattern: "storage.objects.get", Capabilities: []string{capability.StorageReadAll}},
+	{Pattern: "storage.objects.create", Capabilities: []string{capability.StorageWrite}},
+	{Pattern: "roles/secretmanager.admin", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "roles/secretmanager.secretAccessor", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "secretmanager.versions.access", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "roles/logging.admin", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "logging.sinks.delete", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "logging.sinks.update", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "roles/compute.networkAdmin", Capabilities: []string{capability.NetworkAdmin}},
+	{Pattern: "roles/compute.securityAdmin", Capabilities: []string{capability.NetworkAdmin}},
+}
+
 // EscalatePrivileges 权限提升
 func (p *GCPProvider) EscalatePrivileges() (map[string]interface{}, error) {
 	// 这里应该实现GCP权限提升逻辑
+	permissions := []string{
+		"compute.instances.list",
+		"storage.buckets.list",
+		"iam.users.list",
+		"iam.roles.list",
+		"storage.objects.list",
+	}
+	capabilities := capability.Map(permissions, capabilityRules)
+
 	// 返回前端期望的数据结构
 	return map[string]interface{}{
-		"user": "GCP IAM User",
-		"role": "None",
-		"permissions": []string{
-			"compute.instances.list",
-			"storage.buckets.list",
-			"iam.users.list",
-			"iam.roles.list",
-			"storage.objects.list",
-		},
+		"user":         "GCP IAM User",
+		"role":         "None",
+		"permissions":  permissions,
+		"capabilities": capabilities,
+		"riskScore":    capability.Score(capabilities),
 		"potentialEscalation": []string{
 			"Create IAM user with admin privileges",
 			"Modify existing IAM policies",
 			"Access Storage buckets with sensitive data",
 		},
-		"riskLevel": "Medium",
+		"riskLevel": capability.RiskLevel(capabilities),
 		"message":   "Privilege escalation attempted",
 		"actions": []string{
 			"Checked IAM policies",
@@ -208,6 +250,11 @@ func (p *GCPProvider) EscalatePrivileges() (map[string]interface{}, error) {
 
 // OperateResource 资源操作
 func (p *GCPProvider) OperateResource(resourceType, action, resourceID string, params map[string]interface{}) (map[string]interface{}, error) {
+	// GCP控制台只接受Google账号登录，服务账号密钥无法换取控制台会话
+	if action == "federated_login" {
+		return nil, federation.ErrUnsupported("GCP", "the Cloud Console does not accept service account credentials")
+	}
+
 	// 这里应该实现GCP资源操作逻辑
 	// 暂时返回模拟数据
 	return map[string]interface{}{
@@ -237,13 +284,15 @@ func (p *GCPProvider) Takeover() (map[string]interface{}, error) {
 func (p *GCPProvider) GetPermissions() (map[string]interface{}, error) {
 	// 这里应该实现获取GCP权限信息逻辑
 	// 暂时返回模拟数据
+	permissions := []string{
+		"compute.instances.list",
+		"storage.buckets.list",
+		"iam.users.list",
+	}
 	return map[string]interface{}{
-		"message": "Permissions retrieved",
-		"permissions": []string{
-			"compute.instances.list",
-			"storage.buckets.list",
-			"iam.users.list",
-		},
+		"message":      "Permissions retrieved",
+		"permissions":  permissions,
+		"capabilities": capability.Map(permissions, capabilityRules),
 	}, nil
 }
 
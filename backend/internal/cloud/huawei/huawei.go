@@ -0,0 +1,720 @@
+package huawei
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/auth/basic"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/auth/global"
+	ecs "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2"
+	ecsmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/model"
+	ecsregion "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/region"
+	iam "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/iam/v3"
+	iammodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/iam/v3/model"
+	iamregion "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/iam/v3/region"
+	"github.com/redteamsec/backend/internal/cloud/capability"
+	"github.com/redteamsec/backend/internal/cloud/progress"
+	"github.com/redteamsec/backend/internal/cloud/s3compat"
+)
+
+// defaultRegion 未指定区域时使用的默认区域
+const defaultRegion = "cn-north-4"
+
+// commonRegions 未指定区域时遍历的常用区域
+var commonRegions = []string{
+	"cn-north-4", "cn-north-1", "cn-east-3", "cn-east-2", "cn-south-1",
+	"cn-southwest-2", "ap-southeast-1", "ap-southeast-2", "ap-southeast-3",
+	"af-south-1", "la-south-2", "sa-brazil-1",
+}
+
+// HuaweiProvider 华为云平台实现
+type HuaweiProvider struct {
+	progress.Emitter
+
+	accessKey string
+	secretKey string
+	region    string
+	iamClient *iam.IamClient
+}
+
+// callerIdentity 当前AK对应的IAM身份
+type callerIdentity struct {
+	UserID     string
+	UserName   string
+	DomainID   string
+	DomainName string
+}
+
+// NewHuaweiProvider 创建华为云平台实例
+func NewHuaweiProvider(accessKey, secretKey, region string) (*HuaweiProvider, error) {
+	// 保存原始region值，用于判断是否需要遍历所有区域
+	provider := &HuaweiProvider{
+		accessKey: accessKey,
+		secretKey: secretKey,
+		region:    region,
+	}
+
+	// 如果区域为空，使用默认区域进行初始化
+	initRegion := region
+	if initRegion == "" {
+		initRegion = defaultRegion
+	}
+
+	// 初始化客户端
+	err := provider.Init(accessKey, secretKey, initRegion)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
+// Init 初始化华为云客户端
+// ECS 等区域级服务的客户端在使用时按区域创建，这里只初始化全局的IAM客户端
+func (p *HuaweiProvider) Init(accessKey, secretKey, region string) error {
+	credential, err := global.NewCredentialsBuilder().
+		WithAk(accessKey).
+		WithSk(secretKey).
+		SafeBuild()
+	if err != nil {
+		return fmt.Errorf("failed to create Huawei Cloud credential: %w", err)
+	}
+
+	iamRegion, err := iamregion.SafeValueOf(region)
+	if err != nil {
+		iamRegion = iamregion.ValueOf(defaultRegion)
+	}
+
+	hcClient, err := iam.IamClientBuilder().
+		WithRegion(iamRegion).
+		WithCredential(credential).
+		SafeBuild()
+	if err != nil {
+		return fmt.Errorf("failed to create IAM client: %w", err)
+	}
+	p.iamClient = iam.NewIamClient(hcClient)
+
+	return nil
+}
+
+// ecsClient 创建指定区域的ECS客户端
+// 区域级凭证会在首次请求时自动查询并缓存项目ID，因此每个区域需要独立的凭证对象
+func (p *HuaweiProvider) ecsClient(region string) (*ecs.EcsClient, error) {
+	ecsRegion, err := ecsregion.SafeValueOf(region)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported ECS region %s: %w", region, err)
+	}
+
+	credential, err := basic.NewCredentialsBuilder().
+		WithAk(p.accessKey).
+		WithSk(p.secretKey).
+		SafeBuild()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Huawei Cloud credential: %w", err)
+	}
+
+	hcClient, err := ecs.EcsClientBuilder().
+		WithRegion(ecsRegion).
+		WithCredential(credential).
+		SafeBuild()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ECS client: %w", err)
+	}
+
+	return ecs.NewEcsClient(hcClient), nil
+}
+
+// obsClient 创建指定区域的OBS客户端
+// OBS 兼容 S3 协议，这里复用 S3 兼容存储客户端访问 obs.<region>.myhuaweicloud.com
+func (p *HuaweiProvider) obsClient(region string) *s3.Client {
+	return s3compat.NewClient(fmt.Sprintf("https://obs.%s.myhuaweicloud.com", region), p.accessKey, p.secretKey, region, false)
+}
+
+// regions 获取需要遍历的区域列表
+func (p *HuaweiProvider) regions() []string {
+	if p.region != "" {
+		return []string{p.region}
+	}
+	return commonRegions
+}
+
+// primaryRegion 返回全局服务使用的区域
+func (p *HuaweiProvider) primaryRegion() string {
+	if p.region != "" {
+		return p.region
+	}
+	return defaultRegion
+}
+
+// EnumerateResources 枚举华为云资源
+func (p *HuaweiProvider) EnumerateResources(resourceType string) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	errors := []string{}
+
+	// 检查是否是多个资源类型（逗号分隔）
+	if strings.Contains(resourceType, ",") {
+		for _, rt := range strings.Split(resourceType, ",") {
+			rt = strings.TrimSpace(rt)
+			if rt == "" {
+				continue
+			}
+
+			singleResult, err := p.EnumerateResources(rt)
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", rt, err))
+				continue
+			}
+
+			// 合并结果
+			for k, v := range singleResult {
+				if k != "errors" {
+					result[k] = v
+				}
+			}
+			if errs, ok := singleResult["errors"].([]string); ok {
+				errors = append(errors, errs...)
+			}
+		}
+
+		if len(errors) > 0 {
+			result["errors"] = errors
+		}
+
+		return result, nil
+	}
+
+	switch resourceType {
+	case "ecs":
+		instances, errs := p.enumerateECSInstances()
+		result["instances"] = instances
+		errors = append(errors, errs...)
+
+	case "obs":
+		buckets, err := p.enumerateOBSBuckets()
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("OBS: %v", err))
+			buckets = []interface{}{}
+		}
+		result["buckets"] = buckets
+
+	case "iam":
+		users, err := p.enumerateIAMUsers()
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("IAM Users: %v", err))
+			users = []interface{}{}
+		}
+		result["users"] = users
+
+		agencies, err := p.enumerateIAMAgencies()
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("IAM Agencies: %v", err))
+			agencies = []interface{}{}
+		}
+		result["roles"] = agencies
+
+	case "all":
+		return p.EnumerateResources("ecs,obs,iam")
+
+	default:
+		return nil, fmt.Errorf("unsupported resource type: %s", resourceType)
+	}
+
+	if len(errors) > 0 {
+		result["errors"] = errors
+	}
+
+	return result, nil
+}
+
+// enumerateECSInstances 枚举所有区域的ECS实例
+func (p *HuaweiProvider) enumerateECSInstances() ([]interface{}, []string) {
+	allInstances := []interface{}{}
+	errors := []string{}
+
+	for _, region := range p.regions() {
+		if p.Err() != nil {
+			break
+		}
+		p.RegionStarted("ECS", region)
+		instances, err := p.describeECSInstances(region)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("ECS (%s): %v", region, err))
+			fmt.Printf("Warning: Failed to enumerate ECS instances in region %s: %v\n", region, err)
+			p.RegionFailed("ECS", region, err)
+			continue
+		}
+		p.RegionFinished("ECS", region, len(instances))
+
+		for _, instance := range instances {
+			instance["region"] = region
+			allInstances = append(allInstances, instance)
+		}
+	}
+
+	return allInstances, errors
+}
+
+// describeECSInstances 分页查询指定区域的ECS实例
+func (p *HuaweiProvider) describeECSInstances(region string) ([]map[string]interface{}, error) {
+	client, err := p.ecsClient(region)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := []map[string]interface{}{}
+	var limit int32 = 100
+	var offset int32 = 1
+	for {
+		response, err := client.ListServersDetails(&ecsmodel.ListServersDetailsRequest{
+			Limit:  &limit,
+			Offset: &offset,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if response.Servers == nil || len(*response.Servers) == 0 {
+			break
+		}
+
+		for _, server := range *response.Servers {
+			publicIPs := []string{}
+			privateIPs := []string{}
+			vpcIDs := []string{}
+			for vpcID, addresses := range server.Addresses {
+				vpcIDs = append(vpcIDs, vpcID)
+				for _, address := range addresses {
+					if address.OSEXTIPStype != nil && address.OSEXTIPStype.Value() == "floating" {
+						publicIPs = append(publicIPs, address.Addr)
+					} else {
+						privateIPs = append(privateIPs, address.Addr)
+					}
+				}
+			}
+
+			securityGroups := []string{}
+			for _, sg := range server.SecurityGroups {
+				securityGroups = append(securityGroups, sg.Name)
+			}
+
+			instanceType := ""
+			if server.Flavor != nil {
+				instanceType = server.Flavor.Id
+			}
+
+			instances = append(instances, map[string]interface{}{
+				"instanceId":       server.Id,
+				"instanceName":     server.Name,
+				"state":            server.Status,
+				"instanceType":     instanceType,
+				"publicIpAddress":  strings.Join(publicIPs, ","),
+				"privateIpAddress": strings.Join(privateIPs, ","),
+				"vpcId":            strings.Join(vpcIDs, ","),
+				"securityGroups":   securityGroups,
+				"availabilityZone": server.OSEXTAZavailabilityZone,
+				"launchTime":       server.Created,
+				"metadata":         server.Metadata,
+			})
+		}
+
+		if len(*response.Servers) < int(limit) {
+			break
+		}
+		offset++
+	}
+
+	return instances, nil
+}
+
+// enumerateOBSBuckets 枚举OBS存储桶
+// OBS 的桶列表是全局的，任意区域的 endpoint 都会返回账号下所有存储桶
+func (p *HuaweiProvider) enumerateOBSBuckets() ([]interface{}, error) {
+	ctx, cancel := context.WithTimeout(p.Context(), 1*time.Minute)
+	defer cancel()
+
+	buckets, err := s3compat.ListBuckets(ctx, p.obsClient(p.primaryRegion()), "")
+	if err != nil {
+		return nil, err
+	}
+
+	// 补充每个存储桶所在区域
+	for _, b := range buckets {
+		bucket := b.(map[string]interface{})
+		bucket["region"] = p.bucketLocation(ctx, bucket["bucketName"].(string))
+	}
+
+	return buckets, nil
+}
+
+// bucketLocation 查询存储桶所在区域，查询失败时返回默认区域
+func (p *HuaweiProvider) bucketLocation(ctx context.Context, bucketName string) string {
+	response, err := p.obsClient(p.primaryRegion()).GetBucketLocation(ctx, &s3.GetBucketLocationInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil || response.LocationConstraint == "" {
+		return p.primaryRegion()
+	}
+	return string(response.LocationConstraint)
+}
+
+// locateBucket 查找存储桶所在区域
+func (p *HuaweiProvider) locateBucket(bucketName string, params map[string]interface{}) string {
+	if region, ok := params["region"].(string); ok && region != "" {
+		return region
+	}
+
+	ctx, cancel := context.WithTimeout(p.Context(), 30*time.Second)
+	defer cancel()
+
+	return p.bucketLocation(ctx, bucketName)
+}
+
+// enumerateIAMUsers 枚举IAM用户
+func (p *HuaweiProvider) enumerateIAMUsers() ([]interface{}, error) {
+	response, err := p.iamClient.KeystoneListUsers(&iammodel.KeystoneListUsersRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	users := []interface{}{}
+	if response.Users == nil {
+		return users, nil
+	}
+	for _, user := range *response.Users {
+		users = append(users, map[string]interface{}{
+			"userId":      user.Id,
+			"userName":    user.Name,
+			"domainId":    user.DomainId,
+			"enabled":     user.Enabled,
+			"description": stringValue(user.Description),
+		})
+	}
+
+	return users, nil
+}
+
+// enumerateIAMAgencies 枚举IAM委托（相当于其他云平台的角色）
+func (p *HuaweiProvider) enumerateIAMAgencies() ([]interface{}, error) {
+	identity, err := p.getCallerIdentity()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := p.iamClient.ListAgencies(&iammodel.ListAgenciesRequest{
+		DomainId: identity.DomainID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	agencies := []interface{}{}
+	if response.Agencies == nil {
+		return agencies, nil
+	}
+	for _, agency := range *response.Agencies {
+		agencies = append(agencies, map[string]interface{}{
+			"roleId":          agency.Id,
+			"roleName":        agency.Name,
+			"description":     agency.Description,
+			"trustDomainId":   stringValue(agency.TrustDomainId),
+			"trustDomainName": stringValue(agency.TrustDomainName),
+			"duration":        agency.Duration,
+			"createTime":      agency.CreateTime,
+		})
+	}
+
+	return agencies, nil
+}
+
+// EscalatePrivileges 权限提升
+func (p *HuaweiProvider) EscalatePrivileges() (map[string]interface{}, error) {
+	identity, err := p.getCallerIdentity()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %w", err)
+	}
+
+	userType := analyzeCallerIdentity(identity)
+
+	var permissions []string
+	if userType == "Root Account" {
+		permissions = []string{"All Permissions"}
+	} else {
+		permissions, err = p.getGroupPermissions(identity)
+		if err != nil {
+			permissions = []string{"Access Denied"}
+		}
+	}
+
+	potentialEscalation := analyzePotentialEscalation(permissions)
+	capabilities := capability.Map(permissions, capabilityRules)
+
+	return map[string]interface{}{
+		"message":             "Privilege escalation analysis completed",
+		"userType":            userType,
+		"userName":            identity.UserName,
+		"userId":              identity.UserID,
+		"accountId":           identity.DomainID,
+		"accountName":         identity.DomainName,
+		"permissions":         permissions,
+		"capabilities":        capabilities,
+		"riskScore":           capability.Score(capabilities),
+		"potentialEscalation": potentialEscalation,
+		"riskLevel":           capability.RiskLevel(capabilities),
+	}, nil
+}
+
+// getGroupPermissions 获取用户所在用户组被授予的全局权限
+func (p *HuaweiProvider) getGroupPermissions(identity *callerIdentity) ([]string, error) {
+	groups, err := p.iamClient.KeystoneListGroupsForUser(&iammodel.KeystoneListGroupsForUserRequest{
+		UserId: identity.UserID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := []string{}
+	if groups.Groups == nil {
+		return permissions, nil
+	}
+
+	for _, group := range *groups.Groups {
+		// admin 用户组拥有账号下的全部权限
+		if group.Name == "admin" {
+			permissions = append(permissions, "admin (Group)")
+		}
+
+		roles, err := p.iamClient.KeystoneListDomainPermissionsForGroup(&iammodel.KeystoneListDomainPermissionsForGroupRequest{
+			DomainId: identity.DomainID,
+			GroupId:  group.Id,
+		})
+		if err != nil {
+			fmt.Printf("Warning: Failed to list permissions for group %s: %v\n", group.Name, err)
+			continue
+		}
+		if roles.Roles == nil {
+			continue
+		}
+		for _, role := range *roles.Roles {
+			name := role.Name
+			if role.DisplayName != nil && *role.DisplayName != "" {
+				name = *role.DisplayName
+			}
+			permissions = append(permissions, name)
+		}
+	}
+
+	return permissions, nil
+}
+
+// analyzeCallerIdentity 根据身份信息判断用户类型
+// 华为云账号的默认IAM用户与账号同名，视为主账号
+func analyzeCallerIdentity(identity *callerIdentity) string {
+	if identity.DomainName != "" && identity.UserName == identity.DomainName {
+		return "Root Account"
+	}
+	return "IAM User"
+}
+
+// analyzePotentialEscalation 根据权限分析潜在提权路径
+func analyzePotentialEscalation(permissions []string) []string {
+	escalation := []string{}
+	for _, permission := range permissions {
+		switch {
+		case permission == "All Permissions", permission == "admin (Group)":
+			escalation = append(escalation, "Full account administrator access")
+		case strings.Contains(permission, "Security Administrator"), strings.Contains(permission, "IAM FullAccess"):
+			escalation = append(escalation, "Can create IAM users, access keys and grant arbitrary permissions")
+		case strings.Contains(permission, "Tenant Administrator"):
+			escalation = append(escalation, "Can manage all cloud services except IAM")
+		case strings.Contains(permission, "ECS FullAccess"), strings.Contains(permission, "ECS Admin"):
+			escalation = append(escalation, "Can modify ECS instances and attach agencies for lateral movement")
+		case strings.Contains(permission, "OBS Administrator"):
+			escalation = append(escalation, "Can read and modify all OBS buckets")
+		}
+	}
+	return escalation
+}
+
+// capabilityRules 华为云原生权限（系统角色/策略名称及操作）到标准化能力的映射
+var capabilityRules = []capability.Rule{
+	{Pattern: "All Permissions", Capabilities: capability.All},
+	{Pattern: "admin (Group)", Capabilities: capability.All},
+	{Pattern: "FullAccess", Capabilities: capability.AllExceptIdentity},
+	{Pattern: "Tenant Administrator", Capabilities: capability.AllExceptIdentity},
+	{Pattern: "Tenant Guest", Capabilities: []string{capability.IdentityRead}},
+	{Pattern: "Security Administrator", Capabilities: []string{capability.IdentityAdmin, capability.IdentityRead}},
+	{Pattern: "IAM FullAccess", Capabilities: []string{capability.IdentityAdmin, capability.IdentityRead}},
+	{Pattern: "IAM ReadOnlyAccess", Capabilities: []string{capability.IdentityRead}},
+	{Pattern: "iam:*", Capabilities: []string{capability.IdentityAdmin, capability.IdentityRead}},
+	{Pattern: "ECS FullAccess", Capabilities: []string{capability.ComputeAdmin}},
+	{Pattern: "ECS Admin*", Capabilities: []string{capability.ComputeAdmin}},
+	{Pattern: "COC FullAccess", Capabilities: []string{capability.ComputeExec}},
+	{Pattern: "coc:instance:executeDocument", Capabilities: []string{capability.ComputeExec}},
+	{Pattern: "OBS Administrator", Capabilities: []string{capability.StorageReadAll, capability.StorageWrite}},
+	{Pattern: "OBS OperateAccess", Capabilities: []string{capability.StorageReadAll, capability.StorageWrite}},
+	{Pattern: "obs:object:GetObject", Capabilities: []string{capability.StorageReadAll}},
+	{Pattern: "CSMS FullAccess", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "KMS Administrator", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "csms:secret:getVersion", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "CTS Administrator", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "CTS FullAccess", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "cts:tracker:update", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "cts:tracker:delete", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "VPC FullAccess", Capabilities: []string{capability.NetworkAdmin}},
+	{Pattern: "VPC Administrator", Capabilities: []string{capability.NetworkAdmin}},
+}
+
+// OperateResource 资源操作
+func (p *HuaweiProvider) OperateResource(resourceType, action, resourceID string, params map[string]interface{}) (map[string]interface{}, error) {
+	switch resourceType {
+	case "obs", "s3":
+		switch action {
+		case "list_objects":
+			prefix, _ := params["prefix"].(string)
+
+			ctx, cancel := context.WithTimeout(p.Context(), 30*time.Second)
+			defer cancel()
+
+			objects, err := s3compat.ListObjects(ctx, p.obsClient(p.locateBucket(resourceID, params)), resourceID, prefix)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list OBS objects: %w", err)
+			}
+			return map[string]interface{}{
+				"message": "OBS objects listed successfully",
+				"bucket":  resourceID,
+				"objects": objects,
+			}, nil
+
+		case "download":
+			key, ok := params["key"].(string)
+			if !ok {
+				return nil, fmt.Errorf("key is required")
+			}
+
+			bucketRegion := p.locateBucket(resourceID, params)
+			downloadURL, err := s3compat.PresignDownload(p.Context(), p.obsClient(bucketRegion), resourceID, key, 15*time.Minute)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{
+				"message":      "Download URL generated",
+				"bucket":       resourceID,
+				"key":          key,
+				"region":       bucketRegion,
+				"download_url": downloadURL,
+			}, nil
+		}
+	}
+
+	// 其他资源操作
+	return map[string]interface{}{
+		"message":      "Resource operation attempted",
+		"resourceType": resourceType,
+		"action":       action,
+		"resourceID":   resourceID,
+		"params":       params,
+	}, nil
+}
+
+// Takeover 平台接管
+func (p *HuaweiProvider) Takeover() (map[string]interface{}, error) {
+	// 这里应该实现华为云平台接管逻辑
+	// 暂时返回模拟数据
+	return map[string]interface{}{
+		"message": "Cloud platform takeover attempted",
+	}, nil
+}
+
+// GetPermissions 获取权限信息
+func (p *HuaweiProvider) GetPermissions() (map[string]interface{}, error) {
+	identity, err := p.getCallerIdentity()
+	if err != nil {
+		return map[string]interface{}{
+			"message":     "Permissions retrieved",
+			"userType":    "Unknown",
+			"userName":    "Unknown (Access Denied)",
+			"permissions": []string{"Unknown"},
+		}, nil
+	}
+
+	userType := analyzeCallerIdentity(identity)
+
+	var permissions []string
+	if userType == "Root Account" {
+		permissions = []string{"All Permissions"}
+	} else {
+		permissions, err = p.getGroupPermissions(identity)
+		if err != nil {
+			permissions = []string{"Access Denied"}
+		}
+	}
+
+	return map[string]interface{}{
+		"message":      "Permissions retrieved",
+		"userType":     userType,
+		"userName":     identity.UserName,
+		"accountId":    identity.DomainID,
+		"permissions":  permissions,
+		"capabilities": capability.Map(permissions, capabilityRules),
+	}, nil
+}
+
+// ValidateCredentials 验证凭证
+func (p *HuaweiProvider) ValidateCredentials() (bool, error) {
+	if _, err := p.getCallerIdentity(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// getCallerIdentity 查询当前AK所属的IAM用户及账号
+func (p *HuaweiProvider) getCallerIdentity() (*callerIdentity, error) {
+	accessKey, err := p.iamClient.ShowPermanentAccessKey(&iammodel.ShowPermanentAccessKeyRequest{
+		AccessKey: p.accessKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if accessKey.Credential == nil {
+		return nil, fmt.Errorf("empty access key response")
+	}
+
+	user, err := p.iamClient.KeystoneShowUser(&iammodel.KeystoneShowUserRequest{
+		UserId: accessKey.Credential.UserId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if user.User == nil {
+		return nil, fmt.Errorf("empty user response")
+	}
+
+	identity := &callerIdentity{
+		UserID:   user.User.Id,
+		UserName: user.User.Name,
+		DomainID: user.User.DomainId,
+	}
+
+	// 查询账号名称，用于判断是否为主账号
+	domains, err := p.iamClient.KeystoneListAuthDomains(&iammodel.KeystoneListAuthDomainsRequest{})
+	if err == nil && domains.Domains != nil {
+		for _, domain := range *domains.Domains {
+			if domain.Id == identity.DomainID {
+				identity.DomainName = domain.Name
+			}
+		}
+	}
+
+	return identity, nil
+}
+
+// stringValue 安全地获取字符串指针的值
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,144 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// 预检结果
+const (
+	CheckPassed       = "passed"        // 云平台的 DryRun 或策略模拟表明操作会被允许
+	CheckDenied       = "denied"        // 云平台的 DryRun 或策略模拟表明操作会被拒绝
+	CheckNotSupported = "not_supported" // 该接口不支持 DryRun，且无法模拟策略
+	CheckError        = "error"         // 预检调用本身失败
+)
+
+// Mutation 操作将对云上资源做的一项修改
+type Mutation struct {
+	Service     string `json:"service"`
+	API         string `json:"api"`
+	Resource    string `json:"resource"`
+	Description string `json:"description"`
+	// Check 预检结果，CheckMethod 为 dry_run 或 simulate_policy
+	Check       string `json:"check"`
+	CheckMethod string `json:"checkMethod,omitempty"`
+	CheckDetail string `json:"checkDetail,omitempty"`
+}
+
+// Plan 资源操作的执行计划，Mutations 按执行顺序排列
+type Plan struct {
+	ResourceType string     `json:"resourceType"`
+	Action       string     `json:"action"`
+	ResourceID   string     `json:"resourceId"`
+	Mutations    []Mutation `json:"mutations"`
+	Notes        []string   `json:"notes,omitempty"`
+}
+
+// Planner 支持 dry_run 参数的云平台实现，不支持的云平台收到 dry_run 时会真实执行，因此调用方必须先检查
+type Planner interface {
+	SupportsDryRun() bool
+}
+
+// ApprovedPlanParam 操作参数中引用已批准执行计划（dry_run 任务ID）的字段
+const ApprovedPlanParam = "approved_plan"
+
+// IsDryRun 判断操作参数是否要求只生成执行计划
+func IsDryRun(params map[string]interface{}) bool {
+	dryRun, _ := params["dry_run"].(bool)
+	return dryRun
+}
+
+// ApprovedPlanID 返回操作参数引用的已批准执行计划，未引用时返回 0
+func ApprovedPlanID(params map[string]interface{}) uint {
+	switch id := params[ApprovedPlanParam].(type) {
+	case float64: // JSON 数字解码后为 float64
+		if id > 0 {
+			return uint(id)
+		}
+	case string:
+		if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+			return uint(n)
+		}
+	}
+	return 0
+}
+
+// DryRunParams 复制操作参数并改为只生成执行计划
+func DryRunParams(params map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		copied[k] = v
+	}
+	delete(copied, ApprovedPlanParam)
+	copied["dry_run"] = true
+	return copied
+}
+
+// SameOperation 判断两次操作的参数是否一致，忽略 dry_run 和计划引用
+func SameOperation(a, b map[string]interface{}) bool {
+	a, b = DryRunParams(a), DryRunParams(b)
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if fmt.Sprint(v) != fmt.Sprint(b[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Add 追加一项修改
+func (p *Plan) Add(m Mutation) {
+	p.Mutations = append(p.Mutations, m)
+}
+
+// Result 转换为 OperateResource 的返回结果
+func (p *Plan) Result() map[string]interface{} {
+	mutations := p.Mutations
+	if mutations == nil {
+		mutations = []Mutation{}
+	}
+	return map[string]interface{}{
+		"message":   "Dry run completed, no changes were made",
+		"dry_run":   true,
+		"plan":      p,
+		"mutations": mutations,
+	}
+}
+
+// Same 判断两个计划的修改列表是否一致，只比较服务、接口和资源，不比较预检结果
+func Same(a, b []Mutation) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Service != b[i].Service || a[i].API != b[i].API || a[i].Resource != b[i].Resource {
+			return false
+		}
+	}
+	return true
+}
+
+// FromResult 从 OperateResource 的返回结果中取出执行计划，结果可以是进程内的返回值，也可以是保存后再解码的 JSON
+func FromResult(result map[string]interface{}) (*Plan, error) {
+	if dryRun, _ := result["dry_run"].(bool); !dryRun {
+		return nil, fmt.Errorf("result is not a dry run plan")
+	}
+	switch p := result["plan"].(type) {
+	case *Plan:
+		return p, nil
+	case map[string]interface{}:
+		data, err := json.Marshal(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid plan: %w", err)
+		}
+		var decoded Plan
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return nil, fmt.Errorf("invalid plan: %w", err)
+		}
+		return &decoded, nil
+	}
+	return nil, fmt.Errorf("result does not contain a plan")
+}
@@ -0,0 +1,110 @@
+package plan
+
+import "testing"
+
+func TestSameOperation(t *testing.T) {
+	base := map[string]interface{}{"action": "stop_instance", "instance_id": "i-1", "region": "us-east-1"}
+	with := func(extra map[string]interface{}) map[string]interface{} {
+		params := map[string]interface{}{}
+		for k, v := range base {
+			params[k] = v
+		}
+		for k, v := range extra {
+			if v == nil {
+				delete(params, k)
+				continue
+			}
+			params[k] = v
+		}
+		return params
+	}
+
+	tests := []struct {
+		name string
+		b    map[string]interface{}
+		want bool
+	}{
+		{name: "identical", b: with(nil), want: true},
+		// 执行计划本身带有 dry_run，执行时带有计划引用，两者都不参与比较
+		{name: "dry run and plan reference", b: with(map[string]interface{}{"dry_run": true, ApprovedPlanParam: float64(12)}), want: true},
+		{name: "different target", b: with(map[string]interface{}{"instance_id": "i-2"}), want: false},
+		{name: "different action", b: with(map[string]interface{}{"action": "terminate_instance"}), want: false},
+		{name: "extra parameter", b: with(map[string]interface{}{"force": true}), want: false},
+		{name: "missing parameter", b: with(map[string]interface{}{"region": nil}), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SameOperation(base, tt.b); got != tt.want {
+				t.Fatalf("SameOperation() = %v, want %v", got, tt.want)
+			}
+			if got := SameOperation(tt.b, base); got != tt.want {
+				t.Fatalf("SameOperation() reversed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := base["dry_run"]; ok {
+		t.Fatal("SameOperation modified its arguments")
+	}
+}
+
+func TestSame(t *testing.T) {
+	stop := Mutation{Service: "ec2", API: "StopInstances", Resource: "i-1", Check: CheckPassed}
+	detach := Mutation{Service: "iam", API: "DetachRolePolicy", Resource: "role/app"}
+
+	tests := []struct {
+		name string
+		a, b []Mutation
+		want bool
+	}{
+		{name: "both empty", want: true},
+		{name: "identical", a: []Mutation{stop, detach}, b: []Mutation{stop, detach}, want: true},
+		{name: "check result ignored", a: []Mutation{stop}, b: []Mutation{{Service: "ec2", API: "StopInstances", Resource: "i-1", Check: CheckDenied, Description: "changed"}}, want: true},
+		{name: "different order", a: []Mutation{stop, detach}, b: []Mutation{detach, stop}, want: false},
+		{name: "extra mutation", a: []Mutation{stop}, b: []Mutation{stop, detach}, want: false},
+		{name: "different resource", a: []Mutation{stop}, b: []Mutation{{Service: "ec2", API: "StopInstances", Resource: "i-2"}}, want: false},
+		{name: "different api", a: []Mutation{stop}, b: []Mutation{{Service: "ec2", API: "TerminateInstances", Resource: "i-1"}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Same(tt.a, tt.b); got != tt.want {
+				t.Fatalf("Same() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromResult(t *testing.T) {
+	p := &Plan{ResourceType: "ec2", Action: "stop_instance", ResourceID: "i-1"}
+	p.Add(Mutation{Service: "ec2", API: "StopInstances", Resource: "i-1"})
+
+	decoded := map[string]interface{}{
+		"dry_run": true,
+		"plan": map[string]interface{}{
+			"resourceType": "ec2",
+			"mutations":    []interface{}{map[string]interface{}{"service": "ec2", "api": "StopInstances", "resource": "i-1"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		result  map[string]interface{}
+		wantErr bool
+	}{
+		{name: "in process", result: p.Result()},
+		{name: "decoded", result: decoded},
+		{name: "not a dry run", result: map[string]interface{}{"message": "stopped"}, wantErr: true},
+		{name: "missing plan", result: map[string]interface{}{"dry_run": true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromResult(tt.result)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromResult() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !Same(got.Mutations, p.Mutations) {
+				t.Fatalf("FromResult() mutations = %+v, want %+v", got.Mutations, p.Mutations)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package progress
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 进度事件类型
+const (
+	TypeStatus         = "status"          // 任务状态变化（由任务 worker 发布）
+	TypeRegionStarted  = "region_started"  // 开始枚举某个区域
+	TypeRegionFinished = "region_finished" // 区域枚举完成，Count 为发现的资源数
+	TypeRegionFailed   = "region_failed"   // 区域枚举失败
+	TypeStep           = "step"            // 执行步骤，例如命令执行过程
+	TypeCancel         = "cancel"          // 请求取消任务（由 API 发布给正在执行该任务的 worker）
+)
+
+// Event 长时间运行的云平台操作产生的进度事件
+type Event struct {
+	Type      string `json:"type"`
+	Message   string `json:"message,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Resource  string `json:"resource,omitempty"`
+	Region    string `json:"region,omitempty"`
+	Count     int    `json:"count,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Reporter 接收进度事件的回调
+type Reporter func(Event)
+
+// Reportable 支持上报进度的云平台实现
+type Reportable interface {
+	SetReporter(reporter Reporter)
+}
+
+// Cancellable 支持取消的云平台实现，云平台调用使用设置的上下文，任务取消或超时后随之结束
+type Cancellable interface {
+	SetContext(ctx context.Context)
+}
+
+// Emitter 嵌入云平台实现中用于上报进度，未设置 Reporter 时所有方法均为空操作
+// 同时保存操作的上下文，供云平台在区域之间、步骤之间检查任务是否已取消
+type Emitter struct {
+	mu       sync.RWMutex
+	reporter Reporter
+	ctx      context.Context
+}
+
+// SetReporter 设置进度回调
+func (e *Emitter) SetReporter(reporter Reporter) {
+	e.mu.Lock()
+	e.reporter = reporter
+	e.mu.Unlock()
+}
+
+// SetContext 设置操作的上下文
+func (e *Emitter) SetContext(ctx context.Context) {
+	e.mu.Lock()
+	e.ctx = ctx
+	e.mu.Unlock()
+}
+
+// Context 返回操作的上下文，未设置时返回 context.Background()
+func (e *Emitter) Context() context.Context {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+// Err 任务已取消或超时时返回非空错误，云平台据此停止后续的区域和步骤
+func (e *Emitter) Err() error {
+	return e.Context().Err()
+}
+
+// Sleep 等待 d，期间任务取消或超时时立即返回上下文的错误
+func (e *Emitter) Sleep(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-e.Context().Done():
+		return e.Context().Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Emit 上报进度事件
+func (e *Emitter) Emit(event Event) {
+	e.mu.RLock()
+	reporter := e.reporter
+	e.mu.RUnlock()
+	if reporter == nil {
+		return
+	}
+
+	if event.Timestamp == "" {
+		event.Timestamp = time.Now().Format(time.RFC3339)
+	}
+	reporter(event)
+}
+
+// RegionStarted 上报开始枚举区域
+func (e *Emitter) RegionStarted(resource, region string) {
+	e.Emit(Event{Type: TypeRegionStarted, Resource: resource, Region: region})
+}
+
+// RegionFinished 上报区域枚举完成及发现的资源数
+func (e *Emitter) RegionFinished(resource, region string, count int) {
+	e.Emit(Event{Type: TypeRegionFinished, Resource: resource, Region: region, Count: count})
+}
+
+// RegionFailed 上报区域枚举失败
+func (e *Emitter) RegionFailed(resource, region string, err error) {
+	e.Emit(Event{Type: TypeRegionFailed, Resource: resource, Region: region, Error: err.Error()})
+}
+
+// AddStep 上报执行步骤并追加到步骤列表
+func (e *Emitter) AddStep(steps []string, step string) []string {
+	e.Emit(Event{Type: TypeStep, Message: step})
+	return append(steps, step)
+}
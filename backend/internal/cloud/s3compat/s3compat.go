@@ -0,0 +1,301 @@
+package s3compat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/redteamsec/backend/internal/cloud/capability"
+)
+
+// defaultRegion MinIO/Ceph 等自建存储通常不校验区域，签名时使用 us-east-1
+const defaultRegion = "us-east-1"
+
+// NewClient 创建访问 S3 兼容存储的客户端
+// pathStyle 为 true 时使用 endpoint/bucket/key 形式的路径访问（MinIO/Ceph 默认方式）
+func NewClient(endpoint, accessKey, secretKey, region string, pathStyle bool) *s3.Client {
+	if region == "" {
+		region = defaultRegion
+	}
+	return s3.New(s3.Options{
+		Region:       region,
+		BaseEndpoint: aws.String(endpoint),
+		Credentials:  credentials.NewStaticCredentialsProvider(accessKey, secretKey, ""),
+		UsePathStyle: pathStyle,
+	})
+}
+
+// ListBuckets 列出存储桶，返回与资源总览一致的存储桶结构
+func ListBuckets(ctx context.Context, client *s3.Client, region string) ([]interface{}, error) {
+	response, err := client.ListBuckets(ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := []interface{}{}
+	for _, bucket := range response.Buckets {
+		creationDate := ""
+		if bucket.CreationDate != nil {
+			creationDate = bucket.CreationDate.Format("2006-01-02T15:04:05Z")
+		}
+		buckets = append(buckets, map[string]interface{}{
+			"bucketName":   aws.ToString(bucket.Name),
+			"creationDate": creationDate,
+			"region":       region,
+			"objects":      []interface{}{},
+			"moreObjects":  true,
+		})
+	}
+
+	return buckets, nil
+}
+
+// ListObjects 列出存储桶中指定前缀下的所有对象
+func ListObjects(ctx context.Context, client *s3.Client, bucketName, prefix string) ([]interface{}, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(prefix),
+	}
+
+	objects := []interface{}{}
+	paginator := s3.NewListObjectsV2Paginator(client, input)
+	for paginator.HasMorePages() {
+		response, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range response.Contents {
+			lastModified := ""
+			if obj.LastModified != nil {
+				lastModified = obj.LastModified.Format("2006-01-02T15:04:05Z")
+			}
+			objects = append(objects, map[string]interface{}{
+				"key":          aws.ToString(obj.Key),
+				"size":         obj.Size,
+				"lastModified": lastModified,
+				"eTag":         aws.ToString(obj.ETag),
+			})
+		}
+	}
+
+	return objects, nil
+}
+
+// PresignDownload 生成对象的预签名下载URL
+func PresignDownload(ctx context.Context, client *s3.Client, bucketName, key string, expires time.Duration) (string, error) {
+	presignClient := s3.NewPresignClient(client)
+	presignedURL, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
+	}
+	return presignedURL.URL, nil
+}
+
+// capabilityRules S3兼容存储探测到的权限到标准化能力的映射
+// 能列出存储桶内容的AK通常也能读取其中的对象
+var capabilityRules = []capability.Rule{
+	{Pattern: "s3:ListBucket (*)", Capabilities: []string{capability.StorageReadAll}},
+}
+
+// S3CompatProvider 通用S3兼容对象存储实现（MinIO、Ceph RGW等）
+type S3CompatProvider struct {
+	accessKey string
+	secretKey string
+	region    string
+	endpoint  string
+	client    *s3.Client
+}
+
+// NewS3CompatProvider 创建S3兼容存储实例
+func NewS3CompatProvider(accessKey, secretKey, region, endpoint string) (*S3CompatProvider, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("endpoint is required for S3-compatible storage")
+	}
+	// 未携带协议时默认使用 https
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		endpoint = "https://" + endpoint
+	}
+
+	provider := &S3CompatProvider{
+		accessKey: accessKey,
+		secretKey: secretKey,
+		region:    region,
+		endpoint:  strings.TrimRight(endpoint, "/"),
+	}
+
+	// 初始化客户端
+	err := provider.Init(accessKey, secretKey, region)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
+// Init 初始化S3兼容存储客户端
+func (p *S3CompatProvider) Init(accessKey, secretKey, region string) error {
+	p.client = NewClient(p.endpoint, accessKey, secretKey, region, true)
+	return nil
+}
+
+// EnumerateResources 枚举S3兼容存储资源
+func (p *S3CompatProvider) EnumerateResources(resourceType string) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+
+	// 只有对象存储一种资源，逗号分隔的资源类型中包含 s3 即可
+	matched := false
+	for _, rt := range strings.Split(resourceType, ",") {
+		switch strings.TrimSpace(rt) {
+		case "s3", "all":
+			matched = true
+		}
+	}
+	if !matched {
+		return nil, fmt.Errorf("unsupported resource type: %s", resourceType)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	buckets, err := ListBuckets(ctx, p.client, p.signingRegion())
+	if err != nil {
+		result["buckets"] = []interface{}{}
+		result["errors"] = []string{fmt.Sprintf("S3: %v", err)}
+		return result, nil
+	}
+	result["buckets"] = buckets
+
+	return result, nil
+}
+
+// signingRegion 返回签名使用的区域
+func (p *S3CompatProvider) signingRegion() string {
+	if p.region != "" {
+		return p.region
+	}
+	return defaultRegion
+}
+
+// EscalatePrivileges 权限提升
+func (p *S3CompatProvider) EscalatePrivileges() (map[string]interface{}, error) {
+	// S3兼容存储没有统一的IAM接口，只能根据存储桶访问情况判断权限
+	permissions, err := p.probePermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	capabilities := capability.Map(permissions, capabilityRules)
+
+	return map[string]interface{}{
+		"message":             "Privilege escalation analysis completed",
+		"userType":            "S3 Access Key",
+		"userName":            p.accessKey,
+		"endpoint":            p.endpoint,
+		"permissions":         permissions,
+		"capabilities":        capabilities,
+		"riskScore":           capability.Score(capabilities),
+		"potentialEscalation": []string{},
+		"riskLevel":           capability.RiskLevel(capabilities),
+	}, nil
+}
+
+// probePermissions 通过列举存储桶探测凭证可访问的范围
+func (p *S3CompatProvider) probePermissions() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	response, err := p.client.ListBuckets(ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list buckets: %w", err)
+	}
+
+	permissions := []string{"s3:ListAllMyBuckets"}
+	for _, bucket := range response.Buckets {
+		permissions = append(permissions, fmt.Sprintf("s3:ListBucket (%s)", aws.ToString(bucket.Name)))
+	}
+	return permissions, nil
+}
+
+// OperateResource 资源操作
+func (p *S3CompatProvider) OperateResource(resourceType, action, resourceID string, params map[string]interface{}) (map[string]interface{}, error) {
+	if resourceType == "s3" {
+		switch action {
+		case "list_objects":
+			prefix, _ := params["prefix"].(string)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			objects, err := ListObjects(ctx, p.client, resourceID, prefix)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list objects: %w", err)
+			}
+			return map[string]interface{}{
+				"message": "Objects listed successfully",
+				"bucket":  resourceID,
+				"objects": objects,
+			}, nil
+
+		case "download":
+			key, ok := params["key"].(string)
+			if !ok {
+				return nil, fmt.Errorf("key is required")
+			}
+
+			downloadURL, err := PresignDownload(context.Background(), p.client, resourceID, key, 15*time.Minute)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{
+				"message":      "Download URL generated",
+				"bucket":       resourceID,
+				"key":          key,
+				"endpoint":     p.endpoint,
+				"download_url": downloadURL,
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported operation: %s %s", resourceType, action)
+}
+
+// Takeover 平台接管
+func (p *S3CompatProvider) Takeover() (map[string]interface{}, error) {
+	return nil, fmt.Errorf("takeover is not supported for S3-compatible storage")
+}
+
+// GetPermissions 获取权限信息
+func (p *S3CompatProvider) GetPermissions() (map[string]interface{}, error) {
+	permissions, err := p.probePermissions()
+	if err != nil {
+		permissions = []string{"Access Denied"}
+	}
+
+	return map[string]interface{}{
+		"message":      "Permissions retrieved",
+		"userType":     "S3 Access Key",
+		"userName":     p.accessKey,
+		"endpoint":     p.endpoint,
+		"permissions":  permissions,
+		"capabilities": capability.Map(permissions, capabilityRules),
+	}, nil
+}
+
+// ValidateCredentials 验证凭证
+func (p *S3CompatProvider) ValidateCredentials() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := p.client.ListBuckets(ctx, &s3.ListBucketsInput{}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,265 @@
+package scope
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 改变目标环境状态的操作类别
+const (
+	ClassExecute    = "execute"    // 在实例上执行命令
+	ClassIAMWrite   = "iam_write"  // 创建或修改IAM角色、实例配置文件等身份资源
+	ClassFederation = "federation" // 签发联邦登录凭证
+	ClassModify     = "modify"     // 其他修改资源的操作
+)
+
+// ErrOutOfScope 操作超出项目授权范围
+var ErrOutOfScope = errors.New("action is out of engagement scope")
+
+// Scope 项目（一次评估）的授权范围和交战规则
+// 各列表为空表示该维度不做限制；Resources 和 Tags 任一匹配即视为目标资源在范围内
+type Scope struct {
+	AccountIDs    []string          `json:"accountIds"`
+	Regions       []string          `json:"regions"`
+	Resources     []string          `json:"resources"` // 资源ARN或ID，支持 * 通配符
+	Tags          map[string]string `json:"tags"`      // 资源必须带有全部标签，值为 * 时只要求存在该标签
+	ActionClasses []string          `json:"actionClasses"`
+	NotBefore     string            `json:"notBefore"` // RFC3339
+	NotAfter      string            `json:"notAfter"`  // RFC3339
+}
+
+// Action 待检查的状态变更操作，无法确定的字段留空
+type Action struct {
+	Class     string
+	Operation string
+	AccountID string
+	Region    string
+	Resource  string
+	// Tags 为 nil 表示无法获取资源标签
+	Tags map[string]string
+}
+
+// Violation 操作超出授权范围的原因
+type Violation struct {
+	Action Action
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	target := v.Action.Operation
+	if v.Action.Resource != "" {
+		target += " on " + v.Action.Resource
+	}
+	return fmt.Sprintf("%s refused: %s", target, v.Reason)
+}
+
+// Is 使 errors.Is(err, ErrOutOfScope) 对所有越界错误成立
+func (v *Violation) Is(target error) bool {
+	return target == ErrOutOfScope
+}
+
+// Parse 解析并校验授权范围，data 为空时返回 nil 表示项目未定义授权范围
+func Parse(data string) (*Scope, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+	var s Scope
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return nil, fmt.Errorf("invalid scope: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate 校验操作类别和时间窗口
+func (s *Scope) Validate() error {
+	for _, class := range s.ActionClasses {
+		switch class {
+		case ClassExecute, ClassIAMWrite, ClassFederation, ClassModify:
+		default:
+			return fmt.Errorf("invalid scope: unknown action class %q", class)
+		}
+	}
+	notBefore, err := parseTime(s.NotBefore)
+	if err != nil {
+		return fmt.Errorf("invalid scope: notBefore: %w", err)
+	}
+	notAfter, err := parseTime(s.NotAfter)
+	if err != nil {
+		return fmt.Errorf("invalid scope: notAfter: %w", err)
+	}
+	if !notBefore.IsZero() && !notAfter.IsZero() && !notAfter.After(notBefore) {
+		return errors.New("invalid scope: notAfter must be after notBefore")
+	}
+	return nil
+}
+
+// Check 检查操作是否在授权范围内，s 为 nil 时不做限制
+// 范围限制了某个维度而操作无法确定该维度的值时拒绝执行
+func (s *Scope) Check(a Action, now time.Time) error {
+	if s == nil {
+		return nil
+	}
+	deny := func(format string, args ...interface{}) error {
+		return &Violation{Action: a, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	if t, _ := parseTime(s.NotBefore); !t.IsZero() && now.Before(t) {
+		return deny("engagement window starts at %s", s.NotBefore)
+	}
+	if t, _ := parseTime(s.NotAfter); !t.IsZero() && now.After(t) {
+		return deny("engagement window ended at %s", s.NotAfter)
+	}
+
+	if len(s.ActionClasses) > 0 && !contains(s.ActionClasses, a.Class) {
+		return deny("action class %q is not allowed (allowed: %s)", a.Class, strings.Join(s.ActionClasses, ", "))
+	}
+
+	if len(s.AccountIDs) > 0 {
+		if a.AccountID == "" {
+			return deny("account ID could not be determined, the scope restricts accounts")
+		}
+		if !contains(s.AccountIDs, a.AccountID) {
+			return deny("account %s is not in scope (allowed: %s)", a.AccountID, strings.Join(s.AccountIDs, ", "))
+		}
+	}
+
+	if len(s.Regions) > 0 {
+		if a.Region == "" {
+			return deny("region could not be determined, the scope restricts regions")
+		}
+		if !contains(s.Regions, a.Region) {
+			return deny("region %s is not in scope (allowed: %s)", a.Region, strings.Join(s.Regions, ", "))
+		}
+	}
+
+	// 只有针对具体资源的操作才检查资源和标签
+	if a.Resource != "" && (len(s.Resources) > 0 || len(s.Tags) > 0) {
+		if s.matchResource(a.Resource) || s.matchTags(a.Tags) {
+			return nil
+		}
+		if len(s.Tags) > 0 && a.Tags == nil {
+			return deny("resource is not listed in scope and its tags could not be verified")
+		}
+		return deny("resource is not listed in scope and does not carry the required tags")
+	}
+	return nil
+}
+
+// matchResource 判断资源是否匹配范围中的任一ARN模式
+func (s *Scope) matchResource(resource string) bool {
+	for _, pattern := range s.Resources {
+		if wildcardMatch(pattern, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTags 判断资源是否带有范围要求的全部标签
+func (s *Scope) matchTags(tags map[string]string) bool {
+	if len(s.Tags) == 0 || tags == nil {
+		return false
+	}
+	for key, want := range s.Tags {
+		got, ok := tags[key]
+		if !ok || (want != "*" && got != want) {
+			return false
+		}
+	}
+	return true
+}
+
+// Classify 返回资源操作的类别，只读操作返回空字符串
+func Classify(resourceType, action string) string {
+	switch action {
+	case "list_objects", "download":
+		return ""
+	case "execute_command":
+		return ClassExecute
+	case "federated_login", "revoke_federation_token":
+		return ClassFederation
+	}
+	return ClassModify
+}
+
+// Guard 执行状态变更操作前调用，返回错误时放弃操作
+type Guard func(Action) error
+
+// Guarded 能在操作内部提供账号、资源标签等信息做范围检查的云平台实现
+type Guarded interface {
+	SetGuard(guard Guard)
+}
+
+// Checker 嵌入云平台实现中用于范围检查，未设置 Guard 时不做限制
+type Checker struct {
+	mu    sync.RWMutex
+	guard Guard
+}
+
+// SetGuard 设置范围检查回调
+func (c *Checker) SetGuard(guard Guard) {
+	c.mu.Lock()
+	c.guard = guard
+	c.mu.Unlock()
+}
+
+// Enabled 是否设置了范围检查，未设置时可以跳过为检查准备信息的额外调用
+func (c *Checker) Enabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.guard != nil
+}
+
+// CheckScope 检查操作是否在授权范围内
+func (c *Checker) CheckScope(a Action) error {
+	c.mu.RLock()
+	guard := c.guard
+	c.mu.RUnlock()
+	if guard == nil {
+		return nil
+	}
+	return guard(a)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardMatch 匹配只包含 * 通配符的模式
+func wildcardMatch(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
@@ -0,0 +1,178 @@
+package scope
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{pattern: "i-123", value: "i-123", want: true},
+		{pattern: "i-123", value: "i-1234", want: false},
+		{pattern: "*", value: "", want: true},
+		{pattern: "*", value: "anything", want: true},
+		{pattern: "arn:aws:ec2:*:111122223333:instance/*", value: "arn:aws:ec2:us-east-1:111122223333:instance/i-0abc", want: true},
+		{pattern: "arn:aws:ec2:*:111122223333:instance/*", value: "arn:aws:ec2:us-east-1:444455556666:instance/i-0abc", want: false},
+		{pattern: "prod-*", value: "prod-web", want: true},
+		{pattern: "prod-*", value: "preprod-web", want: false},
+		{pattern: "*-web", value: "prod-web", want: true},
+		{pattern: "*-web", value: "prod-web-2", want: false},
+		{pattern: "a*b*c", value: "abc", want: true},
+		{pattern: "a*b*c", value: "axxbyyc", want: true},
+		{pattern: "a*b*c", value: "acb", want: false},
+		// 前缀和后缀不能重叠使用同一段字符
+		{pattern: "ab*bc", value: "abc", want: false},
+		{pattern: "a*a", value: "a", want: false},
+		{pattern: "a*a", value: "aa", want: true},
+		{pattern: "**", value: "x", want: true},
+		{pattern: "", value: "", want: true},
+		{pattern: "", value: "x", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := wildcardMatch(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("wildcardMatch(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	instance := "arn:aws:ec2:us-east-1:111122223333:instance/i-0abc"
+
+	tests := []struct {
+		name    string
+		scope   *Scope
+		action  Action
+		allowed bool
+	}{
+		{name: "nil scope", scope: nil, action: Action{Class: ClassExecute}, allowed: true},
+		{name: "empty scope", scope: &Scope{}, action: Action{Class: ClassExecute, Resource: instance}, allowed: true},
+
+		{name: "inside window", scope: &Scope{NotBefore: "2026-02-01T00:00:00Z", NotAfter: "2026-04-01T00:00:00Z"}, action: Action{Class: ClassModify}, allowed: true},
+		{name: "before window", scope: &Scope{NotBefore: "2026-03-02T00:00:00Z"}, action: Action{Class: ClassModify}},
+		{name: "after window", scope: &Scope{NotAfter: "2026-02-28T00:00:00Z"}, action: Action{Class: ClassModify}},
+
+		{name: "allowed class", scope: &Scope{ActionClasses: []string{ClassExecute}}, action: Action{Class: ClassExecute}, allowed: true},
+		{name: "disallowed class", scope: &Scope{ActionClasses: []string{ClassExecute}}, action: Action{Class: ClassFederation}},
+
+		{name: "allowed account", scope: &Scope{AccountIDs: []string{"111122223333"}}, action: Action{AccountID: "111122223333"}, allowed: true},
+		{name: "other account", scope: &Scope{AccountIDs: []string{"111122223333"}}, action: Action{AccountID: "444455556666"}},
+		{name: "unknown account", scope: &Scope{AccountIDs: []string{"111122223333"}}, action: Action{}},
+
+		{name: "allowed region", scope: &Scope{Regions: []string{"us-east-1"}}, action: Action{Region: "us-east-1"}, allowed: true},
+		{name: "other region", scope: &Scope{Regions: []string{"us-east-1"}}, action: Action{Region: "eu-west-1"}},
+		{name: "unknown region", scope: &Scope{Regions: []string{"us-east-1"}}, action: Action{}},
+
+		{name: "listed resource", scope: &Scope{Resources: []string{"arn:aws:ec2:*:111122223333:instance/*"}}, action: Action{Resource: instance}, allowed: true},
+		{name: "unlisted resource", scope: &Scope{Resources: []string{"arn:aws:ec2:*:111122223333:instance/i-0def"}}, action: Action{Resource: instance}},
+		{name: "no resource skips resource check", scope: &Scope{Resources: []string{"i-0def"}}, action: Action{Class: ClassIAMWrite}, allowed: true},
+
+		{name: "matching tags", scope: &Scope{Tags: map[string]string{"env": "test", "owner": "*"}}, action: Action{Resource: instance, Tags: map[string]string{"env": "test", "owner": "red"}}, allowed: true},
+		{name: "wrong tag value", scope: &Scope{Tags: map[string]string{"env": "test"}}, action: Action{Resource: instance, Tags: map[string]string{"env": "prod"}}},
+		{name: "missing tag", scope: &Scope{Tags: map[string]string{"env": "test", "owner": "*"}}, action: Action{Resource: instance, Tags: map[string]string{"env": "test"}}},
+		{name: "unknown tags", scope: &Scope{Tags: map[string]string{"env": "test"}}, action: Action{Resource: instance}},
+		{name: "listed resource without tags", scope: &Scope{Resources: []string{instance}, Tags: map[string]string{"env": "test"}}, action: Action{Resource: instance}, allowed: true},
+		{name: "tagged resource not listed", scope: &Scope{Resources: []string{"i-0def"}, Tags: map[string]string{"env": "test"}}, action: Action{Resource: instance, Tags: map[string]string{"env": "test"}}, allowed: true},
+
+		{
+			name: "all dimensions",
+			scope: &Scope{
+				AccountIDs:    []string{"111122223333"},
+				Regions:       []string{"us-east-1"},
+				Resources:     []string{"arn:aws:ec2:us-east-1:111122223333:instance/*"},
+				ActionClasses: []string{ClassExecute},
+				NotAfter:      "2026-04-01T00:00:00Z",
+			},
+			action:  Action{Class: ClassExecute, AccountID: "111122223333", Region: "us-east-1", Resource: instance},
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.scope.Check(tt.action, now)
+			if tt.allowed {
+				if err != nil {
+					t.Fatalf("Check() = %v, want allowed", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrOutOfScope) {
+				t.Fatalf("Check() = %v, want ErrOutOfScope", err)
+			}
+			var violation *Violation
+			if !errors.As(err, &violation) || violation.Reason == "" {
+				t.Fatalf("Check() = %v, want a violation with a reason", err)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "empty", data: "  ", wantNil: true},
+		{name: "valid", data: `{"accountIds":["111122223333"],"actionClasses":["execute"],"notBefore":"2026-01-01T00:00:00Z","notAfter":"2026-02-01T00:00:00Z"}`},
+		{name: "invalid json", data: `{"accountIds":`, wantErr: true},
+		{name: "unknown class", data: `{"actionClasses":["delete_everything"]}`, wantErr: true},
+		{name: "invalid time", data: `{"notBefore":"yesterday"}`, wantErr: true},
+		{name: "window ends before it starts", data: `{"notBefore":"2026-02-01T00:00:00Z","notAfter":"2026-01-01T00:00:00Z"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (s == nil) != tt.wantNil {
+				t.Fatalf("Parse() = %v, wantNil %v", s, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		action string
+		want   string
+	}{
+		{action: "list_objects", want: ""},
+		{action: "download", want: ""},
+		{action: "execute_command", want: ClassExecute},
+		{action: "federated_login", want: ClassFederation},
+		{action: "revoke_federation_token", want: ClassFederation},
+		{action: "stop_instance", want: ClassModify},
+	}
+	for _, tt := range tests {
+		if got := Classify("any", tt.action); got != tt.want {
+			t.Errorf("Classify(%q) = %q, want %q", tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestCheckerWithoutGuard(t *testing.T) {
+	var c Checker
+	if c.Enabled() {
+		t.Fatal("checker without guard reports enabled")
+	}
+	if err := c.CheckScope(Action{Class: ClassExecute}); err != nil {
+		t.Fatalf("CheckScope() without guard = %v", err)
+	}
+
+	s := &Scope{ActionClasses: []string{ClassModify}}
+	c.SetGuard(func(a Action) error { return s.Check(a, time.Now()) })
+	if err := c.CheckScope(Action{Class: ClassExecute}); !errors.Is(err, ErrOutOfScope) {
+		t.Fatalf("CheckScope() = %v, want ErrOutOfScope", err)
+	}
+}
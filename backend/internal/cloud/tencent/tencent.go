@@ -0,0 +1,1056 @@
+package tencent
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/redteamsec/backend/internal/cloud/capability"
+	"github.com/redteamsec/backend/internal/cloud/progress"
+	"github.com/redteamsec/backend/internal/cloud/s3compat"
+	cam "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cam/v20190116"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	tchttp "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/http"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+	cvm "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cvm/v20170312"
+	sts "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sts/v20180813"
+	tat "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/tat/v20201028"
+	vpc "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/vpc/v20170312"
+)
+
+// defaultRegion 未指定区域时使用的默认区域
+const defaultRegion = "ap-guangzhou"
+
+// TencentProvider 腾讯云平台实现
+type TencentProvider struct {
+	progress.Emitter
+
+	accessKey  string
+	secretKey  string
+	region     string
+	credential *common.Credential
+	cvmClient  *cvm.Client
+	camClient  *cam.Client
+	vpcClient  *vpc.Client
+	tatClient  *tat.Client
+	stsClient  *sts.Client
+}
+
+// NewTencentProvider 创建腾讯云平台实例
+func NewTencentProvider(accessKey, secretKey, region string) (*TencentProvider, error) {
+	// 保存原始region值，用于判断是否需要遍历所有区域
+	provider := &TencentProvider{
+		accessKey: accessKey,
+		secretKey: secretKey,
+		region:    region,
+	}
+
+	// 如果区域为空，使用默认区域进行初始化
+	initRegion := region
+	if initRegion == "" {
+		initRegion = defaultRegion
+	}
+
+	// 初始化客户端
+	err := provider.Init(accessKey, secretKey, initRegion)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
+// Init 初始化腾讯云客户端
+func (p *TencentProvider) Init(accessKey, secretKey, region string) error {
+	p.credential = common.NewCredential(accessKey, secretKey)
+	cpf := profile.NewClientProfile()
+
+	// 创建CVM客户端
+	cvmClient, err := cvm.NewClient(p.credential, region, cpf)
+	if err != nil {
+		return fmt.Errorf("failed to create CVM client: %w", err)
+	}
+	p.cvmClient = cvmClient
+
+	// 创建CAM客户端（CAM为全局服务，不区分区域）
+	camClient, err := cam.NewClient(p.credential, "", cpf)
+	if err != nil {
+		return fmt.Errorf("failed to create CAM client: %w", err)
+	}
+	p.camClient = camClient
+
+	// 创建VPC客户端
+	vpcClient, err := vpc.NewClient(p.credential, region, cpf)
+	if err != nil {
+		return fmt.Errorf("failed to create VPC client: %w", err)
+	}
+	p.vpcClient = vpcClient
+
+	// 创建TAT客户端
+	tatClient, err := tat.NewClient(p.credential, region, cpf)
+	if err != nil {
+		return fmt.Errorf("failed to create TAT client: %w", err)
+	}
+	p.tatClient = tatClient
+
+	// 创建STS客户端
+	stsClient, err := sts.NewClient(p.credential, region, cpf)
+	if err != nil {
+		return fmt.Errorf("failed to create STS client: %w", err)
+	}
+	p.stsClient = stsClient
+
+	return nil
+}
+
+// EnumerateResources 枚举腾讯云资源
+func (p *TencentProvider) EnumerateResources(resourceType string) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	errors := []string{}
+
+	// 检查是否是多个资源类型（逗号分隔）
+	if strings.Contains(resourceType, ",") {
+		for _, rt := range strings.Split(resourceType, ",") {
+			rt = strings.TrimSpace(rt)
+			if rt == "" {
+				continue
+			}
+
+			singleResult, err := p.EnumerateResources(rt)
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", rt, err))
+				continue
+			}
+
+			// 合并结果
+			for k, v := range singleResult {
+				if k != "errors" {
+					result[k] = v
+				}
+			}
+		}
+
+		if len(errors) > 0 {
+			result["errors"] = errors
+		}
+
+		return result, nil
+	}
+
+	switch resourceType {
+	case "cvm":
+		instances, errs := p.enumerateCVMInstances()
+		result["instances"] = instances
+		errors = append(errors, errs...)
+
+	case "cos":
+		buckets, err := p.enumerateCOSBuckets()
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("COS: %v", err))
+			buckets = []interface{}{}
+		}
+		result["buckets"] = buckets
+
+	case "cam":
+		users, err := p.enumerateCAMUsers()
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("CAM Users: %v", err))
+			users = []interface{}{}
+		}
+		result["users"] = users
+
+		roles, err := p.enumerateCAMRoles()
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("CAM Roles: %v", err))
+			roles = []interface{}{}
+		}
+		result["roles"] = roles
+
+		policies, err := p.enumerateCAMPolicies()
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("CAM Policies: %v", err))
+			policies = []interface{}{}
+		}
+		result["policies"] = policies
+
+	case "vpc":
+		vpcs, securityGroups, errs := p.enumerateVPCResources()
+		result["vpcs"] = vpcs
+		result["securityGroups"] = securityGroups
+		errors = append(errors, errs...)
+
+	case "all":
+		return p.EnumerateResources("cvm,cos,cam,vpc")
+
+	default:
+		return nil, fmt.Errorf("unsupported resource type: %s", resourceType)
+	}
+
+	if len(errors) > 0 {
+		result["errors"] = errors
+	}
+
+	return result, nil
+}
+
+// regions 获取需要遍历的区域列表
+func (p *TencentProvider) regions() ([]string, error) {
+	// 如果指定了区域，只使用该区域
+	if p.region != "" {
+		return []string{p.region}, nil
+	}
+
+	// 否则查询所有可用区域
+	response, err := p.cvmClient.DescribeRegions(cvm.NewDescribeRegionsRequest())
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe regions: %w", err)
+	}
+
+	var regions []string
+	for _, region := range response.Response.RegionSet {
+		if region.Region == nil {
+			continue
+		}
+		if region.RegionState != nil && *region.RegionState != "AVAILABLE" {
+			continue
+		}
+		regions = append(regions, *region.Region)
+	}
+
+	return regions, nil
+}
+
+// forRegion 返回指定区域的腾讯云实例
+func (p *TencentProvider) forRegion(region string) (*TencentProvider, error) {
+	if region == p.region {
+		return p, nil
+	}
+	return NewTencentProvider(p.accessKey, p.secretKey, region)
+}
+
+// enumerateCVMInstances 枚举所有区域的CVM实例
+func (p *TencentProvider) enumerateCVMInstances() ([]interface{}, []string) {
+	allInstances := []interface{}{}
+	errors := []string{}
+
+	regions, err := p.regions()
+	if err != nil {
+		return allInstances, []string{fmt.Sprintf("CVM: %v", err)}
+	}
+
+	for _, region := range regions {
+		if p.Err() != nil {
+			break
+		}
+		p.RegionStarted("CVM", region)
+		regionProvider, err := p.forRegion(region)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("CVM (%s): %v", region, err))
+			p.RegionFailed("CVM", region, err)
+			continue
+		}
+
+		instances, err := regionProvider.describeCVMInstances()
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("CVM (%s): %v", region, err))
+			fmt.Printf("Warning: Failed to enumerate CVM instances in region %s: %v\n", region, err)
+			p.RegionFailed("CVM", region, err)
+			continue
+		}
+		p.RegionFinished("CVM", region, len(instances))
+
+		for _, instance := range instances {
+			instance["region"] = region
+			allInstances = append(allInstances, instance)
+		}
+	}
+
+	return allInstances, errors
+}
+
+// describeCVMInstances 枚举当前区域的CVM实例
+func (p *TencentProvider) describeCVMInstances() ([]map[string]interface{}, error) {
+	var instances []map[string]interface{}
+
+	request := cvm.NewDescribeInstancesRequest()
+	request.Limit = common.Int64Ptr(100)
+	var offset int64
+
+	// 分页获取所有实例
+	for {
+		request.Offset = common.Int64Ptr(offset)
+		response, err := p.cvmClient.DescribeInstances(request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe CVM instances: %w", err)
+		}
+
+		for _, instance := range response.Response.InstanceSet {
+			tags := make(map[string]string)
+			for _, tag := range instance.Tags {
+				tags[stringValue(tag.Key)] = stringValue(tag.Value)
+			}
+
+			var publicIp, privateIp, vpcId string
+			if len(instance.PublicIpAddresses) > 0 {
+				publicIp = stringValue(instance.PublicIpAddresses[0])
+			}
+			if len(instance.PrivateIpAddresses) > 0 {
+				privateIp = stringValue(instance.PrivateIpAddresses[0])
+			}
+			if instance.VirtualPrivateCloud != nil {
+				vpcId = stringValue(instance.VirtualPrivateCloud.VpcId)
+			}
+
+			instances = append(instances, map[string]interface{}{
+				"instanceId":     stringValue(instance.InstanceId),
+				"instanceName":   stringValue(instance.InstanceName),
+				"instanceType":   stringValue(instance.InstanceType),
+				"state":          stringValue(instance.InstanceState),
+				"os":             stringValue(instance.OsName),
+				"publicIp":       publicIp,
+				"privateIp":      privateIp,
+				"vpcId":          vpcId,
+				"camRole":        stringValue(instance.CamRoleName),
+				"securityGroups": common.StringValues(instance.SecurityGroupIds),
+				"tags":           tags,
+			})
+		}
+
+		offset += int64(len(response.Response.InstanceSet))
+		if len(response.Response.InstanceSet) == 0 || response.Response.TotalCount == nil || offset >= *response.Response.TotalCount {
+			break
+		}
+	}
+
+	return instances, nil
+}
+
+// cosClient 创建指定区域的COS客户端
+// COS 兼容 S3 协议，这里复用 S3 兼容存储客户端访问 cos.<region>.myqcloud.com
+func (p *TencentProvider) cosClient(region string) *s3.Client {
+	return s3compat.NewClient(fmt.Sprintf("https://cos.%s.myqcloud.com", region), p.accessKey, p.secretKey, region, false)
+}
+
+// enumerateCOSBuckets 枚举所有区域的COS存储桶
+func (p *TencentProvider) enumerateCOSBuckets() ([]interface{}, error) {
+	regions, err := p.regions()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(p.Context(), 1*time.Minute)
+	defer cancel()
+
+	buckets := []interface{}{}
+	for _, region := range regions {
+		if p.Err() != nil {
+			break
+		}
+		p.RegionStarted("COS", region)
+		// 按区域查询存储桶列表，只返回该区域的存储桶
+		regionBuckets, err := s3compat.ListBuckets(ctx, p.cosClient(region), region)
+		if err != nil {
+			fmt.Printf("Warning: Failed to list COS buckets in region %s: %v\n", region, err)
+			p.RegionFailed("COS", region, err)
+			continue
+		}
+		p.RegionFinished("COS", region, len(regionBuckets))
+		buckets = append(buckets, regionBuckets...)
+	}
+
+	return buckets, nil
+}
+
+// enumerateCAMUsers 枚举CAM子用户
+func (p *TencentProvider) enumerateCAMUsers() ([]interface{}, error) {
+	response, err := p.camClient.ListUsers(cam.NewListUsersRequest())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list CAM users: %w", err)
+	}
+
+	users := []interface{}{}
+	for _, user := range response.Response.Data {
+		users = append(users, map[string]interface{}{
+			"userName":     stringValue(user.Name),
+			"userId":       fmt.Sprintf("%d", uint64Value(user.Uin)),
+			"uid":          uint64Value(user.Uid),
+			"consoleLogin": uint64Value(user.ConsoleLogin) == 1,
+			"email":        stringValue(user.Email),
+			"createTime":   stringValue(user.CreateTime),
+		})
+	}
+
+	return users, nil
+}
+
+// enumerateCAMRoles 枚举CAM角色
+func (p *TencentProvider) enumerateCAMRoles() ([]interface{}, error) {
+	roles := []interface{}{}
+
+	request := cam.NewDescribeRoleListRequest()
+	request.Rp = common.Uint64Ptr(200)
+	var page uint64 = 1
+
+	// 分页获取所有角色
+	for {
+		request.Page = common.Uint64Ptr(page)
+		response, err := p.camClient.DescribeRoleList(request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list CAM roles: %w", err)
+		}
+
+		for _, role := range response.Response.List {
+			roles = append(roles, map[string]interface{}{
+				"roleName":       stringValue(role.RoleName),
+				"roleId":         stringValue(role.RoleId),
+				"description":    stringValue(role.Description),
+				"roleType":       stringValue(role.RoleType),
+				"policyDocument": stringValue(role.PolicyDocument),
+			})
+		}
+
+		if len(response.Response.List) == 0 || response.Response.TotalNum == nil || uint64(len(roles)) >= *response.Response.TotalNum {
+			break
+		}
+		page++
+	}
+
+	return roles, nil
+}
+
+// enumerateCAMPolicies 枚举自定义CAM策略
+func (p *TencentProvider) enumerateCAMPolicies() ([]interface{}, error) {
+	policies := []interface{}{}
+
+	request := cam.NewListPoliciesRequest()
+	request.Scope = common.StringPtr("Local")
+	request.Rp = common.Uint64Ptr(200)
+	var page uint64 = 1
+
+	for {
+		request.Page = common.Uint64Ptr(page)
+		response, err := p.camClient.ListPolicies(request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list CAM policies: %w", err)
+		}
+
+		for _, policy := range response.Response.List {
+			policies = append(policies, map[string]interface{}{
+				"policyId":    uint64Value(policy.PolicyId),
+				"policyName":  stringValue(policy.PolicyName),
+				"description": stringValue(policy.Description),
+				"attachments": uint64Value(policy.Attachments),
+				"addTime":     stringValue(policy.AddTime),
+			})
+		}
+
+		if len(response.Response.List) == 0 || response.Response.TotalNum == nil || uint64(len(policies)) >= *response.Response.TotalNum {
+			break
+		}
+		page++
+	}
+
+	return policies, nil
+}
+
+// enumerateVPCResources 枚举所有区域的VPC和安全组
+func (p *TencentProvider) enumerateVPCResources() ([]interface{}, []interface{}, []string) {
+	allVPCs := []interface{}{}
+	allSecurityGroups := []interface{}{}
+	errors := []string{}
+
+	regions, err := p.regions()
+	if err != nil {
+		return allVPCs, allSecurityGroups, []string{fmt.Sprintf("VPC: %v", err)}
+	}
+
+	for _, region := range regions {
+		if p.Err() != nil {
+			break
+		}
+		p.RegionStarted("VPC", region)
+		found := len(allVPCs) + len(allSecurityGroups)
+		regionProvider, err := p.forRegion(region)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("VPC (%s): %v", region, err))
+			p.RegionFailed("VPC", region, err)
+			continue
+		}
+
+		// 枚举VPC
+		vpcResp, err := regionProvider.vpcClient.DescribeVpcs(vpc.NewDescribeVpcsRequest())
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("VPC (%s): %v", region, err))
+		} else {
+			for _, v := range vpcResp.Response.VpcSet {
+				allVPCs = append(allVPCs, map[string]interface{}{
+					"vpcId":     stringValue(v.VpcId),
+					"vpcName":   stringValue(v.VpcName),
+					"cidrBlock": stringValue(v.CidrBlock),
+					"isDefault": v.IsDefault != nil && *v.IsDefault,
+					"region":    region,
+				})
+			}
+		}
+
+		// 枚举安全组及其规则
+		sgResp, err := regionProvider.vpcClient.DescribeSecurityGroups(vpc.NewDescribeSecurityGroupsRequest())
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("Security Groups (%s): %v", region, err))
+			p.RegionFailed("VPC", region, err)
+			continue
+		}
+		for _, sg := range sgResp.Response.SecurityGroupSet {
+			group := map[string]interface{}{
+				"securityGroupId":   stringValue(sg.SecurityGroupId),
+				"securityGroupName": stringValue(sg.SecurityGroupName),
+				"description":       stringValue(sg.SecurityGroupDesc),
+				"region":            region,
+			}
+
+			policyReq := vpc.NewDescribeSecurityGroupPoliciesRequest()
+			policyReq.SecurityGroupId = sg.SecurityGroupId
+			policyResp, err := regionProvider.vpcClient.DescribeSecurityGroupPolicies(policyReq)
+			if err == nil && policyResp.Response.SecurityGroupPolicySet != nil {
+				group["ingress"] = securityGroupRules(policyResp.Response.SecurityGroupPolicySet.Ingress)
+				group["egress"] = securityGroupRules(policyResp.Response.SecurityGroupPolicySet.Egress)
+			}
+
+			allSecurityGroups = append(allSecurityGroups, group)
+		}
+		p.RegionFinished("VPC", region, len(allVPCs)+len(allSecurityGroups)-found)
+	}
+
+	return allVPCs, allSecurityGroups, errors
+}
+
+// securityGroupRules 转换安全组规则
+func securityGroupRules(policies []*vpc.SecurityGroupPolicy) []interface{} {
+	rules := []interface{}{}
+	for _, policy := range policies {
+		rules = append(rules, map[string]interface{}{
+			"protocol":    stringValue(policy.Protocol),
+			"port":        stringValue(policy.Port),
+			"cidrBlock":   stringValue(policy.CidrBlock),
+			"action":      stringValue(policy.Action),
+			"description": stringValue(policy.PolicyDescription),
+		})
+	}
+	return rules
+}
+
+// EscalatePrivileges 权限提升
+func (p *TencentProvider) EscalatePrivileges() (map[string]interface{}, error) {
+	identity, err := p.getCallerIdentity()
+	if err != nil {
+		return map[string]interface{}{
+			"user":                "Unknown (Access Denied)",
+			"userType":            "Unknown",
+			"role":                "None",
+			"permissions":         []string{"Limited permissions (Access Denied)"},
+			"potentialEscalation": []string{"Insufficient permissions to analyze"},
+			"riskLevel":           "Unknown",
+			"message":             "Privilege escalation attempted",
+			"actions":             []string{"Checked caller identity"},
+		}, nil
+	}
+
+	userType, userName := analyzeCallerIdentity(identity)
+	permissions := []string{}
+	if userType == "Root Account" {
+		permissions = []string{"*:*"}
+	} else {
+		policies, err := p.getAttachedPolicies(identity)
+		if err == nil {
+			permissions = policies
+		}
+	}
+
+	potentialEscalation := analyzePotentialEscalation(permissions)
+	capabilities := capability.Map(permissions, capabilityRules)
+
+	return map[string]interface{}{
+		"user":                userName,
+		"userType":            userType,
+		"arn":                 identity.Arn,
+		"accountId":           identity.AccountId,
+		"role":                "None",
+		"permissions":         permissions,
+		"capabilities":        capabilities,
+		"riskScore":           capability.Score(capabilities),
+		"potentialEscalation": potentialEscalation,
+		"riskLevel":           capability.RiskLevel(capabilities),
+		"message":             "Privilege escalation attempted",
+		"actions": []string{
+			"Checked caller identity",
+			"Checked CAM policies",
+		},
+	}, nil
+}
+
+// getAttachedPolicies 获取当前子用户关联的策略（直接关联和通过用户组关联）
+func (p *TencentProvider) getAttachedPolicies(identity *callerIdentity) ([]string, error) {
+	var uin uint64
+	if _, err := fmt.Sscanf(identity.UserId, "%d", &uin); err != nil {
+		return nil, fmt.Errorf("failed to parse user uin: %w", err)
+	}
+
+	request := cam.NewListAttachedUserPoliciesRequest()
+	request.TargetUin = common.Uint64Ptr(uin)
+	request.Rp = common.Uint64Ptr(200)
+	response, err := p.camClient.ListAttachedUserPolicies(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attached user policies: %w", err)
+	}
+
+	var permissions []string
+	for _, policy := range response.Response.List {
+		permissions = append(permissions, stringValue(policy.PolicyName))
+	}
+
+	return permissions, nil
+}
+
+// analyzeCallerIdentity 通过分析调用者身份完成用户类型识别
+func analyzeCallerIdentity(identity *callerIdentity) (string, string) {
+	// 腾讯云ARN格式:
+	//   qcs::cam::uin/100000000001:uin/100000000001            主账号
+	//   qcs::cam::uin/100000000001:uin/100000000011            子用户
+	//   qcs::sts:100000000001:assumed-role/4611686018427397919  角色
+	switch identity.Type {
+	case "CAMUser":
+		return "CAM User", identity.UserId
+	case "AssumedRole":
+		return "CAM Role", identity.PrincipalId
+	}
+
+	if identity.AccountId != "" && identity.AccountId == identity.UserId {
+		return "Root Account", "root"
+	}
+
+	return "Unknown", identity.UserId
+}
+
+// analyzePotentialEscalation 分析潜在的权限提升路径
+func analyzePotentialEscalation(permissions []string) []string {
+	var potentialEscalation []string
+
+	for _, perm := range permissions {
+		if perm == "*:*" || perm == "AdministratorAccess" {
+			return []string{"Already has admin privileges"}
+		}
+	}
+
+	for _, perm := range permissions {
+		switch {
+		case strings.HasPrefix(perm, "QcloudCamFullAccess"):
+			potentialEscalation = append(potentialEscalation, "Attach AdministratorAccess to the current user via CAM")
+		case strings.HasPrefix(perm, "QcloudTATFullAccess"), strings.HasPrefix(perm, "QcloudCVMFullAccess"):
+			potentialEscalation = append(potentialEscalation, "Execute commands on CVM instances via TAT")
+		case strings.HasPrefix(perm, "QcloudCOSFullAccess"), strings.HasPrefix(perm, "QcloudCOSDataFullControl"):
+			potentialEscalation = append(potentialEscalation, "Access COS buckets with sensitive data")
+		case strings.HasPrefix(perm, "QcloudSTSFullAccess"):
+			potentialEscalation = append(potentialEscalation, "Assume CAM roles with higher privileges")
+		}
+	}
+
+	if len(potentialEscalation) == 0 {
+		potentialEscalation = append(potentialEscalation, "No obvious privilege escalation paths found")
+	}
+
+	return potentialEscalation
+}
+
+// capabilityRules 腾讯云原生权限（CAM预设策略名称及操作）到标准化能力的映射
+var capabilityRules = []capability.Rule{
+	{Pattern: "*:*", Capabilities: capability.All},
+	{Pattern: "All Permissions", Capabilities: capability.All},
+	{Pattern: "AdministratorAccess", Capabilities: capability.All},
+	{Pattern: "QCloudResourceFullAccess", Capabilities: capability.AllExceptIdentity},
+	{Pattern: "QcloudCamFullAccess", Capabilities: []string{capability.IdentityAdmin, capability.IdentityRead}},
+	{Pattern: "QcloudCamReadOnlyAccess", Capabilities: []string{capability.IdentityRead}},
+	{Pattern: "cam:*", Capabilities: []string{capability.IdentityAdmin, capability.IdentityRead}},
+	{Pattern: "cam:AttachUserPolicy", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "cam:CreateAccessKey", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "QcloudSTSFullAccess", Capabilities: []string{capability.IdentityAdmin}},
+	{Pattern: "QcloudCVMFullAccess", Capabilities: []string{capability.ComputeAdmin, capability.ComputeExec}},
+	{Pattern: "QcloudTATFullAccess", Capabilities: []string{capability.ComputeExec}},
+	{Pattern: "tat:RunCommand", Capabilities: []string{capability.ComputeExec}},
+	{Pattern: "tat:InvokeCommand", Capabilities: []string{capability.ComputeExec}},
+	{Pattern: "QcloudCOSFullAccess", Capabilities: []string{capability.StorageReadAll, capability.StorageWrite}},
+	{Pattern: "QcloudCOSDataFullControl", Capabilities: []string{capability.StorageReadAll, capability.StorageWrite}},
+	{Pattern: "QcloudCOSReadOnlyAccess", Capabilities: []string{capability.StorageReadAll}},
+	{Pattern: "QcloudCOSDataReadOnly", Capabilities: []string{capability.StorageReadAll}},
+	{Pattern: "cos:GetObject", Capabilities: []string{capability.StorageReadAll}},
+	{Pattern: "QcloudSSMFullAccess", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "ssm:GetSecretValue", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "QcloudKMSFullAccess", Capabilities: []string{capability.SecretsRead}},
+	{Pattern: "QcloudCloudAuditFullAccess", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "cloudaudit:StopLogging", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "cloudaudit:DeleteAudit", Capabilities: []string{capability.LoggingDisable}},
+	{Pattern: "QcloudVPCFullAccess", Capabilities: []string{capability.NetworkAdmin}},
+}
+
+// OperateResource 资源操作
+func (p *TencentProvider) OperateResource(resourceType, action, resourceID string, params map[string]interface{}) (map[string]interface{}, error) {
+	switch resourceType {
+	case "cvm":
+		switch action {
+		case "execute_command":
+			return p.executeCommand(resourceID, params)
+		}
+
+	case "cos", "s3":
+		switch action {
+		case "list_objects":
+			prefix, _ := params["prefix"].(string)
+			objects, err := p.listCOSObjects(resourceID, prefix, params)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list COS objects: %w", err)
+			}
+			return map[string]interface{}{
+				"message": "COS objects listed successfully",
+				"bucket":  resourceID,
+				"objects": objects,
+			}, nil
+
+		case "download":
+			key, ok := params["key"].(string)
+			if !ok {
+				return nil, fmt.Errorf("key is required")
+			}
+			return p.presignCOSObject(resourceID, key, params)
+		}
+	}
+
+	// 其他资源操作
+	return map[string]interface{}{
+		"message":      "Resource operation attempted",
+		"resourceType": resourceType,
+		"action":       action,
+		"resourceID":   resourceID,
+		"params":       params,
+	}, nil
+}
+
+// executeCommand 通过TAT在CVM实例上执行命令
+func (p *TencentProvider) executeCommand(instanceID string, params map[string]interface{}) (map[string]interface{}, error) {
+	command, ok := params["command"].(string)
+	if !ok || command == "" {
+		return nil, fmt.Errorf("command is required")
+	}
+
+	// 获取实例区域
+	instanceRegion, _ := params["region"].(string)
+	if instanceRegion == "" {
+		instanceRegion = p.region
+		if instanceRegion == "" {
+			instanceRegion = defaultRegion
+		}
+	}
+
+	// 命令类型：SHELL 或 POWERSHELL
+	commandType, _ := params["command_type"].(string)
+	if commandType == "" {
+		commandType = "SHELL"
+	}
+
+	executionSteps := []string{}
+
+	executionSteps = append(executionSteps, fmt.Sprintf("创建实例区域 (%s) 的TAT客户端...", instanceRegion))
+	regionProvider, err := p.forRegion(instanceRegion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Tencent Cloud provider for region %s: %w", instanceRegion, err)
+	}
+
+	// 调用TAT RunCommand API执行命令
+	executionSteps = append(executionSteps, "执行命令...")
+	runReq := tat.NewRunCommandRequest()
+	runReq.Content = common.StringPtr(base64.StdEncoding.EncodeToString([]byte(command)))
+	runReq.InstanceIds = common.StringPtrs([]string{instanceID})
+	runReq.CommandType = common.StringPtr(commandType)
+	runReq.Timeout = common.Uint64Ptr(60)
+	runResp, err := regionProvider.tatClient.RunCommand(runReq)
+	if err != nil {
+		executionSteps = append(executionSteps, fmt.Sprintf("发送命令失败: %v", err))
+		executionSteps = append(executionSteps, "可能的原因:")
+		executionSteps = append(executionSteps, "1. TAT Agent 未安装或未运行")
+		executionSteps = append(executionSteps, "2. 实例状态不是RUNNING")
+		executionSteps = append(executionSteps, "3. 凭证缺少 tat:RunCommand 权限")
+		// 返回错误以便任务记录失败，限流和网络错误保留原始信息，由任务队列识别为临时性错误
+		return map[string]interface{}{
+			"instanceId":     instanceID,
+			"command":        command,
+			"executionSteps": executionSteps,
+		}, fmt.Errorf("failed to send command: %w", err)
+	}
+
+	invocationID := stringValue(runResp.Response.InvocationId)
+	executionSteps = append(executionSteps, fmt.Sprintf("命令已发送，InvocationId: %s", invocationID))
+	executionSteps = append(executionSteps, "正在等待命令执行结果...")
+
+	// 轮询命令执行结果
+	var task *tat.InvocationTask
+	for i := 0; i < 10; i++ {
+		// 任务被取消时停止轮询，返回已发送的命令
+		if err := p.Sleep(3 * time.Second); err != nil {
+			executionSteps = append(executionSteps, "任务已取消，停止等待命令执行结果")
+			return map[string]interface{}{
+				"message":        "Command sent but task was cancelled before the result was fetched",
+				"instanceId":     instanceID,
+				"invocationId":   invocationID,
+				"command":        command,
+				"status":         "cancelled",
+				"executionSteps": executionSteps,
+			}, err
+		}
+
+		descReq := tat.NewDescribeInvocationTasksRequest()
+		descReq.Filters = []*tat.Filter{{
+			Name:   common.StringPtr("invocation-id"),
+			Values: common.StringPtrs([]string{invocationID}),
+		}}
+		descReq.HideOutput = common.BoolPtr(false)
+		descResp, err := regionProvider.tatClient.DescribeInvocationTasks(descReq)
+		if err != nil {
+			executionSteps = append(executionSteps, fmt.Sprintf("获取命令执行结果失败: %v", err))
+			// 命令已发送，返回 InvocationId 以便稍后查询执行结果
+			return map[string]interface{}{
+				"instanceId":     instanceID,
+				"invocationId":   invocationID,
+				"command":        command,
+				"executionSteps": executionSteps,
+			}, fmt.Errorf("command sent (invocation %s) but failed to get result: %w", invocationID, err)
+		}
+
+		if len(descResp.Response.InvocationTaskSet) > 0 {
+			task = descResp.Response.InvocationTaskSet[0]
+			if !isPendingTaskStatus(stringValue(task.TaskStatus)) {
+				break
+			}
+		}
+	}
+
+	if task == nil {
+		executionSteps = append(executionSteps, "未获取到命令执行任务")
+		return map[string]interface{}{
+			"instanceId":     instanceID,
+			"invocationId":   invocationID,
+			"command":        command,
+			"executionSteps": executionSteps,
+		}, fmt.Errorf("command sent (invocation %s) but no invocation task was found", invocationID)
+	}
+
+	// 解析命令输出（TAT返回Base64编码的输出）
+	var output string
+	var exitCode int64
+	if task.TaskResult != nil {
+		if decoded, err := base64.StdEncoding.DecodeString(stringValue(task.TaskResult.Output)); err == nil {
+			output = string(decoded)
+		}
+		if task.TaskResult.ExitCode != nil {
+			exitCode = *task.TaskResult.ExitCode
+		}
+	}
+
+	executionSteps = append(executionSteps, "命令执行结果:")
+	executionSteps = append(executionSteps, output)
+
+	return map[string]interface{}{
+		"message":        "Command executed successfully",
+		"instanceId":     instanceID,
+		"invocationId":   invocationID,
+		"command":        command,
+		"status":         stringValue(task.TaskStatus),
+		"stdout":         output,
+		"exitCode":       exitCode,
+		"startTime":      stringValue(task.StartTime),
+		"endTime":        stringValue(task.EndTime),
+		"executionSteps": executionSteps,
+	}, nil
+}
+
+// isPendingTaskStatus 判断TAT任务是否仍在执行中
+func isPendingTaskStatus(status string) bool {
+	switch status {
+	case "PENDING", "DELIVERING", "DELIVER_DELAYED", "RUNNING", "":
+		return true
+	}
+	return false
+}
+
+// locateBucket 查找存储桶所在区域
+func (p *TencentProvider) locateBucket(bucketName string, params map[string]interface{}) string {
+	if region, ok := params["region"].(string); ok && region != "" {
+		return region
+	}
+	if p.region != "" {
+		return p.region
+	}
+
+	// 未指定区域时，逐个区域查询存储桶列表
+	regions, err := p.regions()
+	if err == nil {
+		ctx, cancel := context.WithTimeout(p.Context(), 1*time.Minute)
+		defer cancel()
+
+		for _, region := range regions {
+			response, err := p.cosClient(region).ListBuckets(ctx, &s3.ListBucketsInput{})
+			if err != nil {
+				continue
+			}
+			for _, bucket := range response.Buckets {
+				if aws.ToString(bucket.Name) == bucketName {
+					return region
+				}
+			}
+		}
+	}
+
+	return defaultRegion
+}
+
+// listCOSObjects 列出COS存储桶中的对象
+func (p *TencentProvider) listCOSObjects(bucketName, prefix string, params map[string]interface{}) ([]interface{}, error) {
+	client := p.cosClient(p.locateBucket(bucketName, params))
+
+	ctx, cancel := context.WithTimeout(p.Context(), 30*time.Second)
+	defer cancel()
+
+	return s3compat.ListObjects(ctx, client, bucketName, prefix)
+}
+
+// presignCOSObject 生成COS对象的预签名下载URL
+func (p *TencentProvider) presignCOSObject(bucketName, key string, params map[string]interface{}) (map[string]interface{}, error) {
+	bucketRegion := p.locateBucket(bucketName, params)
+
+	downloadURL, err := s3compat.PresignDownload(p.Context(), p.cosClient(bucketRegion), bucketName, key, 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"message":      "Download URL generated",
+		"bucket":       bucketName,
+		"key":          key,
+		"region":       bucketRegion,
+		"download_url": downloadURL,
+	}, nil
+}
+
+// Takeover 平台接管
+func (p *TencentProvider) Takeover() (map[string]interface{}, error) {
+	// 这里应该实现腾讯云平台接管逻辑
+	// 暂时返回模拟数据
+	return map[string]interface{}{
+		"message": "Cloud platform takeover attempted",
+	}, nil
+}
+
+// GetPermissions 获取权限信息
+func (p *TencentProvider) GetPermissions() (map[string]interface{}, error) {
+	identity, err := p.getCallerIdentity()
+	if err != nil {
+		return map[string]interface{}{
+			"message":     "Permissions retrieved",
+			"userType":    "Unknown",
+			"userName":    "Unknown (Access Denied)",
+			"permissions": []string{"Unknown"},
+		}, nil
+	}
+
+	userType, userName := analyzeCallerIdentity(identity)
+
+	var permissions []string
+	if userType == "Root Account" {
+		permissions = []string{"All Permissions"}
+	} else {
+		policies, err := p.getAttachedPolicies(identity)
+		if err != nil {
+			permissions = []string{"Access Denied"}
+		} else {
+			permissions = policies
+		}
+	}
+
+	return map[string]interface{}{
+		"message":      "Permissions retrieved",
+		"userType":     userType,
+		"userName":     userName,
+		"arn":          identity.Arn,
+		"accountId":    identity.AccountId,
+		"permissions":  permissions,
+		"capabilities": capability.Map(permissions, capabilityRules),
+	}, nil
+}
+
+// ValidateCredentials 验证凭证
+func (p *TencentProvider) ValidateCredentials() (bool, error) {
+	if _, err := p.getCallerIdentity(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// callerIdentity sts:GetCallerIdentity 返回的调用者身份
+type callerIdentity struct {
+	Arn         string `json:"Arn"`
+	AccountId   string `json:"AccountId"`
+	UserId      string `json:"UserId"`
+	PrincipalId string `json:"PrincipalId"`
+	Type        string `json:"Type"`
+	RequestId   string `json:"RequestId"`
+}
+
+// getCallerIdentityRequest sts:GetCallerIdentity 请求
+type getCallerIdentityRequest struct {
+	*tchttp.BaseRequest
+}
+
+// getCallerIdentityResponse sts:GetCallerIdentity 响应
+type getCallerIdentityResponse struct {
+	*tchttp.BaseResponse
+	Response *callerIdentity `json:"Response"`
+}
+
+// FromJsonString 解析响应
+func (r *getCallerIdentityResponse) FromJsonString(s string) error {
+	return json.Unmarshal([]byte(s), &r)
+}
+
+// getCallerIdentity 调用 sts:GetCallerIdentity 获取当前凭证的身份
+func (p *TencentProvider) getCallerIdentity() (*callerIdentity, error) {
+	request := &getCallerIdentityRequest{BaseRequest: &tchttp.BaseRequest{}}
+	request.Init().WithApiInfo("sts", sts.APIVersion, "GetCallerIdentity")
+
+	response := &getCallerIdentityResponse{BaseResponse: &tchttp.BaseResponse{}}
+	if err := p.stsClient.Send(request, response); err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %w", err)
+	}
+	if response.Response == nil {
+		return nil, fmt.Errorf("failed to get caller identity: empty response")
+	}
+
+	return response.Response, nil
+}
+
+// stringValue 安全地解引用字符串指针
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// uint64Value 安全地解引用整数指针
+func uint64Value(v *uint64) uint64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
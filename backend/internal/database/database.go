@@ -2,9 +2,14 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/redteamsec/backend/config"
+	"github.com/redteamsec/backend/internal/auth"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -23,29 +28,99 @@ func InitDB(cfg *config.Config) (*gorm.DB, error) {
 		&CloudCredential{},
 		&Task{},
 		&TaskResult{},
+		&PlanApproval{},
+		&FederationToken{},
+		&Schedule{},
+		&Playbook{},
+		&PlaybookRun{},
+		&PlaybookStep{},
+		&Project{},
+		&ProjectMember{},
+		&AuditLog{},
+		&Artifact{},
+		&RefreshToken{},
+		&RevokedToken{},
+		&RecoveryCode{},
+		&Setting{},
 	); err != nil {
 		return nil, err
 	}
 
+	// 审计日志只允许追加，在数据库层面拒绝修改和删除
+	if err := protectAuditLog(db); err != nil {
+		return nil, err
+	}
+
+	// 将旧版本保存的明文密码迁移为哈希
+	if err := migratePasswords(db); err != nil {
+		return nil, err
+	}
+
+	// 早期版本注册用户的角色为 user，迁移为只读角色，需要执行云操作时由管理员提升
+	if err := db.Model(&User{}).Where("role = ?", "user").Update("role", auth.RoleViewer).Error; err != nil {
+		return nil, err
+	}
+
 	// 检查并创建默认管理员用户
 	var adminUser User
 	result := db.Where("username = ?", "admin").First(&adminUser)
 	if result.Error == gorm.ErrRecordNotFound {
-		// 创建默认管理员用户
+		// 创建默认管理员用户，首次登录后必须修改默认密码
+		hash, err := auth.HashPassword(defaultAdminPassword)
+		if err != nil {
+			return nil, err
+		}
 		adminUser = User{
-			Username: "admin",
-			Password: "admin", // 实际应用中应该加密存储
-			Email:    "admin@example.com",
-			Role:     "admin",
+			Username:           "admin",
+			Password:           hash,
+			Email:              "admin@example.com",
+			Role:               auth.RoleAdmin,
+			MustChangePassword: true,
 		}
 		if err := db.Create(&adminUser).Error; err != nil {
 			return nil, err
 		}
 	}
 
+	// 为每个用户创建个人项目，旧数据归入创建者的个人项目
+	if err := migrateProjects(db); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
 
+// defaultAdminPassword 默认管理员的初始密码
+const defaultAdminPassword = "admin"
+
+// migratePasswords 将明文密码替换为哈希，仍在使用默认密码的管理员需要在登录后修改密码
+// 已是哈希的密码不会重复处理，因此每次启动执行都是安全的
+func migratePasswords(db *gorm.DB) error {
+	var users []User
+	if err := db.Find(&users).Error; err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+
+	for _, user := range users {
+		if auth.IsPasswordHash(user.Password) {
+			continue
+		}
+		hash, err := auth.HashPassword(user.Password)
+		if err != nil {
+			return fmt.Errorf("failed to hash password for user %d: %w", user.ID, err)
+		}
+		updates := map[string]interface{}{"password": hash}
+		if user.Username == "admin" && user.Password == defaultAdminPassword {
+			updates["must_change_password"] = true
+		}
+		if err := db.Model(&User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to migrate password for user %d: %w", user.ID, err)
+		}
+		fmt.Printf("Migrated plaintext password for user %s\n", user.Username)
+	}
+	return nil
+}
+
 // InitRedis 初始化 Redis 连接
 func InitRedis(cfg *config.Config) (*redis.Client, error) {
 	client := redis.NewClient(&redis.Options{
@@ -71,16 +146,73 @@ type User struct {
 	Password string `gorm:"size:255" json:"-"`
 	Email    string `gorm:"uniqueIndex;size:255" json:"email"`
 	Role     string `gorm:"size:50" json:"role"`
+	// MustChangePassword 为 true 时用户只能访问个人资料接口，修改密码后才能使用其他功能
+	MustChangePassword bool `json:"mustChangePassword"`
+	// TokensRevokedAt 在此之前签发的访问令牌全部失效（RFC3339，UTC），退出所有会话或修改密码时更新
+	TokensRevokedAt string `json:"-"`
+	// MFAEnabled 登录时需要验证 TOTP 验证码或恢复码
+	MFAEnabled bool `json:"mfaEnabled"`
+	// MFASecret 已启用的 TOTP 密钥，MFAPendingSecret 为尚未确认的新密钥，均使用主密钥加密
+	MFASecret        string `gorm:"type:text" json:"-"`
+	MFAPendingSecret string `gorm:"type:text" json:"-"`
+	// MFALastCounter 最近一次验证通过的时间步，同一验证码不能重复使用
+	MFALastCounter int64 `json:"-"`
+	// MFAFailedAttempts 连续验证失败次数，达到上限后锁定到 MFALockedUntil（UTC，RFC3339）
+	MFAFailedAttempts int    `gorm:"not null;default:0" json:"-"`
+	MFALockedUntil    string `gorm:"size:40;not null;default:''" json:"-"`
+}
+
+// RecoveryCode MFA 恢复码，只保存哈希，每个只能使用一次
+type RecoveryCode struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	UserID   uint   `gorm:"index" json:"userId"`
+	CodeHash string `gorm:"size:64;index" json:"-"`
+	UsedAt   string `json:"usedAt"`
+}
+
+// Setting 管理员修改的系统设置
+type Setting struct {
+	Key       string `gorm:"primaryKey;size:100" json:"key"`
+	Value     string `gorm:"type:text" json:"value"`
+	UpdatedBy uint   `json:"updatedBy"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// SettingRequireMFA 为 "true" 时可以执行云操作的角色必须启用 MFA
+const SettingRequireMFA = "require_mfa"
+
+// RefreshToken 刷新令牌，只保存哈希
+// 同一次登录轮换产生的令牌属于同一 FamilyID，已轮换的令牌再次使用视为泄露，吊销整个会话
+type RefreshToken struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	UserID    uint   `gorm:"index" json:"userId"`
+	FamilyID  string `gorm:"size:64;index" json:"familyId"`
+	TokenHash string `gorm:"size:64;uniqueIndex" json:"-"`
+	CreatedAt string `json:"createdAt"`
+	ExpiresAt string `gorm:"index" json:"expiresAt"` // RFC3339，UTC
+	UsedAt    string `json:"usedAt"`
+	RevokedAt string `json:"revokedAt"`
+}
+
+// RevokedToken 注销时吊销的访问令牌，过期后即可删除
+type RevokedToken struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	JTI       string `gorm:"size:64;uniqueIndex" json:"jti"`
+	UserID    uint   `json:"userId"`
+	ExpiresAt string `gorm:"index" json:"expiresAt"` // RFC3339，UTC
+	RevokedAt string `json:"revokedAt"`
 }
 
 // CloudCredential 云平台凭证模型
 type CloudCredential struct {
 	ID            uint   `gorm:"primaryKey" json:"id"`
 	UserID        uint   `json:"user_id"`
+	ProjectID     uint   `gorm:"index" json:"project_id"`
 	CloudProvider string `gorm:"size:50" json:"cloud_provider"`
 	AccessKey     string `gorm:"size:255" json:"access_key"`
-	SecretKey     string `gorm:"size:255" json:"-"`
+	SecretKey     string `gorm:"type:text" json:"-"` // 信封加密后的密文，只在创建云平台实例时解密
 	Region        string `gorm:"size:50" json:"region"`
+	Endpoint      string `gorm:"size:255" json:"endpoint"`
 	Name          string `gorm:"size:255" json:"name"`
 	Description   string `gorm:"size:255" json:"description"`
 }
@@ -89,12 +221,23 @@ type CloudCredential struct {
 type Task struct {
 	ID           uint   `gorm:"primaryKey" json:"id"`
 	UserID       uint   `json:"userId"`
+	ProjectID    uint   `gorm:"index" json:"projectId"`
 	CredentialID uint   `json:"credentialId"`
+	Name         string `gorm:"size:255" json:"name"`
 	TaskType     string `gorm:"size:50" json:"taskType"`
 	Status       string `gorm:"size:50" json:"status"`
+	Priority     string `gorm:"size:20;default:normal" json:"priority"`
 	Parameters   string `gorm:"type:jsonb" json:"parameters"`
 	StartTime    string `json:"startTime"`
 	EndTime      string `json:"endTime"`
+	// Deliveries 任务被 worker 取出执行的次数，超过上限后转入死信队列
+	Deliveries int `json:"deliveries"`
+	// 执行结果元数据
+	Error         string `gorm:"type:text" json:"error"`
+	ErrorClass    string `gorm:"size:50" json:"errorClass"`
+	Attempts      int    `json:"attempts"`
+	DurationMs    int64  `json:"durationMs"`
+	ProviderCalls string `gorm:"type:text" json:"providerCalls"`
 }
 
 // TaskResult 任务结果模型
@@ -102,6 +245,260 @@ type TaskResult struct {
 	ID        uint   `gorm:"primaryKey" json:"id"`
 	TaskID    uint   `json:"taskId"`
 	Result    string `gorm:"type:jsonb" json:"result"`
-	Error     string `gorm:"size:255" json:"error"`
+	Error     string `gorm:"type:text" json:"error"`
 	Timestamp string `json:"timestamp"`
 }
+
+// PlanApproval 执行计划（dry_run 资源操作任务）的批准记录
+// 批准人不能是生成计划的用户，批准在到期前只能被一个任务使用
+type PlanApproval struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	PlanTaskID uint   `gorm:"uniqueIndex" json:"planTaskId"`
+	ApprovedBy uint   `json:"approvedBy"`
+	ApprovedAt string `json:"approvedAt"`
+	ExpiresAt  string `json:"expiresAt"`
+	// UsedBy 使用该批准执行操作的任务ID，0 表示尚未使用
+	UsedBy uint   `json:"usedBy"`
+	UsedAt string `json:"usedAt"`
+}
+
+// FederationToken 联邦登录签发的临时凭证记录
+type FederationToken struct {
+	ID               uint   `gorm:"primaryKey" json:"id"`
+	UserID           uint   `json:"userId"`
+	ProjectID        uint   `gorm:"index" json:"projectId"`
+	CredentialID     uint   `json:"credentialId"`
+	CloudProvider    string `gorm:"size:50" json:"cloudProvider"`
+	SessionName      string `gorm:"size:64" json:"sessionName"`
+	PolicyPreset     string `gorm:"size:50" json:"policyPreset"`
+	PolicyDocument   string `gorm:"type:text" json:"policyDocument"`
+	AccessKey        string `gorm:"size:255" json:"accessKey"`
+	FederatedUserArn string `gorm:"size:255" json:"federatedUserArn"`
+	IssuedAt         string `json:"issuedAt"`
+	Expiration       string `json:"expiration"`
+	Status           string `gorm:"size:20" json:"status"`
+	RevokedBy        uint   `json:"revokedBy"`
+	RevokedAt        string `json:"revokedAt"`
+}
+
+// Schedule 定时任务模型，按 cron 表达式周期性地创建任务
+type Schedule struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	UserID       uint   `json:"userId"`
+	ProjectID    uint   `gorm:"index" json:"projectId"`
+	CredentialID uint   `json:"credentialId"`
+	Name         string `gorm:"size:255" json:"name"`
+	TaskType     string `gorm:"size:50" json:"taskType"`
+	Parameters   string `gorm:"type:text" json:"parameters"`
+	CronExpr     string `gorm:"size:100" json:"cronExpr"`
+	Status       string `gorm:"size:20" json:"status"`
+	LastRunAt    string `json:"lastRunAt"`
+	LastTaskID   uint   `json:"lastTaskId"`
+	NextRunAt    string `json:"nextRunAt"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+// Playbook 剧本模型，Definition 为步骤组成的有向无环图（JSON）
+type Playbook struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	UserID      uint   `json:"userId"`
+	ProjectID   uint   `gorm:"index" json:"projectId"`
+	Name        string `gorm:"size:255" json:"name"`
+	Description string `gorm:"type:text" json:"description"`
+	Definition  string `gorm:"type:text" json:"definition"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+// PlaybookRun 剧本的一次执行，Definition 为开始执行时的剧本定义快照
+type PlaybookRun struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	PlaybookID   uint   `json:"playbookId"`
+	UserID       uint   `json:"userId"`
+	ProjectID    uint   `gorm:"index" json:"projectId"`
+	CredentialID uint   `json:"credentialId"`
+	Definition   string `gorm:"type:text" json:"definition"`
+	Status       string `gorm:"size:50" json:"status"`
+	StartTime    string `json:"startTime"`
+	EndTime      string `json:"endTime"`
+}
+
+// PlaybookStep 剧本执行中一个步骤的状态，Parameters 为替换引用后的实际任务参数
+type PlaybookStep struct {
+	ID               uint   `gorm:"primaryKey" json:"id"`
+	RunID            uint   `gorm:"index" json:"runId"`
+	StepID           string `gorm:"size:100" json:"stepId"`
+	Name             string `gorm:"size:255" json:"name"`
+	TaskType         string `gorm:"size:50" json:"taskType"`
+	Status           string `gorm:"size:50" json:"status"`
+	Parameters       string `gorm:"type:text" json:"parameters"`
+	RequiresApproval bool   `json:"requiresApproval"`
+	ApprovedBy       uint   `json:"approvedBy"`
+	ApprovedAt       string `json:"approvedAt"`
+	TaskID           uint   `json:"taskId"`
+	Message          string `gorm:"type:text" json:"message"`
+}
+
+// Project 项目（一次评估任务），凭证、任务及其结果归属于项目，项目成员共享访问
+type Project struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Name        string `gorm:"size:255" json:"name"`
+	Description string `gorm:"type:text" json:"description"`
+	CreatedBy   uint   `json:"createdBy"`
+	// Personal 注册时自动创建的个人项目
+	Personal bool `json:"personal"`
+	// Scope 授权范围和交战规则（JSON），为空表示不限制，见 cloud/scope
+	Scope     string `gorm:"type:text" json:"scope"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// 平台创建的资源的清理状态
+const (
+	ArtifactActive  = "active"  // 资源仍存在于目标账号中
+	ArtifactRemoved = "removed" // 已清理
+	ArtifactFailed  = "failed"  // 清理失败，CleanupError 记录原因，可再次清理
+)
+
+// Artifact 平台在目标账号中创建的资源（角色、实例配置文件、关联等），项目结束时据此清理
+// Type、Resource、Parent、Region、RemoveAfter 的含义见 cloud/artifact
+type Artifact struct {
+	ID            uint   `gorm:"primaryKey" json:"id"`
+	ProjectID     uint   `gorm:"index" json:"projectId"`
+	CredentialID  uint   `gorm:"index" json:"credentialId"`
+	TaskID        uint   `json:"taskId"`
+	UserID        uint   `json:"userId"`
+	CloudProvider string `gorm:"size:50" json:"cloudProvider"`
+	Type          string `gorm:"size:50" json:"type"`
+	Resource      string `gorm:"size:1024" json:"resource"`
+	Parent        string `gorm:"size:1024" json:"parent"`
+	Region        string `gorm:"size:50" json:"region"`
+	RemoveAfter   string `json:"removeAfter"`
+	Description   string `gorm:"type:text" json:"description"`
+	CreatedAt     string `json:"createdAt"`
+	Status        string `gorm:"size:20;index" json:"status"`
+	CleanupError  string `gorm:"type:text" json:"cleanupError"`
+	CleanedBy     uint   `json:"cleanedBy"`
+	CleanedAt     string `json:"cleanedAt"`
+}
+
+// ProjectMember 项目成员，Role 为 owner、member 或 viewer
+type ProjectMember struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	ProjectID uint   `gorm:"uniqueIndex:idx_project_member" json:"projectId"`
+	UserID    uint   `gorm:"uniqueIndex:idx_project_member" json:"userId"`
+	Role      string `gorm:"size:20" json:"role"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// ErrAuditLogImmutable 审计日志只允许追加
+var ErrAuditLogImmutable = errors.New("audit log is append-only")
+
+// AuditLog 云平台操作审计记录，只允许追加
+// Hash 由 PrevHash 和记录内容计算，PrevHash 为前一条记录的 Hash，修改或删除任一记录都会使哈希链断开
+type AuditLog struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Timestamp    string `gorm:"index" json:"timestamp"`
+	ActorID      uint   `gorm:"index" json:"actorId"`
+	Actor        string `gorm:"size:255" json:"actor"`
+	ProjectID    uint   `gorm:"index" json:"projectId"`
+	CredentialID uint   `gorm:"index" json:"credentialId"`
+	Provider     string `gorm:"size:50" json:"provider"`
+	TaskID       uint   `gorm:"index" json:"taskId"`
+	Action       string `gorm:"size:255;index" json:"action"`
+	Target       string `gorm:"size:1024" json:"target"`
+	Parameters   string `gorm:"type:text" json:"parameters"` // 已脱敏
+	Result       string `gorm:"size:20" json:"result"`
+	Error        string `gorm:"type:text" json:"error"`
+	// PrevHash 唯一，并发写入时只有一条记录能接在链尾
+	PrevHash string `gorm:"size:64;uniqueIndex" json:"prevHash"`
+	Hash     string `gorm:"size:64" json:"hash"`
+}
+
+// BeforeUpdate 拒绝通过 ORM 修改审计记录
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// BeforeDelete 拒绝通过 ORM 删除审计记录
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// protectAuditLog 创建拒绝修改和删除审计记录的触发器
+func protectAuditLog(db *gorm.DB) error {
+	for _, event := range []string{"UPDATE", "DELETE"} {
+		stmt := fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS audit_logs_no_%s BEFORE %s ON audit_logs
+BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END`, strings.ToLower(event), event)
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to protect audit log: %w", err)
+		}
+	}
+	return nil
+}
+
+// CreatePersonalProject 为用户创建个人项目，用户为项目所有者
+func CreatePersonalProject(db *gorm.DB, user *User) (*Project, error) {
+	project := Project{
+		Name:      user.Username + " 的个人项目",
+		CreatedBy: user.ID,
+		Personal:  true,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&project).Error; err != nil {
+			return err
+		}
+		return tx.Create(&ProjectMember{
+			ProjectID: project.ID,
+			UserID:    user.ID,
+			Role:      "owner",
+			CreatedAt: project.CreatedAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create personal project: %w", err)
+	}
+	return &project, nil
+}
+
+// migrateProjects 为还没有项目的用户创建个人项目，并将其未归属项目的数据移入该项目
+func migrateProjects(db *gorm.DB) error {
+	var users []User
+	if err := db.Find(&users).Error; err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+
+	for _, user := range users {
+		var project Project
+		err := db.Where("created_by = ? AND personal = ?", user.ID, true).First(&project).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			var created *Project
+			if created, err = CreatePersonalProject(db, &user); err == nil {
+				project = *created
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{&CloudCredential{}, &Task{}, &Schedule{}, &Playbook{}, &PlaybookRun{}, &FederationToken{}} {
+			if err := db.Model(model).Where("user_id = ? AND (project_id = 0 OR project_id IS NULL)", user.ID).Update("project_id", project.ID).Error; err != nil {
+				return fmt.Errorf("failed to assign project for user %d: %w", user.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GetSetting 读取系统设置，未设置时返回空字符串
+func GetSetting(db *gorm.DB, key string) (string, error) {
+	var setting Setting
+	if err := db.Where(&Setting{Key: key}).Limit(1).Find(&setting).Error; err != nil {
+		return "", err
+	}
+	return setting.Value, nil
+}
+
+// SetSetting 保存系统设置
+func SetSetting(db *gorm.DB, key, value string, userID uint) error {
+	return db.Save(&Setting{Key: key, Value: value, UpdatedBy: userID, UpdatedAt: time.Now().Format(time.RFC3339)}).Error
+}
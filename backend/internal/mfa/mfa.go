@@ -0,0 +1,261 @@
+package mfa
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redteamsec/backend/internal/auth"
+	"github.com/redteamsec/backend/internal/database"
+	"github.com/redteamsec/backend/internal/secrets"
+	"gorm.io/gorm"
+)
+
+// Issuer 验证器应用中显示的发行方
+const Issuer = "CloudSecPlatform"
+
+// 连续验证失败的锁定策略，6 位验证码在锁定期间无法被在线穷举
+const (
+	MaxFailedAttempts = 5
+	LockoutDuration   = 15 * time.Minute
+)
+
+var (
+	// ErrInvalidCode 验证码或恢复码错误、已使用或已过期
+	ErrInvalidCode = errors.New("invalid MFA code")
+	// ErrAlreadyEnabled 用户已启用 MFA，需要先停用才能重新绑定
+	ErrAlreadyEnabled = errors.New("MFA is already enabled")
+	// ErrNotEnrolled 用户尚未开始绑定或尚未启用 MFA
+	ErrNotEnrolled = errors.New("MFA is not enrolled")
+	// ErrLocked 连续验证失败次数过多，锁定期间拒绝所有验证码和恢复码
+	ErrLocked = errors.New("too many failed MFA attempts, try again later")
+)
+
+// Enforced 管理员是否要求可以执行云操作的角色启用 MFA，读取设置失败时按要求处理
+func Enforced(db *gorm.DB) bool {
+	value, err := database.GetSetting(db, database.SettingRequireMFA)
+	if err != nil {
+		fmt.Printf("Error loading MFA setting: %v\n", err)
+		return true
+	}
+	return value == "true"
+}
+
+// SetEnforced 修改 MFA 强制策略，已签发的访问令牌在刷新时按新策略签发
+func SetEnforced(db *gorm.DB, enforced bool, userID uint) error {
+	return database.SetSetting(db, database.SettingRequireMFA, fmt.Sprint(enforced), userID)
+}
+
+// RoleRequiresMFA 强制策略只针对可以执行云操作的角色，只读角色不受影响
+func RoleRequiresMFA(role string) bool {
+	return auth.HasPermission(role, auth.PermCloudOperate)
+}
+
+// Required 用户是否必须启用 MFA
+func Required(db *gorm.DB, user *database.User) bool {
+	return RoleRequiresMFA(user.Role) && Enforced(db)
+}
+
+// SetupRequired 用户必须启用 MFA 但尚未绑定验证器
+func SetupRequired(db *gorm.DB, user *database.User) bool {
+	return !user.MFAEnabled && Required(db, user)
+}
+
+// Begin 生成新的 TOTP 密钥作为待确认密钥，返回密钥和验证器扫码使用的 otpauth URI
+func Begin(db *gorm.DB, keyring *secrets.Keyring, user *database.User) (string, string, error) {
+	if user.MFAEnabled {
+		return "", "", ErrAlreadyEnabled
+	}
+
+	secret := auth.GenerateTOTPSecret()
+	encrypted, err := keyring.Encrypt(secret)
+	if err != nil {
+		return "", "", err
+	}
+	if err := db.Model(&database.User{}).Where("id = ?", user.ID).Update("mfa_pending_secret", encrypted).Error; err != nil {
+		return "", "", fmt.Errorf("failed to save MFA secret: %w", err)
+	}
+	user.MFAPendingSecret = encrypted
+	return secret, auth.TOTPProvisioningURI(Issuer, user.Username, secret), nil
+}
+
+// Confirm 用验证码确认待绑定的密钥并启用 MFA，返回新生成的恢复码，恢复码只在此时返回一次
+func Confirm(db *gorm.DB, keyring *secrets.Keyring, user *database.User, code string) ([]string, error) {
+	if user.MFAEnabled {
+		return nil, ErrAlreadyEnabled
+	}
+	if user.MFAPendingSecret == "" {
+		return nil, ErrNotEnrolled
+	}
+
+	secret, err := keyring.Decrypt(user.MFAPendingSecret)
+	if err != nil {
+		return nil, err
+	}
+	if err := claimAttempt(db, user.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	counter, ok := auth.ValidateTOTP(secret, code, 0, time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	codes := auth.GenerateRecoveryCodes()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&database.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"mfa_enabled":         true,
+			"mfa_secret":          user.MFAPendingSecret,
+			"mfa_pending_secret":  "",
+			"mfa_last_counter":    counter,
+			"mfa_failed_attempts": 0,
+			"mfa_locked_until":    "",
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to enable MFA: %w", err)
+		}
+		return replaceRecoveryCodes(tx, user.ID, codes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user.MFAEnabled = true
+	user.MFASecret = user.MFAPendingSecret
+	user.MFAPendingSecret = ""
+	user.MFALastCounter = counter
+	return codes, nil
+}
+
+// Verify 校验 TOTP 验证码或恢复码，每个验证码和恢复码都只能使用一次
+func Verify(db *gorm.DB, keyring *secrets.Keyring, user *database.User, code string) error {
+	if !user.MFAEnabled {
+		return ErrNotEnrolled
+	}
+	if err := claimAttempt(db, user.ID, time.Now()); err != nil {
+		return err
+	}
+	if err := verifyCode(db, keyring, user, code); err != nil {
+		return err
+	}
+	return resetAttempts(db, user.ID)
+}
+
+// verifyCode 校验 TOTP 验证码或恢复码并记录已使用
+func verifyCode(db *gorm.DB, keyring *secrets.Keyring, user *database.User, code string) error {
+	if !auth.IsTOTPCode(code) {
+		result := db.Model(&database.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at = ?", user.ID, auth.HashRecoveryCode(code), "").
+			Update("used_at", time.Now().Format(time.RFC3339))
+		if result.Error != nil {
+			return fmt.Errorf("failed to use recovery code: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidCode
+		}
+		return nil
+	}
+
+	secret, err := keyring.Decrypt(user.MFASecret)
+	if err != nil {
+		return err
+	}
+	counter, ok := auth.ValidateTOTP(secret, code, user.MFALastCounter, time.Now())
+	if !ok {
+		return ErrInvalidCode
+	}
+	// 并发请求使用同一验证码时只有一个能更新时间步
+	result := db.Model(&database.User{}).Where("id = ? AND mfa_last_counter < ?", user.ID, counter).Update("mfa_last_counter", counter)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update MFA counter: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidCode
+	}
+	user.MFALastCounter = counter
+	return nil
+}
+
+// claimAttempt 校验前先计入一次失败，达到上限时在同一条语句中锁定，并发请求也无法超过上限
+// 锁定期间返回 ErrLocked，锁定到期后重新计数；验证通过后由 resetAttempts 清零
+func claimAttempt(db *gorm.DB, userID uint, now time.Time) error {
+	now = now.UTC()
+	result := db.Model(&database.User{}).
+		Where("id = ? AND mfa_locked_until <= ?", userID, now.Format(time.RFC3339)).
+		Updates(map[string]interface{}{
+			"mfa_failed_attempts": gorm.Expr("CASE WHEN mfa_failed_attempts >= ? THEN 1 ELSE mfa_failed_attempts + 1 END", MaxFailedAttempts),
+			"mfa_locked_until":    gorm.Expr("CASE WHEN mfa_failed_attempts = ? THEN ? ELSE mfa_locked_until END", MaxFailedAttempts-1, now.Add(LockoutDuration).Format(time.RFC3339)),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to record MFA attempt: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrLocked
+	}
+	return nil
+}
+
+// resetAttempts 验证通过后清除失败次数及锁定
+func resetAttempts(db *gorm.DB, userID uint) error {
+	err := db.Model(&database.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"mfa_failed_attempts": 0,
+		"mfa_locked_until":    "",
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to reset MFA attempts: %w", err)
+	}
+	return nil
+}
+
+// Disable 停用 MFA 并删除密钥和恢复码
+func Disable(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&database.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"mfa_enabled":         false,
+			"mfa_secret":          "",
+			"mfa_pending_secret":  "",
+			"mfa_last_counter":    0,
+			"mfa_failed_attempts": 0,
+			"mfa_locked_until":    "",
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to disable MFA: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&database.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		return nil
+	})
+}
+
+// RegenerateRecoveryCodes 生成新的恢复码，旧恢复码全部失效
+func RegenerateRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	codes := auth.GenerateRecoveryCodes()
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codes)
+	}); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RemainingRecoveryCodes 统计尚未使用的恢复码
+func RemainingRecoveryCodes(db *gorm.DB, userID uint) (int64, error) {
+	var count int64
+	err := db.Model(&database.RecoveryCode{}).Where("user_id = ? AND used_at = ?", userID, "").Count(&count).Error
+	return count, err
+}
+
+// replaceRecoveryCodes 删除用户的旧恢复码并保存新恢复码的哈希
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&database.RecoveryCode{}).Error; err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	records := make([]database.RecoveryCode, len(codes))
+	for i, code := range codes {
+		records[i] = database.RecoveryCode{UserID: userID, CodeHash: auth.HashRecoveryCode(code)}
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return fmt.Errorf("failed to save recovery codes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package mfa
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redteamsec/backend/internal/auth"
+	"github.com/redteamsec/backend/internal/database"
+	"github.com/redteamsec/backend/internal/secrets"
+	"gorm.io/gorm"
+)
+
+// newTestUser 创建已启用 MFA 的用户，返回用户及其恢复码
+func newTestUser(t *testing.T) (*gorm.DB, *secrets.Keyring, *database.User, []string) {
+	t.Helper()
+	db := database.NewTestDB(t, &database.User{}, &database.RecoveryCode{}, &database.Setting{})
+
+	line, err := secrets.GenerateKey("test")
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	keyring, err := secrets.ParseKeys(line)
+	if err != nil {
+		t.Fatalf("parse key: %v", err)
+	}
+	secret, err := keyring.Encrypt(auth.GenerateTOTPSecret())
+	if err != nil {
+		t.Fatalf("encrypt secret: %v", err)
+	}
+
+	user := &database.User{Username: "alice", Role: auth.RoleOperator, MFAEnabled: true, MFASecret: secret}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	codes, err := RegenerateRecoveryCodes(db, user.ID)
+	if err != nil {
+		t.Fatalf("generate recovery codes: %v", err)
+	}
+	return db, keyring, user, codes
+}
+
+func TestVerifyLocksAfterFailedAttempts(t *testing.T) {
+	db, keyring, user, codes := newTestUser(t)
+
+	for i := 0; i < MaxFailedAttempts; i++ {
+		if err := Verify(db, keyring, user, "wrong-code"); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidCode", i+1, err)
+		}
+	}
+	// 锁定期间正确的恢复码同样被拒绝，且不会被消耗
+	if err := Verify(db, keyring, user, codes[0]); !errors.Is(err, ErrLocked) {
+		t.Fatalf("got %v, want ErrLocked", err)
+	}
+
+	// 锁定到期后重新计数
+	past := time.Now().UTC().Add(-time.Second).Format(time.RFC3339)
+	if err := db.Model(&database.User{}).Where("id = ?", user.ID).Update("mfa_locked_until", past).Error; err != nil {
+		t.Fatalf("expire lock: %v", err)
+	}
+	if err := Verify(db, keyring, user, codes[0]); err != nil {
+		t.Fatalf("verify after lock expired: %v", err)
+	}
+
+	var stored database.User
+	db.First(&stored, user.ID)
+	if stored.MFAFailedAttempts != 0 || stored.MFALockedUntil != "" {
+		t.Fatalf("attempts not reset: attempts=%d lockedUntil=%q", stored.MFAFailedAttempts, stored.MFALockedUntil)
+	}
+}
+
+func TestVerifySuccessResetsFailedAttempts(t *testing.T) {
+	db, keyring, user, codes := newTestUser(t)
+
+	for round, code := range codes[:2] {
+		for i := 0; i < MaxFailedAttempts-1; i++ {
+			if err := Verify(db, keyring, user, "wrong-code"); !errors.Is(err, ErrInvalidCode) {
+				t.Fatalf("round %d attempt %d: got %v, want ErrInvalidCode", round, i+1, err)
+			}
+		}
+		if err := Verify(db, keyring, user, code); err != nil {
+			t.Fatalf("round %d: verify recovery code: %v", round, err)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package playbook
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/redteamsec/backend/internal/task"
+)
+
+// Definition 剧本定义，由任务步骤组成的有向无环图
+type Definition struct {
+	Steps []Step `json:"steps"`
+}
+
+// Step 剧本中的一个步骤，对应一个任务
+// Parameters 中的字符串可以使用 {{steps.<步骤ID>.result.<路径>}} 引用前序步骤的输出
+type Step struct {
+	ID         string                 `json:"id"`
+	Name       string                 `json:"name"`
+	TaskType   string                 `json:"taskType"`
+	Parameters map[string]interface{} `json:"parameters"`
+	DependsOn  []string               `json:"dependsOn"`
+	// When 执行条件，不满足时跳过该步骤及依赖它的步骤
+	When *Condition `json:"when,omitempty"`
+	// RequireApproval 执行前需要人工审批，会修改云上状态的步骤无论是否设置都需要审批
+	RequireApproval bool `json:"requireApproval"`
+}
+
+// Condition 步骤执行条件，Path 为引用路径（例如 steps.recon.result.buckets）
+type Condition struct {
+	Path  string      `json:"path"`
+	Op    string      `json:"op"`
+	Value interface{} `json:"value"`
+}
+
+// 支持的条件运算符
+var conditionOps = map[string]bool{
+	"exists": true, "not_exists": true, "empty": true, "not_empty": true,
+	"eq": true, "ne": true, "gt": true, "lt": true, "contains": true,
+}
+
+// Parse 解析并校验剧本定义
+func Parse(data string) (*Definition, error) {
+	var def Definition
+	if err := json.Unmarshal([]byte(data), &def); err != nil {
+		return nil, fmt.Errorf("invalid playbook definition: %w", err)
+	}
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// Validate 校验步骤ID唯一、任务类型有效、依赖存在且不存在环
+func (d *Definition) Validate() error {
+	if len(d.Steps) == 0 {
+		return fmt.Errorf("playbook has no steps")
+	}
+
+	steps := make(map[string]*Step, len(d.Steps))
+	for i := range d.Steps {
+		step := &d.Steps[i]
+		if step.ID == "" {
+			return fmt.Errorf("step %d has no id", i+1)
+		}
+		if _, ok := steps[step.ID]; ok {
+			return fmt.Errorf("duplicate step id %q", step.ID)
+		}
+		if !task.ValidType(step.TaskType) {
+			return fmt.Errorf("step %q has unsupported task type %q", step.ID, step.TaskType)
+		}
+		if step.When != nil && !conditionOps[step.When.Op] {
+			return fmt.Errorf("step %q has unsupported condition operator %q", step.ID, step.When.Op)
+		}
+		steps[step.ID] = step
+	}
+
+	for _, step := range d.Steps {
+		for _, dep := range step.DependsOn {
+			if _, ok := steps[dep]; !ok {
+				return fmt.Errorf("step %q depends on unknown step %q", step.ID, dep)
+			}
+		}
+	}
+
+	// 拓扑排序检测环
+	indegree := make(map[string]int, len(d.Steps))
+	for _, step := range d.Steps {
+		indegree[step.ID] = len(step.DependsOn)
+	}
+	queue := []string{}
+	for id, n := range indegree {
+		if n == 0 {
+			queue = append(queue, id)
+		}
+	}
+	visited := 0
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		visited++
+		for _, step := range d.Steps {
+			for _, dep := range step.DependsOn {
+				if dep == id {
+					indegree[step.ID]--
+					if indegree[step.ID] == 0 {
+						queue = append(queue, step.ID)
+					}
+				}
+			}
+		}
+	}
+	if visited != len(d.Steps) {
+		return fmt.Errorf("playbook steps contain a dependency cycle")
+	}
+	return nil
+}
+
+// NeedsApproval 判断剧本是否可能包含需要审批的步骤
+// 参数中的引用在执行前无法解析，按未解析的参数判断，引用的操作视为会修改状态
+func (d *Definition) NeedsApproval() bool {
+	for _, step := range d.Steps {
+		if step.RequireApproval || task.ChangesState(step.TaskType, step.Parameters) {
+			return true
+		}
+	}
+	return false
+}
+
+// templatePattern 参数中引用前序步骤输出的模板
+var templatePattern = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// resolve 将参数中的模板替换为前序步骤的输出
+// 整个字符串只有一个模板时保留原始类型（例如数组），否则按字符串拼接
+func resolve(value interface{}, outputs map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if match := templatePattern.FindStringSubmatch(v); match != nil && match[0] == v {
+			return lookup(outputs, match[1])
+		}
+		var resolveErr error
+		resolved := templatePattern.ReplaceAllStringFunc(v, func(s string) string {
+			ref := templatePattern.FindStringSubmatch(s)[1]
+			found, err := lookup(outputs, ref)
+			if err != nil {
+				resolveErr = err
+				return s
+			}
+			return fmt.Sprint(found)
+		})
+		return resolved, resolveErr
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			r, err := resolve(item, outputs)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = r
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			r, err := resolve(item, outputs)
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, r)
+		}
+		return resolved, nil
+	}
+	return value, nil
+}
+
+// lookup 按点分路径读取输出，数字表示数组下标，* 表示对数组每个元素取后续路径
+func lookup(value interface{}, ref string) (interface{}, error) {
+	segments := strings.Split(ref, ".")
+	for i, segment := range segments {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				return nil, fmt.Errorf("reference %q not found", ref)
+			}
+			value = next
+		case []interface{}:
+			if segment == "*" {
+				rest := strings.Join(segments[i+1:], ".")
+				items := make([]interface{}, 0, len(v))
+				for _, item := range v {
+					if rest == "" {
+						items = append(items, item)
+						continue
+					}
+					found, err := lookup(item, rest)
+					if err != nil {
+						continue
+					}
+					items = append(items, found)
+				}
+				return items, nil
+			}
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("reference %q not found", ref)
+			}
+			value = v[index]
+		default:
+			return nil, fmt.Errorf("reference %q not found", ref)
+		}
+	}
+	return value, nil
+}
+
+// evaluate 判断步骤执行条件是否满足
+func (c *Condition) evaluate(outputs map[string]interface{}) bool {
+	value, err := lookup(outputs, c.Path)
+	exists := err == nil && value != nil
+
+	switch c.Op {
+	case "exists":
+		return exists
+	case "not_exists":
+		return !exists
+	case "empty":
+		return !exists || isEmpty(value)
+	case "not_empty":
+		return exists && !isEmpty(value)
+	case "eq":
+		return exists && equal(value, c.Value)
+	case "ne":
+		return !exists || !equal(value, c.Value)
+	case "gt", "lt":
+		a, ok1 := toFloat(value)
+		b, ok2 := toFloat(c.Value)
+		if !exists || !ok1 || !ok2 {
+			return false
+		}
+		if c.Op == "gt" {
+			return a > b
+		}
+		return a < b
+	case "contains":
+		if !exists {
+			return false
+		}
+		switch v := value.(type) {
+		case string:
+			s, ok := c.Value.(string)
+			return ok && strings.Contains(v, s)
+		case []interface{}:
+			for _, item := range v {
+				if equal(item, c.Value) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// isEmpty 判断值是否为空字符串、空数组或空对象
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// equal 比较两个 JSON 值，数字统一按浮点数比较
+func equal(a, b interface{}) bool {
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			return x == y
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// toFloat 将 JSON 数字转换为浮点数
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
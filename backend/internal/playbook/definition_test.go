@@ -0,0 +1,160 @@
+package playbook
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testOutputs 模拟前序步骤的输出，结构与解码后的任务结果一致
+func testOutputs() map[string]interface{} {
+	return map[string]interface{}{
+		"steps": map[string]interface{}{
+			"recon": map[string]interface{}{
+				"result": map[string]interface{}{
+					"account": "123456789012",
+					"count":   float64(2),
+					"buckets": []interface{}{
+						map[string]interface{}{"name": "logs", "region": "us-east-1"},
+						map[string]interface{}{"name": "backup", "region": "eu-west-1"},
+					},
+					"empty": []interface{}{},
+				},
+			},
+		},
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "plain string", value: "us-east-1", want: "us-east-1"},
+		{name: "non-string", value: float64(3), want: float64(3)},
+		{name: "whole template keeps type", value: "{{steps.recon.result.count}}", want: float64(2)},
+		{name: "whitespace", value: "{{ steps.recon.result.account }}", want: "123456789012"},
+		{name: "array index", value: "{{steps.recon.result.buckets.1.name}}", want: "backup"},
+		{name: "wildcard", value: "{{steps.recon.result.buckets.*.name}}", want: []interface{}{"logs", "backup"}},
+		{name: "embedded", value: "arn:aws:s3:::{{steps.recon.result.buckets.0.name}}/*", want: "arn:aws:s3:::logs/*"},
+		{name: "multiple", value: "{{steps.recon.result.account}}-{{steps.recon.result.count}}", want: "123456789012-2"},
+		{
+			name:  "nested",
+			value: map[string]interface{}{"buckets": []interface{}{"{{steps.recon.result.buckets.0.name}}", "static"}},
+			want:  map[string]interface{}{"buckets": []interface{}{"logs", "static"}},
+		},
+		{name: "unknown step", value: "{{steps.missing.result}}", wantErr: true},
+		{name: "index out of range", value: "{{steps.recon.result.buckets.5.name}}", wantErr: true},
+		{name: "embedded unknown", value: "bucket-{{steps.recon.result.nothing}}", wantErr: true},
+		{name: "nested unknown", value: []interface{}{"{{steps.recon.result.nothing}}"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolve(tt.value, testOutputs())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("resolve() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionEvaluate(t *testing.T) {
+	tests := []struct {
+		path  string
+		op    string
+		value interface{}
+		want  bool
+	}{
+		{path: "steps.recon.result.buckets", op: "exists", want: true},
+		{path: "steps.recon.result.missing", op: "exists", want: false},
+		{path: "steps.other.result", op: "not_exists", want: true},
+		{path: "steps.recon.result.empty", op: "empty", want: true},
+		{path: "steps.recon.result.missing", op: "empty", want: true},
+		{path: "steps.recon.result.buckets", op: "not_empty", want: true},
+		{path: "steps.recon.result.empty", op: "not_empty", want: false},
+		{path: "steps.recon.result.count", op: "eq", value: float64(2), want: true},
+		{path: "steps.recon.result.count", op: "eq", value: 2, want: true},
+		{path: "steps.recon.result.account", op: "eq", value: "123456789012", want: true},
+		{path: "steps.recon.result.account", op: "ne", value: "210987654321", want: true},
+		{path: "steps.recon.result.missing", op: "ne", value: "x", want: true},
+		{path: "steps.recon.result.count", op: "gt", value: 1, want: true},
+		{path: "steps.recon.result.count", op: "lt", value: 1, want: false},
+		{path: "steps.recon.result.account", op: "gt", value: 1, want: false},
+		{path: "steps.recon.result.account", op: "contains", value: "4567", want: true},
+		{path: "steps.recon.result.buckets.*.name", op: "contains", value: "backup", want: true},
+		{path: "steps.recon.result.buckets.*.name", op: "contains", value: "audit", want: false},
+		{path: "steps.recon.result.missing", op: "contains", value: "x", want: false},
+		{path: "steps.recon.result.count", op: "unknown", want: false},
+	}
+	for _, tt := range tests {
+		c := &Condition{Path: tt.path, Op: tt.op, Value: tt.value}
+		if got := c.evaluate(testOutputs()); got != tt.want {
+			t.Errorf("%s %s %v = %v, want %v", tt.path, tt.op, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	step := func(id string, dependsOn ...string) Step {
+		return Step{ID: id, TaskType: "enumerate", DependsOn: dependsOn}
+	}
+
+	tests := []struct {
+		name    string
+		steps   []Step
+		wantErr string
+	}{
+		{name: "single step", steps: []Step{step("a")}},
+		{name: "diamond", steps: []Step{step("a"), step("b", "a"), step("c", "a"), step("d", "b", "c")}},
+		{name: "declared out of order", steps: []Step{step("b", "a"), step("a")}},
+		{name: "no steps", wantErr: "no steps"},
+		{name: "missing id", steps: []Step{{TaskType: "enumerate"}}, wantErr: "no id"},
+		{name: "duplicate id", steps: []Step{step("a"), step("a")}, wantErr: "duplicate"},
+		{name: "unsupported task type", steps: []Step{{ID: "a", TaskType: "shell"}}, wantErr: "unsupported task type"},
+		{name: "unsupported operator", steps: []Step{{ID: "a", TaskType: "enumerate", When: &Condition{Path: "steps.a", Op: "matches"}}}, wantErr: "unsupported condition operator"},
+		{name: "unknown dependency", steps: []Step{step("a", "z")}, wantErr: "unknown step"},
+		{name: "self cycle", steps: []Step{step("a", "a")}, wantErr: "cycle"},
+		{name: "cycle", steps: []Step{step("a"), step("b", "a", "d"), step("c", "b"), step("d", "c")}, wantErr: "cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Definition{Steps: tt.steps}).Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := Parse(`{"steps": [`); err == nil {
+		t.Fatal("Parse accepted invalid JSON")
+	}
+}
+
+func TestNeedsApproval(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []Step
+		want  bool
+	}{
+		{name: "read only", steps: []Step{{ID: "a", TaskType: "enumerate"}}, want: false},
+		{name: "require approval", steps: []Step{{ID: "a", TaskType: "enumerate", RequireApproval: true}}, want: true},
+		{name: "state changing", steps: []Step{{ID: "a", TaskType: "enumerate"}, {ID: "b", TaskType: "operate", Parameters: map[string]interface{}{"action": "stop_instance"}}}, want: true},
+		{name: "referenced action", steps: []Step{{ID: "a", TaskType: "operate", Parameters: map[string]interface{}{"action": "{{steps.recon.result.action}}"}}}, want: true},
+	}
+	for _, tt := range tests {
+		if got := (&Definition{Steps: tt.steps}).NeedsApproval(); got != tt.want {
+			t.Errorf("%s: NeedsApproval() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package playbook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redteamsec/backend/internal/database"
+	"github.com/redteamsec/backend/internal/project"
+	"github.com/redteamsec/backend/internal/task"
+	"gorm.io/gorm"
+)
+
+// engineInterval 检查执行中剧本的间隔
+const engineInterval = 5 * time.Second
+
+// ErrStepNotWaiting 步骤不在等待审批状态
+var ErrStepNotWaiting = errors.New("step is not waiting for approval")
+
+// ErrNoApprover 剧本包含需要审批的步骤，但项目中没有发起者以外可以审批的用户
+var ErrNoApprover = errors.New("no eligible approver for this playbook run")
+
+// Engine 剧本执行引擎，前序步骤结束后为满足条件的后续步骤提交任务
+type Engine struct {
+	db     *gorm.DB
+	queue  task.Queue
+	broker task.Broker
+
+	// mu 避免定时推进与接口触发的推进同时处理同一剧本
+	mu sync.Mutex
+}
+
+// NewEngine 创建剧本执行引擎
+func NewEngine(db *gorm.DB, queue task.Queue, broker task.Broker) *Engine {
+	return &Engine{db: db, queue: queue, broker: broker}
+}
+
+// Start 启动推进循环，ctx 取消后退出
+func (e *Engine) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(engineInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.advanceAll(ctx)
+			}
+		}
+	}()
+}
+
+// advanceAll 推进所有未结束的剧本执行
+func (e *Engine) advanceAll(ctx context.Context) {
+	var runs []database.PlaybookRun
+	if result := e.db.Where("status IN ?", []string{"running", "waiting_approval"}).Find(&runs); result.Error != nil {
+		fmt.Printf("Error loading playbook runs: %v\n", result.Error)
+		return
+	}
+	for _, run := range runs {
+		if err := e.Advance(ctx, run.ID); err != nil {
+			fmt.Printf("Error advancing playbook run %d: %v\n", run.ID, err)
+		}
+	}
+}
+
+// Run 开始执行剧本，保存定义快照并创建所有步骤
+func (e *Engine) Run(ctx context.Context, playbook *database.Playbook, userID, credentialID uint) (*database.PlaybookRun, error) {
+	def, err := Parse(playbook.Definition)
+	if err != nil {
+		return nil, err
+	}
+
+	// 没有人能审批时拒绝执行，避免剧本一直停在等待审批
+	if def.NeedsApproval() {
+		ok, err := project.HasApprover(e.db, playbook.ProjectID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrNoApprover
+		}
+	}
+
+	run := database.PlaybookRun{
+		PlaybookID:   playbook.ID,
+		UserID:       userID,
+		ProjectID:    playbook.ProjectID,
+		CredentialID: credentialID,
+		Definition:   playbook.Definition,
+		Status:       "running",
+		StartTime:    time.Now().Format(time.RFC3339),
+	}
+	err = e.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&run).Error; err != nil {
+			return err
+		}
+		for _, step := range def.Steps {
+			name := step.Name
+			if name == "" {
+				name = step.ID
+			}
+			if err := tx.Create(&database.PlaybookStep{
+				RunID:    run.ID,
+				StepID:   step.ID,
+				Name:     name,
+				TaskType: step.TaskType,
+				Status:   "pending",
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create playbook run: %w", err)
+	}
+
+	if err := e.Advance(ctx, run.ID); err != nil {
+		return nil, err
+	}
+	e.db.First(&run, run.ID)
+	return &run, nil
+}
+
+// Advance 推进剧本执行：同步步骤任务的状态，为依赖已完成的步骤提交任务或进入审批
+func (e *Engine) Advance(ctx context.Context, runID uint) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var run database.PlaybookRun
+	if result := e.db.First(&run, runID); result.Error != nil {
+		return fmt.Errorf("failed to load playbook run: %w", result.Error)
+	}
+	if run.Status != "running" && run.Status != "waiting_approval" {
+		return nil
+	}
+
+	def, err := Parse(run.Definition)
+	if err != nil {
+		return err
+	}
+	steps, err := e.loadSteps(run.ID)
+	if err != nil {
+		return err
+	}
+
+	// 同步已提交步骤的任务状态
+	for _, step := range steps {
+		if step.Status == "running" && step.TaskID != 0 {
+			e.syncStep(step)
+		}
+	}
+
+	outputs := e.outputs(steps)
+
+	// 反复评估直到没有步骤状态变化，跳过的步骤可能使后续步骤也被跳过
+	for changed := true; changed; {
+		changed = false
+		for _, stepDef := range def.Steps {
+			step := steps[stepDef.ID]
+			if step == nil || (step.Status != "pending" && step.Status != "approved") {
+				continue
+			}
+
+			if step.Status == "pending" {
+				ready, skipReason := dependencyState(stepDef, steps)
+				if !ready {
+					continue
+				}
+				changed = true
+				if skipReason != "" {
+					e.setStep(step, "skipped", skipReason)
+					continue
+				}
+				if stepDef.When != nil && !stepDef.When.evaluate(outputs) {
+					e.setStep(step, "skipped", fmt.Sprintf("condition not met: %s %s", stepDef.When.Path, stepDef.When.Op))
+					continue
+				}
+
+				// 替换参数中的引用，审批人看到的是实际执行的参数
+				params := map[string]interface{}{}
+				if stepDef.Parameters != nil {
+					resolved, err := resolve(stepDef.Parameters, outputs)
+					if err != nil {
+						e.setStep(step, "failed", err.Error())
+						continue
+					}
+					params = resolved.(map[string]interface{})
+				}
+				data, _ := json.Marshal(params)
+				step.Parameters = string(data)
+				step.RequiresApproval = stepDef.RequireApproval || task.ChangesState(stepDef.TaskType, params)
+				if step.RequiresApproval {
+					e.setStep(step, "waiting_approval", "")
+					continue
+				}
+			}
+
+			e.submit(ctx, &run, step)
+			changed = true
+		}
+	}
+
+	return e.updateRunStatus(&run, steps)
+}
+
+// loadSteps 加载剧本执行的所有步骤
+func (e *Engine) loadSteps(runID uint) (map[string]*database.PlaybookStep, error) {
+	var list []database.PlaybookStep
+	if result := e.db.Where("run_id = ?", runID).Find(&list); result.Error != nil {
+		return nil, fmt.Errorf("failed to load playbook steps: %w", result.Error)
+	}
+	steps := make(map[string]*database.PlaybookStep, len(list))
+	for i := range list {
+		steps[list[i].StepID] = &list[i]
+	}
+	return steps, nil
+}
+
+// syncStep 任务结束后更新步骤状态
+func (e *Engine) syncStep(step *database.PlaybookStep) {
+	var t database.Task
+	if result := e.db.First(&t, step.TaskID); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			e.setStep(step, "failed", "task was deleted")
+		}
+		return
+	}
+	switch t.Status {
+	case "completed":
+		e.setStep(step, "completed", "")
+	case "failed":
+		e.setStep(step, "failed", t.Error)
+	case "cancelled":
+		e.setStep(step, "cancelled", t.Error)
+	}
+}
+
+// outputs 构造步骤参数和条件可引用的数据：steps.<步骤ID>.status 及 steps.<步骤ID>.result
+func (e *Engine) outputs(steps map[string]*database.PlaybookStep) map[string]interface{} {
+	stepOutputs := make(map[string]interface{}, len(steps))
+	for id, step := range steps {
+		output := map[string]interface{}{"status": step.Status}
+		if step.Status == "completed" && step.TaskID != 0 {
+			var taskResult database.TaskResult
+			if err := e.db.Where("task_id = ?", step.TaskID).Order("id desc").First(&taskResult).Error; err == nil {
+				var result map[string]interface{}
+				if err := json.Unmarshal([]byte(taskResult.Result), &result); err == nil {
+					output["result"] = result
+				}
+			}
+		}
+		stepOutputs[id] = output
+	}
+	return map[string]interface{}{"steps": stepOutputs}
+}
+
+// dependencyState 判断步骤的依赖是否都已结束，依赖失败或未执行时返回跳过原因
+func dependencyState(stepDef Step, steps map[string]*database.PlaybookStep) (bool, string) {
+	skipReason := ""
+	for _, dep := range stepDef.DependsOn {
+		switch status := steps[dep].Status; status {
+		case "completed":
+		case "failed", "skipped", "rejected", "cancelled":
+			if skipReason == "" {
+				skipReason = fmt.Sprintf("dependency %s %s", dep, status)
+			}
+		default:
+			return false, ""
+		}
+	}
+	return true, skipReason
+}
+
+// setStep 更新步骤状态
+func (e *Engine) setStep(step *database.PlaybookStep, status, message string) {
+	step.Status = status
+	step.Message = message
+	if result := e.db.Save(step); result.Error != nil {
+		fmt.Printf("Error updating playbook step %d: %v\n", step.ID, result.Error)
+	}
+}
+
+// submit 为步骤提交任务，先以条件更新占用步骤，避免多个实例重复提交
+func (e *Engine) submit(ctx context.Context, run *database.PlaybookRun, step *database.PlaybookStep) {
+	result := e.db.Model(&database.PlaybookStep{}).Where("id = ? AND status = ?", step.ID, step.Status).Updates(map[string]interface{}{
+		"status":            "running",
+		"parameters":        step.Parameters,
+		"requires_approval": step.RequiresApproval,
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		if result.Error != nil {
+			fmt.Printf("Error claiming playbook step %d: %v\n", step.ID, result.Error)
+		}
+		return
+	}
+	step.Status = "running"
+
+	t := &database.Task{
+		UserID:       run.UserID,
+		ProjectID:    run.ProjectID,
+		CredentialID: run.CredentialID,
+		Name:         fmt.Sprintf("Playbook run #%d: %s", run.ID, step.Name),
+		TaskType:     step.TaskType,
+		Parameters:   step.Parameters,
+	}
+	if err := task.Submit(ctx, e.db, e.queue, t); err != nil {
+		e.setStep(step, "failed", err.Error())
+		return
+	}
+	step.TaskID = t.ID
+	if result := e.db.Model(step).Update("task_id", t.ID); result.Error != nil {
+		fmt.Printf("Error updating playbook step %d: %v\n", step.ID, result.Error)
+	}
+}
+
+// updateRunStatus 根据步骤状态更新剧本执行状态
+func (e *Engine) updateRunStatus(run *database.PlaybookRun, steps map[string]*database.PlaybookStep) error {
+	status := "completed"
+	waiting, active, failed := false, false, false
+	for _, step := range steps {
+		switch step.Status {
+		case "pending", "approved", "running":
+			active = true
+		case "waiting_approval":
+			waiting = true
+		case "failed":
+			failed = true
+		}
+	}
+	switch {
+	case active:
+		status = "running"
+	case waiting:
+		status = "waiting_approval"
+	case failed:
+		status = "failed"
+	}
+
+	updates := map[string]interface{}{"status": status}
+	if status == "completed" || status == "failed" {
+		updates["end_time"] = time.Now().Format(time.RFC3339)
+	}
+	if status == run.Status {
+		return nil
+	}
+	if result := e.db.Model(run).Updates(updates); result.Error != nil {
+		return fmt.Errorf("failed to update playbook run: %w", result.Error)
+	}
+	return nil
+}
+
+// Approve 批准等待审批的步骤，随后提交该步骤的任务
+func (e *Engine) Approve(ctx context.Context, runID uint, stepID string, userID uint) error {
+	result := e.db.Model(&database.PlaybookStep{}).Where("run_id = ? AND step_id = ? AND status = ?", runID, stepID, "waiting_approval").Updates(map[string]interface{}{
+		"status":      "approved",
+		"approved_by": userID,
+		"approved_at": time.Now().Format(time.RFC3339),
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to approve step: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrStepNotWaiting
+	}
+	return e.Advance(ctx, runID)
+}
+
+// Reject 拒绝等待审批的步骤，依赖该步骤的后续步骤将被跳过
+func (e *Engine) Reject(ctx context.Context, runID uint, stepID string, userID uint) error {
+	result := e.db.Model(&database.PlaybookStep{}).Where("run_id = ? AND step_id = ? AND status = ?", runID, stepID, "waiting_approval").Updates(map[string]interface{}{
+		"status":      "rejected",
+		"approved_by": userID,
+		"approved_at": time.Now().Format(time.RFC3339),
+		"message":     "rejected by approver",
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to reject step: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrStepNotWaiting
+	}
+	return e.Advance(ctx, runID)
+}
+
+// Cancel 取消剧本执行，未开始的步骤标记为已取消，执行中的任务一并取消
+func (e *Engine) Cancel(ctx context.Context, runID uint) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	result := e.db.Model(&database.PlaybookRun{}).Where("id = ? AND status IN ?", runID, []string{"running", "waiting_approval"}).Updates(map[string]interface{}{
+		"status":   "cancelled",
+		"end_time": time.Now().Format(time.RFC3339),
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to cancel playbook run: %w", result.Error)
+	}
+
+	steps, err := e.loadSteps(runID)
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		switch step.Status {
+		case "pending", "waiting_approval", "approved":
+			e.setStep(step, "cancelled", "playbook run cancelled")
+		case "running":
+			if step.TaskID != 0 {
+				if _, err := task.Cancel(ctx, e.db, e.broker, step.TaskID); err != nil && !errors.Is(err, task.ErrTaskFinished) {
+					fmt.Printf("Error cancelling task %d: %v\n", step.TaskID, err)
+				}
+			}
+			e.setStep(step, "cancelled", "playbook run cancelled")
+		}
+	}
+	return nil
+}
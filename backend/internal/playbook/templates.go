@@ -0,0 +1,54 @@
+package playbook
+
+// Template 内置剧本模板
+type Template struct {
+	Key         string     `json:"key"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Definition  Definition `json:"definition"`
+}
+
+// Templates 内置剧本模板列表
+var Templates = []Template{
+	{
+		Key:         "recon-escalate-s3scan",
+		Name:        "资源侦察 → 权限分析 → 存储桶敏感数据扫描",
+		Description: "枚举存储桶后分析凭证权限，发现存储桶时扫描敏感文件；权限为高风险时生成控制台联邦登录链接（需审批）",
+		Definition: Definition{
+			Steps: []Step{
+				{
+					ID:         "recon",
+					Name:       "枚举存储桶",
+					TaskType:   "enumerate",
+					Parameters: map[string]interface{}{"resource_type": "s3"},
+				},
+				{
+					ID:        "escalate",
+					Name:      "权限分析",
+					TaskType:  "escalate",
+					DependsOn: []string{"recon"},
+				},
+				{
+					ID:         "scan",
+					Name:       "存储桶敏感数据扫描",
+					TaskType:   "sensitive_scan",
+					Parameters: map[string]interface{}{"buckets": "{{steps.recon.result.buckets}}"},
+					DependsOn:  []string{"recon"},
+					When:       &Condition{Path: "steps.recon.result.buckets", Op: "not_empty"},
+				},
+				{
+					ID:       "console",
+					Name:     "生成控制台联邦登录链接",
+					TaskType: "operate",
+					Parameters: map[string]interface{}{
+						"resource_type": "iam",
+						"action":        "federated_login",
+						"resource_id":   "console",
+					},
+					DependsOn: []string{"escalate"},
+					When:      &Condition{Path: "steps.escalate.result.riskLevel", Op: "eq", Value: "High"},
+				},
+			},
+		},
+	},
+}
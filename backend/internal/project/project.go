@@ -0,0 +1,138 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/redteamsec/backend/internal/auth"
+	"github.com/redteamsec/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// 项目成员角色
+const (
+	RoleOwner  = "owner"  // 可以管理项目和成员
+	RoleMember = "member" // 可以使用项目中的凭证执行操作
+	RoleViewer = "viewer" // 只能查看项目中的凭证和结果
+)
+
+// ErrNoAccess 用户不是项目成员
+var ErrNoAccess = errors.New("not a member of the project")
+
+// ValidRole 判断项目成员角色是否有效
+func ValidRole(role string) bool {
+	return role == RoleOwner || role == RoleMember || role == RoleViewer
+}
+
+// Access 返回用户在项目中的角色，全局管理员视为所有项目的所有者
+func Access(db *gorm.DB, userID uint, globalRole string, projectID uint) (string, error) {
+	if globalRole == auth.RoleAdmin {
+		var count int64
+		if err := db.Model(&database.Project{}).Where("id = ?", projectID).Count(&count).Error; err != nil {
+			return "", fmt.Errorf("failed to load project: %w", err)
+		}
+		if count == 0 {
+			return "", ErrNoAccess
+		}
+		return RoleOwner, nil
+	}
+
+	var member database.ProjectMember
+	err := db.Where("project_id = ? AND user_id = ?", projectID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrNoAccess
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load project member: %w", err)
+	}
+	return member.Role, nil
+}
+
+// Allows 判断项目角色是否允许指定权限，全局角色的权限由路由单独检查
+// viewer 只允许只读权限，管理项目成员需要 owner
+func Allows(role, permission string) bool {
+	switch role {
+	case RoleOwner:
+		return true
+	case RoleMember:
+		return permission != auth.PermProjectsWrite
+	case RoleViewer:
+		return strings.HasSuffix(permission, ":read")
+	}
+	return false
+}
+
+// IDs 返回用户可以访问的项目ID，全局管理员可以访问所有项目
+func IDs(db *gorm.DB, userID uint, globalRole string) ([]uint, error) {
+	var ids []uint
+	var err error
+	if globalRole == auth.RoleAdmin {
+		err = db.Model(&database.Project{}).Pluck("id", &ids).Error
+	} else {
+		err = db.Model(&database.ProjectMember{}).Where("user_id = ?", userID).Pluck("project_id", &ids).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load projects: %w", err)
+	}
+	return ids, nil
+}
+
+// Default 返回用户的个人项目，未指定项目时新建的凭证和剧本归入该项目
+func Default(db *gorm.DB, user *database.User) (uint, error) {
+	var p database.Project
+	err := db.Where("created_by = ? AND personal = ?", user.ID, true).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		created, err := database.CreatePersonalProject(db, user)
+		if err != nil {
+			return 0, err
+		}
+		return created.ID, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load personal project: %w", err)
+	}
+	return p.ID, nil
+}
+
+// CanApprove 判断用户能否审批项目中的操作：全局管理员，或持有审批权限的项目所有者
+// 发起者不能审批自己的操作，由调用方单独检查
+func CanApprove(db *gorm.DB, userID uint, globalRole string, projectID uint) (bool, error) {
+	if !auth.HasPermission(globalRole, auth.PermPlaybooksApprove) {
+		return false, nil
+	}
+	role, err := Access(db, userID, globalRole, projectID)
+	if errors.Is(err, ErrNoAccess) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return role == RoleOwner, nil
+}
+
+// HasApprover 判断除 excludeUserID 外是否有用户可以审批项目中的操作
+func HasApprover(db *gorm.DB, projectID, excludeUserID uint) (bool, error) {
+	var count int64
+	err := db.Model(&database.User{}).Where("id <> ? AND role = ?", excludeUserID, auth.RoleAdmin).Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to load users: %w", err)
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	var owners []database.User
+	err = db.Joins("JOIN project_members ON project_members.user_id = users.id").
+		Where("project_members.project_id = ? AND project_members.role = ? AND users.id <> ?", projectID, RoleOwner, excludeUserID).
+		Find(&owners).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to load project owners: %w", err)
+	}
+	for _, owner := range owners {
+		if auth.HasPermission(owner.Role, auth.PermPlaybooksApprove) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,92 @@
+package project
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/redteamsec/backend/internal/auth"
+	"github.com/redteamsec/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// newTestProject 创建项目及成员，members 为用户的全局角色和项目角色，项目角色为空表示不是成员
+func newTestProject(t *testing.T, members [][2]string) (*gorm.DB, uint, []uint) {
+	t.Helper()
+	db := database.NewTestDB(t, &database.User{}, &database.Project{}, &database.ProjectMember{})
+
+	p := database.Project{Name: "engagement"}
+	if err := db.Create(&p).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	var ids []uint
+	for i, m := range members {
+		user := database.User{Username: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i), Role: m[0]}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		if m[1] != "" {
+			if err := db.Create(&database.ProjectMember{ProjectID: p.ID, UserID: user.ID, Role: m[1]}).Error; err != nil {
+				t.Fatalf("create member: %v", err)
+			}
+		}
+		ids = append(ids, user.ID)
+	}
+	return db, p.ID, ids
+}
+
+func TestCanApprove(t *testing.T) {
+	tests := []struct {
+		name        string
+		globalRole  string
+		projectRole string
+		want        bool
+	}{
+		{name: "admin", globalRole: auth.RoleAdmin, want: true},
+		{name: "operator owner", globalRole: auth.RoleOperator, projectRole: RoleOwner, want: true},
+		{name: "operator member", globalRole: auth.RoleOperator, projectRole: RoleMember, want: false},
+		{name: "viewer owner", globalRole: auth.RoleViewer, projectRole: RoleOwner, want: false},
+		{name: "not a member", globalRole: auth.RoleOperator, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, projectID, ids := newTestProject(t, [][2]string{{tt.globalRole, tt.projectRole}})
+			got, err := CanApprove(db, ids[0], tt.globalRole, projectID)
+			if err != nil {
+				t.Fatalf("CanApprove: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("CanApprove() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasApprover(t *testing.T) {
+	// 第一个用户为发起者
+	tests := []struct {
+		name    string
+		members [][2]string
+		want    bool
+	}{
+		{name: "starter only", members: [][2]string{{auth.RoleOperator, RoleOwner}}, want: false},
+		{name: "starter is the only admin", members: [][2]string{{auth.RoleAdmin, ""}, {auth.RoleOperator, RoleMember}}, want: false},
+		{name: "other admin", members: [][2]string{{auth.RoleOperator, RoleOwner}, {auth.RoleAdmin, ""}}, want: true},
+		{name: "other owner", members: [][2]string{{auth.RoleOperator, RoleMember}, {auth.RoleOperator, RoleOwner}}, want: true},
+		{name: "other member", members: [][2]string{{auth.RoleOperator, RoleOwner}, {auth.RoleOperator, RoleMember}}, want: false},
+		{name: "owner without approve permission", members: [][2]string{{auth.RoleOperator, RoleOwner}, {auth.RoleViewer, RoleOwner}}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, projectID, ids := newTestProject(t, tt.members)
+			got, err := HasApprover(db, projectID, ids[0])
+			if err != nil {
+				t.Fatalf("HasApprover: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("HasApprover() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
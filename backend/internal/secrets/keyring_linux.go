@@ -0,0 +1,30 @@
+package secrets
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// readOSKeyring 从 Linux 内核密钥环读取主密钥，先查找会话密钥环再查找用户密钥环
+// 可通过 keyctl add user <描述> "<密钥ID>:<密钥>" @u 写入
+func readOSKeyring(description string) (string, error) {
+	id, err := unix.KeyctlSearch(unix.KEY_SPEC_SESSION_KEYRING, "user", description, 0)
+	if err != nil {
+		id, err = unix.KeyctlSearch(unix.KEY_SPEC_USER_KEYRING, "user", description, 0)
+	}
+	if err != nil {
+		return "", fmt.Errorf("key %q not found: %w", description, err)
+	}
+
+	size, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, nil, 0)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, size)
+	n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, buf, 0)
+	if err != nil {
+		return "", err
+	}
+	return string(buf[:n]), nil
+}
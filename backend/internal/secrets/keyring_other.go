@@ -0,0 +1,10 @@
+//go:build !linux
+
+package secrets
+
+import "errors"
+
+// readOSKeyring 目前只支持 Linux 内核密钥环
+func readOSKeyring(description string) (string, error) {
+	return "", errors.New("OS keyring is only supported on Linux")
+}
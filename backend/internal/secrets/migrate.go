@@ -0,0 +1,104 @@
+package secrets
+
+import (
+	"fmt"
+
+	"github.com/redteamsec/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// encryptedColumn 使用主密钥加密保存的字段
+type encryptedColumn struct {
+	name   string // 错误信息中的记录名称
+	model  interface{}
+	column string
+}
+
+// encryptedColumns 所有加密保存的字段：凭证的 SecretKey 和用户的 TOTP 密钥
+var encryptedColumns = []encryptedColumn{
+	{name: "credential", model: &database.CloudCredential{}, column: "secret_key"},
+	{name: "user", model: &database.User{}, column: "mfa_secret"},
+	{name: "user", model: &database.User{}, column: "mfa_pending_secret"},
+}
+
+// encryptedValue 加密字段的一条记录
+type encryptedValue struct {
+	ID    uint
+	Value string
+}
+
+// EncryptPlaintext 加密旧版本保存的明文 SecretKey，已加密的记录不做处理
+func EncryptPlaintext(db *gorm.DB, keyring *Keyring) (int, error) {
+	encrypted, _, err := reencrypt(db, keyring, false)
+	return encrypted, err
+}
+
+// Reencrypt 加密明文 SecretKey，并将使用旧主密钥的记录改为使用当前主密钥，供离线轮换命令使用
+func Reencrypt(db *gorm.DB, keyring *Keyring) (encrypted, rewrapped int, err error) {
+	return reencrypt(db, keyring, true)
+}
+
+// reencrypt 遍历所有加密字段，rotate 为 true 时同时轮换主密钥
+func reencrypt(db *gorm.DB, keyring *Keyring, rotate bool) (int, int, error) {
+	encrypted, rewrapped := 0, 0
+	for _, target := range encryptedColumns {
+		values, err := loadColumn(db, target)
+		if err != nil {
+			return encrypted, rewrapped, err
+		}
+
+		for _, record := range values {
+			var value string
+			var err error
+			switch {
+			case record.Value == "":
+				continue
+			case !IsEncrypted(record.Value):
+				value, err = keyring.Encrypt(record.Value)
+				encrypted++
+			case rotate && KeyID(record.Value) != keyring.PrimaryKeyID():
+				value, err = keyring.Rewrap(record.Value)
+				rewrapped++
+			default:
+				continue
+			}
+			if err != nil {
+				return encrypted, rewrapped, fmt.Errorf("failed to encrypt %s of %s %d: %w", target.column, target.name, record.ID, err)
+			}
+			if err := db.Model(target.model).Where("id = ?", record.ID).Update(target.column, value).Error; err != nil {
+				return encrypted, rewrapped, fmt.Errorf("failed to update %s %d: %w", target.name, record.ID, err)
+			}
+		}
+	}
+	return encrypted, rewrapped, nil
+}
+
+// KeyUsage 统计各主密钥加密的记录数，明文记录计入 plaintext
+func KeyUsage(db *gorm.DB) (map[string]int, error) {
+	usage := map[string]int{}
+	for _, target := range encryptedColumns {
+		values, err := loadColumn(db, target)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range values {
+			switch {
+			case record.Value == "":
+			case IsEncrypted(record.Value):
+				usage[KeyID(record.Value)]++
+			default:
+				usage["plaintext"]++
+			}
+		}
+	}
+	return usage, nil
+}
+
+// loadColumn 读取加密字段的全部记录
+func loadColumn(db *gorm.DB, target encryptedColumn) ([]encryptedValue, error) {
+	var values []encryptedValue
+	if err := db.Model(target.model).Select("id, " + target.column + " AS value").Scan(&values).Error; err != nil {
+		return nil, fmt.Errorf("failed to load %s %s: %w", target.name, target.column, err)
+	}
+	return values, nil
+}
@@ -0,0 +1,236 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 信封加密：每个值使用随机生成的数据密钥（DEK）加密，数据密钥再由主密钥（KEK）加密后与密文一起保存
+// 格式为 enc:v1:<主密钥ID>:<加密的数据密钥>:<加密的数据>，轮换主密钥时只需重新加密数据密钥
+
+const (
+	envelopePrefix = "enc:v1:"
+	keySize        = 32
+)
+
+// keyIDPattern 主密钥ID只允许字母、数字、下划线和短横线
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ErrUnknownKey 密文使用的主密钥不在当前密钥环中
+var ErrUnknownKey = errors.New("master key not found in keyring")
+
+// Keyring 主密钥环，第一个密钥为当前主密钥用于加密，其余密钥仅用于解密轮换前的数据
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// ParseKeys 解析主密钥列表，每行格式为 <密钥ID>:<base64编码的32字节密钥>，# 开头的行为注释
+// 只有一个不带ID的密钥时使用 default 作为ID
+func ParseKeys(data string) (*Keyring, error) {
+	k := &Keyring{keys: map[string][]byte{}}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, encoded := "default", line
+		if i := strings.Index(line, ":"); i >= 0 {
+			id, encoded = line[:i], line[i+1:]
+		}
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid master key id %q", id)
+		}
+		if _, exists := k.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate master key id %q", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key %q: %w", id, err)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("master key %q must be %d bytes, got %d", id, keySize, len(key))
+		}
+
+		k.keys[id] = key
+		if k.primary == "" {
+			k.primary = id
+		}
+	}
+	if k.primary == "" {
+		return nil, errors.New("no master key configured")
+	}
+	return k, nil
+}
+
+// GenerateKey 生成一行新的主密钥，格式与 ParseKeys 一致
+func GenerateKey(id string) (string, error) {
+	if !keyIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid master key id %q", id)
+	}
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate master key: %w", err)
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// PrimaryKeyID 当前用于加密的主密钥ID
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary
+}
+
+// IsEncrypted 判断值是否为信封加密后的密文
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix)
+}
+
+// KeyID 返回密文使用的主密钥ID，非密文返回空字符串
+func KeyID(value string) string {
+	parts, err := splitEnvelope(value)
+	if err != nil {
+		return ""
+	}
+	return parts[0]
+}
+
+// Encrypt 使用新的数据密钥加密明文，并以当前主密钥加密数据密钥
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	data, err := seal(dek, []byte(plaintext), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+	wrapped, err := seal(k.keys[k.primary], dek, []byte(k.primary))
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return envelope(k.primary, wrapped, data), nil
+}
+
+// Decrypt 解密信封加密的密文
+func (k *Keyring) Decrypt(value string) (string, error) {
+	parts, err := splitEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	dek, err := k.unwrap(parts[0], parts[1])
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted secret: %w", err)
+	}
+	plaintext, err := open(dek, data, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Rewrap 使用当前主密钥重新加密数据密钥，数据密文保持不变，用于主密钥轮换
+func (k *Keyring) Rewrap(value string) (string, error) {
+	parts, err := splitEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	if parts[0] == k.primary {
+		return value, nil
+	}
+	dek, err := k.unwrap(parts[0], parts[1])
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.primary], dek, []byte(k.primary))
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted secret: %w", err)
+	}
+	return envelope(k.primary, wrapped, data), nil
+}
+
+// unwrap 使用指定主密钥解密数据密钥
+func (k *Keyring) unwrap(keyID, encoded string) ([]byte, error) {
+	kek, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted data key: %w", err)
+	}
+	dek, err := open(kek, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dek, nil
+}
+
+// envelope 拼接密文
+func envelope(keyID string, wrapped, data []byte) string {
+	return envelopePrefix + keyID + ":" + base64.RawURLEncoding.EncodeToString(wrapped) + ":" + base64.RawURLEncoding.EncodeToString(data)
+}
+
+// splitEnvelope 拆分密文为主密钥ID、加密的数据密钥和加密的数据
+func splitEnvelope(value string) ([]string, error) {
+	if !IsEncrypted(value) {
+		return nil, errors.New("value is not encrypted")
+	}
+	parts := strings.Split(strings.TrimPrefix(value, envelopePrefix), ":")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed encrypted value")
+	}
+	return parts, nil
+}
+
+// seal 使用 AES-256-GCM 加密，随机 nonce 放在密文前
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open 解密 seal 生成的密文
+func open(key, ciphertext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, additionalData)
+}
+
+// newGCM 创建 AES-GCM 实例
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
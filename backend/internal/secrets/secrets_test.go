@@ -0,0 +1,242 @@
+package secrets
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/redteamsec/backend/internal/database"
+)
+
+// newTestKeyring 按顺序生成密钥并解析为密钥环，第一个ID为主密钥
+func newTestKeyring(t *testing.T, ids ...string) *Keyring {
+	t.Helper()
+	var lines []string
+	for _, id := range ids {
+		line, err := GenerateKey(id)
+		if err != nil {
+			t.Fatalf("GenerateKey(%q): %v", id, err)
+		}
+		lines = append(lines, line)
+	}
+	k, err := ParseKeys(strings.Join(lines, "\n"))
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	return k
+}
+
+// rotate 返回以 newID 为主密钥、保留原有密钥的新密钥环
+func rotate(t *testing.T, k *Keyring, newID string) *Keyring {
+	t.Helper()
+	rotated := newTestKeyring(t, newID)
+	for id, key := range k.keys {
+		rotated.keys[id] = key
+	}
+	return rotated
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k := newTestKeyring(t, "k1")
+
+	for _, plaintext := range []string{"", "secret", "包含中文的密钥", strings.Repeat("x", 4096)} {
+		value, err := k.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt: %v", err)
+		}
+		if !IsEncrypted(value) || KeyID(value) != "k1" {
+			t.Fatalf("Encrypt() = %q, want envelope for key k1", value)
+		}
+		if plaintext != "" && strings.Contains(value, plaintext) {
+			t.Fatalf("ciphertext contains plaintext")
+		}
+		got, err := k.Decrypt(value)
+		if err != nil {
+			t.Fatalf("Decrypt: %v", err)
+		}
+		if got != plaintext {
+			t.Fatalf("Decrypt() = %q, want %q", got, plaintext)
+		}
+	}
+
+	// 每次加密使用新的数据密钥和 nonce
+	a, _ := k.Encrypt("secret")
+	b, _ := k.Encrypt("secret")
+	if a == b {
+		t.Fatal("encrypting the same plaintext twice produced the same ciphertext")
+	}
+}
+
+func TestRotateKeys(t *testing.T) {
+	old := newTestKeyring(t, "k1")
+	value, err := old.Encrypt("secret")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	k := rotate(t, old, "k2")
+	if k.PrimaryKeyID() != "k2" {
+		t.Fatalf("PrimaryKeyID() = %q, want k2", k.PrimaryKeyID())
+	}
+	// 轮换后仍可解密旧主密钥加密的数据
+	if got, err := k.Decrypt(value); err != nil || got != "secret" {
+		t.Fatalf("Decrypt old value = %q, %v", got, err)
+	}
+
+	rewrapped, err := k.Rewrap(value)
+	if err != nil {
+		t.Fatalf("Rewrap: %v", err)
+	}
+	if KeyID(rewrapped) != "k2" {
+		t.Fatalf("KeyID after Rewrap = %q, want k2", KeyID(rewrapped))
+	}
+	// 数据密文不变，只重新加密数据密钥
+	if rewrapped[strings.LastIndex(rewrapped, ":"):] != value[strings.LastIndex(value, ":"):] {
+		t.Fatal("Rewrap changed the encrypted data")
+	}
+	if again, err := k.Rewrap(rewrapped); err != nil || again != rewrapped {
+		t.Fatalf("Rewrap with primary key = %q, %v, want unchanged", again, err)
+	}
+
+	// 移除旧主密钥后，重新加密过的数据仍可解密，未重新加密的数据无法解密
+	retired := newTestKeyring(t, "k2")
+	retired.keys["k2"] = k.keys["k2"]
+	if got, err := retired.Decrypt(rewrapped); err != nil || got != "secret" {
+		t.Fatalf("Decrypt rewrapped value = %q, %v", got, err)
+	}
+	if _, err := retired.Decrypt(value); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Decrypt with retired key: got %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestDecryptRejectsInvalidValues(t *testing.T) {
+	k := newTestKeyring(t, "k1")
+	value, err := k.Encrypt("secret")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	parts := strings.Split(strings.TrimPrefix(value, envelopePrefix), ":")
+
+	// 替换第一个 base64 字符，末尾字符可能只包含被忽略的填充位
+	flip := func(s string) string {
+		if s[0] == 'A' {
+			return "B" + s[1:]
+		}
+		return "A" + s[1:]
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "plaintext", value: "secret"},
+		{name: "malformed", value: envelopePrefix + "k1:abc"},
+		{name: "tampered data", value: envelopePrefix + parts[0] + ":" + parts[1] + ":" + flip(parts[2])},
+		{name: "tampered data key", value: envelopePrefix + parts[0] + ":" + flip(parts[1]) + ":" + parts[2]},
+		// 数据密钥与主密钥ID绑定，改写ID无法通过认证
+		{name: "relabelled key id", value: envelopePrefix + "k2:" + parts[1] + ":" + parts[2]},
+	}
+
+	other := rotate(t, k, "k2")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := other.Decrypt(tt.value); err == nil {
+				t.Fatal("Decrypt succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	valid, err := GenerateKey("k1")
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	second, err := GenerateKey("k2")
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	encoded := valid[len("k1:"):]
+
+	tests := []struct {
+		name        string
+		data        string
+		wantPrimary string
+		wantErr     bool
+	}{
+		{name: "single key", data: valid, wantPrimary: "k1"},
+		{name: "without id", data: encoded, wantPrimary: "default"},
+		{name: "comments and blank lines", data: "# 当前主密钥\n\n" + second + "\n" + valid + "\n", wantPrimary: "k2"},
+		{name: "empty", data: "\n# 只有注释\n", wantErr: true},
+		{name: "invalid id", data: "bad id:" + encoded, wantErr: true},
+		{name: "duplicate id", data: valid + "\n" + valid, wantErr: true},
+		{name: "invalid base64", data: "k1:not base64", wantErr: true},
+		{name: "short key", data: "k1:" + encoded[:20], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := ParseKeys(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && k.PrimaryKeyID() != tt.wantPrimary {
+				t.Fatalf("PrimaryKeyID() = %q, want %q", k.PrimaryKeyID(), tt.wantPrimary)
+			}
+		})
+	}
+
+	if _, err := GenerateKey("bad:id"); err == nil {
+		t.Fatal("GenerateKey accepted an invalid id")
+	}
+}
+
+func TestReencrypt(t *testing.T) {
+	db := database.NewTestDB(t, &database.CloudCredential{}, &database.User{})
+
+	old := newTestKeyring(t, "k1")
+	encrypted, err := old.Encrypt("old-secret")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	credentials := []database.CloudCredential{
+		{Name: "legacy", SecretKey: "plain-secret"},
+		{Name: "old", SecretKey: encrypted},
+		{Name: "empty"},
+	}
+	if err := db.Create(&credentials).Error; err != nil {
+		t.Fatalf("create credentials: %v", err)
+	}
+
+	k := rotate(t, old, "k2")
+	count, rewrapped, err := Reencrypt(db, k)
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+	if count != 1 || rewrapped != 1 {
+		t.Fatalf("Reencrypt() = (%d, %d), want (1, 1)", count, rewrapped)
+	}
+
+	usage, err := KeyUsage(db)
+	if err != nil {
+		t.Fatalf("KeyUsage: %v", err)
+	}
+	if len(usage) != 1 || usage["k2"] != 2 {
+		t.Fatalf("KeyUsage() = %v, want only k2", usage)
+	}
+
+	want := map[string]string{"legacy": "plain-secret", "old": "old-secret", "empty": ""}
+	var stored []database.CloudCredential
+	db.Find(&stored)
+	for _, c := range stored {
+		got := c.SecretKey
+		if got != "" {
+			if got, err = k.Decrypt(c.SecretKey); err != nil {
+				t.Fatalf("Decrypt %s: %v", c.Name, err)
+			}
+		}
+		if got != want[c.Name] {
+			t.Fatalf("credential %s secret = %q, want %q", c.Name, got, want[c.Name])
+		}
+	}
+}
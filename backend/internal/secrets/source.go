@@ -0,0 +1,60 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/redteamsec/backend/config"
+)
+
+// devKeyFile 开发环境未配置主密钥时自动生成的密钥文件
+const devKeyFile = "master.key"
+
+// LoadKeyring 按 MASTER_KEY、MASTER_KEY_FILE、MASTER_KEY_KEYRING 的顺序加载主密钥
+// 都未配置时，开发环境在当前目录生成 master.key，其他环境拒绝启动
+func LoadKeyring(cfg *config.Config) (*Keyring, error) {
+	switch {
+	case cfg.MasterKey != "":
+		return ParseKeys(cfg.MasterKey)
+	case cfg.MasterKeyFile != "":
+		return loadKeyFile(cfg.MasterKeyFile)
+	case cfg.MasterKeyKeyring != "":
+		data, err := readOSKeyring(cfg.MasterKeyKeyring)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master key from OS keyring: %w", err)
+		}
+		return ParseKeys(data)
+	}
+
+	if cfg.Environment != "development" {
+		return nil, errors.New("no master key configured, set MASTER_KEY, MASTER_KEY_FILE or MASTER_KEY_KEYRING")
+	}
+	if _, err := os.Stat(devKeyFile); errors.Is(err, os.ErrNotExist) {
+		line, err := GenerateKey("dev")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(devKeyFile, []byte(line+"\n"), 0600); err != nil {
+			return nil, fmt.Errorf("failed to write master key file: %w", err)
+		}
+		fmt.Printf("Warning: no master key configured, generated %s for development\n", devKeyFile)
+	}
+	return loadKeyFile(devKeyFile)
+}
+
+// loadKeyFile 从文件读取主密钥，文件不应被其他用户读取
+func loadKeyFile(path string) (*Keyring, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key file: %w", err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		fmt.Printf("Warning: master key file %s is accessible by other users\n", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key file: %w", err)
+	}
+	return ParseKeys(string(data))
+}
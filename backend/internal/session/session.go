@@ -0,0 +1,228 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redteamsec/backend/config"
+	"github.com/redteamsec/backend/internal/auth"
+	"github.com/redteamsec/backend/internal/database"
+	"github.com/redteamsec/backend/internal/mfa"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidToken 令牌无效、已过期或已被吊销
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrRefreshReused 已轮换的刷新令牌被再次使用，整个会话已被吊销
+	ErrRefreshReused = errors.New("refresh token reuse detected, session revoked")
+)
+
+// Tokens 登录或刷新后返回给客户端的令牌
+type Tokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // 访问令牌有效期（秒）
+}
+
+// Manager 管理访问令牌和刷新令牌的签发、轮换和吊销，吊销记录保存在数据库中，多个实例共享
+type Manager struct {
+	db         *gorm.DB
+	issuer     *auth.TokenIssuer
+	refreshTTL time.Duration
+}
+
+// NewManager 创建会话管理器
+func NewManager(db *gorm.DB, issuer *auth.TokenIssuer, cfg *config.Config) *Manager {
+	return &Manager{db: db, issuer: issuer, refreshTTL: time.Duration(cfg.JWTRefreshTTL) * time.Hour}
+}
+
+// Issuer 返回访问令牌签发器
+func (m *Manager) Issuer() *auth.TokenIssuer {
+	return m.issuer
+}
+
+// Issue 为用户开始新的登录会话
+func (m *Manager) Issue(user *database.User) (*Tokens, error) {
+	return m.issue(m.db, user, auth.RandomToken(16))
+}
+
+// issue 签发访问令牌和属于 familyID 会话的刷新令牌
+// 角色要求启用 MFA 但尚未绑定的用户只获得受限令牌，刷新时按当前策略重新判断
+func (m *Manager) issue(tx *gorm.DB, user *database.User, familyID string) (*Tokens, error) {
+	access, err := m.issuer.GenerateToken(user.ID, user.Username, user.Role, user.MustChangePassword, mfa.SetupRequired(tx, user))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	refresh := auth.RandomToken(32)
+	now := time.Now().UTC()
+	record := database.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refresh),
+		CreatedAt: now.Format(time.RFC3339),
+		ExpiresAt: now.Add(m.refreshTTL).Format(time.RFC3339),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, fmt.Errorf("failed to save refresh token: %w", err)
+	}
+
+	return &Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int(m.issuer.TTL().Seconds()),
+	}, nil
+}
+
+// Refresh 用刷新令牌换取新的访问令牌和刷新令牌，旧刷新令牌随即失效
+func (m *Manager) Refresh(refreshToken string) (*Tokens, *database.User, error) {
+	var tokens *Tokens
+	var user database.User
+	reused := false
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		var record database.RefreshToken
+		if err := tx.Where("token_hash = ?", hashToken(refreshToken)).First(&record).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidToken
+			}
+			return err
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339)
+		if record.RevokedAt != "" || record.ExpiresAt <= now {
+			return ErrInvalidToken
+		}
+		// 只有一个请求能把令牌标记为已使用，并发或重放的请求视为泄露
+		result := tx.Model(&database.RefreshToken{}).Where("id = ? AND used_at = ?", record.ID, "").Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return ErrRefreshReused
+		}
+
+		if err := tx.First(&user, record.UserID).Error; err != nil {
+			return ErrInvalidToken
+		}
+		issued, err := m.issue(tx, &user, record.FamilyID)
+		if err != nil {
+			return err
+		}
+		tokens = issued
+		return nil
+	})
+
+	if reused {
+		if err := m.revokeFamily(refreshToken); err != nil {
+			fmt.Printf("Error revoking refresh token family: %v\n", err)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, &user, nil
+}
+
+// Authenticate 验证访问令牌并加载用户，检查注销吊销的令牌和退出所有会话的时间
+func (m *Manager) Authenticate(tokenString string) (*auth.Claims, *database.User, error) {
+	claims, err := m.issuer.ValidateToken(tokenString)
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+
+	// 角色以数据库为准，修改角色或删除用户后无需等待令牌过期即可生效
+	var user database.User
+	if err := m.db.Select("id", "role", "tokens_revoked_at").First(&user, claims.UserID).Error; err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+	if user.TokensRevokedAt != "" && claims.IssuedAt != nil {
+		revokedAt, err := time.Parse(time.RFC3339, user.TokensRevokedAt)
+		if err == nil && claims.IssuedAt.Time.Before(revokedAt) {
+			return nil, nil, ErrInvalidToken
+		}
+	}
+
+	var revoked int64
+	if err := m.db.Model(&database.RevokedToken{}).Where("jti = ?", claims.ID).Count(&revoked).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked > 0 {
+		return nil, nil, ErrInvalidToken
+	}
+	return claims, &user, nil
+}
+
+// Logout 吊销当前访问令牌，refreshToken 不为空时一并吊销其所属会话的刷新令牌
+func (m *Manager) Logout(claims *auth.Claims, refreshToken string) error {
+	now := time.Now().UTC()
+	record := database.RevokedToken{
+		JTI:       claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.ExpiresAt.Time.UTC().Format(time.RFC3339),
+		RevokedAt: now.Format(time.RFC3339),
+	}
+	if err := m.db.Create(&record).Error; err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+	if refreshToken != "" {
+		if err := m.revokeFamily(refreshToken); err != nil {
+			return err
+		}
+	}
+	m.prune(now)
+	return nil
+}
+
+// RevokeAll 使用户已签发的全部访问令牌和刷新令牌失效，用于退出所有会话和修改密码
+func (m *Manager) RevokeAll(userID uint) error {
+	now := time.Now().UTC()
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&database.User{}).Where("id = ?", userID).Update("tokens_revoked_at", now.Format(time.RFC3339)).Error; err != nil {
+			return fmt.Errorf("failed to revoke access tokens: %w", err)
+		}
+		if err := tx.Model(&database.RefreshToken{}).Where("user_id = ? AND revoked_at = ?", userID, "").Update("revoked_at", now.Format(time.RFC3339)).Error; err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+		return nil
+	})
+}
+
+// revokeFamily 吊销刷新令牌所属会话的全部刷新令牌
+func (m *Manager) revokeFamily(refreshToken string) error {
+	var record database.RefreshToken
+	if err := m.db.Select("family_id").Where("token_hash = ?", hashToken(refreshToken)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to load refresh token: %w", err)
+	}
+	err := m.db.Model(&database.RefreshToken{}).Where("family_id = ? AND revoked_at = ?", record.FamilyID, "").
+		Update("revoked_at", time.Now().UTC().Format(time.RFC3339)).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// prune 删除已过期的吊销记录和刷新令牌，失败只打印日志
+func (m *Manager) prune(now time.Time) {
+	cutoff := now.Format(time.RFC3339)
+	if err := m.db.Where("expires_at < ?", cutoff).Delete(&database.RevokedToken{}).Error; err != nil {
+		fmt.Printf("Error pruning revoked tokens: %v\n", err)
+	}
+	if err := m.db.Where("expires_at < ?", cutoff).Delete(&database.RefreshToken{}).Error; err != nil {
+		fmt.Printf("Error pruning refresh tokens: %v\n", err)
+	}
+}
+
+// hashToken 刷新令牌只保存 SHA-256 哈希，数据库泄露后无法直接使用
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
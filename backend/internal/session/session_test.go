@@ -0,0 +1,137 @@
+package session
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redteamsec/backend/config"
+	"github.com/redteamsec/backend/internal/auth"
+	"github.com/redteamsec/backend/internal/database"
+)
+
+// newTestManager 使用临时 SQLite 数据库创建会话管理器和一个用户
+func newTestManager(t *testing.T) (*Manager, *database.User) {
+	t.Helper()
+	db := database.NewTestDB(t, &database.User{}, &database.RefreshToken{}, &database.RevokedToken{}, &database.Setting{})
+
+	cfg := &config.Config{
+		JWTSecret:     strings.Repeat("s", 32),
+		JWTAlgorithm:  "HS256",
+		JWTAccessTTL:  15,
+		JWTRefreshTTL: 24,
+		Environment:   "test",
+	}
+	issuer, err := auth.NewTokenIssuer(cfg)
+	if err != nil {
+		t.Fatalf("create token issuer: %v", err)
+	}
+
+	user := &database.User{Username: "alice", Role: auth.RoleViewer}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return NewManager(db, issuer, cfg), user
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	m, user := newTestManager(t)
+	first, err := m.Issue(user)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	second, refreshed, err := m.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if refreshed.ID != user.ID {
+		t.Fatalf("refreshed user = %d, want %d", refreshed.ID, user.ID)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	if _, _, err := m.Authenticate(second.AccessToken); err != nil {
+		t.Fatalf("Authenticate new access token: %v", err)
+	}
+	if _, _, err := m.Refresh(second.RefreshToken); err != nil {
+		t.Fatalf("Refresh with rotated token: %v", err)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	m, user := newTestManager(t)
+	stolen, err := m.Issue(user)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	// 同一用户在其他设备上的会话属于不同的令牌族
+	other, err := m.Issue(user)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	rotated, _, err := m.Refresh(stolen.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	// 已轮换的令牌再次使用视为泄露
+	if _, _, err := m.Refresh(stolen.RefreshToken); !errors.Is(err, ErrRefreshReused) {
+		t.Fatalf("reusing rotated token: got %v, want ErrRefreshReused", err)
+	}
+	// 同一令牌族中轮换得到的令牌随之失效
+	if _, _, err := m.Refresh(rotated.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("refreshing after reuse: got %v, want ErrInvalidToken", err)
+	}
+	// 其他会话不受影响
+	if _, _, err := m.Refresh(other.RefreshToken); err != nil {
+		t.Fatalf("refreshing other session: %v", err)
+	}
+}
+
+func TestRefreshRejectsInvalidTokens(t *testing.T) {
+	m, user := newTestManager(t)
+	expired, err := m.Issue(user)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	past := time.Now().UTC().Add(-time.Minute).Format(time.RFC3339)
+	if err := m.db.Model(&database.RefreshToken{}).Where("token_hash = ?", hashToken(expired.RefreshToken)).Update("expires_at", past).Error; err != nil {
+		t.Fatalf("expire token: %v", err)
+	}
+
+	loggedOut, err := m.Issue(user)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	claims, _, err := m.Authenticate(loggedOut.AccessToken)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if err := m.Logout(claims, loggedOut.RefreshToken); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "unknown", token: auth.RandomToken(32)},
+		{name: "empty", token: ""},
+		{name: "expired", token: expired.RefreshToken},
+		{name: "logged out", token: loggedOut.RefreshToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := m.Refresh(tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("got %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+	// 注销后的访问令牌同样失效
+	if _, _, err := m.Authenticate(loggedOut.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Authenticate after logout: got %v, want ErrInvalidToken", err)
+	}
+}
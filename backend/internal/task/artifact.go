@@ -0,0 +1,147 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redteamsec/backend/internal/audit"
+	"github.com/redteamsec/backend/internal/cloud"
+	"github.com/redteamsec/backend/internal/cloud/artifact"
+	"github.com/redteamsec/backend/internal/cloud/progress"
+	"github.com/redteamsec/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// TrackArtifacts 支持上报的云平台创建资源时写入项目的资源台账，写入失败只打印日志
+func TrackArtifacts(db *gorm.DB, provider cloud.CloudProvider, credential *database.CloudCredential, userID, taskID uint) {
+	tracked, ok := provider.(artifact.Tracked)
+	if !ok {
+		return
+	}
+	tracked.SetRecorder(func(a artifact.Artifact) {
+		record := database.Artifact{
+			ProjectID:     credential.ProjectID,
+			CredentialID:  credential.ID,
+			TaskID:        taskID,
+			UserID:        userID,
+			CloudProvider: credential.CloudProvider,
+			Type:          a.Type,
+			Resource:      a.Resource,
+			Parent:        a.Parent,
+			Region:        a.Region,
+			RemoveAfter:   a.RemoveAfter,
+			Description:   a.Description,
+			CreatedAt:     time.Now().Format(time.RFC3339),
+			Status:        database.ArtifactActive,
+		}
+		if err := db.Create(&record).Error; err != nil {
+			fmt.Printf("Error recording artifact %s %s: %v\n", a.Type, a.Resource, err)
+		}
+	})
+}
+
+// cleanupArtifacts 按创建的相反顺序删除任务凭证在项目中创建且尚未清理的资源，单项失败不影响其余资源的清理
+// 清理只撤销平台自己做过的修改，不受项目授权范围和时间窗口的限制，ctx 结束后不再删除剩余资源
+func (w *Worker) cleanupArtifacts(ctx context.Context, task *database.Task, credential *database.CloudCredential) (map[string]interface{}, error) {
+	var artifacts []database.Artifact
+	err := w.db.Where("project_id = ? AND credential_id = ? AND status IN ?", task.ProjectID, credential.ID, []string{database.ArtifactActive, database.ArtifactFailed}).
+		Order("id desc").Find(&artifacts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load artifacts: %w", err)
+	}
+
+	removed, failed := []database.Artifact{}, []database.Artifact{}
+	providers := map[string]cloud.CloudProvider{}
+	for _, record := range artifacts {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := w.removeArtifact(ctx, providers, credential, &record); err != nil {
+			record.Status = database.ArtifactFailed
+			record.CleanupError = err.Error()
+		} else {
+			record.Status = database.ArtifactRemoved
+			record.CleanupError = ""
+			record.CleanedBy = task.UserID
+			record.CleanedAt = time.Now().Format(time.RFC3339)
+		}
+
+		err := w.db.Model(&database.Artifact{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+			"status":        record.Status,
+			"cleanup_error": record.CleanupError,
+			"cleaned_by":    record.CleanedBy,
+			"cleaned_at":    record.CleanedAt,
+		}).Error
+		if err != nil {
+			fmt.Printf("Error updating artifact %d: %v\n", record.ID, err)
+		}
+		w.recordCleanupAudit(task, &record)
+
+		if record.Status == database.ArtifactRemoved {
+			removed = append(removed, record)
+		} else {
+			failed = append(failed, record)
+		}
+	}
+	return map[string]interface{}{
+		"removed": removed,
+		"failed":  failed, // CleanupError 为失败原因
+		"clean":   len(failed) == 0,
+	}, nil
+}
+
+// removeArtifact 删除资源台账中的一项资源，同一区域复用云平台实例
+func (w *Worker) removeArtifact(ctx context.Context, providers map[string]cloud.CloudProvider, credential *database.CloudCredential, record *database.Artifact) error {
+	provider, ok := providers[record.Region]
+	if !ok {
+		created, err := NewProvider(w.keyring, credential, record.Region)
+		if err != nil {
+			return fmt.Errorf("failed to create cloud provider: %w", err)
+		}
+		if cancellable, ok := created.(progress.Cancellable); ok {
+			cancellable.SetContext(ctx)
+		}
+		provider = created
+		providers[record.Region] = provider
+	}
+
+	cleaner, ok := provider.(artifact.Cleaner)
+	if !ok {
+		return fmt.Errorf("automatic cleanup is not supported for %s, remove it manually", record.CloudProvider)
+	}
+	return cleaner.RemoveArtifact(artifact.Artifact{
+		Type:        record.Type,
+		Resource:    record.Resource,
+		Parent:      record.Parent,
+		Region:      record.Region,
+		RemoveAfter: record.RemoveAfter,
+	})
+}
+
+// recordCleanupAudit 每项资源的删除单独记录审计日志
+func (w *Worker) recordCleanupAudit(task *database.Task, record *database.Artifact) {
+	entry := database.AuditLog{
+		ActorID:      task.UserID,
+		ProjectID:    record.ProjectID,
+		CredentialID: record.CredentialID,
+		TaskID:       task.ID,
+		Provider:     record.CloudProvider,
+		Action:       "cleanup:" + record.Type,
+		Target:       record.Resource,
+		Parameters:   fmt.Sprintf(`{"artifact_id":%d}`, record.ID),
+		Result:       audit.ResultSuccess,
+	}
+	if record.Status != database.ArtifactRemoved {
+		entry.Result = audit.ResultFailed
+		entry.Error = record.CleanupError
+	}
+
+	var actor database.User
+	if result := w.db.Select("username").First(&actor, task.UserID); result.Error == nil {
+		entry.Actor = actor.Username
+	}
+	if _, err := audit.Record(w.db, entry); err != nil {
+		fmt.Printf("Error recording audit log for artifact %d: %v\n", record.ID, err)
+	}
+}
@@ -0,0 +1,90 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/redteamsec/backend/internal/audit"
+	"github.com/redteamsec/backend/internal/cloud/plan"
+	"github.com/redteamsec/backend/internal/cloud/scope"
+	"github.com/redteamsec/backend/internal/database"
+)
+
+// recordAudit 为执行过的任务追加审计记录，写入失败只打印日志，不影响任务结果
+func (w *Worker) recordAudit(task *database.Task, taskErr error) {
+	var params map[string]interface{}
+	_ = json.Unmarshal([]byte(task.Parameters), &params)
+
+	entry := database.AuditLog{
+		ActorID:      task.UserID,
+		ProjectID:    task.ProjectID,
+		CredentialID: task.CredentialID,
+		TaskID:       task.ID,
+		Action:       auditAction(task.TaskType, params),
+		Target:       auditTarget(params),
+		Parameters:   task.Parameters,
+		Result:       auditResult(taskErr),
+	}
+	if taskErr != nil {
+		entry.Error = taskErr.Error()
+	}
+
+	var actor database.User
+	if result := w.db.Select("username").First(&actor, task.UserID); result.Error == nil {
+		entry.Actor = actor.Username
+	}
+	var credential database.CloudCredential
+	if result := w.db.Select("cloud_provider").First(&credential, task.CredentialID); result.Error == nil {
+		entry.Provider = credential.CloudProvider
+	}
+
+	if _, err := audit.Record(w.db, entry); err != nil {
+		fmt.Printf("Error recording audit log for task %d: %v\n", task.ID, err)
+	}
+}
+
+// auditAction 返回任务调用的云平台操作，资源操作包含资源类型和动作
+func auditAction(taskType string, params map[string]interface{}) string {
+	parts := []string{taskType}
+	for _, key := range []string{"resource_type", "action"} {
+		if value, ok := params[key].(string); ok && value != "" {
+			parts = append(parts, value)
+		}
+	}
+	if plan.IsDryRun(params) {
+		parts = append(parts, "dry_run")
+	}
+	return strings.Join(parts, ":")
+}
+
+// auditTarget 返回操作的目标资源
+func auditTarget(params map[string]interface{}) string {
+	if id, ok := params["resource_id"].(string); ok && id != "" {
+		return id
+	}
+	if bucket, ok := params["bucket"].(string); ok && bucket != "" {
+		if key, ok := params["key"].(string); ok && key != "" {
+			return bucket + "/" + key
+		}
+		return bucket
+	}
+	if buckets := bucketNames(params["buckets"]); len(buckets) > 0 {
+		return strings.Join(buckets, ",")
+	}
+	return ""
+}
+
+// auditResult 将任务错误转换为审计结果
+func auditResult(err error) string {
+	switch {
+	case err == nil:
+		return audit.ResultSuccess
+	case errors.Is(err, scope.ErrOutOfScope):
+		return audit.ResultRefused
+	case errorClass(err) == ErrorClassCancelled:
+		return audit.ResultCancelled
+	}
+	return audit.ResultFailed
+}
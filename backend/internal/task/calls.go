@@ -0,0 +1,145 @@
+package task
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/redteamsec/backend/internal/cloud"
+	"github.com/redteamsec/backend/internal/cloud/progress"
+)
+
+// countingProvider 包装云平台实例，统计任务执行期间各接口的调用次数
+type countingProvider struct {
+	cloud.CloudProvider
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// newCountingProvider 创建带调用计数的云平台实例
+func newCountingProvider(provider cloud.CloudProvider) *countingProvider {
+	return &countingProvider{CloudProvider: provider, calls: make(map[string]int)}
+}
+
+// record 记录一次接口调用
+func (p *countingProvider) record(method string) {
+	p.mu.Lock()
+	p.calls[method]++
+	p.mu.Unlock()
+}
+
+// Calls 返回各接口调用次数的快照
+func (p *countingProvider) Calls() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	calls := make(map[string]int, len(p.calls))
+	for method, count := range p.calls {
+		calls[method] = count
+	}
+	return calls
+}
+
+// EnumerateResources 资源枚举
+func (p *countingProvider) EnumerateResources(resourceType string) (map[string]interface{}, error) {
+	p.record("EnumerateResources")
+	return p.CloudProvider.EnumerateResources(resourceType)
+}
+
+// EscalatePrivileges 权限提升
+func (p *countingProvider) EscalatePrivileges() (map[string]interface{}, error) {
+	p.record("EscalatePrivileges")
+	return p.CloudProvider.EscalatePrivileges()
+}
+
+// OperateResource 资源操作
+func (p *countingProvider) OperateResource(resourceType, action, resourceID string, params map[string]interface{}) (map[string]interface{}, error) {
+	p.record("OperateResource")
+	return p.CloudProvider.OperateResource(resourceType, action, resourceID, params)
+}
+
+// Takeover 平台接管
+func (p *countingProvider) Takeover() (map[string]interface{}, error) {
+	p.record("Takeover")
+	return p.CloudProvider.Takeover()
+}
+
+// GetPermissions 获取权限信息
+func (p *countingProvider) GetPermissions() (map[string]interface{}, error) {
+	p.record("GetPermissions")
+	return p.CloudProvider.GetPermissions()
+}
+
+// ValidateCredentials 验证凭证
+func (p *countingProvider) ValidateCredentials() (bool, error) {
+	p.record("ValidateCredentials")
+	return p.CloudProvider.ValidateCredentials()
+}
+
+// taskRun 一次任务执行的元数据
+type taskRun struct {
+	start    time.Time
+	attempts int
+	provider *countingProvider
+
+	mu        sync.Mutex
+	events    []progress.Event // 云平台上报的进度，任务取消时作为部分结果保存
+	cancelled bool
+}
+
+// newTaskRun 开始记录一次任务执行
+func newTaskRun() *taskRun {
+	return &taskRun{start: time.Now()}
+}
+
+// providerCallsJSON 返回云平台接口调用次数的 JSON
+func (r *taskRun) providerCallsJSON() string {
+	calls := map[string]int{}
+	if r.provider != nil {
+		calls = r.provider.Calls()
+	}
+	data, err := json.Marshal(calls)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// record 记录云平台上报的进度事件
+func (r *taskRun) record(event progress.Event) {
+	if event.Timestamp == "" {
+		event.Timestamp = time.Now().Format(time.RFC3339)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// markCancelled 标记任务已被用户取消
+func (r *taskRun) markCancelled() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cancelled = true
+}
+
+// isCancelled 判断任务是否已被用户取消
+func (r *taskRun) isCancelled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cancelled
+}
+
+// partialResult 任务取消时的部分结果：云平台已返回的结果加上取消前收到的进度
+func (r *taskRun) partialResult(result map[string]interface{}) map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	partial := map[string]interface{}{}
+	for k, v := range result {
+		partial[k] = v
+	}
+	partial["cancelled"] = true
+	partial["progress"] = append([]progress.Event{}, r.events...)
+	return partial
+}
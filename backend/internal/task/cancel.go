@@ -0,0 +1,51 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redteamsec/backend/internal/cloud/progress"
+	"github.com/redteamsec/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// ErrTaskFinished 任务已结束，无法取消
+var ErrTaskFinished = errors.New("task has already finished")
+
+// Cancel 取消任务，返回取消后的任务状态
+// 排队中的任务直接标记为 cancelled，worker 取出后会将其丢弃；
+// 执行中的任务标记为 cancelling 并通知执行该任务的 worker，由 worker 停止执行并保存部分结果
+func Cancel(ctx context.Context, db *gorm.DB, broker Broker, taskID uint) (string, error) {
+	result := db.Model(&database.Task{}).Where("id = ? AND status = ?", taskID, "pending").Updates(map[string]interface{}{
+		"status":      "cancelled",
+		"end_time":    time.Now().Format(time.RFC3339),
+		"error":       errTaskCancelled.Error(),
+		"error_class": ErrorClassCancelled,
+	})
+	if result.Error != nil {
+		return "", fmt.Errorf("failed to cancel task %d: %w", taskID, result.Error)
+	}
+	if result.RowsAffected == 1 {
+		event := progress.Event{Type: progress.TypeStatus, Status: "cancelled", Timestamp: time.Now().Format(time.RFC3339)}
+		if err := broker.Publish(ctx, taskID, event); err != nil {
+			fmt.Printf("Error publishing event for task %d: %v\n", taskID, err)
+		}
+		return "cancelled", nil
+	}
+
+	result = db.Model(&database.Task{}).Where("id = ? AND status IN ?", taskID, []string{"running", "cancelling"}).Update("status", "cancelling")
+	if result.Error != nil {
+		return "", fmt.Errorf("failed to cancel task %d: %w", taskID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return "", ErrTaskFinished
+	}
+
+	event := progress.Event{Type: progress.TypeCancel, Message: "cancellation requested", Timestamp: time.Now().Format(time.RFC3339)}
+	if err := broker.Publish(ctx, taskID, event); err != nil {
+		fmt.Printf("Error publishing cancel request for task %d: %v\n", taskID, err)
+	}
+	return "cancelling", nil
+}
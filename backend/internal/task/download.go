@@ -0,0 +1,61 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/redteamsec/backend/internal/cloud"
+)
+
+// downloadObject 获取对象存储文件的下载地址并保存到本地下载目录
+func downloadObject(provider cloud.CloudProvider, downloadPath, bucket, key string) (map[string]interface{}, error) {
+	result, err := provider.OperateResource("s3", "download", bucket, map[string]interface{}{"key": key})
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取下载URL
+	downloadURL, ok := result["download_url"].(string)
+	if !ok {
+		return nil, errors.New("failed to get download URL")
+	}
+
+	// 创建下载目录结构
+	filePath := downloadPath + "/" + bucket + "/" + key
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create download directory: %w", err)
+	}
+
+	// 下载文件
+	resp, err := http.Get(downloadURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request download URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 检查响应状态
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	out, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return map[string]interface{}{
+		"bucket":   bucket,
+		"key":      key,
+		"path":     filePath,
+		"basePath": downloadPath,
+	}, nil
+}
@@ -0,0 +1,138 @@
+package task
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/redteamsec/backend/internal/cloud/scope"
+)
+
+// 任务失败的错误分类
+const (
+	ErrorClassTimeout     = "timeout"         // 执行超过任务超时时间
+	ErrorClassTransient   = "transient"       // 限流或网络异常，重试耗尽
+	ErrorClassAuth        = "auth"            // 凭证无效或已过期
+	ErrorClassPermission  = "permission"      // 凭证缺少所需权限
+	ErrorClassInvalid     = "invalid_request" // 任务参数或类型无效
+	ErrorClassCredential  = "credential"      // 凭证不存在或无法创建云平台客户端
+	ErrorClassAbandoned   = "abandoned"       // worker 多次异常退出
+	ErrorClassProvider    = "provider_error"  // 云平台返回的其他错误
+	ErrorClassCancelled   = "cancelled"       // 用户取消了任务
+	ErrorClassOutOfScope  = "out_of_scope"    // 操作超出项目授权范围
+	ErrorClassPlanChanged = "plan_changed"    // 执行前重新生成的计划与已批准的计划不一致
+	ErrorClassNotRetried  = "not_retried"     // 修改目标环境的任务遇到限流或网络异常，为避免重复执行不自动重试
+)
+
+// errTaskTimeout 任务执行超时
+var errTaskTimeout = errors.New("task timed out")
+
+// errTaskCancelled 任务被用户取消
+var errTaskCancelled = errors.New("task cancelled by user")
+
+// classifiedError 已明确分类的任务错误
+type classifiedError struct {
+	class string
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// classify 为错误指定分类
+func classify(class string, err error) error {
+	return &classifiedError{class: class, err: err}
+}
+
+// authErrorPatterns 各云平台凭证无效的错误特征（小写）
+var authErrorPatterns = []string{
+	"invalidaccesskeyid",     // AWS、阿里云
+	"invalidclienttokenid",   // AWS STS
+	"signaturedoesnotmatch",  // AWS、阿里云、S3兼容
+	"expiredtoken",           // AWS 临时凭证过期
+	"authfailure",            // 腾讯云
+	"invalidaccesskey",       // 华为云
+	"incompletesignature",    // AWS
+	"invalid_grant",          // GCP
+	"unauthenticated",        // GCP
+	"the security token",     // AWS STS
+	"invalid authentication", // 通用
+}
+
+// permissionErrorPatterns 各云平台权限不足的错误特征（小写）
+var permissionErrorPatterns = []string{
+	"accessdenied",
+	"access denied",
+	"unauthorizedoperation",
+	"unauthorizedaccess",
+	"forbidden",
+	"nopermission",
+	"permission denied",
+	"permission_denied",
+	"statuscode: 403",
+}
+
+// errorClass 判断任务错误的分类
+func errorClass(err error) string {
+	var ce *classifiedError
+	if errors.As(err, &ce) {
+		return ce.class
+	}
+	if errors.Is(err, errTaskTimeout) {
+		return ErrorClassTimeout
+	}
+	if errors.Is(err, scope.ErrOutOfScope) {
+		return ErrorClassOutOfScope
+	}
+	if isTransientError(err) {
+		return ErrorClassTransient
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, pattern := range authErrorPatterns {
+		if strings.Contains(message, pattern) {
+			return ErrorClassAuth
+		}
+	}
+	for _, pattern := range permissionErrorPatterns {
+		if strings.Contains(message, pattern) {
+			return ErrorClassPermission
+		}
+	}
+	return ErrorClassProvider
+}
+
+// ExplainFailure 返回错误分类对应的失败原因说明
+func ExplainFailure(class string) string {
+	switch class {
+	case ErrorClassTimeout:
+		return "任务执行超过超时时间（TASK_TIMEOUT），可缩小枚举范围或调大超时时间后重试"
+	case ErrorClassTransient:
+		return "云平台限流或网络异常，多次重试后仍然失败，任务已转入死信队列，可稍后重试"
+	case ErrorClassAuth:
+		return "云平台拒绝了凭证，AccessKey/SecretKey 可能无效、已禁用或已过期"
+	case ErrorClassPermission:
+		return "凭证有效但缺少执行该操作所需的权限"
+	case ErrorClassInvalid:
+		return "任务参数无效或任务类型不受支持，请检查任务参数"
+	case ErrorClassCredential:
+		return "任务关联的凭证不存在，或无法使用该凭证创建云平台客户端"
+	case ErrorClassAbandoned:
+		return "执行该任务的 worker 多次异常退出，任务已转入死信队列"
+	case ErrorClassProvider:
+		return "云平台返回错误，详见错误信息"
+	case ErrorClassCancelled:
+		return "任务已被用户取消，结果中保留了取消前已收集的进度"
+	case ErrorClassOutOfScope:
+		return "操作超出项目的授权范围（账号、区域、资源、操作类别或时间窗口），未对目标环境做任何修改"
+	case ErrorClassPlanChanged:
+		return "批准执行计划后目标环境发生了变化，将要执行的修改与已批准的计划不一致，未对目标环境做任何修改，请重新生成并批准执行计划"
+	case ErrorClassNotRetried:
+		return "云平台限流或网络异常。该任务会修改目标环境，操作可能已部分生效，为避免重复执行未自动重试，请先确认目标环境的状态再决定是否重新提交"
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/redteamsec/backend/internal/cloud/scope"
+)
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "classified", err: classify(ErrorClassInvalid, errors.New("unsupported task type")), want: ErrorClassInvalid},
+		{name: "wrapped classified", err: fmt.Errorf("run: %w", classify(ErrorClassCredential, errors.New("credential not found"))), want: ErrorClassCredential},
+		{name: "timeout", err: fmt.Errorf("%w after 300s", errTaskTimeout), want: ErrorClassTimeout},
+		{name: "out of scope", err: fmt.Errorf("region cn-north-1: %w", scope.ErrOutOfScope), want: ErrorClassOutOfScope},
+		{name: "throttling", err: errors.New("api error Throttling: Rate exceeded"), want: ErrorClassTransient},
+		{name: "aws invalid key", err: errors.New("api error InvalidClientTokenId: The security token included in the request is invalid"), want: ErrorClassAuth},
+		{name: "tencent auth", err: errors.New("[TencentCloudSDKError] Code=AuthFailure.SecretIdNotFound"), want: ErrorClassAuth},
+		{name: "gcp auth", err: errors.New("oauth2: cannot fetch token: invalid_grant"), want: ErrorClassAuth},
+		{name: "aws access denied", err: errors.New("api error AccessDenied: User is not authorized"), want: ErrorClassPermission},
+		{name: "ec2 unauthorized", err: errors.New("api error UnauthorizedOperation"), want: ErrorClassPermission},
+		{name: "http 403", err: errors.New("https response error StatusCode: 403"), want: ErrorClassPermission},
+		{name: "other", err: errors.New("api error InvalidInstanceID.NotFound"), want: ErrorClassProvider},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorClass(tt.err); got != tt.want {
+				t.Fatalf("errorClass(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExplainFailure(t *testing.T) {
+	classes := []string{
+		ErrorClassTimeout, ErrorClassTransient, ErrorClassAuth, ErrorClassPermission,
+		ErrorClassInvalid, ErrorClassCredential, ErrorClassAbandoned, ErrorClassProvider,
+		ErrorClassCancelled, ErrorClassOutOfScope, ErrorClassPlanChanged, ErrorClassNotRetried,
+	}
+	for _, class := range classes {
+		if ExplainFailure(class) == "" {
+			t.Errorf("ExplainFailure(%q) is empty", class)
+		}
+	}
+	if got := ExplainFailure("unknown"); got != "" {
+		t.Errorf("ExplainFailure(unknown) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,135 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/redteamsec/backend/internal/cloud/progress"
+)
+
+// eventBufferSize 每个订阅者缓冲的事件数，消费过慢时丢弃新事件
+const eventBufferSize = 64
+
+// Broker 任务进度事件的发布订阅
+type Broker interface {
+	// Publish 发布任务的进度事件
+	Publish(ctx context.Context, taskID uint, event progress.Event) error
+	// Subscribe 订阅任务的进度事件，调用返回的函数取消订阅
+	Subscribe(ctx context.Context, taskID uint) (<-chan progress.Event, func(), error)
+}
+
+// NewBroker 创建事件代理，Redis 可用时通过 Redis pub/sub 跨实例分发，否则使用进程内代理
+func NewBroker(redisClient *redis.Client) Broker {
+	if redisClient != nil {
+		return NewRedisBroker(redisClient)
+	}
+	return NewMemoryBroker()
+}
+
+// eventChannel 任务事件在 Redis 中的频道名
+func eventChannel(taskID uint) string {
+	return fmt.Sprintf("task_events:%d", taskID)
+}
+
+// RedisBroker 基于 Redis pub/sub 的事件代理
+type RedisBroker struct {
+	client *redis.Client
+}
+
+// NewRedisBroker 创建 Redis 事件代理
+func NewRedisBroker(client *redis.Client) *RedisBroker {
+	return &RedisBroker{client: client}
+}
+
+// Publish 发布任务的进度事件
+func (b *RedisBroker) Publish(ctx context.Context, taskID uint, event progress.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	if err := b.client.Publish(ctx, eventChannel(taskID), data).Err(); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+// Subscribe 订阅任务的进度事件
+func (b *RedisBroker) Subscribe(ctx context.Context, taskID uint) (<-chan progress.Event, func(), error) {
+	pubsub := b.client.Subscribe(ctx, eventChannel(taskID))
+	// 等待订阅确认，确保之后发布的事件不会丢失
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, nil, fmt.Errorf("failed to subscribe to task events: %w", err)
+	}
+
+	events := make(chan progress.Event, eventBufferSize)
+	go func() {
+		defer close(events)
+		for message := range pubsub.Channel() {
+			var event progress.Event
+			if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+				continue
+			}
+			select {
+			case events <- event:
+			default:
+			}
+		}
+	}()
+
+	return events, func() { pubsub.Close() }, nil
+}
+
+// MemoryBroker 进程内事件代理，用于未部署 Redis 的单机模式
+type MemoryBroker struct {
+	mu          sync.Mutex
+	subscribers map[uint]map[chan progress.Event]struct{}
+}
+
+// NewMemoryBroker 创建进程内事件代理
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subscribers: make(map[uint]map[chan progress.Event]struct{})}
+}
+
+// Publish 发布任务的进度事件
+func (b *MemoryBroker) Publish(ctx context.Context, taskID uint, event progress.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for events := range b.subscribers[taskID] {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe 订阅任务的进度事件
+func (b *MemoryBroker) Subscribe(ctx context.Context, taskID uint) (<-chan progress.Event, func(), error) {
+	events := make(chan progress.Event, eventBufferSize)
+
+	b.mu.Lock()
+	if b.subscribers[taskID] == nil {
+		b.subscribers[taskID] = make(map[chan progress.Event]struct{})
+	}
+	b.subscribers[taskID][events] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[taskID], events)
+			if len(b.subscribers[taskID]) == 0 {
+				delete(b.subscribers, taskID)
+			}
+			b.mu.Unlock()
+			close(events)
+		})
+	}
+	return events, unsubscribe, nil
+}
@@ -0,0 +1,121 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redteamsec/backend/internal/database"
+	"github.com/redteamsec/backend/internal/secrets"
+	"gorm.io/gorm"
+)
+
+// SealedKey 任务结果中保存加密字段的键名
+const SealedKey = "sealed"
+
+// federationSecretKeys 联邦登录结果中的临时凭证和控制台登录地址，持有即可登录目标账号
+var federationSecretKeys = []string{"url", "federated_login_url", "console_url", "secret_key", "session_token", "credentials"}
+
+// sealSecrets 将结果中的敏感字段移出，使用主密钥加密后保存在 sealed 字段中
+// 任务结果对项目成员可见，加密失败时丢弃这些字段，不以明文保存
+func sealSecrets(keyring *secrets.Keyring, result map[string]interface{}, keys []string) error {
+	hidden := map[string]interface{}{}
+	for _, key := range keys {
+		if value, ok := result[key]; ok {
+			hidden[key] = value
+			delete(result, key)
+		}
+	}
+	if len(hidden) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(hidden)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sealed fields: %w", err)
+	}
+	sealed, err := keyring.Encrypt(string(data))
+	if err != nil {
+		return err
+	}
+	result[SealedKey] = sealed
+	return nil
+}
+
+// RevealSecrets 处理任务结果中加密保存的字段：reveal 为 true 时解密并放回结果，供任务提交者读取，
+// 否则只移除密文
+func RevealSecrets(keyring *secrets.Keyring, result map[string]interface{}, reveal bool) error {
+	sealed, ok := result[SealedKey].(string)
+	if !ok {
+		return nil
+	}
+	delete(result, SealedKey)
+	if !reveal {
+		return nil
+	}
+
+	data, err := keyring.Decrypt(sealed)
+	if err != nil {
+		return err
+	}
+	var hidden map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &hidden); err != nil {
+		return fmt.Errorf("failed to parse sealed fields: %w", err)
+	}
+	for key, value := range hidden {
+		result[key] = value
+	}
+	return nil
+}
+
+// recordFederationToken 保存联邦登录签发的临时凭证记录
+func recordFederationToken(db *gorm.DB, userID uint, credential database.CloudCredential, result map[string]interface{}) (uint, error) {
+	str := func(key string) string {
+		v, _ := result[key].(string)
+		return v
+	}
+
+	policyDocument := str("policy_document")
+	if policyDocument == "" && result["policy_arns"] != nil {
+		arns, _ := json.Marshal(result["policy_arns"])
+		policyDocument = string(arns)
+	}
+
+	accessKey := str("access_key")
+	if creds, ok := result["credentials"].(map[string]interface{}); ok {
+		if ak, ok := creds["access_key"].(string); ok && ak != "" {
+			accessKey = ak
+		}
+	}
+
+	token := database.FederationToken{
+		UserID:           userID,
+		ProjectID:        credential.ProjectID,
+		CredentialID:     credential.ID,
+		CloudProvider:    credential.CloudProvider,
+		SessionName:      str("session_name"),
+		PolicyPreset:     str("policy_preset"),
+		PolicyDocument:   policyDocument,
+		AccessKey:        accessKey,
+		FederatedUserArn: str("federated_user_arn"),
+		IssuedAt:         time.Now().Format(time.RFC3339),
+		Expiration:       str("expiry"),
+		Status:           "active",
+	}
+	if token.FederatedUserArn == "" {
+		token.FederatedUserArn = str("role_arn")
+	}
+
+	if err := db.Create(&token).Error; err != nil {
+		return 0, err
+	}
+	return token.ID, nil
+}
+
+// markFederationTokensRevoked 撤销会拒绝同一会话名称此前签发的所有令牌，因此一并标记
+func markFederationTokensRevoked(db *gorm.DB, userID, credentialID uint, params map[string]interface{}) error {
+	sessionName, _ := params["session_name"].(string)
+	return db.Model(&database.FederationToken{}).
+		Where("credential_id = ? AND session_name = ? AND status = ?", credentialID, sessionName, "active").
+		Updates(map[string]interface{}{"status": "revoked", "revoked_by": userID, "revoked_at": time.Now().Format(time.RFC3339)}).Error
+}
@@ -0,0 +1,74 @@
+package task
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redteamsec/backend/internal/cloud/federation"
+	"github.com/redteamsec/backend/internal/secrets"
+)
+
+func TestSealFederatedLoginResult(t *testing.T) {
+	line, err := secrets.GenerateKey("test")
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	keyring, err := secrets.ParseKeys(line)
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+
+	login := &federation.Login{
+		Provider:     "AWS",
+		URL:          "https://signin.aws.amazon.com/federation?Action=login&SigninToken=signin-token",
+		Expiry:       time.Now().Add(time.Hour),
+		AccessKey:    "ASIAEXAMPLE",
+		SecretKey:    "temporary-secret-key",
+		SessionToken: "temporary-session-token",
+	}
+	result := login.Result()
+	if err := sealSecrets(keyring, result, federationSecretKeys); err != nil {
+		t.Fatalf("sealSecrets: %v", err)
+	}
+
+	// 保存的结果中不包含临时凭证和登录地址
+	stored, _ := json.Marshal(result)
+	for _, secret := range []string{login.SecretKey, login.SessionToken, "signin-token"} {
+		if strings.Contains(string(stored), secret) {
+			t.Fatalf("stored result contains %q: %s", secret, stored)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		reveal bool
+	}{
+		{name: "requester", reveal: true},
+		{name: "other member", reveal: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]interface{}
+			json.Unmarshal(stored, &got)
+			if err := RevealSecrets(keyring, got, tt.reveal); err != nil {
+				t.Fatalf("RevealSecrets: %v", err)
+			}
+			if _, ok := got[SealedKey]; ok {
+				t.Fatal("sealed field returned to the client")
+			}
+			if got["access_key"] != login.AccessKey {
+				t.Fatalf("access_key = %v, want %s", got["access_key"], login.AccessKey)
+			}
+			secretKey, _ := got["secret_key"].(string)
+			url, _ := got["url"].(string)
+			if tt.reveal && (secretKey != login.SecretKey || url != login.URL) {
+				t.Fatalf("revealed secret_key = %q, url = %q", secretKey, url)
+			}
+			if !tt.reveal && (secretKey != "" || url != "" || got["credentials"] != nil) {
+				t.Fatalf("secrets returned to other member: %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// limitWindow 速率限制的统计窗口
+const limitWindow = time.Minute
+
+// limitBusyDelay 并发名额占满时任务延后执行的时间
+const limitBusyDelay = 5 * time.Second
+
+// Limit 一个限流维度（例如某个凭证或某个云平台）的并发上限及每分钟开始执行的任务数上限，0 表示不限制
+type Limit struct {
+	Key         string
+	Concurrency int
+	Rate        int
+}
+
+// Limiter 任务执行限流器，防止同一凭证或云平台同时执行过多任务而触发限流或告警
+type Limiter interface {
+	// Acquire 为任务占用各维度的执行名额，任一维度达到上限时不占用任何名额，并返回建议的延后时间
+	Acquire(ctx context.Context, taskID uint, limits []Limit) (time.Duration, error)
+	// Extend 延长任务持有的并发名额的租约，云平台调用超过租约时间仍未返回时由心跳调用
+	Extend(ctx context.Context, taskID uint, limits []Limit) error
+	// Release 释放任务占用的并发名额
+	Release(ctx context.Context, taskID uint, limits []Limit) error
+}
+
+// NewLimiter 创建限流器，Redis 可用时在多个实例间共享名额，否则仅在进程内限流
+// lease 为并发名额的最长占用时间，持有名额的进程崩溃后名额在租约到期时自动释放
+func NewLimiter(redisClient *redis.Client, lease time.Duration) Limiter {
+	if redisClient != nil {
+		return NewRedisLimiter(redisClient, lease)
+	}
+	return NewMemoryLimiter(lease)
+}
+
+// RedisLimiter 基于 Redis 有序集合的限流器
+// 每个维度使用两个有序集合：执行中的任务（分数为租约到期时间）和最近的开始时间（分数为开始时间）
+type RedisLimiter struct {
+	client *redis.Client
+	lease  time.Duration
+}
+
+// NewRedisLimiter 创建 Redis 限流器
+func NewRedisLimiter(client *redis.Client, lease time.Duration) *RedisLimiter {
+	return &RedisLimiter{client: client, lease: lease}
+}
+
+// limitKeys 返回限流维度在 Redis 中使用的键名
+func limitKeys(key string) (running, starts string) {
+	return "task_limit:" + key + ":running", "task_limit:" + key + ":starts"
+}
+
+// acquireScript 检查所有维度的名额，全部满足时才占用，保证多实例下的原子性
+// KEYS: 每个维度两个键（执行中集合、开始时间集合）
+// ARGV: 当前时间（毫秒）, 租约时长（毫秒）, 任务 ID, 并发名额占满时的延后时间（毫秒）, 统计窗口（毫秒），之后每个维度两个参数（并发上限、每分钟上限）
+var acquireScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local lease = tonumber(ARGV[2])
+local member = ARGV[3]
+local busy = tonumber(ARGV[4])
+local window = tonumber(ARGV[5])
+local wait = 0
+for i = 1, #KEYS / 2 do
+	local running, starts = KEYS[2 * i - 1], KEYS[2 * i]
+	local concurrency, rate = tonumber(ARGV[4 + 2 * i]), tonumber(ARGV[5 + 2 * i])
+	redis.call('ZREMRANGEBYSCORE', running, '-inf', now)
+	redis.call('ZREMRANGEBYSCORE', starts, '-inf', now - window)
+	if concurrency > 0 and not redis.call('ZSCORE', running, member) and redis.call('ZCARD', running) >= concurrency then
+		wait = math.max(wait, busy)
+	end
+	if rate > 0 and redis.call('ZCARD', starts) >= rate then
+		local oldest = redis.call('ZRANGE', starts, 0, 0, 'WITHSCORES')
+		wait = math.max(wait, tonumber(oldest[2]) + window - now)
+	end
+end
+if wait > 0 then
+	return wait
+end
+for i = 1, #KEYS / 2 do
+	local running, starts = KEYS[2 * i - 1], KEYS[2 * i]
+	local concurrency, rate = tonumber(ARGV[4 + 2 * i]), tonumber(ARGV[5 + 2 * i])
+	if concurrency > 0 then
+		redis.call('ZADD', running, now + lease, member)
+		redis.call('PEXPIRE', running, lease)
+	end
+	if rate > 0 then
+		redis.call('ZADD', starts, now, member .. ':' .. now)
+		redis.call('PEXPIRE', starts, window)
+	end
+end
+return 0
+`)
+
+// Acquire 为任务占用各维度的执行名额
+func (l *RedisLimiter) Acquire(ctx context.Context, taskID uint, limits []Limit) (time.Duration, error) {
+	if len(limits) == 0 {
+		return 0, nil
+	}
+
+	keys := make([]string, 0, len(limits)*2)
+	args := []interface{}{
+		time.Now().UnixMilli(),
+		l.lease.Milliseconds(),
+		strconv.FormatUint(uint64(taskID), 10),
+		limitBusyDelay.Milliseconds(),
+		limitWindow.Milliseconds(),
+	}
+	for _, limit := range limits {
+		running, starts := limitKeys(limit.Key)
+		keys = append(keys, running, starts)
+		args = append(args, limit.Concurrency, limit.Rate)
+	}
+
+	wait, err := acquireScript.Run(ctx, l.client, keys, args...).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to acquire limits for task %d: %w", taskID, err)
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// extendScript 仅为租约未到期的名额续期，到期但尚未清理的成员不会被重新占用
+// KEYS: 各维度的执行中集合
+// ARGV: 当前时间（毫秒）, 租约时长（毫秒）, 任务 ID
+var extendScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local lease = tonumber(ARGV[2])
+local member = ARGV[3]
+for _, running in ipairs(KEYS) do
+	local expiry = redis.call('ZSCORE', running, member)
+	if expiry and tonumber(expiry) > now then
+		redis.call('ZADD', running, now + lease, member)
+		redis.call('PEXPIRE', running, lease)
+	end
+end
+return 0
+`)
+
+// Extend 延长任务持有的并发名额的租约，已释放或已过期的名额不会重新占用
+func (l *RedisLimiter) Extend(ctx context.Context, taskID uint, limits []Limit) error {
+	var keys []string
+	for _, limit := range limits {
+		if limit.Concurrency > 0 {
+			running, _ := limitKeys(limit.Key)
+			keys = append(keys, running)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	now := time.Now().UnixMilli()
+	if err := extendScript.Run(ctx, l.client, keys, now, l.lease.Milliseconds(), strconv.FormatUint(uint64(taskID), 10)).Err(); err != nil {
+		return fmt.Errorf("failed to extend limits for task %d: %w", taskID, err)
+	}
+	return nil
+}
+
+// Release 释放任务占用的并发名额
+func (l *RedisLimiter) Release(ctx context.Context, taskID uint, limits []Limit) error {
+	_, err := l.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, limit := range limits {
+			if limit.Concurrency > 0 {
+				running, _ := limitKeys(limit.Key)
+				pipe.ZRem(ctx, running, strconv.FormatUint(uint64(taskID), 10))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release limits for task %d: %w", taskID, err)
+	}
+	return nil
+}
+
+// MemoryLimiter 进程内限流器，用于未部署 Redis 的单机模式
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	lease   time.Duration
+	running map[string]map[uint]time.Time // 维度 -> 任务 -> 租约到期时间
+	starts  map[string][]time.Time        // 维度 -> 统计窗口内的开始时间（按时间排序）
+}
+
+// NewMemoryLimiter 创建进程内限流器
+func NewMemoryLimiter(lease time.Duration) *MemoryLimiter {
+	return &MemoryLimiter{
+		lease:   lease,
+		running: make(map[string]map[uint]time.Time),
+		starts:  make(map[string][]time.Time),
+	}
+}
+
+// Acquire 为任务占用各维度的执行名额
+func (l *MemoryLimiter) Acquire(ctx context.Context, taskID uint, limits []Limit) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	wait := time.Duration(0)
+	for _, limit := range limits {
+		running := l.running[limit.Key]
+		for id, expiry := range running {
+			if !now.Before(expiry) {
+				delete(running, id)
+			}
+		}
+		starts := l.starts[limit.Key]
+		for len(starts) > 0 && !starts[0].After(now.Add(-limitWindow)) {
+			starts = starts[1:]
+		}
+		l.starts[limit.Key] = starts
+
+		if _, held := running[taskID]; limit.Concurrency > 0 && !held && len(running) >= limit.Concurrency && wait < limitBusyDelay {
+			wait = limitBusyDelay
+		}
+		if limit.Rate > 0 && len(starts) >= limit.Rate {
+			if d := starts[0].Add(limitWindow).Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	if wait > 0 {
+		return wait, nil
+	}
+
+	for _, limit := range limits {
+		if limit.Concurrency > 0 {
+			if l.running[limit.Key] == nil {
+				l.running[limit.Key] = make(map[uint]time.Time)
+			}
+			l.running[limit.Key][taskID] = now.Add(l.lease)
+		}
+		if limit.Rate > 0 {
+			l.starts[limit.Key] = append(l.starts[limit.Key], now)
+		}
+	}
+	return 0, nil
+}
+
+// Extend 延长任务持有的并发名额的租约，已释放或已过期的名额不会重新占用
+func (l *MemoryLimiter) Extend(ctx context.Context, taskID uint, limits []Limit) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	for _, limit := range limits {
+		if expiry, held := l.running[limit.Key][taskID]; held && now.Before(expiry) {
+			l.running[limit.Key][taskID] = now.Add(l.lease)
+		}
+	}
+	return nil
+}
+
+// Release 释放任务占用的并发名额
+func (l *MemoryLimiter) Release(ctx context.Context, taskID uint, limits []Limit) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, limit := range limits {
+		delete(l.running[limit.Key], taskID)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// limiterImplementations 返回需要保持相同行为的限流器实现，Redis 实现通过 Lua 脚本占用名额
+func limiterImplementations(t *testing.T, lease time.Duration) map[string]Limiter {
+	return map[string]Limiter{
+		"memory": NewMemoryLimiter(lease),
+		"redis":  NewRedisLimiter(newTestRedis(t), lease),
+	}
+}
+
+func TestLimiterAcquire(t *testing.T) {
+	credential := Limit{Key: "credential:1", Concurrency: 2}
+	provider := Limit{Key: "provider:aws", Rate: 2}
+
+	type step struct {
+		release uint // 大于 0 时先释放该任务的名额
+		taskID  uint
+		limits  []Limit
+		// wantWait 期望的延后时间下限，0 表示应立即占用名额
+		wantWait time.Duration
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "no limits",
+			steps: []step{
+				{taskID: 1},
+				{taskID: 2, limits: []Limit{{Key: "unlimited"}}},
+			},
+		},
+		{
+			name: "concurrency",
+			steps: []step{
+				{taskID: 1, limits: []Limit{credential}},
+				{taskID: 2, limits: []Limit{credential}},
+				{taskID: 3, limits: []Limit{credential}, wantWait: limitBusyDelay},
+				// 已持有名额的任务再次占用不受并发上限影响
+				{taskID: 1, limits: []Limit{credential}},
+				{release: 2, taskID: 3, limits: []Limit{credential}},
+			},
+		},
+		{
+			name: "rate",
+			steps: []step{
+				{taskID: 1, limits: []Limit{provider}},
+				{release: 1, taskID: 2, limits: []Limit{provider}},
+				// 释放并发名额不影响统计窗口内的开始次数
+				{release: 2, taskID: 3, limits: []Limit{provider}, wantWait: limitWindow - time.Second},
+			},
+		},
+		{
+			name: "all or nothing",
+			steps: []step{
+				{taskID: 1, limits: []Limit{provider}},
+				{taskID: 2, limits: []Limit{provider}},
+				{taskID: 3, limits: []Limit{credential, provider}, wantWait: limitWindow - time.Second},
+				// 任一维度不满足时其他维度也不占用名额
+				{taskID: 4, limits: []Limit{credential}},
+				{taskID: 5, limits: []Limit{credential}},
+				{taskID: 6, limits: []Limit{credential}, wantWait: limitBusyDelay},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		for name, l := range limiterImplementations(t, time.Minute) {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				for i, s := range tt.steps {
+					if s.release > 0 {
+						if err := l.Release(ctx, s.release, []Limit{credential, provider}); err != nil {
+							t.Fatalf("step %d: Release: %v", i, err)
+						}
+					}
+					wait, err := l.Acquire(ctx, s.taskID, s.limits)
+					if err != nil {
+						t.Fatalf("step %d: Acquire: %v", i, err)
+					}
+					if s.wantWait == 0 && wait != 0 {
+						t.Fatalf("step %d: task %d waits %s, want acquired", i, s.taskID, wait)
+					}
+					if s.wantWait > 0 && (wait < s.wantWait || wait > limitWindow) {
+						t.Fatalf("step %d: task %d waits %s, want at least %s", i, s.taskID, wait, s.wantWait)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestLimiterLease(t *testing.T) {
+	ctx := context.Background()
+	limits := []Limit{{Key: "credential:1", Concurrency: 1}}
+
+	for name, l := range limiterImplementations(t, 300*time.Millisecond) {
+		t.Run(name, func(t *testing.T) {
+			if wait, err := l.Acquire(ctx, 1, limits); err != nil || wait != 0 {
+				t.Fatalf("Acquire = %s, %v", wait, err)
+			}
+
+			// 续期后超过原租约时间仍持有名额
+			time.Sleep(200 * time.Millisecond)
+			if err := l.Extend(ctx, 1, limits); err != nil {
+				t.Fatalf("Extend: %v", err)
+			}
+			time.Sleep(200 * time.Millisecond)
+			if wait, _ := l.Acquire(ctx, 2, limits); wait == 0 {
+				t.Fatal("extended lease expired")
+			}
+
+			// 租约到期后名额自动释放，过期任务续期不会重新占用
+			time.Sleep(350 * time.Millisecond)
+			if err := l.Extend(ctx, 1, limits); err != nil {
+				t.Fatalf("Extend: %v", err)
+			}
+			if wait, err := l.Acquire(ctx, 2, limits); err != nil || wait != 0 {
+				t.Fatalf("Acquire after lease expired = %s, %v", wait, err)
+			}
+		})
+	}
+}
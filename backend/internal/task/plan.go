@@ -0,0 +1,165 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redteamsec/backend/internal/cloud/plan"
+	"github.com/redteamsec/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// errPlanChanged 执行前重新生成的计划与已批准的计划不一致
+var errPlanChanged = errors.New("cloud mutations differ from the approved plan, run a new dry run and approve it again")
+
+// PlanApprovalValidity 执行计划批准后的有效期，过期后需要重新生成并批准
+const PlanApprovalValidity = 24 * time.Hour
+
+// ErrNotPlan 任务不是已完成的 dry_run 资源操作
+var ErrNotPlan = errors.New("task is not a completed dry run")
+
+// ErrPlanSelfApproval 生成计划的用户不能批准自己的计划
+var ErrPlanSelfApproval = errors.New("a plan must be approved by someone other than the user who generated it")
+
+// ErrPlanAlreadyApproved 计划已被批准过
+var ErrPlanAlreadyApproved = errors.New("plan has already been approved")
+
+// planParams 返回执行计划任务的操作参数，任务必须是已完成的 dry_run 资源操作
+func planParams(planTask *database.Task) (map[string]interface{}, error) {
+	if planTask.TaskType != "operate" || planTask.Status != "completed" {
+		return nil, ErrNotPlan
+	}
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(planTask.Parameters), &params); err != nil || !plan.IsDryRun(params) {
+		return nil, ErrNotPlan
+	}
+	return params, nil
+}
+
+// ApprovePlan 记录对执行计划的批准，批准人不能是生成计划的用户
+func ApprovePlan(db *gorm.DB, planTask *database.Task, approverID uint) (*database.PlanApproval, error) {
+	if _, err := planParams(planTask); err != nil {
+		return nil, err
+	}
+	if planTask.UserID == approverID {
+		return nil, ErrPlanSelfApproval
+	}
+
+	var count int64
+	if err := db.Model(&database.PlanApproval{}).Where("plan_task_id = ?", planTask.ID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to load plan approval: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrPlanAlreadyApproved
+	}
+
+	now := time.Now()
+	approval := database.PlanApproval{
+		PlanTaskID: planTask.ID,
+		ApprovedBy: approverID,
+		ApprovedAt: now.Format(time.RFC3339),
+		ExpiresAt:  now.Add(PlanApprovalValidity).Format(time.RFC3339),
+	}
+	// plan_task_id 唯一，同时批准时只有一个成功
+	if err := db.Create(&approval).Error; err != nil {
+		if db.Model(&database.PlanApproval{}).Where("plan_task_id = ?", planTask.ID).Count(&count); count > 0 {
+			return nil, ErrPlanAlreadyApproved
+		}
+		return nil, fmt.Errorf("failed to save plan approval: %w", err)
+	}
+	return &approval, nil
+}
+
+// consumeApproval 使用执行计划的批准，批准只能被一个任务使用，同一任务被重新投递时可以继续使用
+func consumeApproval(db *gorm.DB, planTaskID, taskID uint) error {
+	var approval database.PlanApproval
+	if err := db.Where("plan_task_id = ?", planTaskID).First(&approval).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("plan %d has not been approved", planTaskID)
+		}
+		return fmt.Errorf("failed to load plan approval: %w", err)
+	}
+	if approval.UsedBy == taskID {
+		return nil
+	}
+	if approval.UsedBy != 0 {
+		return fmt.Errorf("approval of plan %d has already been used by task %d", planTaskID, approval.UsedBy)
+	}
+	if expiresAt, err := time.Parse(time.RFC3339, approval.ExpiresAt); err != nil || time.Now().After(expiresAt) {
+		return fmt.Errorf("approval of plan %d expired at %s", planTaskID, approval.ExpiresAt)
+	}
+
+	result := db.Model(&database.PlanApproval{}).Where("id = ? AND used_by = ?", approval.ID, 0).Updates(map[string]interface{}{
+		"used_by": taskID,
+		"used_at": time.Now().Format(time.RFC3339),
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to use plan approval: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("approval of plan %d has already been used", planTaskID)
+	}
+	return nil
+}
+
+// loadApprovedPlan 加载资源操作引用的已批准执行计划并使用其批准
+// 计划必须来自同一项目、同一凭证下已完成的 dry_run 资源操作，操作参数一致，且已由其他用户批准、未过期、未被其他任务使用
+func loadApprovedPlan(db *gorm.DB, task *database.Task, params map[string]interface{}, planTaskID uint) (*plan.Plan, error) {
+	var planTask database.Task
+	if err := db.First(&planTask, planTaskID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("approved plan %d not found", planTaskID)
+		}
+		return nil, fmt.Errorf("failed to load approved plan: %w", err)
+	}
+	if planTask.TaskType != "operate" || planTask.ProjectID != task.ProjectID || planTask.CredentialID != task.CredentialID {
+		return nil, fmt.Errorf("task %d is not a plan for this credential", planTaskID)
+	}
+	if planTask.Status != "completed" {
+		return nil, fmt.Errorf("approved plan %d has not completed (status: %s)", planTaskID, planTask.Status)
+	}
+
+	operation, err := planParams(&planTask)
+	if err != nil {
+		return nil, fmt.Errorf("task %d is not a dry run", planTaskID)
+	}
+	if !plan.SameOperation(operation, params) {
+		return nil, fmt.Errorf("approved plan %d was made for different operation parameters", planTaskID)
+	}
+
+	var taskResult database.TaskResult
+	if err := db.Where("task_id = ?", planTaskID).Order("id desc").First(&taskResult).Error; err != nil {
+		return nil, fmt.Errorf("approved plan %d has no result", planTaskID)
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(taskResult.Result), &result); err != nil {
+		return nil, fmt.Errorf("invalid approved plan result: %w", err)
+	}
+	approved, err := plan.FromResult(result)
+	if err != nil {
+		return nil, err
+	}
+	if err := consumeApproval(db, planTaskID, task.ID); err != nil {
+		return nil, err
+	}
+	return approved, nil
+}
+
+// operateWithPlan 先重新生成执行计划，与已批准的计划一致时才真正执行
+// 批准后目标环境发生变化（例如角色已被创建或删除）时拒绝执行，避免做出未经批准的修改
+func operateWithPlan(provider *countingProvider, approved *plan.Plan, resourceType, action, resourceID string, params map[string]interface{}) (map[string]interface{}, error) {
+	current, err := provider.OperateResource(resourceType, action, resourceID, plan.DryRunParams(params))
+	if err != nil {
+		return nil, err
+	}
+	currentPlan, err := plan.FromResult(current)
+	if err != nil {
+		return nil, err
+	}
+	if !plan.Same(approved.Mutations, currentPlan.Mutations) {
+		return map[string]interface{}{"approved_plan": approved, "current_plan": currentPlan}, classify(ErrorClassPlanChanged, errPlanChanged)
+	}
+	return provider.OperateResource(resourceType, action, resourceID, params)
+}
@@ -0,0 +1,120 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redteamsec/backend/internal/cloud/plan"
+	"github.com/redteamsec/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// planOperation 测试使用的资源操作参数
+var planOperation = map[string]interface{}{"resource_type": "iam", "action": "attach_admin_policy", "resource_id": "app-role"}
+
+// createPlanTask 创建用户 1 生成的已完成 dry_run 任务及其执行计划
+func createPlanTask(t *testing.T, db *gorm.DB) *database.Task {
+	t.Helper()
+	parameters, _ := json.Marshal(plan.DryRunParams(planOperation))
+	planTask := database.Task{UserID: 1, ProjectID: 1, CredentialID: 1, TaskType: "operate", Status: "completed", Parameters: string(parameters)}
+	if err := db.Create(&planTask).Error; err != nil {
+		t.Fatalf("create plan task: %v", err)
+	}
+
+	p := &plan.Plan{ResourceType: "iam", Action: "attach_admin_policy", ResourceID: "app-role"}
+	p.Add(plan.Mutation{Service: "iam", API: "AttachRolePolicy", Resource: "app-role"})
+	result, _ := json.Marshal(p.Result())
+	db.Create(&database.TaskResult{TaskID: planTask.ID, Result: string(result)})
+	return &planTask
+}
+
+func TestApprovePlan(t *testing.T) {
+	db := database.NewTestDB(t, &database.Task{}, &database.TaskResult{}, &database.PlanApproval{})
+	planTask := createPlanTask(t, db)
+
+	pending := *planTask
+	pending.Status = "running"
+	executed := *planTask
+	executed.Parameters = `{"resource_type":"iam","action":"attach_admin_policy","resource_id":"app-role"}`
+
+	tests := []struct {
+		name     string
+		task     *database.Task
+		approver uint
+		wantErr  error
+	}{
+		{name: "not completed", task: &pending, approver: 2, wantErr: ErrNotPlan},
+		{name: "not a dry run", task: &executed, approver: 2, wantErr: ErrNotPlan},
+		{name: "self approval", task: planTask, approver: 1, wantErr: ErrPlanSelfApproval},
+		{name: "other user", task: planTask, approver: 2},
+		{name: "approved twice", task: planTask, approver: 3, wantErr: ErrPlanAlreadyApproved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approval, err := ApprovePlan(db, tt.task, tt.approver)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApprovePlan() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (approval.ApprovedBy != tt.approver || approval.ApprovedAt == "" || approval.UsedBy != 0) {
+				t.Fatalf("ApprovePlan() = %+v", approval)
+			}
+		})
+	}
+}
+
+func TestLoadApprovedPlanConsumesApproval(t *testing.T) {
+	db := database.NewTestDB(t, &database.Task{}, &database.TaskResult{}, &database.PlanApproval{})
+	approved := createPlanTask(t, db)
+	if _, err := ApprovePlan(db, approved, 2); err != nil {
+		t.Fatalf("ApprovePlan: %v", err)
+	}
+	unapproved := createPlanTask(t, db)
+	expired := createPlanTask(t, db)
+	db.Create(&database.PlanApproval{PlanTaskID: expired.ID, ApprovedBy: 2, ExpiresAt: time.Now().Add(-time.Minute).Format(time.RFC3339)})
+
+	operation := func(extra string) map[string]interface{} {
+		params := map[string]interface{}{}
+		for k, v := range planOperation {
+			params[k] = v
+		}
+		if extra != "" {
+			params["resource_id"] = extra
+		}
+		return params
+	}
+
+	// 按顺序执行，批准在第一次使用后被消耗
+	tests := []struct {
+		name    string
+		taskID  uint
+		planID  uint
+		params  map[string]interface{}
+		wantErr string
+	}{
+		{name: "not approved", taskID: 10, planID: unapproved.ID, params: operation(""), wantErr: "has not been approved"},
+		{name: "expired", taskID: 10, planID: expired.ID, params: operation(""), wantErr: "expired"},
+		{name: "different operation", taskID: 10, planID: approved.ID, params: operation("other-role"), wantErr: "different operation"},
+		{name: "first use", taskID: 10, planID: approved.ID, params: operation("")},
+		// 同一任务被重新投递时仍可使用
+		{name: "redelivered", taskID: 10, planID: approved.ID, params: operation("")},
+		{name: "replayed by another task", taskID: 11, planID: approved.ID, params: operation(""), wantErr: "already been used by task 10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &database.Task{ID: tt.taskID, ProjectID: 1, CredentialID: 1, TaskType: "operate"}
+			p, err := loadApprovedPlan(db, task, tt.params, tt.planID)
+			if tt.wantErr == "" {
+				if err != nil || len(p.Mutations) != 1 {
+					t.Fatalf("loadApprovedPlan() = %+v, %v", p, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("loadApprovedPlan() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/redteamsec/backend/internal/cloud"
+	"github.com/redteamsec/backend/internal/cloud/scope"
+	"github.com/redteamsec/backend/internal/database"
+	"github.com/redteamsec/backend/internal/secrets"
+	"gorm.io/gorm"
+)
+
+// NewProvider 解密凭证密钥并创建云平台实例，明文密钥只在此处出现，不会写回凭证
+// region 为空时使用凭证的默认区域
+func NewProvider(keyring *secrets.Keyring, credential *database.CloudCredential, region string) (cloud.CloudProvider, error) {
+	secretKey, err := keyring.Decrypt(credential.SecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credential secret: %w", err)
+	}
+	if region == "" {
+		region = credential.Region
+	}
+	return cloud.NewCloudProvider(credential.CloudProvider, credential.AccessKey, secretKey, region, credential.Endpoint)
+}
+
+// projectScope 加载项目的授权范围，项目不存在或未定义范围时返回 nil
+func projectScope(db *gorm.DB, projectID uint) (*scope.Scope, error) {
+	var project database.Project
+	err := db.Select("id", "scope").Where("id = ?", projectID).First(&project).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load project scope: %w", err)
+	}
+	return scope.Parse(project.Scope)
+}
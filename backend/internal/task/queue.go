@@ -0,0 +1,472 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/redteamsec/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// Redis 中任务队列使用的键名
+const (
+	queueKey      = "task_queue"            // 普通优先级的待处理任务
+	highQueueKey  = "task_queue:high"       // 高优先级的待处理任务
+	lowQueueKey   = "task_queue:low"        // 低优先级的待处理任务
+	delayedKey    = "task_queue:delayed"    // 延后执行的任务及其可执行时间（毫秒）
+	prioritiesKey = "task_queue:priorities" // 任务 ID 到优先级的映射
+	processingKey = "task_queue:processing" // 已取出、尚未确认的任务
+	deadlinesKey  = "task_queue:deadlines"  // 已取出任务的可见性截止时间
+	deadLetterKey = "task_queue:dead"       // 死信任务
+)
+
+// 任务优先级，高优先级队列中的任务总是先于低优先级的任务取出
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// priorities 按取出顺序排列的优先级
+var priorities = []string{PriorityHigh, PriorityNormal, PriorityLow}
+
+// ValidPriority 判断优先级是否有效
+func ValidPriority(priority string) bool {
+	for _, p := range priorities {
+		if p == priority {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePriority 未指定或无效的优先级按普通优先级处理
+func normalizePriority(priority string) string {
+	if ValidPriority(priority) {
+		return priority
+	}
+	return PriorityNormal
+}
+
+// queuePollInterval Redis 队列为空时轮询的间隔
+// 多个优先级列表无法通过一次阻塞命令原子地转入处理中列表，因此改为轮询
+const queuePollInterval = 500 * time.Millisecond
+
+// ErrQueueEmpty 在等待时间内队列中没有任务
+var ErrQueueEmpty = errors.New("task queue is empty")
+
+// Queue 任务队列，保存待处理的任务 ID，提供至少一次投递语义
+// Dequeue 取出的任务进入处理中状态，必须通过 Ack、Requeue、Defer 或 DeadLetter 结束；
+// 超过可见性截止时间仍未续期的任务由 reaper 重新入队
+type Queue interface {
+	// Enqueue 将任务追加到对应优先级队列的尾部
+	Enqueue(ctx context.Context, taskID uint, priority string) error
+	// Dequeue 按优先级从队列头部取出任务并标记为处理中，timeout 内没有任务时返回 ErrQueueEmpty
+	Dequeue(ctx context.Context, timeout time.Duration) (uint, error)
+	// Extend 延长处理中任务的可见性截止时间
+	Extend(ctx context.Context, taskID uint) error
+	// Ack 确认任务处理结束，从处理中移除
+	Ack(ctx context.Context, taskID uint) error
+	// Requeue 将任务从处理中移除并放回所属优先级队列的头部，优先于新任务处理
+	Requeue(ctx context.Context, taskID uint) error
+	// Defer 将任务从处理中移除，delay 之后重新放入所属优先级队列的尾部
+	Defer(ctx context.Context, taskID uint, delay time.Duration) error
+	// DeadLetter 将任务从处理中移除并放入死信队列
+	DeadLetter(ctx context.Context, taskID uint) error
+	// Processing 返回所有处理中的任务及其可见性截止时间
+	Processing(ctx context.Context) (map[uint]time.Time, error)
+}
+
+// NewQueue 创建任务队列，Redis 可用时使用 Redis 队列，否则使用进程内队列
+func NewQueue(redisClient *redis.Client, visibility time.Duration) Queue {
+	if redisClient != nil {
+		return NewRedisQueue(redisClient, visibility)
+	}
+	return NewMemoryQueue(visibility)
+}
+
+// RedisQueue 基于 Redis 列表的任务队列，支持多实例共享
+// 每个优先级一个列表，取出的任务通过 Lua 脚本原子地转入处理中列表，截止时间保存在有序集合中
+type RedisQueue struct {
+	client     *redis.Client
+	visibility time.Duration
+}
+
+// NewRedisQueue 创建 Redis 任务队列
+func NewRedisQueue(client *redis.Client, visibility time.Duration) *RedisQueue {
+	return &RedisQueue{client: client, visibility: visibility}
+}
+
+// priorityQueueKey 返回优先级对应的列表键名
+func priorityQueueKey(priority string) string {
+	switch priority {
+	case PriorityHigh:
+		return highQueueKey
+	case PriorityLow:
+		return lowQueueKey
+	}
+	return queueKey
+}
+
+// dequeueScript 先将到期的延后任务放回所属优先级列表，再按优先级取出一个任务转入处理中列表
+// KEYS: delayed, priorities, high, normal, low, processing, deadlines
+// ARGV: 当前时间（毫秒）, 可见性截止时间（秒）
+var dequeueScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+for _, member in ipairs(due) do
+	redis.call('ZREM', KEYS[1], member)
+	local priority = redis.call('HGET', KEYS[2], member)
+	local key = KEYS[4]
+	if priority == 'high' then
+		key = KEYS[3]
+	elseif priority == 'low' then
+		key = KEYS[5]
+	end
+	redis.call('RPUSH', key, member)
+end
+for i = 3, 5 do
+	local member = redis.call('LMOVE', KEYS[i], KEYS[6], 'LEFT', 'RIGHT')
+	if member then
+		redis.call('ZADD', KEYS[7], ARGV[2], member)
+		return member
+	end
+end
+return false
+`)
+
+// Enqueue 将任务追加到对应优先级队列的尾部
+func (q *RedisQueue) Enqueue(ctx context.Context, taskID uint, priority string) error {
+	priority = normalizePriority(priority)
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, prioritiesKey, taskID, priority)
+		pipe.RPush(ctx, priorityQueueKey(priority), taskID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue task %d: %w", taskID, err)
+	}
+	return nil
+}
+
+// Dequeue 按优先级取出任务并转入处理中列表
+func (q *RedisQueue) Dequeue(ctx context.Context, timeout time.Duration) (uint, error) {
+	keys := []string{delayedKey, prioritiesKey, highQueueKey, queueKey, lowQueueKey, processingKey, deadlinesKey}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		now := time.Now()
+		member, err := dequeueScript.Run(ctx, q.client, keys, now.UnixMilli(), now.Add(q.visibility).Unix()).Text()
+		if err == nil {
+			taskID, err := strconv.ParseUint(member, 10, 64)
+			if err != nil {
+				// 无法解析的条目直接移入死信队列，避免被反复投递
+				q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+					pipe.LRem(ctx, processingKey, 1, member)
+					pipe.ZRem(ctx, deadlinesKey, member)
+					pipe.RPush(ctx, deadLetterKey, member)
+					return nil
+				})
+				return 0, fmt.Errorf("failed to parse task ID %q: %w", member, err)
+			}
+			return uint(taskID), nil
+		}
+		if err != redis.Nil {
+			return 0, fmt.Errorf("failed to dequeue task: %w", err)
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return 0, ErrQueueEmpty
+		}
+		if wait > queuePollInterval {
+			wait = queuePollInterval
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Extend 延长处理中任务的可见性截止时间
+func (q *RedisQueue) Extend(ctx context.Context, taskID uint) error {
+	deadline := float64(time.Now().Add(q.visibility).Unix())
+	if err := q.client.ZAddXX(ctx, deadlinesKey, redis.Z{Score: deadline, Member: taskID}).Err(); err != nil {
+		return fmt.Errorf("failed to extend task %d: %w", taskID, err)
+	}
+	return nil
+}
+
+// Ack 确认任务处理结束
+func (q *RedisQueue) Ack(ctx context.Context, taskID uint) error {
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, processingKey, 0, taskID)
+		pipe.ZRem(ctx, deadlinesKey, taskID)
+		pipe.HDel(ctx, prioritiesKey, strconv.FormatUint(uint64(taskID), 10))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to ack task %d: %w", taskID, err)
+	}
+	return nil
+}
+
+// Requeue 将任务放回所属优先级队列的头部
+func (q *RedisQueue) Requeue(ctx context.Context, taskID uint) error {
+	priority, err := q.client.HGet(ctx, prioritiesKey, strconv.FormatUint(uint64(taskID), 10)).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to get priority of task %d: %w", taskID, err)
+	}
+
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, processingKey, 0, taskID)
+		pipe.ZRem(ctx, deadlinesKey, taskID)
+		pipe.LPush(ctx, priorityQueueKey(priority), taskID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to requeue task %d: %w", taskID, err)
+	}
+	return nil
+}
+
+// Defer 将任务移入延后集合，到期后由 Dequeue 放回所属优先级队列
+func (q *RedisQueue) Defer(ctx context.Context, taskID uint, delay time.Duration) error {
+	readyAt := float64(time.Now().Add(delay).UnixMilli())
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, processingKey, 0, taskID)
+		pipe.ZRem(ctx, deadlinesKey, taskID)
+		pipe.ZAdd(ctx, delayedKey, redis.Z{Score: readyAt, Member: taskID})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to defer task %d: %w", taskID, err)
+	}
+	return nil
+}
+
+// DeadLetter 将任务放入死信队列
+func (q *RedisQueue) DeadLetter(ctx context.Context, taskID uint) error {
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, processingKey, 0, taskID)
+		pipe.ZRem(ctx, deadlinesKey, taskID)
+		pipe.HDel(ctx, prioritiesKey, strconv.FormatUint(uint64(taskID), 10))
+		pipe.RPush(ctx, deadLetterKey, taskID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to dead-letter task %d: %w", taskID, err)
+	}
+	return nil
+}
+
+// Processing 返回所有处理中的任务及其可见性截止时间
+func (q *RedisQueue) Processing(ctx context.Context) (map[uint]time.Time, error) {
+	members, err := q.client.LRange(ctx, processingKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processing tasks: %w", err)
+	}
+
+	processing := make(map[uint]time.Time)
+	for _, member := range members {
+		taskID, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		score, err := q.client.ZScore(ctx, deadlinesKey, member).Result()
+		if err == redis.Nil {
+			// 取出后未能写入截止时间，从现在开始计算
+			deadline := time.Now().Add(q.visibility)
+			q.client.ZAddNX(ctx, deadlinesKey, redis.Z{Score: float64(deadline.Unix()), Member: member})
+			processing[uint(taskID)] = deadline
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get deadline of task %d: %w", taskID, err)
+		}
+		processing[uint(taskID)] = time.Unix(int64(score), 0)
+	}
+	return processing, nil
+}
+
+// MemoryQueue 进程内任务队列，用于未部署 Redis 的单机模式
+// 队列内容不会持久化，重启后通过 EnqueuePending 和 reaper 从数据库恢复
+type MemoryQueue struct {
+	mu         sync.Mutex
+	visibility time.Duration
+	items      map[string][]uint
+	priorities map[uint]string
+	delayed    map[uint]time.Time
+	processing map[uint]time.Time
+	dead       []uint
+	// ready 有新任务入队时关闭并替换，唤醒所有等待中的 Dequeue
+	ready chan struct{}
+}
+
+// NewMemoryQueue 创建进程内任务队列
+func NewMemoryQueue(visibility time.Duration) *MemoryQueue {
+	return &MemoryQueue{
+		visibility: visibility,
+		items:      make(map[string][]uint),
+		priorities: make(map[uint]string),
+		delayed:    make(map[uint]time.Time),
+		processing: make(map[uint]time.Time),
+		ready:      make(chan struct{}),
+	}
+}
+
+// Enqueue 将任务追加到对应优先级队列的尾部
+func (q *MemoryQueue) Enqueue(ctx context.Context, taskID uint, priority string) error {
+	priority = normalizePriority(priority)
+	q.mu.Lock()
+	q.priorities[taskID] = priority
+	q.items[priority] = append(q.items[priority], taskID)
+	q.notify()
+	q.mu.Unlock()
+	return nil
+}
+
+// notify 唤醒等待中的 Dequeue，调用时需持有锁
+func (q *MemoryQueue) notify() {
+	close(q.ready)
+	q.ready = make(chan struct{})
+}
+
+// promote 将到期的延后任务放回所属优先级队列，返回最近一个未到期任务的等待时间，调用时需持有锁
+func (q *MemoryQueue) promote(now time.Time) time.Duration {
+	next := time.Duration(0)
+	for taskID, readyAt := range q.delayed {
+		if !now.Before(readyAt) {
+			delete(q.delayed, taskID)
+			priority := normalizePriority(q.priorities[taskID])
+			q.items[priority] = append(q.items[priority], taskID)
+			continue
+		}
+		if wait := readyAt.Sub(now); next == 0 || wait < next {
+			next = wait
+		}
+	}
+	return next
+}
+
+// Dequeue 按优先级从队列头部取出任务并标记为处理中
+func (q *MemoryQueue) Dequeue(ctx context.Context, timeout time.Duration) (uint, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		q.mu.Lock()
+		now := time.Now()
+		next := q.promote(now)
+		for _, priority := range priorities {
+			if items := q.items[priority]; len(items) > 0 {
+				taskID := items[0]
+				q.items[priority] = items[1:]
+				q.processing[taskID] = now.Add(q.visibility)
+				q.mu.Unlock()
+				return taskID, nil
+			}
+		}
+		ready := q.ready
+		q.mu.Unlock()
+
+		// 有延后任务时在其到期时重新检查
+		var due <-chan time.Time
+		if next > 0 {
+			due = time.After(next)
+		}
+		select {
+		case <-ready:
+		case <-due:
+		case <-timer.C:
+			return 0, ErrQueueEmpty
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// Extend 延长处理中任务的可见性截止时间
+func (q *MemoryQueue) Extend(ctx context.Context, taskID uint) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.processing[taskID]; ok {
+		q.processing[taskID] = time.Now().Add(q.visibility)
+	}
+	return nil
+}
+
+// Ack 确认任务处理结束
+func (q *MemoryQueue) Ack(ctx context.Context, taskID uint) error {
+	q.mu.Lock()
+	delete(q.processing, taskID)
+	delete(q.priorities, taskID)
+	q.mu.Unlock()
+	return nil
+}
+
+// Requeue 将任务放回所属优先级队列的头部
+func (q *MemoryQueue) Requeue(ctx context.Context, taskID uint) error {
+	q.mu.Lock()
+	delete(q.processing, taskID)
+	priority := normalizePriority(q.priorities[taskID])
+	q.items[priority] = append([]uint{taskID}, q.items[priority]...)
+	q.notify()
+	q.mu.Unlock()
+	return nil
+}
+
+// Defer 将任务移入延后集合，到期后由 Dequeue 放回所属优先级队列
+func (q *MemoryQueue) Defer(ctx context.Context, taskID uint, delay time.Duration) error {
+	q.mu.Lock()
+	delete(q.processing, taskID)
+	q.delayed[taskID] = time.Now().Add(delay)
+	q.notify()
+	q.mu.Unlock()
+	return nil
+}
+
+// DeadLetter 将任务放入死信队列
+func (q *MemoryQueue) DeadLetter(ctx context.Context, taskID uint) error {
+	q.mu.Lock()
+	delete(q.processing, taskID)
+	delete(q.priorities, taskID)
+	q.dead = append(q.dead, taskID)
+	q.mu.Unlock()
+	return nil
+}
+
+// Processing 返回所有处理中的任务及其可见性截止时间
+func (q *MemoryQueue) Processing(ctx context.Context) (map[uint]time.Time, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	processing := make(map[uint]time.Time, len(q.processing))
+	for taskID, deadline := range q.processing {
+		processing[taskID] = deadline
+	}
+	return processing, nil
+}
+
+// EnqueuePending 将数据库中处于 pending 状态的任务放入队列
+// 进程内队列重启后为空，需要在启动时调用以恢复未处理的任务
+func EnqueuePending(ctx context.Context, db *gorm.DB, queue Queue) (int, error) {
+	var tasks []database.Task
+	if result := db.Where("status = ?", "pending").Order("id").Find(&tasks); result.Error != nil {
+		return 0, fmt.Errorf("failed to load pending tasks: %w", result.Error)
+	}
+
+	for _, task := range tasks {
+		if err := queue.Enqueue(ctx, task.ID, task.Priority); err != nil {
+			return 0, err
+		}
+	}
+	return len(tasks), nil
+}
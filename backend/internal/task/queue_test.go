@@ -0,0 +1,206 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis 启动进程内的 Redis 模拟服务并返回客户端
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// queueImplementations 返回需要保持相同行为的队列实现
+func queueImplementations(t *testing.T, visibility time.Duration) map[string]Queue {
+	return map[string]Queue{
+		"memory": NewMemoryQueue(visibility),
+		"redis":  NewRedisQueue(newTestRedis(t), visibility),
+	}
+}
+
+// dequeueAll 取出队列中当前的全部任务
+func dequeueAll(t *testing.T, q Queue) []uint {
+	t.Helper()
+	var ids []uint
+	for {
+		id, err := q.Dequeue(context.Background(), 10*time.Millisecond)
+		if errors.Is(err, ErrQueueEmpty) {
+			return ids
+		}
+		if err != nil {
+			t.Fatalf("Dequeue: %v", err)
+		}
+		ids = append(ids, id)
+	}
+}
+
+func TestQueueDelivery(t *testing.T) {
+	ctx := context.Background()
+	for name, q := range queueImplementations(t, time.Minute) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := q.Dequeue(ctx, 10*time.Millisecond); !errors.Is(err, ErrQueueEmpty) {
+				t.Fatalf("Dequeue on empty queue: got %v, want ErrQueueEmpty", err)
+			}
+
+			for _, id := range []uint{1, 2, 3} {
+				if err := q.Enqueue(ctx, id, PriorityNormal); err != nil {
+					t.Fatalf("Enqueue: %v", err)
+				}
+			}
+			first, err := q.Dequeue(ctx, time.Second)
+			if err != nil || first != 1 {
+				t.Fatalf("Dequeue = %d, %v, want 1", first, err)
+			}
+
+			// 取出后进入处理中，截止时间为可见性超时之后
+			processing, err := q.Processing(ctx)
+			if err != nil {
+				t.Fatalf("Processing: %v", err)
+			}
+			deadline, ok := processing[1]
+			if !ok || len(processing) != 1 {
+				t.Fatalf("Processing() = %v, want only task 1", processing)
+			}
+			if remaining := time.Until(deadline); remaining < 58*time.Second || remaining > time.Minute {
+				t.Fatalf("deadline in %s, want about 1m", remaining)
+			}
+
+			// 放回的任务位于队头，先于其他任务再次投递
+			if err := q.Requeue(ctx, 1); err != nil {
+				t.Fatalf("Requeue: %v", err)
+			}
+			if got := dequeueAll(t, q); len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+				t.Fatalf("delivery order after requeue = %v, want [1 2 3]", got)
+			}
+
+			if err := q.Ack(ctx, 1); err != nil {
+				t.Fatalf("Ack: %v", err)
+			}
+			if err := q.DeadLetter(ctx, 2); err != nil {
+				t.Fatalf("DeadLetter: %v", err)
+			}
+			processing, _ = q.Processing(ctx)
+			if _, ok := processing[3]; !ok || len(processing) != 1 {
+				t.Fatalf("Processing() = %v, want only task 3", processing)
+			}
+			if got := dequeueAll(t, q); len(got) != 0 {
+				t.Fatalf("acked or dead-lettered tasks delivered again: %v", got)
+			}
+		})
+	}
+}
+
+func TestQueueVisibilityTimeout(t *testing.T) {
+	ctx := context.Background()
+	for name, q := range queueImplementations(t, 2*time.Second) {
+		t.Run(name, func(t *testing.T) {
+			if err := q.Enqueue(ctx, 7, PriorityNormal); err != nil {
+				t.Fatalf("Enqueue: %v", err)
+			}
+			if _, err := q.Dequeue(ctx, time.Second); err != nil {
+				t.Fatalf("Dequeue: %v", err)
+			}
+			processing, _ := q.Processing(ctx)
+			before := processing[7]
+
+			// Redis 中截止时间精确到秒，等待超过一秒以确保续期后的截止时间变化
+			time.Sleep(1100 * time.Millisecond)
+			if err := q.Extend(ctx, 7); err != nil {
+				t.Fatalf("Extend: %v", err)
+			}
+			processing, _ = q.Processing(ctx)
+			if !processing[7].After(before) {
+				t.Fatalf("deadline %s not extended past %s", processing[7], before)
+			}
+
+			// 已确认的任务续期后不会重新出现在处理中
+			q.Ack(ctx, 7)
+			q.Extend(ctx, 7)
+			if processing, _ = q.Processing(ctx); len(processing) != 0 {
+				t.Fatalf("Processing() after ack = %v, want empty", processing)
+			}
+		})
+	}
+}
+
+func TestQueuePriority(t *testing.T) {
+	ctx := context.Background()
+	for name, q := range queueImplementations(t, time.Minute) {
+		t.Run(name, func(t *testing.T) {
+			enqueued := []struct {
+				id       uint
+				priority string
+			}{
+				{1, PriorityLow},
+				{2, PriorityNormal},
+				{3, PriorityHigh},
+				{4, ""}, // 未指定优先级按普通优先级处理
+				{5, PriorityHigh},
+				{6, "urgent"},
+			}
+			for _, e := range enqueued {
+				if err := q.Enqueue(ctx, e.id, e.priority); err != nil {
+					t.Fatalf("Enqueue: %v", err)
+				}
+			}
+
+			want := []uint{3, 5, 2, 4, 6, 1}
+			got := dequeueAll(t, q)
+			if len(got) != len(want) {
+				t.Fatalf("delivery order = %v, want %v", got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("delivery order = %v, want %v", got, want)
+				}
+			}
+
+			// 放回的低优先级任务仍排在高优先级任务之后
+			if err := q.Requeue(ctx, 1); err != nil {
+				t.Fatalf("Requeue: %v", err)
+			}
+			q.Enqueue(ctx, 8, PriorityHigh)
+			if got := dequeueAll(t, q); len(got) != 2 || got[0] != 8 || got[1] != 1 {
+				t.Fatalf("delivery order after requeue = %v, want [8 1]", got)
+			}
+		})
+	}
+}
+
+func TestQueueDefer(t *testing.T) {
+	ctx := context.Background()
+	for name, q := range queueImplementations(t, time.Minute) {
+		t.Run(name, func(t *testing.T) {
+			q.Enqueue(ctx, 1, PriorityHigh)
+			if _, err := q.Dequeue(ctx, time.Second); err != nil {
+				t.Fatalf("Dequeue: %v", err)
+			}
+			if err := q.Defer(ctx, 1, 300*time.Millisecond); err != nil {
+				t.Fatalf("Defer: %v", err)
+			}
+			if processing, _ := q.Processing(ctx); len(processing) != 0 {
+				t.Fatalf("deferred task still processing: %v", processing)
+			}
+
+			// 延后期间不会投递，到期后回到原优先级队列
+			q.Enqueue(ctx, 2, PriorityNormal)
+			if got := dequeueAll(t, q); len(got) != 1 || got[0] != 2 {
+				t.Fatalf("delivered before delay = %v, want [2]", got)
+			}
+			time.Sleep(350 * time.Millisecond)
+			q.Enqueue(ctx, 3, PriorityNormal)
+			if got := dequeueAll(t, q); len(got) != 2 || got[0] != 1 || got[1] != 3 {
+				t.Fatalf("delivered after delay = %v, want [1 3]", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redteamsec/backend/internal/cloud/progress"
+	"github.com/redteamsec/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// reapLoop 定期检查超过可见性截止时间的任务
+func (w *Worker) reapLoop(ctx context.Context) {
+	defer w.wg.Done()
+
+	interval := time.Duration(w.cfg.TaskVisibilityTimeout) * time.Second / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.reap(ctx)
+		}
+	}
+}
+
+// reap 恢复孤儿任务：处理中但超过可见性截止时间的任务，以及数据库中 running/cancelling 但不在处理中的任务
+func (w *Worker) reap(ctx context.Context) {
+	// 先读取数据库再读取处理中列表，刚被取出的任务此时一定已在处理中列表里
+	var running []database.Task
+	if result := w.db.Where("status IN ?", []string{"running", "cancelling"}).Find(&running); result.Error != nil {
+		fmt.Printf("Error loading running tasks: %v\n", result.Error)
+		return
+	}
+
+	processing, err := w.queue.Processing(ctx)
+	if err != nil {
+		fmt.Printf("Error loading processing tasks: %v\n", err)
+		return
+	}
+
+	now := time.Now()
+	for taskID, deadline := range processing {
+		if now.After(deadline) && !w.isInFlight(taskID) {
+			w.recoverTask(ctx, taskID)
+		}
+	}
+	for _, task := range running {
+		if _, ok := processing[task.ID]; !ok && !w.isInFlight(task.ID) {
+			w.recoverTask(ctx, task.ID)
+		}
+	}
+}
+
+// recoverTask 将孤儿任务重新入队，投递次数达到上限的任务标记为失败并转入死信队列
+func (w *Worker) recoverTask(ctx context.Context, taskID uint) {
+	var task database.Task
+	if result := w.db.First(&task, taskID); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			w.ack(taskID)
+			return
+		}
+		fmt.Printf("Error getting task: %v\n", result.Error)
+		return
+	}
+
+	// 已结束的任务只是未能确认，移除队列条目即可
+	if IsTerminal(task.Status) {
+		w.ack(taskID)
+		return
+	}
+
+	// 执行中被请求取消的任务无需再次投递，直接标记为已取消
+	if task.Status == "cancelling" {
+		w.finishTask(taskID, classify(ErrorClassCancelled, errTaskCancelled), nil)
+		w.ack(taskID)
+		return
+	}
+
+	if task.Deliveries >= w.cfg.TaskMaxDeliveries {
+		fmt.Printf("Task %d exceeded %d deliveries, moving to dead-letter queue\n", taskID, w.cfg.TaskMaxDeliveries)
+		if err := w.queue.DeadLetter(ctx, taskID); err != nil {
+			fmt.Printf("Error dead-lettering task %d: %v\n", taskID, err)
+			return
+		}
+		err := classify(ErrorClassAbandoned, fmt.Errorf("task was abandoned %d times by crashed workers", task.Deliveries))
+		w.saveTaskResult(taskID, nil, err)
+		w.finishTask(taskID, err, nil)
+		return
+	}
+
+	// 仅在状态未被其他 worker 修改时重新入队
+	result := w.db.Model(&database.Task{}).Where("id = ? AND status = ?", taskID, task.Status).Updates(map[string]interface{}{
+		"status":     "pending",
+		"start_time": "",
+	})
+	if result.Error != nil {
+		fmt.Printf("Error resetting task %d: %v\n", taskID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	if err := w.queue.Requeue(ctx, taskID); err != nil {
+		fmt.Printf("Error requeueing task %d: %v\n", taskID, err)
+		return
+	}
+	w.publish(taskID, progress.Event{Type: progress.TypeStatus, Status: "pending", Message: "orphaned task requeued"})
+	fmt.Printf("Recovered orphaned task %d (delivery %d)\n", taskID, task.Deliveries)
+}
@@ -0,0 +1,94 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redteamsec/backend/config"
+	"github.com/redteamsec/backend/internal/database"
+)
+
+func TestReapRecoversOrphanedTasks(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		deliveries int
+		dequeued   bool // 任务已被取出并超过可见性截止时间
+		inFlight   bool // 任务仍在本 worker 中执行
+		deleted    bool
+		wantStatus string
+		wantQueued bool // 任务被放回队列等待再次投递
+		wantClass  string
+	}{
+		{name: "expired", status: "running", deliveries: 1, dequeued: true, wantStatus: "pending", wantQueued: true},
+		{name: "running but not processing", status: "running", deliveries: 1, wantStatus: "pending", wantQueued: true},
+		{name: "max deliveries", status: "running", deliveries: 3, dequeued: true, wantStatus: "failed", wantClass: ErrorClassAbandoned},
+		{name: "cancelling", status: "cancelling", deliveries: 1, dequeued: true, wantStatus: "cancelled", wantClass: ErrorClassCancelled},
+		{name: "finished but not acked", status: "completed", deliveries: 1, dequeued: true, wantStatus: "completed"},
+		{name: "deleted", status: "running", dequeued: true, deleted: true},
+		{name: "in flight", status: "running", deliveries: 1, dequeued: true, inFlight: true, wantStatus: "running"},
+	}
+
+	ctx := context.Background()
+	// 可见性超时取 1 秒，Redis 中截止时间精确到秒
+	for name, q := range queueImplementations(t, time.Second) {
+		t.Run(name, func(t *testing.T) {
+			db := database.NewTestDB(t, &database.Task{}, &database.TaskResult{})
+			w := &Worker{
+				db:       db,
+				queue:    q,
+				broker:   NewMemoryBroker(),
+				cfg:      &config.Config{TaskVisibilityTimeout: 1, TaskMaxDeliveries: 3},
+				inFlight: make(map[uint]bool),
+			}
+
+			ids := make([]uint, len(tests))
+			for i, tt := range tests {
+				task := database.Task{Name: tt.name, TaskType: "list", Status: tt.status, Deliveries: tt.deliveries}
+				if err := db.Create(&task).Error; err != nil {
+					t.Fatalf("create task: %v", err)
+				}
+				ids[i] = task.ID
+				if tt.dequeued {
+					q.Enqueue(ctx, task.ID, PriorityNormal)
+					if _, err := q.Dequeue(ctx, time.Second); err != nil {
+						t.Fatalf("Dequeue: %v", err)
+					}
+				}
+				if tt.inFlight {
+					w.inFlight[task.ID] = true
+				}
+				if tt.deleted {
+					db.Delete(&task)
+				}
+			}
+
+			time.Sleep(2100 * time.Millisecond)
+			w.reap(ctx)
+
+			queued := make(map[uint]bool)
+			for _, id := range dequeueAll(t, q) {
+				queued[id] = true
+			}
+			processing, _ := q.Processing(ctx)
+			for i, tt := range tests {
+				id := ids[i]
+				if queued[id] != tt.wantQueued {
+					t.Errorf("%s: queued = %v, want %v", tt.name, queued[id], tt.wantQueued)
+				}
+				if _, ok := processing[id]; ok != (tt.inFlight || tt.wantQueued) {
+					t.Errorf("%s: processing = %v", tt.name, ok)
+				}
+				if tt.deleted {
+					continue
+				}
+				var task database.Task
+				db.First(&task, id)
+				if task.Status != tt.wantStatus || task.ErrorClass != tt.wantClass {
+					t.Errorf("%s: status = %s (%s), want %s (%s)", tt.name, task.Status, task.ErrorClass, tt.wantStatus, tt.wantClass)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/redteamsec/backend/internal/cloud/progress"
+	"github.com/redteamsec/backend/internal/cloud/scope"
+)
+
+// 重试退避参数
+const (
+	retryBaseDelay = 2 * time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// transientErrorPatterns 各云平台限流、服务端错误及网络错误的特征字符串
+var transientErrorPatterns = []string{
+	"throttl",                 // AWS Throttling/ThrottlingException、阿里云 Throttling.User
+	"requestlimitexceeded",    // AWS EC2、腾讯云 RequestLimitExceeded
+	"toomanyrequests",         // 429
+	"rate exceeded",           // AWS
+	"slowdown",                // S3 SlowDown
+	"serviceunavailable",      // 503
+	"internalerror",           // 各平台服务端内部错误
+	"internalservererror",     // 500
+	"service.busy",            // 阿里云 ServiceUnavailable/Busy
+	"connection reset",        // 网络错误
+	"connection refused",      // 网络错误
+	"i/o timeout",             // 网络超时
+	"tls handshake timeout",   // 网络超时
+	"unexpected eof",          // 连接中断
+	"statuscode: 429",         // AWS SDK 错误格式
+	"statuscode: 500",         // AWS SDK 错误格式
+	"statuscode: 502",         // AWS SDK 错误格式
+	"statuscode: 503",         // AWS SDK 错误格式
+	"statuscode: 504",         // AWS SDK 错误格式
+	"too many requests",       // 429
+	"temporarily unavailable", // 503
+}
+
+// isTransientError 判断错误是否为可重试的临时性错误（限流、服务端错误、网络抖动）
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	// 任务本身超时或被取消不重试
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	// 超出授权范围的操作重试也不会成功
+	if errors.Is(err, scope.ErrOutOfScope) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, pattern := range transientErrorPatterns {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// backoffDelay 计算第 attempt 次重试前的等待时间（指数退避加随机抖动）
+func backoffDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << uint(attempt)
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay) / 2))
+	return delay/2 + jitter
+}
+
+// executeWithRetry 执行任务，遇到临时性错误时按指数退避重试，最多重试 cfg.TaskMaxRetries 次
+// 修改目标环境的任务（changesState）失败时无法确认操作是否已生效，不重试，避免重复执行命令、签发凭证等操作
+func (w *Worker) executeWithRetry(ctx context.Context, taskID uint, run *taskRun, changesState bool, call func() (map[string]interface{}, error)) (map[string]interface{}, error) {
+	for attempt := 0; ; attempt++ {
+		run.attempts++
+		// 云平台调用使用 ctx，任务取消或超时后调用随之结束，返回后才更新任务状态
+		result, err := call()
+		// 调用期间已取消或超时的任务即使调用返回成功，结果也只是部分结果
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			if errors.Is(ctxErr, context.DeadlineExceeded) {
+				return result, fmt.Errorf("%w after %ds", errTaskTimeout, w.cfg.TaskTimeout)
+			}
+			return result, ctxErr
+		}
+		if err == nil {
+			return result, nil
+		}
+		// 部分云平台操作失败时仍返回已执行的步骤，一并保留
+		if !isTransientError(err) {
+			return result, err
+		}
+		if changesState {
+			return result, classify(ErrorClassNotRetried, err)
+		}
+		if attempt >= w.cfg.TaskMaxRetries {
+			return result, err
+		}
+
+		delay := backoffDelay(attempt)
+		fmt.Printf("Task %d attempt %d failed with transient error, retrying in %s: %v\n", taskID, attempt+1, delay, err)
+		w.publish(taskID, progress.Event{
+			Type:    progress.TypeStep,
+			Message: fmt.Sprintf("attempt %d failed, retrying in %s", attempt+1, delay.Round(time.Second)),
+			Error:   err.Error(),
+		})
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("%w after %ds", errTaskTimeout, w.cfg.TaskTimeout)
+			}
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/redteamsec/backend/config"
+	"github.com/redteamsec/backend/internal/cloud/scope"
+)
+
+// timeoutError 模拟网络超时错误
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "dial tcp: lookup ec2.amazonaws.com" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "aws throttling", err: errors.New("operation error EC2: DescribeInstances, api error Throttling: Rate exceeded"), want: true},
+		{name: "tencent limit", err: errors.New("[TencentCloudSDKError] Code=RequestLimitExceeded"), want: true},
+		{name: "s3 slow down", err: errors.New("api error SlowDown: Please reduce your request rate"), want: true},
+		{name: "aws status 503", err: errors.New("https response error StatusCode: 503, RequestID: abc"), want: true},
+		{name: "connection reset", err: fmt.Errorf("list buckets: %w", errors.New("read tcp: connection reset by peer")), want: true},
+		{name: "net timeout", err: fmt.Errorf("describe: %w", timeoutError{}), want: true},
+		{name: "access denied", err: errors.New("api error AccessDenied: not authorized"), want: false},
+		{name: "invalid key", err: errors.New("api error InvalidClientTokenId"), want: false},
+		{name: "deadline exceeded", err: fmt.Errorf("i/o timeout: %w", context.DeadlineExceeded), want: false},
+		{name: "cancelled", err: context.Canceled, want: false},
+		{name: "out of scope", err: fmt.Errorf("throttling: %w", scope.ErrOutOfScope), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientError(tt.err); got != tt.want {
+				t.Fatalf("isTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 0, max: retryBaseDelay},
+		{attempt: 1, max: 2 * retryBaseDelay},
+		{attempt: 3, max: 8 * retryBaseDelay},
+		{attempt: 4, max: retryMaxDelay},
+		{attempt: 10, max: retryMaxDelay},
+		// 位移溢出时使用最大等待时间
+		{attempt: 64, max: retryMaxDelay},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if got := backoffDelay(tt.attempt); got < tt.max/2 || got >= tt.max {
+				t.Fatalf("backoffDelay(%d) = %s, want in [%s, %s)", tt.attempt, got, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestExecuteWithRetry(t *testing.T) {
+	throttled := errors.New("api error Throttling: Rate exceeded")
+	denied := errors.New("api error AccessDenied")
+
+	tests := []struct {
+		name         string
+		errs         []error // 每次调用返回的错误，超出部分返回成功
+		changesState bool
+		wantAttempts int
+		wantClass    string // 空表示成功
+	}{
+		{name: "success", wantAttempts: 1},
+		{name: "permanent error", errs: []error{denied}, wantAttempts: 1, wantClass: ErrorClassPermission},
+		{name: "transient then success", errs: []error{throttled}, wantAttempts: 2},
+		{name: "retries exhausted", errs: []error{throttled, throttled, throttled}, wantAttempts: 2, wantClass: ErrorClassTransient},
+		// 修改目标环境的任务不重试，避免重复执行
+		{name: "state-changing transient", errs: []error{throttled}, changesState: true, wantAttempts: 1, wantClass: ErrorClassNotRetried},
+		{name: "state-changing permanent", errs: []error{denied}, changesState: true, wantAttempts: 1, wantClass: ErrorClassPermission},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			w := &Worker{broker: NewMemoryBroker(), cfg: &config.Config{TaskMaxRetries: 1}}
+			run := newTaskRun()
+			calls := 0
+			result, err := w.executeWithRetry(context.Background(), 1, run, tt.changesState, func() (map[string]interface{}, error) {
+				calls++
+				if calls <= len(tt.errs) {
+					return nil, tt.errs[calls-1]
+				}
+				return map[string]interface{}{"ok": true}, nil
+			})
+
+			if run.attempts != tt.wantAttempts || calls != tt.wantAttempts {
+				t.Fatalf("attempts = %d, calls = %d, want %d", run.attempts, calls, tt.wantAttempts)
+			}
+			if tt.wantClass == "" {
+				if err != nil || result["ok"] != true {
+					t.Fatalf("executeWithRetry() = %v, %v, want success", result, err)
+				}
+				return
+			}
+			if err == nil || errorClass(err) != tt.wantClass {
+				t.Fatalf("executeWithRetry() error = %v, want class %s", err, tt.wantClass)
+			}
+		})
+	}
+}
+
+func TestExecuteWithRetryStopsOnCancel(t *testing.T) {
+	w := &Worker{broker: NewMemoryBroker(), cfg: &config.Config{TaskMaxRetries: 5, TaskTimeout: 1}}
+
+	tests := []struct {
+		name    string
+		timeout time.Duration // 大于 0 时调用期间任务超时，否则调用期间任务被取消
+		wantErr error
+	}{
+		{name: "cancelled", wantErr: context.Canceled},
+		{name: "timed out", timeout: 50 * time.Millisecond, wantErr: errTaskTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.timeout > 0 {
+				cancel()
+				ctx, cancel = context.WithTimeout(context.Background(), tt.timeout)
+			}
+			defer cancel()
+
+			// 调用期间任务被取消或超时，即使调用返回临时性错误也不再重试
+			run := newTaskRun()
+			_, err := w.executeWithRetry(ctx, 1, run, false, func() (map[string]interface{}, error) {
+				if tt.timeout > 0 {
+					<-ctx.Done()
+				} else {
+					cancel()
+				}
+				return nil, errors.New("api error Throttling")
+			})
+			if !errors.Is(err, tt.wantErr) || run.attempts != 1 {
+				t.Fatalf("executeWithRetry() = %v after %d attempts, want %v", err, run.attempts, tt.wantErr)
+			}
+		})
+	}
+}
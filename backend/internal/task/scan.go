@@ -0,0 +1,115 @@
+package task
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/redteamsec/backend/internal/cloud"
+)
+
+// sensitivePattern 敏感文件的匹配规则，Suffixes 匹配文件名结尾，Keywords 匹配文件名中的关键字（均为小写）
+type sensitivePattern struct {
+	Category string
+	Suffixes []string
+	Keywords []string
+}
+
+// sensitivePatterns 对象存储中常见的敏感文件
+var sensitivePatterns = []sensitivePattern{
+	{Category: "credentials", Suffixes: []string{".env", ".pem", ".key", ".p12", ".pfx", ".keystore", ".jks", ".ppk"}, Keywords: []string{"credential", "id_rsa", "id_ed25519", "secret", "password", "passwd", ".aws/config", "accesskey"}},
+	{Category: "database", Suffixes: []string{".sql", ".sql.gz", ".dump", ".bak", ".db", ".sqlite", ".mdb"}, Keywords: []string{"backup", "dump"}},
+	{Category: "config", Suffixes: []string{".tfstate", ".tfvars", ".kubeconfig", ".ovpn"}, Keywords: []string{"kubeconfig", "terraform.tfstate", "wp-config", "application.properties", "settings.py"}},
+	{Category: "personal_data", Suffixes: []string{".csv", ".xlsx", ".xls"}, Keywords: []string{"customer", "user_info", "employee", "身份证", "手机号"}},
+}
+
+// matchSensitive 判断对象键名是否匹配敏感文件规则，返回匹配的分类
+func matchSensitive(key string) (string, bool) {
+	lower := strings.ToLower(key)
+	name := path.Base(lower)
+	for _, pattern := range sensitivePatterns {
+		for _, suffix := range pattern.Suffixes {
+			if strings.HasSuffix(name, suffix) {
+				return pattern.Category, true
+			}
+		}
+		for _, keyword := range pattern.Keywords {
+			if strings.Contains(lower, keyword) {
+				return pattern.Category, true
+			}
+		}
+	}
+	return "", false
+}
+
+// bucketNames 从任务参数中解析存储桶列表，支持名称列表或资源枚举返回的存储桶对象列表
+func bucketNames(value interface{}) []string {
+	names := []string{}
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			names = append(names, v)
+		}
+	case []interface{}:
+		for _, item := range v {
+			switch b := item.(type) {
+			case string:
+				names = append(names, b)
+			case map[string]interface{}:
+				if name, ok := b["bucketName"].(string); ok {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// scanSensitiveObjects 遍历存储桶中的对象，找出疑似包含敏感数据的文件
+// 单个存储桶失败时记录错误并继续扫描其余存储桶
+func scanSensitiveObjects(provider cloud.CloudProvider, buckets []string) (map[string]interface{}, error) {
+	findings := []map[string]interface{}{}
+	errs := []string{}
+	scanned := 0
+
+	for _, bucket := range buckets {
+		result, err := provider.OperateResource("s3", "list_objects", bucket, map[string]interface{}{})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", bucket, err))
+			continue
+		}
+		scanned++
+
+		objects, _ := result["objects"].([]interface{})
+		for _, item := range objects {
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			key, _ := object["key"].(string)
+			category, matched := matchSensitive(key)
+			if !matched {
+				continue
+			}
+			findings = append(findings, map[string]interface{}{
+				"bucket":       bucket,
+				"key":          key,
+				"size":         object["size"],
+				"lastModified": object["lastModified"],
+				"category":     category,
+			})
+		}
+	}
+
+	// 所有存储桶都无法访问时视为失败
+	if scanned == 0 && len(errs) > 0 {
+		return nil, fmt.Errorf("failed to scan buckets: %s", strings.Join(errs, "; "))
+	}
+
+	return map[string]interface{}{
+		"message":        "Sensitive data scan completed",
+		"bucketsScanned": scanned,
+		"findings":       findings,
+		"errors":         errs,
+	}, nil
+}
@@ -0,0 +1,136 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redteamsec/backend/internal/database"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// schedulerInterval 检查到期定时任务的间隔
+const schedulerInterval = 30 * time.Second
+
+// ParseCron 解析标准五段 cron 表达式，支持 @daily、@every 1h 等描述符
+func ParseCron(expr string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	return schedule, nil
+}
+
+// NextRun 计算 cron 表达式在 from 之后的下一次运行时间（RFC3339，UTC）
+func NextRun(expr string, from time.Time) (string, error) {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return "", err
+	}
+	return schedule.Next(from).UTC().Format(time.RFC3339), nil
+}
+
+// Submit 创建任务并放入队列，未指定优先级时按普通优先级处理
+func Submit(ctx context.Context, db *gorm.DB, queue Queue, t *database.Task) error {
+	t.Status = "pending"
+	t.Priority = normalizePriority(t.Priority)
+	if result := db.Create(t); result.Error != nil {
+		return fmt.Errorf("failed to create task: %w", result.Error)
+	}
+	if err := queue.Enqueue(ctx, t.ID, t.Priority); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Scheduler 定时任务调度器，到期时创建任务并放入 worker 队列
+type Scheduler struct {
+	db    *gorm.DB
+	queue Queue
+}
+
+// NewScheduler 创建定时任务调度器
+func NewScheduler(db *gorm.DB, queue Queue) *Scheduler {
+	return &Scheduler{db: db, queue: queue}
+}
+
+// Start 启动调度循环，ctx 取消后退出
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(schedulerInterval)
+		defer ticker.Stop()
+
+		s.runDue(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.runDue(ctx)
+			}
+		}
+	}()
+}
+
+// runDue 为所有到期的定时任务创建任务
+func (s *Scheduler) runDue(ctx context.Context) {
+	var schedules []database.Schedule
+	if result := s.db.Where("status = ?", "active").Find(&schedules); result.Error != nil {
+		fmt.Printf("Error loading schedules: %v\n", result.Error)
+		return
+	}
+
+	now := time.Now()
+	for _, schedule := range schedules {
+		nextRunAt, err := time.Parse(time.RFC3339, schedule.NextRunAt)
+		if err == nil && nextRunAt.After(now) {
+			continue
+		}
+		s.fire(ctx, schedule, now)
+	}
+}
+
+// fire 执行一次定时任务
+// 通过比较 next_run_at 认领本次运行，多个实例共用数据库时只有一个实例会创建任务
+func (s *Scheduler) fire(ctx context.Context, schedule database.Schedule, now time.Time) {
+	nextRunAt, err := NextRun(schedule.CronExpr, now)
+	if err != nil {
+		fmt.Printf("Error parsing schedule %d: %v\n", schedule.ID, err)
+		return
+	}
+
+	claim := s.db.Model(&database.Schedule{}).
+		Where("id = ? AND next_run_at = ? AND status = ?", schedule.ID, schedule.NextRunAt, "active").
+		Updates(map[string]interface{}{
+			"next_run_at": nextRunAt,
+			"last_run_at": now.UTC().Format(time.RFC3339),
+		})
+	if claim.Error != nil {
+		fmt.Printf("Error claiming schedule %d: %v\n", schedule.ID, claim.Error)
+		return
+	}
+	if claim.RowsAffected == 0 {
+		return
+	}
+
+	t := &database.Task{
+		UserID:       schedule.UserID,
+		ProjectID:    schedule.ProjectID,
+		CredentialID: schedule.CredentialID,
+		Name:         schedule.Name,
+		TaskType:     schedule.TaskType,
+		Parameters:   schedule.Parameters,
+		// 定时任务在后台运行，不应抢占用户正在等待的任务
+		Priority: PriorityLow,
+	}
+	if err := Submit(ctx, s.db, s.queue, t); err != nil {
+		fmt.Printf("Error submitting task for schedule %d: %v\n", schedule.ID, err)
+		return
+	}
+
+	if result := s.db.Model(&database.Schedule{}).Where("id = ?", schedule.ID).Update("last_task_id", t.ID); result.Error != nil {
+		fmt.Printf("Error updating schedule %d: %v\n", schedule.ID, result.Error)
+	}
+	fmt.Printf("Schedule %d created task %d, next run at %s\n", schedule.ID, t.ID, nextRunAt)
+}
@@ -0,0 +1,94 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redteamsec/backend/internal/database"
+)
+
+func TestNextRun(t *testing.T) {
+	from := time.Date(2026, 1, 31, 10, 7, 30, 0, time.UTC)
+	shanghai := time.FixedZone("UTC+8", 8*60*60)
+
+	tests := []struct {
+		name    string
+		expr    string
+		from    time.Time
+		want    string
+		wantErr bool
+	}{
+		{name: "daily at 03:00", expr: "0 3 * * *", from: from, want: "2026-02-01T03:00:00Z"},
+		{name: "every 15 minutes", expr: "*/15 * * * *", from: from, want: "2026-01-31T10:15:00Z"},
+		{name: "monthly", expr: "0 0 1 * *", from: from, want: "2026-02-01T00:00:00Z"},
+		{name: "weekdays", expr: "30 9 * * 1-5", from: from, want: "2026-02-02T09:30:00Z"},
+		{name: "daily descriptor", expr: "@daily", from: from, want: "2026-02-01T00:00:00Z"},
+		{name: "every descriptor", expr: "@every 1h", from: from, want: "2026-01-31T11:07:30Z"},
+		// 按 from 所在时区计算，结果统一为 UTC
+		{name: "local time zone", expr: "0 3 * * *", from: from.In(shanghai), want: "2026-01-31T19:00:00Z"},
+		{name: "empty", expr: "", wantErr: true},
+		{name: "too few fields", expr: "* * *", wantErr: true},
+		{name: "out of range", expr: "61 * * * *", wantErr: true},
+		{name: "unknown descriptor", expr: "@fortnightly", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NextRun(tt.expr, tt.from)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NextRun(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("NextRun(%q) = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedulerRunDue(t *testing.T) {
+	db := database.NewTestDB(t, &database.Schedule{}, &database.Task{})
+	now := time.Now().UTC()
+	past := now.Add(-time.Minute).Format(time.RFC3339)
+	future := now.Add(time.Hour).Format(time.RFC3339)
+
+	schedules := []database.Schedule{
+		{Name: "due", TaskType: "enumerate", CronExpr: "@every 1h", Status: "active", NextRunAt: past},
+		{Name: "never run", TaskType: "enumerate", CronExpr: "@every 1h", Status: "active"},
+		{Name: "not due", TaskType: "enumerate", CronExpr: "@every 1h", Status: "active", NextRunAt: future},
+		{Name: "paused", TaskType: "enumerate", CronExpr: "@every 1h", Status: "paused", NextRunAt: past},
+	}
+	if err := db.Create(&schedules).Error; err != nil {
+		t.Fatalf("create schedules: %v", err)
+	}
+
+	s := NewScheduler(db, NewMemoryQueue(time.Minute))
+	s.runDue(context.Background())
+	// 第二次检查时下一次运行时间已推后，不会重复创建任务
+	s.runDue(context.Background())
+
+	var tasks []database.Task
+	db.Order("id").Find(&tasks)
+	if len(tasks) != 2 || tasks[0].Name != "due" || tasks[1].Name != "never run" {
+		t.Fatalf("created tasks = %+v, want one each for the due schedules", tasks)
+	}
+
+	for i, want := range []bool{true, true, false, false} {
+		var stored database.Schedule
+		db.First(&stored, schedules[i].ID)
+		if fired := stored.LastTaskID != 0; fired != want {
+			t.Errorf("schedule %q fired = %v, want %v", stored.Name, fired, want)
+		}
+		if want && stored.NextRunAt <= now.Format(time.RFC3339) {
+			t.Errorf("schedule %q next run %s was not advanced", stored.Name, stored.NextRunAt)
+		}
+	}
+
+	// 其他实例已认领本次运行时，使用旧的 next_run_at 认领失败
+	s.fire(context.Background(), schedules[0], now)
+	var count int64
+	db.Model(&database.Task{}).Count(&count)
+	if count != 2 {
+		t.Fatalf("stale claim created a task, got %d tasks", count)
+	}
+}
@@ -0,0 +1,35 @@
+package task
+
+import "github.com/redteamsec/backend/internal/cloud/plan"
+
+// taskTypes 可通过任务队列执行的任务类型
+var taskTypes = []string{"enumerate", "escalate", "operate", "takeover", "userinfo", "download", "sensitive_scan", "cleanup"}
+
+// ValidType 判断任务类型是否受支持
+func ValidType(taskType string) bool {
+	for _, t := range taskTypes {
+		if t == taskType {
+			return true
+		}
+	}
+	return false
+}
+
+// readOnlyActions 不修改云上资源状态的 OperateResource 操作
+var readOnlyActions = map[string]bool{
+	"list_objects": true,
+	"download":     true,
+}
+
+// ChangesState 判断任务是否会修改云上资源或身份状态，例如执行命令、签发临时凭证、平台接管
+// 未知的操作一律按会修改状态处理，dry_run 的资源操作只生成执行计划
+func ChangesState(taskType string, params map[string]interface{}) bool {
+	switch taskType {
+	case "enumerate", "escalate", "userinfo", "download", "sensitive_scan":
+		return false
+	case "operate":
+		action, _ := params["action"].(string)
+		return !readOnlyActions[action] && !plan.IsDryRun(params)
+	}
+	return true
+}
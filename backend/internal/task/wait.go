@@ -0,0 +1,54 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redteamsec/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// waitPollInterval 等待任务结束时兜底查询数据库的间隔，避免因事件丢失而一直等待
+const waitPollInterval = 2 * time.Second
+
+// TerminalStatuses 已结束的任务状态，用于按状态筛选的查询
+var TerminalStatuses = []string{"completed", "failed", "cancelled"}
+
+// IsTerminal 判断任务状态是否为已结束
+func IsTerminal(status string) bool {
+	return status == "completed" || status == "failed" || status == "cancelled"
+}
+
+// Wait 等待任务结束并返回最终的任务记录，ctx 结束时返回 ctx 的错误
+func Wait(ctx context.Context, db *gorm.DB, broker Broker, taskID uint) (*database.Task, error) {
+	// 先订阅再查询状态，避免错过两者之间发布的结束事件
+	events, unsubscribe, err := broker.Subscribe(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer unsubscribe()
+
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		var t database.Task
+		if result := db.First(&t, taskID); result.Error != nil {
+			return nil, fmt.Errorf("failed to load task: %w", result.Error)
+		}
+		if IsTerminal(t.Status) {
+			return &t, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case _, ok := <-events:
+			if !ok {
+				events = nil
+			}
+		case <-ticker.C:
+		}
+	}
+}
@@ -3,180 +3,609 @@ package task
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/redteamsec/backend/config"
-	"github.com/redteamsec/backend/internal/cloud"
+	"github.com/redteamsec/backend/internal/auth"
+	"github.com/redteamsec/backend/internal/cloud/plan"
+	"github.com/redteamsec/backend/internal/cloud/progress"
+	"github.com/redteamsec/backend/internal/cloud/scope"
 	"github.com/redteamsec/backend/internal/database"
-	"github.com/redis/go-redis/v9"
+	"github.com/redteamsec/backend/internal/project"
+	"github.com/redteamsec/backend/internal/secrets"
 	"gorm.io/gorm"
 )
 
-// Worker 任务处理 worker
+// Worker 任务处理 worker 池
 type Worker struct {
-	db          *gorm.DB
-	redisClient *redis.Client
-	cfg         *config.Config
+	db      *gorm.DB
+	queue   Queue
+	broker  Broker
+	limiter Limiter
+	keyring *secrets.Keyring
+	cfg     *config.Config
+
+	wg sync.WaitGroup
+
+	// inFlight 正在处理的任务，退出超时时据此将任务重新入队
+	mu       sync.Mutex
+	inFlight map[uint]bool
+
+	// abortCtx 在退出超时后取消，通知进行中的任务放弃执行
+	abortCtx context.Context
+	abort    context.CancelFunc
 }
 
 // NewWorker 创建新的任务处理 worker
-func NewWorker(db *gorm.DB, redisClient *redis.Client, cfg *config.Config) *Worker {
+func NewWorker(db *gorm.DB, queue Queue, broker Broker, limiter Limiter, keyring *secrets.Keyring, cfg *config.Config) *Worker {
+	abortCtx, abort := context.WithCancel(context.Background())
 	return &Worker{
-		db:          db,
-		redisClient: redisClient,
-		cfg:         cfg,
+		db:       db,
+		queue:    queue,
+		broker:   broker,
+		limiter:  limiter,
+		keyring:  keyring,
+		cfg:      cfg,
+		inFlight: make(map[uint]bool),
+		abortCtx: abortCtx,
+		abort:    abort,
 	}
 }
 
-// Start 启动任务处理 worker
-func (w *Worker) Start() {
-	ctx := context.Background()
+// Start 启动 cfg.WorkerCount 个 worker，ctx 取消后停止从队列获取新任务
+func (w *Worker) Start(ctx context.Context) {
+	count := w.cfg.WorkerCount
+	if count < 1 {
+		count = 1
+	}
 
-	for {
-		// 检查Redis客户端是否可用
-		if w.redisClient == nil {
-			time.Sleep(5 * time.Second)
-			continue
-		}
+	// 启动前先恢复上次崩溃遗留的任务
+	w.reap(ctx)
+	w.wg.Add(1)
+	go w.reapLoop(ctx)
 
+	for i := 0; i < count; i++ {
+		w.wg.Add(1)
+		go w.run(ctx)
+	}
+}
+
+// Stop 等待进行中的任务完成，超过 timeout 仍未完成的任务重新入队
+// 需要在 Start 的 ctx 取消之后调用
+func (w *Worker) Stop(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-time.After(timeout):
+	}
+
+	// 超时后将未完成的任务恢复为 pending 并放回队列，避免一直停留在 running 状态
+	w.mu.Lock()
+	for taskID := range w.inFlight {
+		delete(w.inFlight, taskID)
+		w.requeueTask(taskID)
+	}
+	w.mu.Unlock()
+	w.abort()
+}
+
+// run 单个 worker 的主循环
+func (w *Worker) run(ctx context.Context) {
+	defer w.wg.Done()
+
+	for ctx.Err() == nil {
 		// 从队列中获取任务
-		taskID, err := w.redisClient.BLPop(ctx, 5*time.Second, "task_queue").Result()
+		taskID, err := w.queue.Dequeue(ctx, 5*time.Second)
 		if err != nil {
-			if err != redis.Nil {
+			if err != ErrQueueEmpty && ctx.Err() == nil {
 				fmt.Printf("Error popping task: %v\n", err)
+				time.Sleep(time.Second)
 			}
 			continue
 		}
 
+		// 已开始退出时不再处理新任务，放回队列等待下次启动
+		if ctx.Err() != nil {
+			if err := w.queue.Requeue(context.Background(), taskID); err != nil {
+				fmt.Printf("Error requeueing task %d: %v\n", taskID, err)
+			}
+			return
+		}
+
 		// 处理任务
-		w.processTask(taskID[1])
+		w.processTask(taskID)
 	}
 }
 
-// processTask 处理任务
-func (w *Worker) processTask(taskIDStr string) {
-	// 解析任务 ID
-	var taskID uint
-	_, err := fmt.Sscanf(taskIDStr, "%d", &taskID)
-	if err != nil {
-		fmt.Printf("Error parsing task ID: %v\n", err)
+// acquire 登记进行中的任务
+func (w *Worker) acquire(taskID uint) {
+	w.mu.Lock()
+	w.inFlight[taskID] = true
+	w.mu.Unlock()
+}
+
+// release 注销进行中的任务，返回 false 表示任务已在退出时被重新入队
+func (w *Worker) release(taskID uint) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.inFlight[taskID] {
+		return false
+	}
+	delete(w.inFlight, taskID)
+	return true
+}
+
+// isInFlight 判断任务是否正由本进程处理
+func (w *Worker) isInFlight(taskID uint) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.inFlight[taskID]
+}
+
+// heartbeat 定期延长任务的可见性截止时间及并发名额的租约，返回停止函数
+func (w *Worker) heartbeat(taskID uint, limits []Limit) func() {
+	interval := time.Duration(w.cfg.TaskVisibilityTimeout) * time.Second / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := w.queue.Extend(context.Background(), taskID); err != nil {
+					fmt.Printf("Error extending task %d: %v\n", taskID, err)
+				}
+				if err := w.limiter.Extend(context.Background(), taskID, limits); err != nil {
+					fmt.Printf("Error extending limits for task %d: %v\n", taskID, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// ack 确认任务处理结束
+func (w *Worker) ack(taskID uint) {
+	if err := w.queue.Ack(context.Background(), taskID); err != nil {
+		fmt.Printf("Error acking task %d: %v\n", taskID, err)
+	}
+}
+
+// requeueTask 将任务恢复为 pending 并放回队列头部
+// 因退出而中断的执行不计入投递次数
+func (w *Worker) requeueTask(taskID uint) {
+	// 已请求取消的任务保留状态，再次取出时直接标记为已取消
+	if result := w.db.Model(&database.Task{}).Where("id = ? AND status = ?", taskID, "running").Updates(map[string]interface{}{
+		"status":     "pending",
+		"start_time": "",
+		"deliveries": gorm.Expr("CASE WHEN deliveries > 0 THEN deliveries - 1 ELSE 0 END"),
+	}); result.Error != nil {
+		fmt.Printf("Error resetting task %d: %v\n", taskID, result.Error)
+	}
+	if err := w.queue.Requeue(context.Background(), taskID); err != nil {
+		fmt.Printf("Error requeueing task %d: %v\n", taskID, err)
 		return
 	}
+	w.publish(taskID, progress.Event{Type: progress.TypeStatus, Status: "pending", Message: "task requeued on shutdown"})
+	fmt.Printf("Task %d requeued on shutdown\n", taskID)
+}
 
+// publish 发布任务进度事件
+func (w *Worker) publish(taskID uint, event progress.Event) {
+	if event.Timestamp == "" {
+		event.Timestamp = time.Now().Format(time.RFC3339)
+	}
+	if err := w.broker.Publish(context.Background(), taskID, event); err != nil {
+		fmt.Printf("Error publishing event for task %d: %v\n", taskID, err)
+	}
+}
+
+// processTask 处理任务
+func (w *Worker) processTask(taskID uint) {
 	// 获取任务信息
 	var task database.Task
 	if result := w.db.First(&task, taskID); result.Error != nil {
 		fmt.Printf("Error getting task: %v\n", result.Error)
+		// 任务已被删除时丢弃队列条目，其他错误留给 reaper 重新投递
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			w.ack(taskID)
+		}
 		return
 	}
 
-	// 更新任务状态为 running
-	task.Status = "running"
+	// 排队期间已被取消的任务不再执行
+	switch task.Status {
+	case "cancelled":
+		w.ack(taskID)
+		return
+	case "cancelling":
+		w.finishTask(taskID, classify(ErrorClassCancelled, errTaskCancelled), nil)
+		w.ack(taskID)
+		return
+	}
+
+	// 凭证或云平台达到并发/速率上限时延后执行，延后不计入投递次数
+	limits := w.limits(&task)
+	wait, err := w.limiter.Acquire(context.Background(), taskID, limits)
+	if err != nil {
+		// 限流器不可用时不阻塞任务执行
+		fmt.Printf("Error acquiring limits for task %d: %v\n", taskID, err)
+	}
+	if wait > 0 {
+		if err := w.queue.Defer(context.Background(), taskID, wait); err != nil {
+			fmt.Printf("Error deferring task %d: %v\n", taskID, err)
+			return
+		}
+		w.publish(taskID, progress.Event{Type: progress.TypeStatus, Status: "pending", Message: fmt.Sprintf("deferred for %s: concurrency or rate limit reached", wait.Round(time.Second))})
+		return
+	}
+	// 名额在云平台调用返回后才释放，调用超过租约时间时由心跳续期
+	defer func() {
+		if err := w.limiter.Release(context.Background(), taskID, limits); err != nil {
+			fmt.Printf("Error releasing limits for task %d: %v\n", taskID, err)
+		}
+	}()
+
+	// 登记为进行中，提前返回时同样注销
+	w.acquire(taskID)
+	defer w.release(taskID)
+	stopHeartbeat := w.heartbeat(taskID, limits)
+	defer stopHeartbeat()
+
+	// 更新任务状态为 running，状态已被并发修改（例如被取消）时交由 reaper 处理
 	task.StartTime = time.Now().Format(time.RFC3339)
-	if result := w.db.Save(&task); result.Error != nil {
-		fmt.Printf("Error updating task status: %v\n", result.Error)
+	task.Deliveries++
+	result := w.db.Model(&database.Task{}).Where("id = ? AND status = ?", taskID, task.Status).Updates(map[string]interface{}{
+		"status":     "running",
+		"start_time": task.StartTime,
+		"deliveries": task.Deliveries,
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		if result.Error != nil {
+			fmt.Printf("Error updating task status: %v\n", result.Error)
+		}
 		return
 	}
+	task.Status = "running"
+	w.publish(taskID, progress.Event{Type: progress.TypeStatus, Status: "running", Message: fmt.Sprintf("delivery %d", task.Deliveries)})
 
-	// 获取凭证信息
-	var credential database.CloudCredential
-	if result := w.db.First(&credential, task.CredentialID); result.Error != nil {
-		fmt.Printf("Error getting credential: %v\n", result.Error)
-		w.updateTaskStatus(taskID, "failed", "Credential not found")
+	// 监听取消请求，收到后取消任务的执行上下文
+	ctx, cancel := context.WithCancel(w.abortCtx)
+	defer cancel()
+	run := newTaskRun()
+	stopWatch := w.watchCancel(ctx, taskID, run, cancel)
+	defer stopWatch()
+
+	taskResult, err := w.executeTask(ctx, &task, run)
+
+	// 任务已在退出时重新入队，不再更新状态
+	if !w.release(taskID) {
 		return
 	}
 
-	// 创建云平台实例
-	provider, err := cloud.NewCloudProvider(credential.CloudProvider, credential.AccessKey, credential.SecretKey, credential.Region)
-	if err != nil {
-		fmt.Printf("Error creating cloud provider: %v\n", err)
-		w.updateTaskStatus(taskID, "failed", "Failed to create cloud provider")
+	// 用户取消的任务保存取消前已收集的部分结果
+	if err != nil && run.isCancelled() {
+		err = classify(ErrorClassCancelled, errTaskCancelled)
+		taskResult = run.partialResult(taskResult)
+	}
+
+	// 先保存结果再更新状态，等待结束状态的请求读取结果时结果已存在
+	w.saveTaskResult(taskID, taskResult, err)
+	w.finishTask(taskID, err, run)
+	w.recordAudit(&task, err)
+
+	// 临时性错误重试耗尽说明任务反复失败，转入死信队列
+	if err != nil && errorClass(err) == ErrorClassTransient {
+		if err := w.queue.DeadLetter(context.Background(), taskID); err != nil {
+			fmt.Printf("Error dead-lettering task %d: %v\n", taskID, err)
+		}
 		return
 	}
+	w.ack(taskID)
+}
+
+// limits 返回任务所属凭证及云平台的限流配置
+func (w *Worker) limits(task *database.Task) []Limit {
+	limits := []Limit{{
+		Key:         fmt.Sprintf("credential:%d", task.CredentialID),
+		Concurrency: w.cfg.TaskCredentialConcurrency,
+		Rate:        w.cfg.TaskCredentialRate,
+	}}
+
+	var credential database.CloudCredential
+	if result := w.db.Select("cloud_provider").First(&credential, task.CredentialID); result.Error == nil {
+		limits = append(limits, Limit{
+			Key:         "provider:" + credential.CloudProvider,
+			Concurrency: w.cfg.TaskProviderConcurrency,
+			Rate:        w.cfg.TaskProviderRate,
+		})
+	}
+	return limits
+}
+
+// watchCancel 订阅任务事件，收到取消请求时标记任务并调用 cancel，返回停止监听的函数
+func (w *Worker) watchCancel(ctx context.Context, taskID uint, run *taskRun, cancel context.CancelFunc) func() {
+	events, unsubscribe, err := w.broker.Subscribe(ctx, taskID)
+	if err != nil {
+		fmt.Printf("Error subscribing to events for task %d: %v\n", taskID, err)
+		return func() {}
+	}
+
+	// 订阅建立前已请求取消的任务只能从数据库状态得知
+	var task database.Task
+	if result := w.db.Select("status").First(&task, taskID); result.Error == nil && task.Status == "cancelling" {
+		run.markCancelled()
+		cancel()
+	}
+
+	go func() {
+		for event := range events {
+			if event.Type == progress.TypeCancel {
+				run.markCancelled()
+				cancel()
+			}
+		}
+	}()
+	return unsubscribe
+}
+
+// executeTask 根据任务类型调用云平台接口，ctx 取消时结束执行，调用返回后才返回
+func (w *Worker) executeTask(ctx context.Context, task *database.Task, run *taskRun) (map[string]interface{}, error) {
+	// 按执行时的角色检查权限，定时任务和剧本步骤在创建者被降级或移出项目后不再执行
+	var owner database.User
+	if result := w.db.Select("id", "role").First(&owner, task.UserID); result.Error != nil {
+		return nil, classify(ErrorClassInvalid, errors.New("task owner not found"))
+	}
+	permission := auth.TaskPermission(task.TaskType)
+	if !auth.HasPermission(owner.Role, permission) {
+		return nil, classify(ErrorClassInvalid, fmt.Errorf("task owner lacks permission %s", permission))
+	}
+	projectRole, err := project.Access(w.db, owner.ID, owner.Role, task.ProjectID)
+	if errors.Is(err, project.ErrNoAccess) {
+		return nil, classify(ErrorClassInvalid, errors.New("task owner is no longer a member of the project"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !project.Allows(projectRole, permission) {
+		return nil, classify(ErrorClassInvalid, fmt.Errorf("task owner lacks permission %s in the project", permission))
+	}
+
+	// 获取凭证信息
+	var credential database.CloudCredential
+	if result := w.db.First(&credential, task.CredentialID); result.Error != nil {
+		fmt.Printf("Error getting credential: %v\n", result.Error)
+		return nil, classify(ErrorClassCredential, errors.New("credential not found"))
+	}
 
 	// 解析任务参数
 	var params map[string]interface{}
 	if err := json.Unmarshal([]byte(task.Parameters), &params); err != nil {
 		fmt.Printf("Error unmarshaling parameters: %v\n", err)
-		w.updateTaskStatus(taskID, "failed", "Invalid parameters")
-		return
+		return nil, classify(ErrorClassInvalid, errors.New("invalid parameters"))
 	}
 
-	// 根据任务类型执行不同的操作
-	var result map[string]interface{}
+	// 参数中指定了区域时覆盖凭证的默认区域
+	region := credential.Region
+	if r, ok := params["region"].(string); ok && r != "" {
+		region = r
+	}
+
+	// 创建云平台实例
+	cloudProvider, err := NewProvider(w.keyring, &credential, region)
+	if err != nil {
+		fmt.Printf("Error creating cloud provider: %v\n", err)
+		return nil, classify(ErrorClassCredential, fmt.Errorf("failed to create cloud provider: %w", err))
+	}
+	// 任务超时时间内执行，支持取消的云平台在任务取消或超时后结束进行中的调用
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(w.cfg.TaskTimeout)*time.Second)
+	defer cancel()
+	if cancellable, ok := cloudProvider.(progress.Cancellable); ok {
+		cancellable.SetContext(ctx)
+	}
+	// 支持进度上报的云平台将进度转发为任务事件
+	if reportable, ok := cloudProvider.(progress.Reportable); ok {
+		reportable.SetReporter(func(event progress.Event) {
+			run.record(event)
+			w.publish(task.ID, event)
+		})
+	}
+	// 操作创建的角色、实例配置文件等资源记入项目的资源台账，项目结束时统一清理
+	TrackArtifacts(w.db, cloudProvider, &credential, task.UserID, task.ID)
+	// 项目定义了授权范围时，能在操作内部检查范围的云平台在状态变更前逐项检查
+	engagementScope, err := projectScope(w.db, task.ProjectID)
+	if err != nil {
+		return nil, classify(ErrorClassInvalid, err)
+	}
+	guarded, isGuarded := cloudProvider.(scope.Guarded)
+	if engagementScope != nil && isGuarded {
+		guarded.SetGuard(func(action scope.Action) error {
+			return engagementScope.Check(action, time.Now())
+		})
+	}
+	provider := newCountingProvider(cloudProvider)
+	run.provider = provider
+
+	// 根据任务类型确定要执行的操作
+	var call func() (map[string]interface{}, error)
 	switch task.TaskType {
 	case "enumerate":
 		resourceType, ok := params["resource_type"].(string)
 		if !ok {
-			w.updateTaskStatus(taskID, "failed", "Invalid resource type")
-			return
+			return nil, classify(ErrorClassInvalid, errors.New("invalid resource type"))
+		}
+		call = func() (map[string]interface{}, error) {
+			return provider.EnumerateResources(resourceType)
 		}
-		result, err = provider.EnumerateResources(resourceType)
 
 	case "escalate":
-		result, err = provider.EscalatePrivileges()
+		call = provider.EscalatePrivileges
 
 	case "operate":
 		resourceType, ok1 := params["resource_type"].(string)
 		action, ok2 := params["action"].(string)
 		resourceID, ok3 := params["resource_id"].(string)
 		if !ok1 || !ok2 || !ok3 {
-			w.updateTaskStatus(taskID, "failed", "Invalid parameters")
-			return
+			return nil, classify(ErrorClassInvalid, errors.New("invalid parameters"))
+		}
+		// 其他云平台无法提供账号和资源标签，执行前按已知信息检查，范围限制了未知维度时拒绝
+		if class := scope.Classify(resourceType, action); class != "" && !isGuarded {
+			err := engagementScope.Check(scope.Action{Class: class, Operation: action, Region: region, Resource: resourceID}, time.Now())
+			if err != nil {
+				return nil, err
+			}
+		}
+		// 不支持 dry_run 的云平台会忽略该参数直接执行，必须在调用前拒绝
+		planID := plan.ApprovedPlanID(params)
+		if plan.IsDryRun(params) || planID != 0 {
+			if planner, ok := cloudProvider.(plan.Planner); !ok || !planner.SupportsDryRun() {
+				return nil, classify(ErrorClassInvalid, fmt.Errorf("dry run is not supported for %s", credential.CloudProvider))
+			}
+		}
+		if planID != 0 {
+			approved, err := loadApprovedPlan(w.db, task, params, planID)
+			if err != nil {
+				return nil, classify(ErrorClassInvalid, err)
+			}
+			call = func() (map[string]interface{}, error) {
+				return operateWithPlan(provider, approved, resourceType, action, resourceID, params)
+			}
+			break
+		}
+		call = func() (map[string]interface{}, error) {
+			return provider.OperateResource(resourceType, action, resourceID, params)
 		}
-		result, err = provider.OperateResource(resourceType, action, resourceID, params)
 
 	case "takeover":
-		result, err = provider.Takeover()
+		call = provider.Takeover
+
+	case "userinfo":
+		call = provider.GetPermissions
+
+	case "download":
+		bucket, ok1 := params["bucket"].(string)
+		key, ok2 := params["key"].(string)
+		if !ok1 || !ok2 {
+			return nil, classify(ErrorClassInvalid, errors.New("invalid parameters"))
+		}
+		call = func() (map[string]interface{}, error) {
+			return downloadObject(provider, w.cfg.DownloadPath, bucket, key)
+		}
+
+	case "sensitive_scan":
+		buckets := bucketNames(params["buckets"])
+		if len(buckets) == 0 {
+			return nil, classify(ErrorClassInvalid, errors.New("no buckets to scan"))
+		}
+		call = func() (map[string]interface{}, error) {
+			return scanSensitiveObjects(provider, buckets)
+		}
+
+	case "cleanup":
+		// 资源台账记录了资源所在区域，清理时按区域创建云平台实例，不经过授权范围检查
+		call = func() (map[string]interface{}, error) {
+			return w.cleanupArtifacts(ctx, task, &credential)
+		}
 
 	default:
-		w.updateTaskStatus(taskID, "failed", "Unsupported task type")
-		return
+		return nil, classify(ErrorClassInvalid, fmt.Errorf("unsupported task type: %s", task.TaskType))
 	}
 
-	// 处理执行结果
-	if err != nil {
-		fmt.Printf("Error executing task: %v\n", err)
-		w.updateTaskStatus(taskID, "failed", err.Error())
-		return
-	}
+	// 临时性错误按退避策略重试
+	result, err := w.executeWithRetry(ctx, task.ID, run, ChangesState(task.TaskType, params), call)
 
-	// 保存任务结果
-	w.updateTaskStatus(taskID, "completed", "")
-	w.saveTaskResult(taskID, result)
+	// 记录联邦登录签发的临时凭证，便于后续撤销和审计
+	if err == nil && task.TaskType == "operate" && params["action"] == "federated_login" && !plan.IsDryRun(params) {
+		if tokenID, err := recordFederationToken(w.db, task.UserID, credential, result); err != nil {
+			fmt.Printf("Failed to record federation token: %v\n", err)
+		} else {
+			result["token_id"] = tokenID
+		}
+	}
+	// 临时凭证和控制台登录地址只返回给提交者，保存前加密
+	if result != nil && task.TaskType == "operate" && params["action"] == "federated_login" {
+		if err := sealSecrets(w.keyring, result, federationSecretKeys); err != nil {
+			fmt.Printf("Failed to seal federated login result: %v\n", err)
+		}
+	}
+	if err == nil && task.TaskType == "operate" && params["action"] == "revoke_federation_token" && !plan.IsDryRun(params) {
+		if err := markFederationTokensRevoked(w.db, task.UserID, credential.ID, params); err != nil {
+			fmt.Printf("Failed to mark federation tokens revoked: %v\n", err)
+		}
+	}
+	return result, err
 }
 
-// updateTaskStatus 更新任务状态
-func (w *Worker) updateTaskStatus(taskID uint, status, errorMsg string) {
-	var task database.Task
-	if result := w.db.First(&task, taskID); result.Error != nil {
-		fmt.Printf("Error getting task: %v\n", result.Error)
-		return
+// finishTask 记录任务的结束状态、错误信息及执行元数据，run 为空时不更新执行元数据
+// 结束状态发布后 Wait 即返回，有结果的任务需要先调用 saveTaskResult
+func (w *Worker) finishTask(taskID uint, taskErr error, run *taskRun) {
+	updates := map[string]interface{}{
+		"status":      "completed",
+		"end_time":    time.Now().Format(time.RFC3339),
+		"error":       "",
+		"error_class": "",
+	}
+	if taskErr != nil {
+		fmt.Printf("Error executing task %d: %v\n", taskID, taskErr)
+		updates["status"] = "failed"
+		updates["error"] = taskErr.Error()
+		updates["error_class"] = errorClass(taskErr)
+		if updates["error_class"] == ErrorClassCancelled {
+			updates["status"] = "cancelled"
+		}
+	}
+	if run != nil {
+		updates["attempts"] = run.attempts
+		updates["duration_ms"] = time.Since(run.start).Milliseconds()
+		updates["provider_calls"] = run.providerCallsJSON()
 	}
 
-	task.Status = status
-	task.EndTime = time.Now().Format(time.RFC3339)
-	if result := w.db.Save(&task); result.Error != nil {
+	if result := w.db.Model(&database.Task{}).Where("id = ?", taskID).Updates(updates); result.Error != nil {
 		fmt.Printf("Error updating task status: %v\n", result.Error)
 	}
-}
 
-// saveTaskResult 保存任务结果
-func (w *Worker) saveTaskResult(taskID uint, result map[string]interface{}) {
-	resultJSON, err := json.Marshal(result)
-	if err != nil {
-		fmt.Printf("Error marshaling result: %v\n", err)
-		return
+	event := progress.Event{Type: progress.TypeStatus, Status: updates["status"].(string)}
+	if taskErr != nil {
+		event.Error = taskErr.Error()
 	}
+	w.publish(taskID, event)
+}
 
+// saveTaskResult 保存任务结果，任务失败时记录错误信息及已获得的部分结果
+func (w *Worker) saveTaskResult(taskID uint, result map[string]interface{}, taskErr error) {
 	taskResult := database.TaskResult{
 		TaskID:    taskID,
-		Result:    string(resultJSON),
-		Error:     "",
+		Result:    "{}",
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
+	if taskErr != nil {
+		taskResult.Error = taskErr.Error()
+	}
+	if result != nil {
+		resultJSON, err := json.Marshal(result)
+		if err != nil {
+			fmt.Printf("Error marshaling result: %v\n", err)
+			return
+		}
+		taskResult.Result = string(resultJSON)
+	}
+
 	if result := w.db.Create(&taskResult); result.Error != nil {
 		fmt.Printf("Error saving task result: %v\n", result.Error)
 	}
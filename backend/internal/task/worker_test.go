@@ -0,0 +1,50 @@
+package task
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/redteamsec/backend/internal/auth"
+	"github.com/redteamsec/backend/internal/database"
+	"github.com/redteamsec/backend/internal/project"
+)
+
+func TestExecuteTaskChecksProjectAccess(t *testing.T) {
+	tests := []struct {
+		name        string
+		globalRole  string
+		projectRole string // 空表示不是项目成员
+		projectID   uint
+		taskType    string
+		wantClass   string
+		wantErr     string
+	}{
+		{name: "removed from project", globalRole: auth.RoleOperator, projectID: 1, taskType: "operate", wantClass: ErrorClassInvalid, wantErr: "no longer a member"},
+		{name: "project viewer", globalRole: auth.RoleOperator, projectRole: project.RoleViewer, projectID: 1, taskType: "operate", wantClass: ErrorClassInvalid, wantErr: "in the project"},
+		{name: "globally demoted", globalRole: auth.RoleViewer, projectRole: project.RoleMember, projectID: 1, taskType: "enumerate", wantClass: ErrorClassInvalid, wantErr: "lacks permission"},
+		{name: "admin in deleted project", globalRole: auth.RoleAdmin, projectID: 2, taskType: "operate", wantClass: ErrorClassInvalid, wantErr: "no longer a member"},
+		// 通过权限检查后才加载凭证
+		{name: "project member", globalRole: auth.RoleOperator, projectRole: project.RoleMember, projectID: 1, taskType: "operate", wantClass: ErrorClassCredential},
+		{name: "admin", globalRole: auth.RoleAdmin, projectID: 1, taskType: "operate", wantClass: ErrorClassCredential},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := database.NewTestDB(t, &database.User{}, &database.Project{}, &database.ProjectMember{}, &database.CloudCredential{})
+			owner := database.User{Username: "owner", Role: tt.globalRole}
+			db.Create(&owner)
+			db.Create(&database.Project{ID: 1, Name: "engagement"})
+			if tt.projectRole != "" {
+				db.Create(&database.ProjectMember{ProjectID: 1, UserID: owner.ID, Role: tt.projectRole})
+			}
+
+			w := &Worker{db: db}
+			task := &database.Task{ID: 1, UserID: owner.ID, ProjectID: tt.projectID, CredentialID: 1, TaskType: tt.taskType, Parameters: "{}"}
+			_, err := w.executeTask(context.Background(), task, newTaskRun())
+			if err == nil || errorClass(err) != tt.wantClass || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("executeTask() error = %v, want class %s containing %q", err, tt.wantClass, tt.wantErr)
+			}
+		})
+	}
+}
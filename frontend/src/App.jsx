@@ -22,7 +22,7 @@ import Settings from './pages/Settings'
 import APTAttackScenarios from './pages/APTAttackScenarios'
 
 import { useSelector, useDispatch } from 'react-redux'
-import { logout } from './store/authSlice'
+import { signOut } from './store/authSlice'
 
 const { Header, Sider, Content } = Layout
 const { Title } = Typography
@@ -130,7 +130,7 @@ function App() {
         onClick={({ key }) => {
           if (key === 'logout') {
             // 退出登录逻辑
-            dispatch(logout());
+            dispatch(signOut());
             navigate('/login');
           }
         }}
@@ -0,0 +1,130 @@
+import React, { useState, useEffect } from 'react'
+import { Alert, Button, Form, Input, QRCode, Space, Spin, Typography, message } from 'antd'
+import { SafetyOutlined } from '@ant-design/icons'
+import axios from 'axios'
+import { saveTokens, withSession } from '../store/session'
+
+const { Text, Paragraph } = Typography
+
+// 配置 axios 基础 URL
+const api = axios.create({
+  baseURL: 'http://localhost:8080/api',
+  headers: {
+    'Content-Type': 'application/json'
+  }
+})
+
+// 添加 Bearer 令牌，访问令牌过期时自动刷新
+withSession(api)
+
+// 绑定 TOTP 验证器：扫码、输入验证码确认，然后展示一次性恢复码
+// 确认后服务端会吊销此前的全部令牌，这里立即保存新令牌，用户确认已保存恢复码后调用 onEnrolled
+const MFAEnrollment = ({ onEnrolled }) => {
+  const [form] = Form.useForm()
+  const [enrollment, setEnrollment] = useState(null)
+  const [result, setResult] = useState(null)
+  const [loading, setLoading] = useState(false)
+  const [error, setError] = useState(null)
+
+  // 每次开始绑定都会生成新密钥，只使用最后一次请求返回的密钥
+  useEffect(() => {
+    let ignore = false
+    setLoading(true)
+    api.post('/user/mfa/enroll')
+      .then(response => {
+        if (!ignore) setEnrollment(response.data)
+      })
+      .catch(error => {
+        if (!ignore) setError(error.response?.data?.error || '获取验证器密钥失败')
+      })
+      .finally(() => {
+        if (!ignore) setLoading(false)
+      })
+    return () => {
+      ignore = true
+    }
+  }, [])
+
+  const handleVerify = async (values) => {
+    setLoading(true)
+    setError(null)
+    try {
+      const response = await api.post('/user/mfa/verify', { otp: values.otp.trim() })
+      saveTokens(response.data)
+      setResult(response.data)
+    } catch (error) {
+      setError(error.response?.data?.error || '验证失败')
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  const handleCopyCodes = async () => {
+    try {
+      await navigator.clipboard.writeText(result.recoveryCodes.join('\n'))
+      message.success('恢复码已复制')
+    } catch (error) {
+      message.error('复制失败，请手动记录')
+    }
+  }
+
+  if (result) {
+    return (
+      <div>
+        <Alert
+          message="双因素认证已启用"
+          description="请将下面的恢复码保存在安全的地方。丢失验证器时可以用恢复码登录，每个恢复码只能使用一次，关闭后将无法再次查看。"
+          type="success"
+          showIcon
+          style={{ marginBottom: 16 }}
+        />
+        <div style={{ display: 'grid', gridTemplateColumns: 'repeat(2, 1fr)', gap: 8, marginBottom: 16 }}>
+          {result.recoveryCodes.map(code => (
+            <Text key={code} code style={{ fontSize: 16 }}>{code}</Text>
+          ))}
+        </div>
+        <Space>
+          <Button onClick={handleCopyCodes}>复制恢复码</Button>
+          <Button type="primary" onClick={() => onEnrolled(result)}>我已保存恢复码</Button>
+        </Space>
+      </div>
+    )
+  }
+
+  return (
+    <Spin spinning={loading && !enrollment}>
+      {error && (
+        <Alert message={error} type="error" showIcon style={{ marginBottom: 16 }} />
+      )}
+      {enrollment && (
+        <>
+          <Paragraph>
+            使用 Google Authenticator、Microsoft Authenticator 等验证器应用扫描二维码，然后输入应用显示的 6 位验证码。
+          </Paragraph>
+          <div style={{ display: 'flex', justifyContent: 'center', marginBottom: 16 }}>
+            <QRCode value={enrollment.otpauthUrl} size={180} />
+          </div>
+          <Paragraph style={{ textAlign: 'center' }}>
+            无法扫码时手动输入密钥：<Text code copyable>{enrollment.secret}</Text>
+          </Paragraph>
+          <Form form={form} layout="inline" onFinish={handleVerify} style={{ justifyContent: 'center' }}>
+            <Form.Item
+              name="otp"
+              rules={[
+                { required: true, message: '请输入验证码' },
+                { pattern: /^\s*\d{6}\s*$/, message: '验证码为 6 位数字' }
+              ]}
+            >
+              <Input prefix={<SafetyOutlined />} placeholder="6 位验证码" maxLength={8} autoComplete="one-time-code" />
+            </Form.Item>
+            <Form.Item>
+              <Button type="primary" htmlType="submit" loading={loading}>验证并启用</Button>
+            </Form.Item>
+          </Form>
+        </>
+      )}
+    </Spin>
+  )
+}
+
+export default MFAEnrollment
@@ -1,9 +1,11 @@
 import React, { useState, useEffect } from 'react'
 import { useDispatch, useSelector } from 'react-redux'
 import { fetchCredentials } from '../store/credentialSlice'
+import { runCloudOperation } from '../store/taskSlice'
 import { Typography, Card, Button, Select, Table, Tabs, Form, Input, Modal, message, Alert, Spin, Badge } from 'antd'
 import { CloudOutlined, KeyOutlined, SearchOutlined, PlayCircleOutlined, SafetyOutlined, LaptopOutlined, DownloadOutlined, LockOutlined, AppstoreOutlined, DatabaseOutlined, CloudServerOutlined, FolderOpenOutlined, UserOutlined, BuildOutlined } from '@ant-design/icons'
 import axios from 'axios'
+import { withSession } from '../store/session'
 
 // 配置 axios 基础 URL
 const api = axios.create({
@@ -13,14 +15,8 @@ const api = axios.create({
   }
 })
 
-// 请求拦截器，添加 token
-api.interceptors.request.use(config => {
-  const token = localStorage.getItem('token')
-  if (token) {
-    config.headers.Authorization = token
-  }
-  return config
-})
+// 添加 Bearer 令牌，访问令牌过期时自动刷新
+withSession(api)
 
 const { Title, Text } = Typography
 const { Option } = Select
@@ -305,7 +301,7 @@ const AKSKUtilization = () => {
         })
       }, 300)
 
-      const response = await api.post('/cloud/enumerate', {
+      const response = await runCloudOperation(api, '/cloud/enumerate', {
         credential_id: selectedCredential.id,
         resource_type: selectedResourceTypes.includes('all') ? 'all' : selectedResourceTypes.join(',')
       })
@@ -1465,7 +1461,7 @@ const AKSKUtilization = () => {
     setLoading(true)
     try {
       // 调用真实 API
-      const response = await api.post('/cloud/escalate', {
+      const response = await runCloudOperation(api, '/cloud/escalate', {
         credential_id: selectedCredential.id
       })
       
@@ -1501,7 +1497,7 @@ const AKSKUtilization = () => {
     setLoading(true)
     try {
       // 调用真实 API
-      const response = await api.post('/cloud/operate', {
+      const response = await runCloudOperation(api, '/cloud/operate', {
         credential_id: selectedCredential.id,
         resource_type: resourceType,
         action: action,
@@ -1516,11 +1512,11 @@ const AKSKUtilization = () => {
           message: response.data.message || '操作执行成功',
           resourceId: resourceId,
           objects: response.data.result?.objects,
-          console_url: response.data.result?.federated_login_url || response.data.result?.console_url,
-          access_key: response.data.result?.access_key,
-          secret_key: response.data.result?.secret_key,
-          session_token: response.data.result?.session_token,
-          expiration: response.data.result?.expiration,
+          console_url: response.data.result?.url || response.data.result?.federated_login_url || response.data.result?.console_url,
+          access_key: response.data.result?.credentials?.access_key || response.data.result?.access_key,
+          secret_key: response.data.result?.credentials?.secret_key || response.data.result?.secret_key,
+          session_token: response.data.result?.credentials?.session_token || response.data.result?.session_token,
+          expiration: response.data.result?.expiry || response.data.result?.expiration,
           role_arn: response.data.result?.role_arn,
           federated: response.data.result?.federated,
           timestamp: new Date().toISOString()
@@ -1563,7 +1559,7 @@ const AKSKUtilization = () => {
 
     try {
       // 调用下载 API
-      const response = await api.post('/cloud/download', {
+      const response = await runCloudOperation(api, '/cloud/download', {
         credential_id: selectedCredential.id,
         bucket: bucket,
         key: key
@@ -1617,7 +1613,7 @@ const AKSKUtilization = () => {
     setLoading(true)
     try {
       // 调用真实 API
-      const response = await api.post('/cloud/escalate', {
+      const response = await runCloudOperation(api, '/cloud/escalate', {
         credential_id: selectedCredential.id
       })
       
@@ -1651,7 +1647,7 @@ const AKSKUtilization = () => {
     setLoading(true)
     try {
       // 调用真实 API 执行平台接管
-      const response = await api.post('/cloud/takeover', {
+      const response = await runCloudOperation(api, '/cloud/takeover', {
         credential_id: selectedCredential.id
       })
       
@@ -1667,19 +1663,19 @@ const AKSKUtilization = () => {
 
         // 自动生成控制台URL并打开
         try {
-          const consoleResponse = await api.post('/cloud/operate', {
+          const consoleResponse = await runCloudOperation(api, '/cloud/operate', {
             credential_id: selectedCredential.id,
             resource_type: 's3', // 任意资源类型，主要是为了调用接管控制台功能
             action: 'federated_login',
             resource_id: 'dummy', // 占位符，不影响功能
-            params: {}
+            params: { policy: 'admin', session_name: 'takeover-console' }
           })
 
-          if (consoleResponse.data && consoleResponse.data.result && (consoleResponse.data.result.federated_login_url || consoleResponse.data.result.console_url)) {
-            const consoleUrl = consoleResponse.data.result.federated_login_url || consoleResponse.data.result.console_url
+          const consoleUrl = consoleResponse.data?.result?.url || consoleResponse.data?.result?.federated_login_url || consoleResponse.data?.result?.console_url
+          if (consoleUrl) {
             // 自动打开联邦登录网页
             window.open(consoleUrl, '_blank')
-            message.success('已自动打开AWS联邦登录页面')
+            message.success('已自动打开联邦登录页面')
           } else {
             message.warning('无法生成联邦登录URL')
           }
@@ -1710,7 +1706,7 @@ const AKSKUtilization = () => {
     setLoading(true)
     try {
       // 调用真实 API 获取用户信息
-      const response = await api.post('/cloud/userinfo', {
+      const response = await runCloudOperation(api, '/cloud/userinfo', {
         credential_id: selectedCredential.id
       })
       
@@ -2144,6 +2140,21 @@ const AKSKUtilization = () => {
                       </div>
                     </div>
 
+                    {Array.isArray(permissions.capabilities) && (
+                      <div style={{ marginBottom: '12px' }}>
+                        <Text strong>标准化能力：</Text>
+                        <div style={{ marginLeft: '20px', marginTop: '8px' }}>
+                          {permissions.capabilities.length > 0 ? (
+                            permissions.capabilities.map((cap, index) => (
+                              <div key={index} style={{ marginBottom: '4px' }}>• {cap}</div>
+                            ))
+                          ) : (
+                            <div>无高风险能力</div>
+                          )}
+                        </div>
+                      </div>
+                    )}
+
                     <div>
                       <Text strong>风险等级：</Text> 
                       <Text style={{ 
@@ -2204,6 +2215,73 @@ const AKSKUtilization = () => {
                   </Form.Item>
                 )}
                 
+                {action === 'federated_login' && (
+                  <>
+                    <Form.Item 
+                      label="会话策略 (AWS)"
+                      name="policy"
+                    >
+                      <Select 
+                        value={operationParams.policy || 'read-only'}
+                        onChange={(value) => setOperationParams({ ...operationParams, policy: value })}
+                        style={{ width: '100%' }}
+                      >
+                        <Option value="read-only">只读 (ReadOnlyAccess)</Option>
+                        <Option value="service">指定服务</Option>
+                        <Option value="custom">自定义策略 JSON</Option>
+                        <Option value="admin">管理员 (Action: *)</Option>
+                      </Select>
+                    </Form.Item>
+                    {operationParams.policy === 'service' && (
+                      <Form.Item label="服务前缀" name="service">
+                        <Input 
+                          value={operationParams.service || ''}
+                          onChange={(e) => setOperationParams({ ...operationParams, service: e.target.value })}
+                          placeholder="例如 s3、ec2"
+                        />
+                      </Form.Item>
+                    )}
+                    {operationParams.policy === 'custom' && (
+                      <Form.Item label="策略 JSON" name="policy_document">
+                        <Input.TextArea 
+                          rows={6}
+                          value={operationParams.policy_document || ''}
+                          onChange={(e) => setOperationParams({ ...operationParams, policy_document: e.target.value })}
+                          placeholder='{"Version":"2012-10-17","Statement":[...]}'
+                        />
+                      </Form.Item>
+                    )}
+                    <Form.Item label="会话名称 (可选)" name="session_name">
+                      <Input 
+                        value={operationParams.session_name || ''}
+                        onChange={(e) => setOperationParams({ ...operationParams, session_name: e.target.value })}
+                        placeholder="默认 federated-user"
+                      />
+                    </Form.Item>
+                    <Form.Item label="有效期 (秒，可选)" name="duration_seconds">
+                      <Input 
+                        type="number"
+                        value={operationParams.duration_seconds || ''}
+                        onChange={(e) => setOperationParams({ ...operationParams, duration_seconds: e.target.value ? Number(e.target.value) : undefined })}
+                        placeholder="900 - 129600，默认 3600"
+                      />
+                    </Form.Item>
+                  </>
+                )}
+
+                {action === 'federated_login' && (
+                  <Form.Item 
+                    label="角色 ARN (阿里云必填)"
+                    name="role_arn"
+                  >
+                    <Input 
+                      value={operationParams.role_arn || ''}
+                      onChange={(e) => setOperationParams({ ...operationParams, role_arn: e.target.value })}
+                      placeholder="例如 acs:ram::1234567890:role/admin"
+                    />
+                  </Form.Item>
+                )}
+
                 {action === 'download' && (
                   <Form.Item 
                     label="文件路径"
@@ -1,6 +1,6 @@
 import React, { useState, useEffect } from 'react'
 import { useDispatch, useSelector } from 'react-redux'
-import { fetchCredentials, createCredential, updateCredential, deleteCredential, clearError } from '../store/credentialSlice'
+import { fetchCredentials, fetchProjects, createCredential, updateCredential, deleteCredential, clearError } from '../store/credentialSlice'
 import { Typography, Card, Button, Table, Modal, Form, Input, Select, message, Alert } from 'antd'
 import { PlusOutlined, EditOutlined, DeleteOutlined, KeyOutlined, CloudOutlined, EyeOutlined, EyeInvisibleOutlined } from '@ant-design/icons'
 
@@ -9,7 +9,7 @@ const { Option } = Select
 
 const CredentialManagement = () => {
   const dispatch = useDispatch()
-  const { credentials, loading, error } = useSelector(state => state.credential)
+  const { credentials, projects, loading, error } = useSelector(state => state.credential)
   const [isModalVisible, setIsModalVisible] = useState(false)
   const [editingRecord, setEditingRecord] = useState(null)
   const [form] = Form.useForm()
@@ -17,6 +17,7 @@ const CredentialManagement = () => {
 
   useEffect(() => {
     dispatch(fetchCredentials())
+    dispatch(fetchProjects())
   }, [dispatch])
 
   // 模拟数据
@@ -124,6 +125,7 @@ const CredentialManagement = () => {
       cloudProvider: record.cloudProvider,
       accessKey: record.accessKey,
       secretKey: record.secretKey,
+      endpoint: record.endpoint,
       description: record.description
     })
     setIsModalVisible(true)
@@ -238,6 +240,19 @@ const CredentialManagement = () => {
           >
             <Input placeholder="例如：AWS Production" />
           </Form.Item>
+          {!editingRecord && (
+            <Form.Item
+              name="projectId"
+              label="所属项目"
+              extra="不选择时归入个人项目"
+            >
+              <Select placeholder="选择项目" allowClear>
+                {projects.filter(p => p.role !== 'viewer').map(p => (
+                  <Option key={p.id} value={p.id}>{p.name}</Option>
+                ))}
+              </Select>
+            </Form.Item>
+          )}
           <Form.Item
             name="cloudProvider"
             label="云平台"
@@ -249,8 +264,24 @@ const CredentialManagement = () => {
               <Option value="GCP">GCP</Option>
               <Option value="Azure">Azure</Option>
               <Option value="腾讯云">腾讯云</Option>
+              <Option value="华为云">华为云</Option>
+              <Option value="S3兼容">S3兼容存储 (MinIO/Ceph)</Option>
             </Select>
           </Form.Item>
+          <Form.Item
+            noStyle
+            shouldUpdate={(prev, curr) => prev.cloudProvider !== curr.cloudProvider}
+          >
+            {({ getFieldValue }) => getFieldValue('cloudProvider') === 'S3兼容' ? (
+              <Form.Item
+                name="endpoint"
+                label="Endpoint"
+                rules={[{ required: true, message: '请输入 S3 兼容存储的 Endpoint' }]}
+              >
+                <Input placeholder="例如：https://minio.example.com:9000" />
+              </Form.Item>
+            ) : null}
+          </Form.Item>
           <Form.Item
             name="accessKey"
             label="Access Key"
@@ -1,6 +1,7 @@
 import React, { useState, useEffect } from 'react'
 import { Typography, Row, Col, Card, Statistic, Progress, List, Avatar, Badge, Spin, message } from 'antd'
 import { CloudOutlined, KeyOutlined, AppstoreOutlined, BarChartOutlined, UserOutlined, CheckCircleOutlined, CloseCircleOutlined, ClockCircleOutlined, ThunderboltOutlined, RocketOutlined, AlertOutlined } from '@ant-design/icons'
+import { authHeader } from '../store/session'
 
 const { Title, Text } = Typography
 
@@ -60,15 +61,15 @@ const Dashboard = () => {
   // 获取云平台分布数据
   const fetchCloudDistribution = async () => {
     try {
-      const token = localStorage.getItem('token')
-      if (!token) {
+      const authorization = authHeader()
+      if (!authorization) {
         throw new Error('No token found')
       }
 
       // 先获取所有凭证
       const credentialsResponse = await fetch('http://localhost:8080/api/credentials', {
         headers: {
-          'Authorization': token
+          'Authorization': authorization
         }
       })
 
@@ -1,16 +1,18 @@
 import React, { useState, useEffect } from 'react'
 import { useDispatch, useSelector } from 'react-redux'
-import { login, clearError } from '../store/authSlice'
-import { Button, Checkbox, Form, Input, Alert, Card, Typography } from 'antd'
+import { login, changePassword, logout, signOut, mfaEnrolled, clearError } from '../store/authSlice'
+import { Button, Checkbox, Form, Input, Alert, Card, Typography, Modal } from 'antd'
 import { Link, useNavigate } from 'react-router-dom'
-import { LockOutlined, UserOutlined, ThunderboltOutlined } from '@ant-design/icons'
+import { LockOutlined, UserOutlined, ThunderboltOutlined, SafetyOutlined } from '@ant-design/icons'
+import MFAEnrollment from '../components/MFAEnrollment'
 
 const { Title, Text } = Typography
 
 const Login = () => {
   const dispatch = useDispatch()
-  const { isAuthenticated, loading, error } = useSelector(state => state.auth)
+  const { isAuthenticated, mustChangePassword, mfaRequired, mfaSetupRequired, loading, error } = useSelector(state => state.auth)
   const [form] = Form.useForm()
+  const [passwordForm] = Form.useForm()
   const [remember, setRemember] = useState(false)
   const [mounted, setMounted] = useState(false)
   const navigate = useNavigate()
@@ -31,10 +33,30 @@ const Login = () => {
     dispatch(clearError())
     dispatch(login({
       username: values.username,
-      password: values.password
+      password: values.password,
+      otp: values.otp?.trim() || undefined
     }))
   }
 
+  // 首次登录强制修改密码，当前密码默认使用刚输入的登录密码
+  const handleChangePassword = async (values) => {
+    dispatch(clearError())
+    dispatch(changePassword({
+      currentPassword: form.getFieldValue('password'),
+      password: values.newPassword
+    }))
+  }
+
+  const handleCancelChangePassword = () => {
+    passwordForm.resetFields()
+    dispatch(logout())
+  }
+
+  // 放弃绑定验证器时吊销已签发的受限令牌
+  const handleCancelMFASetup = () => {
+    dispatch(signOut())
+  }
+
   return (
     <div style={{
       minHeight: '100vh',
@@ -99,7 +121,7 @@ const Login = () => {
         </div>
         
         <div style={{ padding: '0 30px 40px' }}>
-          {error && (
+          {error && !mustChangePassword && (
             <Alert 
               message="登录失败" 
               description={error} 
@@ -156,6 +178,31 @@ const Login = () => {
                 }}
               />
             </Form.Item>
+
+            {mfaRequired && (
+              <Form.Item
+                name="otp"
+                rules={[{ required: true, message: '请输入验证码或恢复码' }]}
+                extra="输入验证器应用中的 6 位验证码，丢失验证器时可以输入恢复码"
+              >
+                <Input
+                  prefix={<SafetyOutlined className="site-form-item-icon" style={{ color: '#1a2980' }} />}
+                  placeholder="双因素认证验证码"
+                  size="large"
+                  autoFocus
+                  autoComplete="one-time-code"
+                  style={{
+                    background: 'rgba(248, 249, 250, 0.8)',
+                    borderColor: 'rgba(26, 41, 128, 0.2)',
+                    color: '#303133',
+                    borderRadius: '12px',
+                    height: '50px',
+                    fontSize: '16px',
+                    transition: 'all 0.3s ease'
+                  }}
+                />
+              </Form.Item>
+            )}
             
             <Form.Item>
               <div style={{ display: 'flex', justifyContent: 'space-between', alignItems: 'center' }}>
@@ -195,6 +242,77 @@ const Login = () => {
           </Form>
         </div>
       </Card>
+
+      <Modal
+        title="修改初始密码"
+        open={mustChangePassword}
+        onOk={() => passwordForm.submit()}
+        onCancel={handleCancelChangePassword}
+        confirmLoading={loading}
+        okText="修改并登录"
+        cancelText="取消"
+        maskClosable={false}
+      >
+        <Alert
+          message="当前账号仍在使用初始密码，请先设置新密码"
+          description="新密码至少 8 位，包含大写字母、小写字母、数字、符号中的至少三种，且不能包含用户名"
+          type="warning"
+          showIcon
+          style={{ marginBottom: 16 }}
+        />
+        {error && (
+          <Alert message={error} type="error" showIcon style={{ marginBottom: 16 }} />
+        )}
+        <Form form={passwordForm} layout="vertical" onFinish={handleChangePassword}>
+          <Form.Item
+            name="newPassword"
+            label="新密码"
+            rules={[
+              { required: true, message: '请输入新密码' },
+              { min: 8, message: '密码长度至少为8位' }
+            ]}
+          >
+            <Input.Password prefix={<LockOutlined />} placeholder="新密码" />
+          </Form.Item>
+          <Form.Item
+            name="confirmPassword"
+            label="确认新密码"
+            dependencies={['newPassword']}
+            rules={[
+              { required: true, message: '请确认新密码' },
+              ({ getFieldValue }) => ({
+                validator(_, value) {
+                  if (!value || getFieldValue('newPassword') === value) {
+                    return Promise.resolve()
+                  }
+                  return Promise.reject(new Error('两次输入的密码不一致'))
+                }
+              })
+            ]}
+          >
+            <Input.Password prefix={<LockOutlined />} placeholder="确认新密码" />
+          </Form.Item>
+        </Form>
+      </Modal>
+
+      <Modal
+        title="绑定双因素认证"
+        open={mfaSetupRequired && !mustChangePassword}
+        onCancel={handleCancelMFASetup}
+        footer={null}
+        maskClosable={false}
+        destroyOnClose
+      >
+        <Alert
+          message="当前账号可以执行云操作，管理员要求启用双因素认证"
+          type="warning"
+          showIcon
+          style={{ marginBottom: 16 }}
+        />
+        {mfaSetupRequired && !mustChangePassword && (
+          <MFAEnrollment onEnrolled={(result) => dispatch(mfaEnrolled(result.user))} />
+        )}
+      </Modal>
       
       {/* 全局样式 */}
       <style jsx global>{`
@@ -107,7 +107,16 @@ const Register = () => {
             name="password"
             rules={[
               { required: true, message: '请输入密码' },
-              { min: 6, message: '密码长度至少为6位' }
+              { min: 8, message: '密码长度至少为8位' },
+              {
+                validator(_, value) {
+                  const classes = [/[a-z]/, /[A-Z]/, /[0-9]/, /[^a-zA-Z0-9]/].filter(re => re.test(value || '')).length
+                  if (!value || classes >= 3) {
+                    return Promise.resolve()
+                  }
+                  return Promise.reject(new Error('密码需包含大写字母、小写字母、数字、符号中的至少三种'))
+                }
+              }
             ]}
           >
             <Input
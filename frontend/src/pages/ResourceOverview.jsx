@@ -1,12 +1,13 @@
 import React, { useState, useEffect, useRef } from 'react'
 import { useDispatch, useSelector } from 'react-redux'
 import { fetchCredentials } from '../store/credentialSlice'
-import { createTask } from '../store/taskSlice'
+import { createTask, runCloudOperation } from '../store/taskSlice'
 import { Typography, Card, Select, Table, Tabs, Spin, message, Alert, Button, Modal, List, Badge, Tag } from 'antd'
 import { CloudOutlined, KeyOutlined, DatabaseOutlined, AppstoreOutlined, UploadOutlined, UserOutlined, SearchOutlined, DownloadOutlined, DownOutlined, RightOutlined, FolderOpenOutlined, BuildOutlined } from '@ant-design/icons'
 import ReactFlow, { Controls, Background, MiniMap } from 'reactflow'
 import 'reactflow/dist/style.css'
 import axios from 'axios'
+import { withSession } from '../store/session'
 
 // 配置 axios 基础 URL
 const api = axios.create({
@@ -16,14 +17,8 @@ const api = axios.create({
   }
 })
 
-// 请求拦截器，添加 token
-api.interceptors.request.use(config => {
-  const token = localStorage.getItem('token')
-  if (token) {
-    config.headers.Authorization = token
-  }
-  return config
-})
+// 添加 Bearer 令牌，访问令牌过期时自动刷新
+withSession(api)
 
 const { Title, Text } = Typography
 const { Option } = Select
@@ -87,7 +82,7 @@ const ResourceOverview = () => {
         message.success('从数据库读取权限信息成功')
       } else {
         // 如果数据库中没有权限信息，调用云API获取
-        const cloudResponse = await api.post('/cloud/escalate', {
+        const cloudResponse = await runCloudOperation(api, '/cloud/escalate', {
           credential_id: credential.id
         })
         
@@ -151,7 +146,7 @@ const ResourceOverview = () => {
       const instanceRegion = instance?.region || selectedCredential.region
 
       // 调用后端API执行命令
-      const response = await api.post('/cloud/operate', {
+      const response = await runCloudOperation(api, '/cloud/operate', {
         credential_id: selectedCredential.id,
         resource_type: 'ec2',
         action: 'execute_command',
@@ -587,7 +582,7 @@ const ResourceOverview = () => {
 
     try {
       // 调用下载 API
-      const response = await api.post('/cloud/download', {
+      const response = await runCloudOperation(api, '/cloud/download', {
         credential_id: selectedCredential.id,
         bucket: bucket,
         key: key
@@ -754,7 +749,7 @@ const ResourceOverview = () => {
 
     try {
       // 调用遍历 API
-      const response = await api.post('/cloud/operate', {
+      const response = await runCloudOperation(api, '/cloud/operate', {
         credential_id: selectedCredential.id,
         resource_type: 's3',
         action: 'list_objects',
@@ -1558,6 +1553,20 @@ const ResourceOverview = () => {
                       ))}
                     </ul>
                   </div>
+                  {Array.isArray(permissions.capabilities) && (
+                    <div style={{ marginBottom: 12 }}>
+                      <Text strong>标准化能力：</Text>
+                      <div style={{ marginTop: 8 }}>
+                        {permissions.capabilities.length > 0 ? (
+                          permissions.capabilities.map((cap, index) => (
+                            <Tag key={index} color="volcano">{cap}</Tag>
+                          ))
+                        ) : (
+                          <Text type="secondary">无高风险能力</Text>
+                        )}
+                      </div>
+                    </div>
+                  )}
                   <div>
                     <Text strong>风险等级：</Text> 
                     <Tag color={permissions.riskLevel === 'High' ? 'red' : permissions.riskLevel === 'Medium' ? 'orange' : 'green'}>
@@ -1,7 +1,11 @@
 import React, { useState, useEffect } from 'react'
-import { Typography, Card, Form, Input, Button, message, Alert, Spin } from 'antd'
-import { SettingOutlined, SaveOutlined } from '@ant-design/icons'
+import { useSelector, useDispatch } from 'react-redux'
+import { Typography, Card, Form, Input, Button, message, Alert, Spin, Table, Select, Space, Popconfirm, Modal, Switch, Tag } from 'antd'
+import { SettingOutlined, SaveOutlined, TeamOutlined, ProjectOutlined, SafetyOutlined } from '@ant-design/icons'
 import axios from 'axios'
+import { mfaEnrolled } from '../store/authSlice'
+import MFAEnrollment from '../components/MFAEnrollment'
+import { withSession } from '../store/session'
 
 const { Title, Text } = Typography
 
@@ -13,26 +17,336 @@ const api = axios.create({
   }
 })
 
-// 请求拦截器，添加 token
-api.interceptors.request.use(config => {
-  const token = localStorage.getItem('token')
-  if (token) {
-    config.headers.Authorization = token
-  }
-  return config
-})
+// 添加 Bearer 令牌，访问令牌过期时自动刷新
+withSession(api)
+
+// 角色说明
+const roleOptions = [
+  { value: 'admin', label: '管理员（全部权限）' },
+  { value: 'operator', label: '操作员（执行云操作和管理任务）' },
+  { value: 'viewer', label: '只读（查看结果）' }
+]
+
+// 项目成员角色说明
+const projectRoleOptions = [
+  { value: 'owner', label: '所有者（管理项目和成员）' },
+  { value: 'member', label: '成员（使用项目凭证执行操作）' },
+  { value: 'viewer', label: '只读（查看项目结果）' }
+]
 
 const Settings = () => {
   const [form] = Form.useForm()
   const [loading, setLoading] = useState(false)
   const [error, setError] = useState(null)
   const [success, setSuccess] = useState(null)
+  const [users, setUsers] = useState([])
+  const [usersLoading, setUsersLoading] = useState(false)
+  const currentUser = useSelector(state => state.auth.user)
+  const canManageUsers = currentUser?.permissions?.includes('users:manage')
+  const [projects, setProjects] = useState([])
+  const [selectedProjectId, setSelectedProjectId] = useState(null)
+  const [members, setMembers] = useState([])
+  const [membersLoading, setMembersLoading] = useState(false)
+  const [newProjectName, setNewProjectName] = useState('')
+  const [memberForm] = Form.useForm()
+  const [scopeText, setScopeText] = useState('')
+  const canCreateProjects = currentUser?.permissions?.includes('projects:write')
+  const selectedProject = projects.find(p => p.id === selectedProjectId)
+  const isProjectOwner = selectedProject?.role === 'owner'
+  const dispatch = useDispatch()
+  const [mfaStatus, setMfaStatus] = useState(null)
+  const [mfaEnrollOpen, setMfaEnrollOpen] = useState(false)
+  // 'disable' 停用双因素认证，'recovery' 重新生成恢复码
+  const [mfaAction, setMfaAction] = useState(null)
+  const [recoveryCodes, setRecoveryCodes] = useState(null)
+  const [mfaForm] = Form.useForm()
+  const [requireMfa, setRequireMfa] = useState(false)
 
   // 获取当前配置
   useEffect(() => {
     fetchSettings()
   }, [])
 
+  // 管理员加载用户列表和安全设置
+  useEffect(() => {
+    if (canManageUsers) {
+      fetchUsers()
+      fetchSecuritySettings()
+    }
+  }, [canManageUsers])
+
+  // 加载当前用户的双因素认证状态
+  useEffect(() => {
+    fetchMfaStatus()
+  }, [])
+
+  // 加载项目列表
+  useEffect(() => {
+    fetchProjects()
+  }, [])
+
+  // 切换项目时加载成员
+  useEffect(() => {
+    if (selectedProjectId) {
+      fetchMembers(selectedProjectId)
+      fetchScope(selectedProjectId)
+    }
+  }, [selectedProjectId])
+
+  const fetchProjects = async () => {
+    try {
+      const response = await api.get('/projects')
+      const list = response.data || []
+      setProjects(list)
+      if (list.length > 0 && !list.some(p => p.id === selectedProjectId)) {
+        setSelectedProjectId(list[0].id)
+      }
+    } catch (error) {
+      message.error('获取项目列表失败: ' + (error.response?.data?.error || '未知错误'))
+    }
+  }
+
+  const fetchMembers = async (projectId) => {
+    setMembersLoading(true)
+    try {
+      const response = await api.get(`/projects/${projectId}/members`)
+      setMembers(response.data || [])
+    } catch (error) {
+      message.error('获取项目成员失败: ' + (error.response?.data?.error || '未知错误'))
+    } finally {
+      setMembersLoading(false)
+    }
+  }
+
+  const fetchScope = async (projectId) => {
+    try {
+      const response = await api.get(`/projects/${projectId}/scope`)
+      const scope = response.data?.scope
+      setScopeText(scope ? JSON.stringify(scope, null, 2) : '')
+    } catch (error) {
+      message.error('获取授权范围失败: ' + (error.response?.data?.error || '未知错误'))
+    }
+  }
+
+  // 保存授权范围，内容为空时清除限制
+  const handleSaveScope = async () => {
+    let scope = null
+    if (scopeText.trim()) {
+      try {
+        scope = JSON.parse(scopeText)
+      } catch (e) {
+        message.error('授权范围不是有效的 JSON')
+        return
+      }
+    }
+    try {
+      const response = await api.put(`/projects/${selectedProjectId}/scope`, scope === null ? 'null' : scope)
+      const saved = response.data?.scope
+      setScopeText(saved ? JSON.stringify(saved, null, 2) : '')
+      message.success('授权范围已保存')
+    } catch (error) {
+      message.error('保存授权范围失败: ' + (error.response?.data?.error || '未知错误'))
+    }
+  }
+
+  const handleCreateProject = async () => {
+    if (!newProjectName.trim()) {
+      return
+    }
+    try {
+      const response = await api.post('/projects', { name: newProjectName.trim() })
+      message.success('项目已创建')
+      setNewProjectName('')
+      setSelectedProjectId(response.data.id)
+      fetchProjects()
+    } catch (error) {
+      message.error('创建项目失败: ' + (error.response?.data?.error || '未知错误'))
+    }
+  }
+
+  const handleAddMember = async (values) => {
+    try {
+      await api.post(`/projects/${selectedProjectId}/members`, values)
+      message.success('成员已添加')
+      memberForm.resetFields()
+      fetchMembers(selectedProjectId)
+    } catch (error) {
+      message.error('添加成员失败: ' + (error.response?.data?.error || '未知错误'))
+    }
+  }
+
+  const handleMemberRoleChange = async (userId, role) => {
+    try {
+      await api.put(`/projects/${selectedProjectId}/members/${userId}`, { role })
+      message.success('成员角色已更新')
+      fetchMembers(selectedProjectId)
+    } catch (error) {
+      message.error('更新成员角色失败: ' + (error.response?.data?.error || '未知错误'))
+    }
+  }
+
+  const handleRemoveMember = async (userId) => {
+    try {
+      await api.delete(`/projects/${selectedProjectId}/members/${userId}`)
+      message.success('成员已移除')
+      fetchMembers(selectedProjectId)
+    } catch (error) {
+      message.error('移除成员失败: ' + (error.response?.data?.error || '未知错误'))
+    }
+  }
+
+  const memberColumns = [
+    { title: '用户名', dataIndex: 'username', key: 'username' },
+    {
+      title: '项目角色',
+      dataIndex: 'role',
+      key: 'role',
+      render: (role, record) => (
+        <Select
+          value={role}
+          options={projectRoleOptions}
+          style={{ width: 260 }}
+          disabled={!isProjectOwner}
+          onChange={(value) => handleMemberRoleChange(record.userId, value)}
+        />
+      )
+    },
+    {
+      title: '操作',
+      key: 'action',
+      render: (_, record) => (
+        <Popconfirm title="确定移除该成员？" onConfirm={() => handleRemoveMember(record.userId)} disabled={!isProjectOwner}>
+          <Button danger size="small" disabled={!isProjectOwner}>移除</Button>
+        </Popconfirm>
+      )
+    }
+  ]
+
+  const fetchUsers = async () => {
+    setUsersLoading(true)
+    try {
+      const response = await api.get('/users')
+      setUsers(response.data || [])
+    } catch (error) {
+      message.error('获取用户列表失败: ' + (error.response?.data?.error || '未知错误'))
+    } finally {
+      setUsersLoading(false)
+    }
+  }
+
+  const handleRoleChange = async (userId, role) => {
+    try {
+      await api.put(`/users/${userId}/role`, { role })
+      message.success('角色已更新')
+      fetchUsers()
+    } catch (error) {
+      message.error('更新角色失败: ' + (error.response?.data?.error || '未知错误'))
+    }
+  }
+
+  const fetchMfaStatus = async () => {
+    try {
+      const response = await api.get('/user/mfa')
+      setMfaStatus(response.data)
+    } catch (error) {
+      message.error('获取双因素认证状态失败: ' + (error.response?.data?.error || '未知错误'))
+    }
+  }
+
+  const handleMfaEnrolled = (result) => {
+    setMfaEnrollOpen(false)
+    dispatch(mfaEnrolled(result.user))
+    fetchMfaStatus()
+  }
+
+  const openMfaAction = (action) => {
+    mfaForm.resetFields()
+    setMfaAction(action)
+  }
+
+  // 停用双因素认证或重新生成恢复码，都需要验证码
+  const handleMfaAction = async (values) => {
+    try {
+      if (mfaAction === 'disable') {
+        await api.post('/user/mfa/disable', { password: values.password, otp: values.otp.trim() })
+        message.success('双因素认证已停用')
+      } else {
+        const response = await api.post('/user/mfa/recovery-codes', { otp: values.otp.trim() })
+        setRecoveryCodes(response.data.recoveryCodes)
+      }
+      setMfaAction(null)
+      fetchMfaStatus()
+    } catch (error) {
+      message.error('操作失败: ' + (error.response?.data?.error || '未知错误'))
+    }
+  }
+
+  const fetchSecuritySettings = async () => {
+    try {
+      const response = await api.get('/settings/security')
+      setRequireMfa(!!response.data.requireMfa)
+    } catch (error) {
+      message.error('获取安全设置失败: ' + (error.response?.data?.error || '未知错误'))
+    }
+  }
+
+  const handleRequireMfaChange = async (checked) => {
+    try {
+      const response = await api.put('/settings/security', { requireMfa: checked })
+      setRequireMfa(!!response.data.requireMfa)
+      message.success(checked ? '已要求可执行云操作的角色启用双因素认证' : '已取消强制双因素认证')
+      fetchMfaStatus()
+    } catch (error) {
+      message.error('保存安全设置失败: ' + (error.response?.data?.error || '未知错误'))
+    }
+  }
+
+  // 用户丢失验证器时由管理员重置，用户的全部会话随之失效
+  const handleResetMfa = async (userId) => {
+    try {
+      await api.delete(`/users/${userId}/mfa`)
+      message.success('双因素认证已重置')
+      fetchUsers()
+    } catch (error) {
+      message.error('重置双因素认证失败: ' + (error.response?.data?.error || '未知错误'))
+    }
+  }
+
+  const userColumns = [
+    { title: 'ID', dataIndex: 'id', key: 'id', width: 80 },
+    { title: '用户名', dataIndex: 'username', key: 'username' },
+    { title: '邮箱', dataIndex: 'email', key: 'email' },
+    {
+      title: '角色',
+      dataIndex: 'role',
+      key: 'role',
+      render: (role, record) => (
+        <Select
+          value={role}
+          options={roleOptions}
+          style={{ width: 280 }}
+          disabled={record.id === currentUser?.id}
+          onChange={(value) => handleRoleChange(record.id, value)}
+        />
+      )
+    },
+    {
+      title: '双因素认证',
+      dataIndex: 'mfaEnabled',
+      key: 'mfaEnabled',
+      render: (enabled, record) => (
+        <Space>
+          {enabled ? <Tag color="green">已启用</Tag> : <Tag>未启用</Tag>}
+          {enabled && record.id !== currentUser?.id && (
+            <Popconfirm title="重置后该用户需要重新绑定验证器，并退出所有会话，确定重置？" onConfirm={() => handleResetMfa(record.id)}>
+              <Button size="small" danger>重置</Button>
+            </Popconfirm>
+          )}
+        </Space>
+      )
+    }
+  ]
+
   const fetchSettings = async () => {
     setLoading(true)
     try {
@@ -151,6 +465,168 @@ const Settings = () => {
           </Form.Item>
         </Form>
       </Card>
+
+      <Card
+        title={<span><SafetyOutlined style={{ marginRight: 8 }} />双因素认证</span>}
+        style={{ marginTop: 24 }}
+      >
+        {mfaStatus && (
+          <Space direction="vertical">
+            <Space>
+              <Text>状态：</Text>
+              {mfaStatus.enabled ? <Tag color="green">已启用</Tag> : <Tag>未启用</Tag>}
+              {mfaStatus.required && <Tag color="orange">管理员要求启用</Tag>}
+            </Space>
+            {mfaStatus.enabled && (
+              <Text type="secondary">剩余恢复码：{mfaStatus.recoveryCodesRemaining}</Text>
+            )}
+            <Space>
+              {!mfaStatus.enabled && (
+                <Button type="primary" onClick={() => setMfaEnrollOpen(true)}>绑定验证器</Button>
+              )}
+              {mfaStatus.enabled && (
+                <>
+                  <Button onClick={() => openMfaAction('recovery')}>重新生成恢复码</Button>
+                  <Button danger disabled={mfaStatus.required} onClick={() => openMfaAction('disable')}>停用</Button>
+                </>
+              )}
+            </Space>
+          </Space>
+        )}
+      </Card>
+
+      <Modal
+        title="绑定双因素认证"
+        open={mfaEnrollOpen}
+        onCancel={() => setMfaEnrollOpen(false)}
+        footer={null}
+        maskClosable={false}
+        destroyOnClose
+      >
+        <MFAEnrollment onEnrolled={handleMfaEnrolled} />
+      </Modal>
+
+      <Modal
+        title={mfaAction === 'disable' ? '停用双因素认证' : '重新生成恢复码'}
+        open={!!mfaAction}
+        onOk={() => mfaForm.submit()}
+        onCancel={() => setMfaAction(null)}
+        okText="确定"
+        cancelText="取消"
+      >
+        {mfaAction === 'recovery' && (
+          <Alert message="生成新的恢复码后，旧恢复码全部失效" type="warning" showIcon style={{ marginBottom: 16 }} />
+        )}
+        <Form form={mfaForm} layout="vertical" onFinish={handleMfaAction}>
+          {mfaAction === 'disable' && (
+            <Form.Item name="password" label="当前密码" rules={[{ required: true, message: '请输入当前密码' }]}>
+              <Input.Password />
+            </Form.Item>
+          )}
+          <Form.Item name="otp" label="验证码或恢复码" rules={[{ required: true, message: '请输入验证码' }]}>
+            <Input autoComplete="one-time-code" />
+          </Form.Item>
+        </Form>
+      </Modal>
+
+      <Modal
+        title="新的恢复码"
+        open={!!recoveryCodes}
+        onOk={() => setRecoveryCodes(null)}
+        onCancel={() => setRecoveryCodes(null)}
+        cancelButtonProps={{ style: { display: 'none' } }}
+        okText="我已保存恢复码"
+        maskClosable={false}
+      >
+        <Alert message="请保存在安全的地方，每个恢复码只能使用一次，关闭后将无法再次查看" type="info" showIcon style={{ marginBottom: 16 }} />
+        <div style={{ display: 'grid', gridTemplateColumns: 'repeat(2, 1fr)', gap: 8 }}>
+          {(recoveryCodes || []).map(code => (
+            <Text key={code} code copyable style={{ fontSize: 16 }}>{code}</Text>
+          ))}
+        </div>
+      </Modal>
+
+      <Card
+        title={<span><ProjectOutlined style={{ marginRight: 8 }} />项目与成员</span>}
+        style={{ marginTop: 24 }}
+      >
+        <Space style={{ marginBottom: 16 }} wrap>
+          <Select
+            value={selectedProjectId}
+            style={{ width: 320 }}
+            placeholder="选择项目"
+            options={projects.map(p => ({ value: p.id, label: `${p.name}（${p.role}）` }))}
+            onChange={setSelectedProjectId}
+          />
+          {canCreateProjects && (
+            <>
+              <Input
+                placeholder="新项目名称"
+                value={newProjectName}
+                onChange={(e) => setNewProjectName(e.target.value)}
+                style={{ width: 200 }}
+              />
+              <Button onClick={handleCreateProject}>创建项目</Button>
+            </>
+          )}
+        </Space>
+        {isProjectOwner && (
+          <Form form={memberForm} layout="inline" onFinish={handleAddMember} style={{ marginBottom: 16 }}>
+            <Form.Item name="username" rules={[{ required: true, message: '请输入用户名' }]}>
+              <Input placeholder="用户名" />
+            </Form.Item>
+            <Form.Item name="role" initialValue="member">
+              <Select options={projectRoleOptions} style={{ width: 260 }} />
+            </Form.Item>
+            <Form.Item>
+              <Button type="primary" htmlType="submit">添加成员</Button>
+            </Form.Item>
+          </Form>
+        )}
+        <Table
+          rowKey="id"
+          columns={memberColumns}
+          dataSource={members}
+          loading={membersLoading}
+          pagination={false}
+        />
+        <div style={{ marginTop: 24 }}>
+          <Text strong>授权范围（交战规则）</Text>
+          <Text type="secondary" style={{ display: 'block', margin: '4px 0 8px' }}>
+            执行命令、创建实例配置文件、联邦登录等改变目标环境的操作前检查。字段：accountIds、regions、resources（ARN，支持 *）、tags、actionClasses（execute / iam_write / federation / modify）、notBefore、notAfter（RFC3339）。留空表示不限制。
+          </Text>
+          <Input.TextArea
+            rows={8}
+            value={scopeText}
+            onChange={(e) => setScopeText(e.target.value)}
+            disabled={!isProjectOwner}
+            placeholder='{"accountIds": ["123456789012"], "regions": ["us-east-1"], "actionClasses": ["execute"], "notAfter": "2026-12-31T23:59:59Z"}'
+            style={{ fontFamily: 'monospace' }}
+          />
+          {isProjectOwner && (
+            <Button style={{ marginTop: 8 }} onClick={handleSaveScope}>保存授权范围</Button>
+          )}
+        </div>
+      </Card>
+
+      {canManageUsers && (
+        <Card
+          title={<span><TeamOutlined style={{ marginRight: 8 }} />用户与角色</span>}
+          style={{ marginTop: 24 }}
+        >
+          <Space style={{ marginBottom: 16 }}>
+            <Switch checked={requireMfa} onChange={handleRequireMfaChange} />
+            <Text>要求可以执行云操作的角色（管理员、操作员）启用双因素认证</Text>
+          </Space>
+          <Table
+            rowKey="id"
+            columns={userColumns}
+            dataSource={users}
+            loading={usersLoading}
+            pagination={false}
+          />
+        </Card>
+      )}
     </div>
   )
 }
@@ -1,6 +1,6 @@
 import React, { useState, useEffect } from 'react'
 import { useDispatch, useSelector } from 'react-redux'
-import { fetchTasks, createTask, fetchTaskDetails, fetchTaskResults, deleteTask, deleteAllTasks, clearError, clearCurrentTask } from '../store/taskSlice'
+import { fetchTasks, createTask, fetchTaskDetails, fetchTaskResults, deleteTask, deleteAllTasks, cancelTask, clearError, clearCurrentTask, streamTaskEvents } from '../store/taskSlice'
 import { Typography, Card, Button, Table, Modal, Form, Select, message, Alert, Tabs, Descriptions, List, Badge } from 'antd'
 import { PlusOutlined, PlayCircleOutlined, StopOutlined, DeleteOutlined, AppstoreOutlined, BarChartOutlined, CheckCircleOutlined, CloseCircleOutlined, ClockCircleOutlined } from '@ant-design/icons'
 
@@ -14,12 +14,44 @@ const TaskManagement = () => {
   const [isModalVisible, setIsModalVisible] = useState(false)
   const [selectedTask, setSelectedTask] = useState(null)
   const [isDetailVisible, setIsDetailVisible] = useState(false)
+  const [taskEvents, setTaskEvents] = useState([])
   const [form] = Form.useForm()
 
   useEffect(() => {
     dispatch(fetchTasks())
   }, [dispatch])
 
+  // 详情打开期间订阅任务进度，任务结束后刷新详情和结果
+  useEffect(() => {
+    if (!isDetailVisible || !selectedTask) return
+    setTaskEvents([])
+    const unsubscribe = streamTaskEvents(selectedTask.id, (event) => {
+      setTaskEvents(prev => [...prev, event])
+      if (event.type === 'status' && ['completed', 'failed', 'cancelled'].includes(event.status)) {
+        dispatch(fetchTaskDetails(selectedTask.id))
+        dispatch(fetchTaskResults(selectedTask.id))
+      }
+    })
+    return unsubscribe
+  }, [isDetailVisible, selectedTask, dispatch])
+
+  const formatTaskEvent = (event) => {
+    switch (event.type) {
+      case 'status':
+        return `任务状态: ${event.status}${event.message ? `（${event.message}）` : ''}`
+      case 'region_started':
+        return `开始枚举 ${event.resource} @ ${event.region}`
+      case 'region_finished':
+        return `${event.resource} @ ${event.region} 完成，发现 ${event.count || 0} 个资源`
+      case 'region_failed':
+        return `${event.resource} @ ${event.region} 失败`
+      case 'cancel':
+        return '已请求取消任务'
+      default:
+        return event.message
+    }
+  }
+
   // 模拟数据
   const mockTasks = [
     {
@@ -73,6 +105,24 @@ const TaskManagement = () => {
     }
   }
 
+  const getStatusBadge = (status) => {
+    switch (status) {
+      case 'success':
+      case 'completed':
+        return <Badge status="success" text="成功" />
+      case 'pending':
+        return <Badge status="default" text="排队中" />
+      case 'running':
+        return <Badge status="processing" text="运行中" />
+      case 'cancelling':
+        return <Badge status="warning" text="取消中" />
+      case 'cancelled':
+        return <Badge status="default" text="已取消" />
+      default:
+        return <Badge status="error" text="失败" />
+    }
+  }
+
   const getTaskTypeText = (type) => {
     switch (type) {
       case 'enumerate':
@@ -105,12 +155,7 @@ const TaskManagement = () => {
       title: '状态',
       dataIndex: 'status',
       key: 'status',
-      render: (status) => (
-        <Badge 
-          status={(status === 'success' || status === 'completed') ? 'success' : status === 'running' ? 'processing' : 'error'}
-          text={(status === 'success' || status === 'completed') ? '成功' : status === 'running' ? '运行中' : '失败'}
-        />
-      )
+      render: (status) => getStatusBadge(status)
     },
     {
       title: '开始时间',
@@ -140,6 +185,7 @@ const TaskManagement = () => {
             icon={<StopOutlined />} 
             size="small" 
             style={{ marginRight: 8 }}
+            disabled={!['pending', 'running'].includes(record.status)}
             onClick={() => handleStopTask(record.id)}
           >
             停止
@@ -175,7 +221,16 @@ const TaskManagement = () => {
   }
 
   const handleStopTask = (id) => {
-    message.success(`停止任务 ${id}`)
+    // 排队中的任务立即取消，运行中的任务由 worker 停止后标记为已取消
+    dispatch(cancelTask(id))
+      .unwrap()
+      .then((data) => {
+        message.success(data.status === 'cancelled' ? `任务 ${id} 已取消` : `已请求取消任务 ${id}`)
+        dispatch(fetchTasks())
+      })
+      .catch((error) => {
+        message.error(`取消任务失败: ${error}`)
+      })
   }
 
   const handleViewTask = (task) => {
@@ -315,6 +370,17 @@ const TaskManagement = () => {
               <Option value="takeover">平台接管</Option>
             </Select>
           </Form.Item>
+          <Form.Item
+            name="priority"
+            label="优先级"
+            initialValue="normal"
+          >
+            <Select>
+              <Option value="high">高</Option>
+              <Option value="normal">普通</Option>
+              <Option value="low">低</Option>
+            </Select>
+          </Form.Item>
 
           <Form.Item style={{ textAlign: 'right' }}>
             <Button onClick={() => setIsModalVisible(false)} style={{ marginRight: 8 }}>
@@ -344,16 +410,47 @@ const TaskManagement = () => {
               <Descriptions column={2}>
                 <Descriptions.Item label="任务名称">{currentTask.name}</Descriptions.Item>
                 <Descriptions.Item label="任务类型">{getTaskTypeText(currentTask.taskType)}</Descriptions.Item>
+                <Descriptions.Item label="优先级">{{ high: '高', normal: '普通', low: '低' }[currentTask.priority] || '普通'}</Descriptions.Item>
                 <Descriptions.Item label="状态">
-                  <Badge 
-                    status={(currentTask.status === 'success' || currentTask.status === 'completed') ? 'success' : currentTask.status === 'running' ? 'processing' : 'error'}
-                    text={(currentTask.status === 'success' || currentTask.status === 'completed') ? '成功' : currentTask.status === 'running' ? '运行中' : '失败'}
-                  />
+                  {getStatusBadge(currentTask.status)}
                 </Descriptions.Item>
                 <Descriptions.Item label="开始时间">{currentTask.startTime}</Descriptions.Item>
                 <Descriptions.Item label="结束时间">{currentTask.endTime || '-'}</Descriptions.Item>
+                <Descriptions.Item label="尝试次数">{currentTask.attempts || 0}</Descriptions.Item>
+                <Descriptions.Item label="执行耗时">{currentTask.durationMs ? `${(currentTask.durationMs / 1000).toFixed(1)} 秒` : '-'}</Descriptions.Item>
+                <Descriptions.Item label="接口调用" span={2}>
+                  {(() => {
+                    try {
+                      const calls = JSON.parse(currentTask.providerCalls || '{}')
+                      const entries = Object.entries(calls)
+                      return entries.length > 0 ? entries.map(([method, count]) => `${method} × ${count}`).join('，') : '-'
+                    } catch (e) {
+                      return '-'
+                    }
+                  })()}
+                </Descriptions.Item>
+                {(currentTask.status === 'failed' || currentTask.status === 'cancelled') && (
+                  <Descriptions.Item label="失败原因" span={2}>
+                    <Text type="danger">{currentTask.failureReason || currentTask.error || '未知错误'}</Text>
+                  </Descriptions.Item>
+                )}
               </Descriptions>
             </TabPane>
+            <TabPane tab="实时进度" key="events">
+              <List
+                size="small"
+                dataSource={taskEvents}
+                locale={{ emptyText: '暂无进度事件' }}
+                renderItem={event => (
+                  <List.Item>
+                    <Text type={event.error ? 'danger' : undefined}>
+                      [{event.timestamp}] {formatTaskEvent(event)}
+                      {event.error ? `: ${event.error}` : ''}
+                    </Text>
+                  </List.Item>
+                )}
+              />
+            </TabPane>
             <TabPane tab="执行结果" key="results">
               <List
                 dataSource={taskResults.length > 0 ? taskResults : [
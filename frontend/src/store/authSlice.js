@@ -1,5 +1,6 @@
 import { createSlice, createAsyncThunk } from '@reduxjs/toolkit'
 import axios from 'axios'
+import { clearTokens, saveTokens, withSession } from './session'
 
 // 配置 axios 基础 URL
 const api = axios.create({
@@ -9,14 +10,8 @@ const api = axios.create({
   }
 })
 
-// 请求拦截器，添加 token
-api.interceptors.request.use(config => {
-  const token = localStorage.getItem('token')
-  if (token) {
-    config.headers.Authorization = token
-  }
-  return config
-})
+// 添加 Bearer 令牌，访问令牌过期时自动刷新
+withSession(api)
 
 // 异步登录操作
 export const login = createAsyncThunk(
@@ -26,7 +21,11 @@ export const login = createAsyncThunk(
       const response = await api.post('/auth/login', credentials)
       return response.data
     } catch (error) {
-      return rejectWithValue(error.response?.data?.error || '登录失败')
+      // 已启用双因素认证的账号需要输入验证码，code 为 mfa_required、mfa_invalid 或 mfa_locked（失败次数过多暂时锁定）
+      return rejectWithValue({
+        message: error.response?.data?.error || '登录失败',
+        code: error.response?.data?.code
+      })
     }
   }
 )
@@ -57,11 +56,44 @@ export const fetchUserProfile = createAsyncThunk(
   }
 )
 
+// 修改密码，成功后使用返回的新令牌
+export const changePassword = createAsyncThunk(
+  'auth/changePassword',
+  async ({ currentPassword, password }, { rejectWithValue }) => {
+    try {
+      const response = await api.put('/user/profile', { currentPassword, password })
+      return response.data
+    } catch (error) {
+      return rejectWithValue(error.response?.data?.error || '修改密码失败')
+    }
+  }
+)
+
+// 退出登录，服务端吊销当前访问令牌和刷新令牌，请求失败时也清除本地登录状态
+export const signOut = createAsyncThunk(
+  'auth/signOut',
+  async (_, { dispatch }) => {
+    try {
+      await api.post('/auth/logout', { refresh_token: localStorage.getItem('refreshToken') || '' })
+    } catch (error) {
+      console.error('Logout request failed:', error)
+    } finally {
+      dispatch(logout())
+    }
+  }
+)
+
 const authSlice = createSlice({
   name: 'auth',
   initialState: {
     isAuthenticated: false,
     user: null,
+    // 默认管理员首次登录等情况需要先修改密码才能进入系统
+    mustChangePassword: false,
+    // 登录需要输入双因素认证验证码
+    mfaRequired: false,
+    // 角色要求启用双因素认证但尚未绑定验证器，需要先完成绑定
+    mfaSetupRequired: false,
     loading: false,
     error: null,
   },
@@ -69,7 +101,16 @@ const authSlice = createSlice({
     logout: (state) => {
       state.isAuthenticated = false
       state.user = null
-      localStorage.removeItem('token')
+      state.mustChangePassword = false
+      state.mfaRequired = false
+      state.mfaSetupRequired = false
+      clearTokens()
+    },
+    // 完成验证器绑定，新令牌已由绑定组件保存
+    mfaEnrolled: (state, action) => {
+      state.mfaSetupRequired = false
+      state.isAuthenticated = !state.mustChangePassword
+      state.user = action.payload
     },
     clearError: (state) => {
       state.error = null
@@ -84,13 +125,21 @@ const authSlice = createSlice({
       })
       .addCase(login.fulfilled, (state, action) => {
         state.loading = false
-        state.isAuthenticated = true
+        state.mustChangePassword = !!action.payload.user?.mustChangePassword
+        state.mfaSetupRequired = !!action.payload.user?.mfaSetupRequired
+        state.mfaRequired = false
+        state.isAuthenticated = !state.mustChangePassword && !state.mfaSetupRequired
         state.user = action.payload.user
-        localStorage.setItem('token', action.payload.token)
+        saveTokens(action.payload)
       })
       .addCase(login.rejected, (state, action) => {
         state.loading = false
-        state.error = action.payload
+        const code = action.payload?.code
+        if (code === 'mfa_required' || code === 'mfa_invalid' || code === 'mfa_locked') {
+          state.mfaRequired = true
+        }
+        // 首次提示输入验证码不作为错误显示
+        state.error = code === 'mfa_required' ? null : (action.payload?.message || '登录失败')
       })
     
     // 注册
@@ -101,15 +150,37 @@ const authSlice = createSlice({
       })
       .addCase(register.fulfilled, (state, action) => {
         state.loading = false
-        state.isAuthenticated = true
+        state.mfaSetupRequired = !!action.payload.user?.mfaSetupRequired
+        state.isAuthenticated = !state.mfaSetupRequired
         state.user = action.payload.user
-        localStorage.setItem('token', action.payload.token)
+        saveTokens(action.payload)
       })
       .addCase(register.rejected, (state, action) => {
         state.loading = false
         state.error = action.payload
       })
     
+    // 修改密码
+    builder
+      .addCase(changePassword.pending, (state) => {
+        state.loading = true
+        state.error = null
+      })
+      .addCase(changePassword.fulfilled, (state, action) => {
+        state.loading = false
+        state.mustChangePassword = false
+        state.mfaSetupRequired = !!action.payload.user?.mfaSetupRequired
+        state.isAuthenticated = !state.mfaSetupRequired
+        state.user = action.payload.user
+        if (action.payload.token) {
+          saveTokens(action.payload)
+        }
+      })
+      .addCase(changePassword.rejected, (state, action) => {
+        state.loading = false
+        state.error = action.payload
+      })
+
     // 获取用户信息
     builder
       .addCase(fetchUserProfile.pending, (state) => {
@@ -127,5 +198,5 @@ const authSlice = createSlice({
   },
 })
 
-export const { logout, clearError } = authSlice.actions
+export const { logout, mfaEnrolled, clearError } = authSlice.actions
 export default authSlice.reducer
@@ -1,5 +1,6 @@
 import { createSlice, createAsyncThunk } from '@reduxjs/toolkit'
 import axios from 'axios'
+import { withSession } from './session'
 
 // 配置 axios 基础 URL
 const api = axios.create({
@@ -9,14 +10,8 @@ const api = axios.create({
   }
 })
 
-// 请求拦截器，添加 token
-api.interceptors.request.use(config => {
-  const token = localStorage.getItem('token')
-  if (token) {
-    config.headers.Authorization = token
-  }
-  return config
-})
+// 添加 Bearer 令牌，访问令牌过期时自动刷新
+withSession(api)
 
 // 异步获取所有凭证
 export const fetchCredentials = createAsyncThunk(
@@ -31,7 +26,9 @@ export const fetchCredentials = createAsyncThunk(
         cloudProvider: credential.cloud_provider,
         accessKey: credential.access_key,
         secretKey: credential.secret_key,
-        description: credential.description
+        endpoint: credential.endpoint,
+        description: credential.description,
+        projectId: credential.project_id
       }))
       return transformedCredentials
     } catch (error) {
@@ -40,6 +37,19 @@ export const fetchCredentials = createAsyncThunk(
   }
 )
 
+// 异步获取可访问的项目，新建凭证时选择所属项目
+export const fetchProjects = createAsyncThunk(
+  'credential/fetchProjects',
+  async (_, { rejectWithValue }) => {
+    try {
+      const response = await api.get('/projects')
+      return response.data
+    } catch (error) {
+      return rejectWithValue(error.response?.data?.error || '获取项目失败')
+    }
+  }
+)
+
 // 异步创建凭证
 export const createCredential = createAsyncThunk(
   'credential/createCredential',
@@ -51,7 +61,9 @@ export const createCredential = createAsyncThunk(
         access_key: credentialData.accessKey,
         secret_key: credentialData.secretKey,
         name: credentialData.name,
-        description: credentialData.description
+        endpoint: credentialData.endpoint,
+        description: credentialData.description,
+        project_id: credentialData.projectId
       }
       const response = await api.post('/credentials', transformedData)
       return response.data
@@ -72,6 +84,7 @@ export const updateCredential = createAsyncThunk(
         access_key: credentialData.accessKey,
         secret_key: credentialData.secretKey,
         name: credentialData.name,
+        endpoint: credentialData.endpoint,
         description: credentialData.description
       }
       const response = await api.put(`/credentials/${id}`, transformedData)
@@ -99,6 +112,7 @@ const credentialSlice = createSlice({
   name: 'credential',
   initialState: {
     credentials: [],
+    projects: [],
     loading: false,
     error: null,
   },
@@ -123,6 +137,12 @@ const credentialSlice = createSlice({
         state.error = action.payload
       })
     
+    // 获取项目
+    builder
+      .addCase(fetchProjects.fulfilled, (state, action) => {
+        state.projects = action.payload || []
+      })
+
     // 创建凭证
     builder
       .addCase(createCredential.pending, (state) => {
@@ -138,7 +158,9 @@ const credentialSlice = createSlice({
           cloudProvider: action.payload.cloud_provider,
           accessKey: action.payload.access_key,
           secretKey: action.payload.secret_key,
-          description: action.payload.description
+          endpoint: action.payload.endpoint,
+          description: action.payload.description,
+          projectId: action.payload.project_id
         }
         state.credentials.push(transformedCredential)
       })
@@ -162,7 +184,9 @@ const credentialSlice = createSlice({
           cloudProvider: action.payload.cloud_provider,
           accessKey: action.payload.access_key,
           secretKey: action.payload.secret_key,
-          description: action.payload.description
+          endpoint: action.payload.endpoint,
+          description: action.payload.description,
+          projectId: action.payload.project_id
         }
         const index = state.credentials.findIndex(c => c.id === transformedCredential.id)
         if (index !== -1) {
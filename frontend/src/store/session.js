@@ -0,0 +1,76 @@
+import axios from 'axios'
+
+const baseURL = 'http://localhost:8080/api'
+
+// 保存登录、刷新或修改密码后返回的访问令牌和刷新令牌
+export const saveTokens = (data) => {
+  if (data?.token) {
+    localStorage.setItem('token', data.token)
+  }
+  if (data?.refresh_token) {
+    localStorage.setItem('refreshToken', data.refresh_token)
+  }
+}
+
+// 清除本地保存的令牌
+export const clearTokens = () => {
+  localStorage.removeItem('token')
+  localStorage.removeItem('refreshToken')
+}
+
+// 返回 Bearer 认证头，未登录时返回空字符串
+export const authHeader = () => {
+  const token = localStorage.getItem('token')
+  return token ? `Bearer ${token}` : ''
+}
+
+// 用刷新令牌换取新的访问令牌，多个请求同时遇到令牌过期时只刷新一次
+let refreshing = null
+export const refreshTokens = () => {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem('refreshToken')
+    const request = refreshToken
+      ? axios.post(`${baseURL}/auth/refresh`, { refresh_token: refreshToken })
+      : Promise.reject(new Error('No refresh token'))
+    refreshing = request
+      .then(response => {
+        saveTokens(response.data)
+        return response.data.token
+      })
+      .catch(error => {
+        clearTokens()
+        throw error
+      })
+      .finally(() => {
+        refreshing = null
+      })
+  }
+  return refreshing
+}
+
+// 为 axios 实例添加认证头，访问令牌过期（401）时刷新令牌后重试一次
+export const withSession = (api) => {
+  api.interceptors.request.use(config => {
+    const header = authHeader()
+    if (header) {
+      config.headers.Authorization = header
+    }
+    return config
+  })
+  api.interceptors.response.use(undefined, async error => {
+    const config = error.config
+    const isAuthRequest = config?.url?.startsWith('/auth/')
+    if (error.response?.status !== 401 || !config || config._retried || isAuthRequest) {
+      return Promise.reject(error)
+    }
+    config._retried = true
+    try {
+      await refreshTokens()
+    } catch {
+      return Promise.reject(error)
+    }
+    config.headers.Authorization = authHeader()
+    return api(config)
+  })
+  return api
+}
@@ -1,5 +1,6 @@
 import { createSlice, createAsyncThunk } from '@reduxjs/toolkit'
 import axios from 'axios'
+import { authHeader, refreshTokens, withSession } from './session'
 
 // 配置 axios 基础 URL
 const api = axios.create({
@@ -9,14 +10,8 @@ const api = axios.create({
   }
 })
 
-// 请求拦截器，添加 token
-api.interceptors.request.use(config => {
-  const token = localStorage.getItem('token')
-  if (token) {
-    config.headers.Authorization = token
-  }
-  return config
-})
+// 添加 Bearer 令牌，访问令牌过期时自动刷新
+withSession(api)
 
 // 异步获取所有任务
 export const fetchTasks = createAsyncThunk(
@@ -57,6 +52,97 @@ export const fetchTaskDetails = createAsyncThunk(
   }
 )
 
+// 任务结束时的状态
+const terminalStatuses = ['completed', 'failed', 'cancelled']
+
+// 订阅任务进度事件（SSE），返回取消订阅的函数
+// EventSource 无法携带 Authorization 头，这里使用 fetch 读取事件流
+// 访问令牌过期时刷新后重新订阅一次；无法订阅、连接中断或事件流在任务结束前关闭时调用 onError
+export const streamTaskEvents = (id, onEvent, onError = () => {}) => {
+  const controller = new AbortController()
+
+  const subscribe = (retried) => fetch(`${api.defaults.baseURL}/tasks/${id}/events`, {
+    headers: { Authorization: authHeader() },
+    signal: controller.signal
+  })
+    .then(async (response) => {
+      if (response.status === 401 && !retried) {
+        await refreshTokens()
+        return subscribe(true)
+      }
+      if (!response.ok || !response.body) {
+        throw new Error(`订阅任务事件失败（HTTP ${response.status}）`)
+      }
+      const reader = response.body.getReader()
+      const decoder = new TextDecoder()
+      let buffer = ''
+      while (true) {
+        const { value, done } = await reader.read()
+        if (done) break
+        buffer += decoder.decode(value, { stream: true })
+        const chunks = buffer.split('\n\n')
+        buffer = chunks.pop()
+        chunks.forEach(chunk => {
+          const data = chunk.split('\n').find(line => line.startsWith('data:'))
+          if (!data) return
+          try {
+            onEvent(JSON.parse(data.slice(5)))
+          } catch (e) {
+            // 忽略无法解析的事件
+          }
+        })
+      }
+      throw new Error('任务事件流已关闭')
+    })
+
+  subscribe(false).catch(error => {
+    // 取消订阅导致的中断不是错误
+    if (!controller.signal.aborted) onError(error)
+  })
+
+  return () => controller.abort()
+}
+
+// 轮询任务详情直到任务结束，事件流不可用时使用
+const pollTask = async (id, interval = 2000) => {
+  while (true) {
+    const response = await api.get(`/tasks/${id}`)
+    if (terminalStatuses.includes(response.data.status)) {
+      return { type: 'status', status: response.data.status, error: response.data.error }
+    }
+    await new Promise(resolve => setTimeout(resolve, interval))
+  }
+}
+
+// 以任务方式执行云操作，请求携带 wait=true 等待任务结束
+// 任务超过服务端等待时间时返回 202，此时继续订阅任务事件，事件流不可用时改为轮询任务状态，结束后读取任务结果
+export const runCloudOperation = async (client, path, body) => {
+  const response = await client.post(path, body, { params: { wait: true } })
+  if (response.status !== 202) return response
+
+  const taskId = response.data.task_id
+  const finished = await new Promise((resolve, reject) => {
+    const unsubscribe = streamTaskEvents(taskId, event => {
+      if (event.type === 'status' && terminalStatuses.includes(event.status)) {
+        unsubscribe()
+        resolve(event)
+      }
+    }, () => {
+      pollTask(taskId).then(resolve, reject)
+    })
+  })
+
+  const results = await api.get(`/tasks/${taskId}/results`)
+  const latest = results.data[results.data.length - 1]
+  const result = latest?.result ? JSON.parse(latest.result) : {}
+  if (finished.status !== 'completed') {
+    const error = new Error(finished.error || (finished.status === 'cancelled' ? '任务已取消' : '任务执行失败'))
+    error.response = { data: { error: finished.error, task_id: taskId } }
+    throw error
+  }
+  return { ...response, status: 200, data: { ...result, result, task_id: taskId } }
+}
+
 // 异步获取任务结果
 export const fetchTaskResults = createAsyncThunk(
   'task/fetchTaskResults',
@@ -70,6 +156,19 @@ export const fetchTaskResults = createAsyncThunk(
   }
 )
 
+// 异步取消任务
+export const cancelTask = createAsyncThunk(
+  'task/cancelTask',
+  async (id, { rejectWithValue }) => {
+    try {
+      const response = await api.post(`/tasks/${id}/cancel`)
+      return response.data
+    } catch (error) {
+      return rejectWithValue(error.response?.data?.error || '取消任务失败')
+    }
+  }
+)
+
 // 异步删除任务
 export const deleteTask = createAsyncThunk(
   'task/deleteTask',
@@ -198,7 +297,8 @@ const taskSlice = createSlice({
       })
       .addCase(deleteAllTasks.fulfilled, (state) => {
         state.loading = false
-        state.tasks = []
+        // 排队和执行中的任务不会被删除
+        state.tasks = state.tasks.filter(task => !['completed', 'failed', 'cancelled'].includes(task.status))
       })
       .addCase(deleteAllTasks.rejected, (state, action) => {
         state.loading = false